@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lyrics-api-go/internal/abuse"
+	"lyrics-api-go/middleware"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// abuseDetector is nil when FF_ABUSE_BANS is disabled
+var abuseDetector *abuse.Detector
+
+// setupAbuse sets up the detection of abusive clients with the configured thresholds
+func setupAbuse() {
+	if !conf.FeatureFlags.AbuseBans {
+		return
+	}
+	seconds := func(value int) time.Duration { return time.Duration(value) * time.Second }
+	abuseDetector = abuse.NewDetector(abuse.Config{
+		Window:         seconds(conf.Configuration.AbuseWindowInSeconds),
+		MinRequests:    conf.Configuration.AbuseMinRequests,
+		MaxErrorRatio:  conf.Configuration.AbuseMaxErrorRatio,
+		MaxRateLimited: conf.Configuration.AbuseMaxRateLimited,
+		BanDuration:    seconds(conf.Configuration.AbuseBanInSeconds),
+		MaxBanDuration: seconds(conf.Configuration.AbuseMaxBanInSeconds),
+		OffenseMemory:  seconds(conf.Configuration.AbuseOffenseMemoryInSeconds),
+	})
+}
+
+// abuseMiddleware rejects banned clients with 403 and counts the responses to the others. It wraps
+// the rate limiter, so clients that keep hammering the API after being limited get banned.
+func abuseMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if abuseDetector == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client := middleware.ClientIP(r)
+		if until, banned := abuseDetector.Banned(client); banned {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(until).Seconds()))))
+			http.Error(w, "Temporarily banned for abusive requests", http.StatusForbidden)
+			return
+		}
+
+		rec := middleware.NewResponseRecorder(w)
+		next.ServeHTTP(rec, r)
+		if ban, banned := abuseDetector.Observe(client, rec.StatusCode); banned {
+			log.Warnf("[Abuse] Banned %s until %s for offense %d: %s", client, ban.Until.Format(time.RFC3339), ban.Offenses, ban.Reason)
+		}
+	})
+}
+
+// getBans lists the clients currently banned for abuse
+func getBans(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminBans) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bans := []abuse.Ban{}
+	if abuseDetector != nil {
+		bans = abuseDetector.Bans()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bans)
+}
+
+// liftBan lifts the ban of a client and forgets its offenses
+func liftBan(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminBans) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	client := mux.Vars(r)["client"]
+	if abuseDetector == nil || !abuseDetector.Lift(client) {
+		http.Error(w, "Client is not banned", http.StatusNotFound)
+		return
+	}
+	log.Infof("[Abuse] Lifted the ban of %s", client)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"client": client, "lifted": true})
+}
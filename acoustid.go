@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lyrics-api-go/internal/provider"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func getLyricsByFingerprint(w http.ResponseWriter, r *http.Request) {
+	r, err := withRequestCacheTTL(r)
+	if err != nil {
+		writeStageError(w, stageResolve, err)
+		return
+	}
+	fingerprint := r.FormValue("fingerprint")
+	duration, err := strconv.Atoi(r.FormValue("duration"))
+
+	if fingerprint == "" || err != nil || duration <= 0 {
+		http.Error(w, "Fingerprint or duration not provided", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if conf.Configuration.AcoustIDApiKey == "" {
+		http.Error(w, "Fingerprint lookup is not configured", http.StatusNotImplemented)
+		return
+	}
+	if !providerChain.enabled("acoustid") {
+		http.Error(w, "Fingerprint lookup is disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	track, err := lookupFingerprint(r.Context(), fingerprint, duration)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if track.Song == "" {
+		http.Error(w, "Recording not found", http.StatusNotFound)
+		return
+	}
+
+	trackID, err := resolveTrackID(r.Context(), track.Song, track.Artist)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if trackID == "" {
+		http.Error(w, "Track not found", http.StatusNotFound)
+		return
+	}
+
+	serveLyrics(w, r, trackID, track.Song)
+}
+
+// lookupFingerprint resolves a Chromaprint fingerprint to the title and artist of the best matching recording
+func lookupFingerprint(ctx context.Context, fingerprint string, duration int) (provider.CanonicalTrack, error) {
+	hash := sha1.Sum([]byte(fingerprint))
+	cacheKey := fmt.Sprintf("fingerprint:%s:%d", hex.EncodeToString(hash[:]), duration)
+	if cached, ok := getCache(cacheKey); ok {
+		log.Info("[Cache:Fingerprint] Found cached recording")
+		var track provider.CanonicalTrack
+		if err := json.Unmarshal([]byte(cached), &track); err == nil {
+			return track, nil
+		}
+	}
+
+	track, err := acoustIDClient.Lookup(ctx, fingerprint, duration)
+	if err != nil {
+		return provider.CanonicalTrack{}, err
+	}
+
+	log.Warn("[Cache:Fingerprint] Caching recording")
+	cacheValue, _ := json.Marshal(track)
+	setCache(cacheKey, string(cacheValue), time.Duration(conf.Configuration.MusicBrainzCacheTTLInSeconds)*time.Second)
+
+	return track, nil
+}
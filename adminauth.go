@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"lyrics-api-go/internal/adminauth"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// adminSignatures verifies admin requests signed with CACHE_ACCESS_TOKEN, which can be rotated
+// through SECRETS_FILE
+var adminSignatures *adminauth.Verifier
+
+type adminSignedKey struct{}
+
+// setupAdminAuth sets up the verification of admin requests signed with CACHE_ACCESS_TOKEN
+func setupAdminAuth() {
+	maxSkew := time.Duration(conf.Configuration.AdminSignatureMaxSkewInSeconds) * time.Second
+	adminSignatures = adminauth.NewVerifier(currentSecrets().CacheAccessToken, maxSkew)
+}
+
+// adminSignatureMiddleware verifies the signature of signed admin requests once, as their nonce can
+// only be used once, and marks them as admin requests in their context. Requests with a signature
+// that doesn't verify are rejected rather than served as anonymous.
+func adminSignatureMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !adminauth.Signed(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// an empty CACHE_ACCESS_TOKEN disables admin access
+		if currentSecrets().CacheAccessToken == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err := adminSignatures.Verify(r); err != nil {
+			log.Warnf("[AdminAuth] Rejected signed request to %s from %s: %v", r.URL.Path, r.RemoteAddr, err)
+			if err == adminauth.ErrBodyTooLarge {
+				http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminSignedKey{}, true)))
+	})
+}
+
+// isAdminRequest checks whether the request was signed with CACHE_ACCESS_TOKEN, or carries it as is
+// in the Authorization header when FF_ADMIN_PLAIN_TOKEN is enabled
+func isAdminRequest(r *http.Request) bool {
+	if signed, _ := r.Context().Value(adminSignedKey{}).(bool); signed {
+		return true
+	}
+	token := currentSecrets().CacheAccessToken
+	return conf.FeatureFlags.AdminPlainToken && token != "" &&
+		subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(token)) == 1
+}
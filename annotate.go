@@ -0,0 +1,113 @@
+package main
+
+import (
+	"lyrics-api-go/internal/romanize"
+	"lyrics-api-go/lyrics"
+)
+
+const (
+	featureFurigana        = "furigana"
+	featureTransliteration = "transliteration"
+)
+
+// lineAnnotations are the optional per-line additions asked for with ?translate=, ?romanize=,
+// ?furigana= and ?transliterate=. Romanizations hold the transliterations when those are asked for.
+// Each slice is either nil or has one entry per line.
+type lineAnnotations struct {
+	translations  []string
+	romanizations []string
+	furigana      [][]romanize.Ruby
+}
+
+func (a lineAnnotations) empty() bool {
+	return a.translations == nil && a.romanizations == nil && a.furigana == nil
+}
+
+// annotatedLine is a lyrics line with its annotations
+type annotatedLine struct {
+	lyrics.Line
+	Translation  *string          `json:"translation,omitempty"`
+	Romanization *string          `json:"romanization,omitempty"`
+	Furigana     *[]romanize.Ruby `json:"furigana,omitempty"`
+}
+
+// v2Line is a line in the /v2 schema, where "words" is the per-word timing of the line and its text
+// moves to "text". The fields shadow those of the embedded line of the same JSON name.
+type v2Line struct {
+	annotatedLine
+	Text        string              `json:"text"`
+	Words       []lyrics.WordTiming `json:"words,omitempty"`
+	WordTimings *struct{}           `json:"wordTimings,omitempty"`
+}
+
+// v2Lines pairs every line with its annotations in the /v2 schema
+func v2Lines(lines []lyrics.Line, annotations lineAnnotations) []v2Line {
+	annotated := annotatedLines(lines, annotations)
+	converted := make([]v2Line, len(annotated))
+	for i, line := range annotated {
+		converted[i] = v2Line{annotatedLine: line, Text: line.Line.Words, Words: line.Line.WordTimings}
+	}
+	return converted
+}
+
+// annotateLines pairs every line with its annotations in the layout of the requested response.
+// Compact lines get them appended in the order translation, romanization, furigana.
+func annotateLines(lines []lyrics.Line, annotations lineAnnotations, compact bool) interface{} {
+	if compact {
+		pairs := lyrics.Compact(lines)
+		for i := range pairs {
+			if annotations.translations != nil {
+				pairs[i] = append(pairs[i], annotations.translations[i])
+			}
+			if annotations.romanizations != nil {
+				pairs[i] = append(pairs[i], annotations.romanizations[i])
+			}
+			if annotations.furigana != nil {
+				pairs[i] = append(pairs[i], annotations.furigana[i])
+			}
+		}
+		return pairs
+	}
+
+	return annotatedLines(lines, annotations)
+}
+
+// annotatedLines pairs every line with its annotations
+func annotatedLines(lines []lyrics.Line, annotations lineAnnotations) []annotatedLine {
+	annotated := make([]annotatedLine, len(lines))
+	for i, line := range lines {
+		annotated[i] = annotatedLine{Line: line}
+		if annotations.translations != nil {
+			annotated[i].Translation = &annotations.translations[i]
+		}
+		if annotations.romanizations != nil {
+			annotated[i].Romanization = &annotations.romanizations[i]
+		}
+		if annotations.furigana != nil {
+			annotated[i].Furigana = &annotations.furigana[i]
+		}
+	}
+	return annotated
+}
+
+// furiganaLines returns the ruby pairs of every line of Japanese lyrics
+func furiganaLines(lines []lyrics.Line, language string) [][]romanize.Ruby {
+	furigana := make([][]romanize.Ruby, len(lines))
+	for i, line := range lines {
+		furigana[i] = romanize.Furigana(line.Words, language)
+		if furigana[i] == nil {
+			furigana[i] = []romanize.Ruby{}
+		}
+	}
+	return furigana
+}
+
+// transliterateLines renders every line in Latin script, romanizing Chinese, Japanese and Korean
+// lines like romanizeLines
+func transliterateLines(lines []lyrics.Line, language string) []string {
+	transliterations := make([]string, len(lines))
+	for i, line := range lines {
+		transliterations[i] = romanize.Transliterate(line.Words, language)
+	}
+	return transliterations
+}
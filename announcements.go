@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Announcement is an operator notice surfaced to users by the extension, such as a maintenance
+// window or a new feature
+type Announcement struct {
+	ID      string `json:"id"`
+	Level   string `json:"level"`
+	Title   string `json:"title"`
+	Message string `json:"message"`
+	URL     string `json:"url,omitempty"`
+	// StartsAt and EndsAt bound when the announcement is served; either may be left out
+	StartsAt *time.Time `json:"startsAt,omitempty"`
+	EndsAt   *time.Time `json:"endsAt,omitempty"`
+}
+
+// active reports whether the announcement should be served at now
+func (a Announcement) active(now time.Time) bool {
+	return (a.StartsAt == nil || !now.Before(*a.StartsAt)) && (a.EndsAt == nil || now.Before(*a.EndsAt))
+}
+
+// announcementFeed serves the announcements file, reloading it whenever it changes so operators
+// can post notices without a restart
+type announcementFeed struct {
+	mu            sync.Mutex
+	modTime       time.Time
+	announcements []Announcement
+}
+
+var announcements = &announcementFeed{}
+
+// current returns the announcements in path, keeping the last good ones when the file can't be read
+func (f *announcementFeed) current(path string) []Announcement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		f.announcements, f.modTime = nil, time.Time{}
+		return nil
+	}
+	if err != nil {
+		log.Errorf("[Announcements] Error reading %s: %v", path, err)
+		return f.announcements
+	}
+	if info.ModTime().Equal(f.modTime) {
+		return f.announcements
+	}
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		log.Errorf("[Announcements] Error reading %s: %v", path, err)
+		return f.announcements
+	}
+	var loaded []Announcement
+	if err := json.Unmarshal(body, &loaded); err != nil {
+		log.Errorf("[Announcements] Error parsing %s: %v", path, err)
+		return f.announcements
+	}
+
+	log.Infof("[Announcements] Loaded %d announcements from %s", len(loaded), path)
+	f.announcements, f.modTime = loaded, info.ModTime()
+	return f.announcements
+}
+
+func getAnnouncements(w http.ResponseWriter, r *http.Request) {
+	active := []Announcement{}
+	if path := conf.Configuration.AnnouncementsFile; path != "" {
+		now := time.Now()
+		for _, announcement := range announcements.current(path) {
+			if announcement.active(now) {
+				active = append(active, announcement)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"announcements": active,
+	})
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	apiV1 = 1
+	// apiV2 is where breaking schema changes land. So far: the lyrics response no longer carries an
+	// always-null "error" field, errors are returned as JSON ({"error": "..."}) instead of plain text,
+	// and the text of lines moves to "text" so "words" is their per-word timing.
+	apiV2 = 2
+
+	latestStableAPIVersion = apiV1
+)
+
+type apiVersionKey struct{}
+
+// rolledOutAPIVersionKey marks requests to the unversioned routes, whose API version is rolled out
+type rolledOutAPIVersionKey struct{}
+
+// publicRoute is a public endpoint served under every API version
+type publicRoute struct {
+	path    string
+	handler http.HandlerFunc
+	methods []string
+	scope   string
+	// versionedOnly routes were added after /v1 and have no unversioned alias
+	versionedOnly bool
+}
+
+var publicRoutes = []publicRoute{
+	{path: "/getLyrics", handler: getLyrics, scope: scopeLyricsRead},
+	{path: "/getLyricsByFingerprint", handler: getLyricsByFingerprint, methods: []string{"GET", "POST"}, scope: scopeLyricsRead},
+	{path: "/offsets", handler: getOffset, methods: []string{"GET"}, scope: scopeLyricsRead, versionedOnly: true},
+	{path: "/offsets", handler: submitOffset, methods: []string{"POST"}, scope: scopeReportsWrite, versionedOnly: true},
+	{path: "/usage", handler: getUsage, methods: []string{"GET"}, versionedOnly: true},
+}
+
+func registerPublicRoutes(router *mux.Router) {
+	for _, version := range []int{apiV1, apiV2} {
+		prefix := fmt.Sprintf("/v%d", version)
+		for _, route := range publicRoutes {
+			r := router.Handle(prefix+route.path, withAPIVersion(version, withScope(route.scope, route.handler)))
+			if len(route.methods) > 0 {
+				r.Methods(route.methods...)
+			}
+		}
+	}
+
+	// the unversioned routes predate /v1 and are kept as deprecated aliases of it, so deployed
+	// extensions keep working while they migrate
+	for _, route := range publicRoutes {
+		if route.versionedOnly {
+			continue
+		}
+		successor := fmt.Sprintf("/v%d%s", latestStableAPIVersion, route.path)
+		r := router.Handle(route.path, deprecatedAlias(successor, withRolledOutAPIVersion(withScope(route.scope, route.handler))))
+		if len(route.methods) > 0 {
+			r.Methods(route.methods...)
+		}
+	}
+}
+
+// withAPIVersion stores the API version in the request context and applies its response conventions
+func withAPIVersion(version int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, version))
+		if version >= apiV2 {
+			errorWriter := &jsonErrorWriter{ResponseWriter: w}
+			defer errorWriter.flush()
+			w = errorWriter
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withRolledOutAPIVersion serves the v2 response shape to clients in its rollout and v1 to the rest
+func withRolledOutAPIVersion(next http.Handler) http.Handler {
+	v1, v2 := withAPIVersion(apiV1, next), withAPIVersion(apiV2, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), rolledOutAPIVersionKey{}, true))
+		if rolloutEnabled(r, rolloutV2Shape) {
+			v2.ServeHTTP(w, r)
+			return
+		}
+		v1.ServeHTTP(w, r)
+	})
+}
+
+// withScope rejects requests lacking scope. Routes without a scope check their own access.
+func withScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if scope == "" || checkScope(w, r, scope) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// deprecatedAlias marks responses of a legacy route as deprecated and points to its successor
+func deprecatedAlias(successor string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiVersion returns the API version the request was routed through
+func apiVersion(r *http.Request) int {
+	if version, ok := r.Context().Value(apiVersionKey{}).(int); ok {
+		return version
+	}
+	return apiV1
+}
+
+// jsonErrorWriter rewrites plain-text error responses written by http.Error into JSON. The status of
+// an error is held back until its message is written, and written by flush if it never is.
+type jsonErrorWriter struct {
+	http.ResponseWriter
+	statusCode int
+	message    bytes.Buffer
+}
+
+func (j *jsonErrorWriter) WriteHeader(code int) {
+	if code >= http.StatusBadRequest && strings.HasPrefix(j.Header().Get("Content-Type"), "text/plain") {
+		j.statusCode = code
+		return
+	}
+	j.ResponseWriter.WriteHeader(code)
+}
+
+func (j *jsonErrorWriter) Write(p []byte) (int, error) {
+	if j.statusCode == 0 {
+		return j.ResponseWriter.Write(p)
+	}
+	// http.Error writes the whole message followed by a newline in a single call
+	j.message.Write(p)
+	if !bytes.HasSuffix(p, []byte("\n")) {
+		return len(p), nil
+	}
+	return len(p), j.flush()
+}
+
+// flush writes the held back error status with the message written so far as JSON
+func (j *jsonErrorWriter) flush() error {
+	if j.statusCode == 0 {
+		return nil
+	}
+	j.Header().Set("Content-Type", "application/json")
+	j.ResponseWriter.WriteHeader(j.statusCode)
+	err := json.NewEncoder(j.ResponseWriter).Encode(map[string]interface{}{
+		"error": strings.TrimSpace(j.message.String()),
+	})
+	j.statusCode = 0
+	j.message.Reset()
+	return err
+}
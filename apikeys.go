@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"lyrics-api-go/internal/adminauth"
+	"lyrics-api-go/internal/apikeys"
+	"lyrics-api-go/middleware"
+	"lyrics-api-go/utils"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// apiKeyHeader carries the API key of a collaborator or bot
+const apiKeyHeader = "X-API-Key"
+
+// Scopes grant access to groups of routes. The admin cache access token has every scope.
+const (
+	scopeLyricsRead     = "lyrics:read"
+	scopeTranslate      = "translate"
+	scopeAdminCache     = "admin:cache"
+	scopeAdminJobs      = "admin:jobs"
+	scopeAdminProviders = "admin:providers"
+	scopeReportsWrite   = "reports:write"
+	scopeCacheTTL       = "cache:ttl"
+	scopeAdminKeys      = "admin:keys"
+	scopeAdminBans      = "admin:bans"
+	scopeAdminSecrets   = "admin:secrets"
+)
+
+var knownScopes = []string{scopeLyricsRead, scopeTranslate, scopeAdminCache, scopeAdminJobs, scopeAdminProviders, scopeReportsWrite, scopeCacheTTL, scopeAdminKeys, scopeAdminBans, scopeAdminSecrets}
+
+var (
+	// apiKeys holds the configured API keys and those issued through /admin/keys
+	apiKeys = apikeys.NewRegistry()
+	// anonymousScopes are the scopes of requests without an API key
+	anonymousScopes map[string]bool
+)
+
+// parseAPIKeys parses API_KEYS and ANONYMOUS_SCOPES. API_KEYS is a JSON object of keys to either
+// their scopes or their settings, e.g. {"key": {"scopes": ["lyrics:read"], "ratePerSecond": 10,
+// "dailyQuota": 50000}}. Admin scopes can't be granted anonymously.
+func parseAPIKeys(value string, anonymous []string) (*apikeys.Registry, map[string]bool, error) {
+	registry := apikeys.NewRegistry()
+	if value != "" {
+		var parsed map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+			return nil, nil, fmt.Errorf("invalid API keys, expected a JSON object of keys to scopes: %v", err)
+		}
+		for secret, raw := range parsed {
+			var key apikeys.Key
+			if err := json.Unmarshal(raw, &key.Scopes); err != nil {
+				if err := json.Unmarshal(raw, &key); err != nil {
+					return nil, nil, fmt.Errorf("invalid API keys, expected scopes or settings: %v", err)
+				}
+			}
+			if err := validateAPIKey(key); err != nil {
+				return nil, nil, err
+			}
+			if err := registry.Add(secret, key); err != nil {
+				return nil, nil, fmt.Errorf("invalid API keys, %v", err)
+			}
+		}
+	}
+
+	anonymousSet, err := scopeSet(anonymous)
+	if err != nil {
+		return nil, nil, err
+	}
+	for scope := range anonymousSet {
+		if strings.HasPrefix(scope, "admin:") {
+			return nil, nil, fmt.Errorf("scope %s can't be granted anonymously", scope)
+		}
+	}
+	return registry, anonymousSet, nil
+}
+
+// validateAPIKey checks the scopes and limits of a key
+func validateAPIKey(key apikeys.Key) error {
+	if _, err := scopeSet(key.Scopes); err != nil {
+		return err
+	}
+	if key.RatePerSecond < 0 || key.Burst < 0 || key.DailyQuota < 0 {
+		return fmt.Errorf("invalid API key limits, they can't be negative")
+	}
+	return nil
+}
+
+func scopeSet(scopes []string) (map[string]bool, error) {
+	set := map[string]bool{}
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if scope == "" {
+			continue
+		}
+		if !knownScope(scope) {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+		set[scope] = true
+	}
+	return set, nil
+}
+
+func knownScope(scope string) bool {
+	for _, s := range knownScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// requestScopes returns the scopes of the request. ok is false when it carries an unknown API key or
+// a bearer token that doesn't verify.
+func requestScopes(r *http.Request) (scopes map[string]bool, all bool, ok bool) {
+	if isAdminRequest(r) {
+		return nil, true, true
+	}
+	if token, ok := bearerToken(r); ok && jwtVerifier != nil {
+		scopes, ok := jwtScopes(r, token)
+		return scopes, false, ok
+	}
+	if secret := r.Header.Get(apiKeyHeader); secret != "" {
+		key, ok := apiKeys.Lookup(secret)
+		if !ok {
+			return nil, false, false
+		}
+		scopes, _ := scopeSet(key.Scopes)
+		return scopes, false, true
+	}
+	return anonymousScopes, false, true
+}
+
+// callerSubject identifies the caller of a request by its credentials: the admin, an API key, a
+// bearer token or, for anonymous requests, the client IP. Secrets are only kept hashed.
+func callerSubject(r *http.Request) string {
+	if isAdminRequest(r) {
+		return "admin"
+	}
+	if secret := r.Header.Get(apiKeyHeader); secret != "" {
+		if key, ok := apiKeys.Lookup(secret); ok {
+			return "key:" + key.ID
+		}
+		return "key:" + apikeys.HashSecret(secret)
+	}
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		return "authorization:" + apikeys.HashSecret(authorization)
+	}
+	return "ip:" + middleware.ClientIP(r)
+}
+
+// hasCredentials reports whether the request carries an API key, a bearer token, the admin token or
+// an admin signature, so its scopes may not be the anonymous ones
+func hasCredentials(r *http.Request) bool {
+	return r.Header.Get(apiKeyHeader) != "" || r.Header.Get("Authorization") != "" || r.Header.Get(adminauth.SignatureHeader) != ""
+}
+
+// isAuthorized checks whether the request is an admin request, or carries an API key with scope
+func isAuthorized(r *http.Request, scope string) bool {
+	scopes, all, ok := requestScopes(r)
+	return ok && (all || scopes[scope])
+}
+
+// checkScope writes a 401 for unknown API keys and a 403 when the request lacks scope, reporting
+// whether the request may proceed
+func checkScope(w http.ResponseWriter, r *http.Request, scope string) bool {
+	if err := scopeError(r, scope); err != nil {
+		writeStageError(w, "", err)
+		return false
+	}
+	return true
+}
+
+// scopeError returns a 401 request error for unknown API keys and a 403 when the request lacks scope
+func scopeError(r *http.Request, scope string) error {
+	scopes, all, ok := requestScopes(r)
+	if !ok {
+		return requestError(http.StatusUnauthorized, "Unauthorized")
+	}
+	if !all && !scopes[scope] {
+		return requestError(http.StatusForbidden, fmt.Sprintf("Missing scope %s", scope))
+	}
+	return nil
+}
+
+// loadAPIKeys restores the keys issued through /admin/keys persisted in path
+func loadAPIKeys(path string) {
+	body, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Errorf("[APIKeys] Error reading issued keys from %s: %v", path, err)
+		return
+	}
+	var issued []apikeys.Key
+	if err := json.Unmarshal(body, &issued); err != nil {
+		log.Errorf("[APIKeys] Error parsing issued keys from %s: %v", path, err)
+		return
+	}
+	apiKeys.Restore(issued)
+	log.Infof("[APIKeys] Restored %d issued keys from %s", len(issued), path)
+}
+
+// apiKeysSaveMu serializes writes of the issued keys, so a slower write can't replace a newer one
+var apiKeysSaveMu sync.Mutex
+
+// saveAPIKeys persists the issued keys, which only hold the hashes of their secrets, to path
+func saveAPIKeys(path string) error {
+	apiKeysSaveMu.Lock()
+	defer apiKeysSaveMu.Unlock()
+	body, _ := json.MarshalIndent(apiKeys.Snapshot(), "", "  ")
+	return utils.WriteFileAtomic(path, body, 0600)
+}
+
+// IssuedAPIKey is the response of POST /admin/keys, the only time the secret is shown
+type IssuedAPIKey struct {
+	Secret string `json:"key"`
+	apikeys.Key
+}
+
+// getAPIKeys lists the configured and issued keys, without their secrets
+func getAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminKeys) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(apiKeys.List())
+}
+
+// issueAPIKey issues a key with the name, scopes and limits in the body, e.g. {"name": "app",
+// "scopes": ["lyrics:read"], "ratePerSecond": 5, "burst": 10, "dailyQuota": 10000}. Only scopes the
+// request has can be granted.
+func issueAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminKeys) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var key apikeys.Key
+	if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+		http.Error(w, "Invalid API key", http.StatusBadRequest)
+		return
+	}
+	if err := validateAPIKey(key); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	scopes, all, _ := requestScopes(r)
+	for _, scope := range key.Scopes {
+		if !all && !scopes[strings.TrimSpace(scope)] {
+			http.Error(w, fmt.Sprintf("Scope %s can't be granted without having it", scope), http.StatusForbidden)
+			return
+		}
+	}
+
+	secret, key, err := apiKeys.Issue(apikeys.Key{
+		Name:          key.Name,
+		Scopes:        key.Scopes,
+		RatePerSecond: key.RatePerSecond,
+		Burst:         key.Burst,
+		DailyQuota:    key.DailyQuota,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := saveAPIKeys(conf.Configuration.APIKeysFile); err != nil {
+		// a key that wouldn't survive a restart is not handed out
+		apiKeys.Revoke(key.ID)
+		log.Errorf("[APIKeys] Error persisting issued keys: %v", err)
+		http.Error(w, "Error persisting the API key", http.StatusInternalServerError)
+		return
+	}
+	log.Infof("[APIKeys] Issued key %s (%s)", key.ID, key.Name)
+
+	key.Hash = ""
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(IssuedAPIKey{Secret: secret, Key: key})
+}
+
+// revokeAPIKey revokes the issued key with the id in the path
+func revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminKeys) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	switch err := apiKeys.Revoke(id); err {
+	case nil:
+	case apikeys.ErrUnknownKey:
+		http.Error(w, "Unknown API key", http.StatusNotFound)
+		return
+	case apikeys.ErrNotIssued:
+		http.Error(w, "Configured API keys can only be removed from API_KEYS", http.StatusConflict)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := saveAPIKeys(conf.Configuration.APIKeysFile); err != nil {
+		log.Errorf("[APIKeys] Error persisting issued keys: %v", err)
+		http.Error(w, "Revoked the API key until the next restart, error persisting the revocation", http.StatusInternalServerError)
+		return
+	}
+	log.Infof("[APIKeys] Revoked key %s", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "revoked": true})
+}
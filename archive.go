@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const archiveDateFormat = "2006-01-02"
+
+// ArchivedQuery is a lookup as it was resolved when it was served
+type ArchivedQuery struct {
+	Time    time.Time `json:"time"`
+	Song    string    `json:"song"`
+	Artist  string    `json:"artist"`
+	TrackID string    `json:"trackId"`
+}
+
+// ReplayChange is an archived query that now resolves differently
+type ReplayChange struct {
+	ArchivedQuery
+	CurrentTrackID string `json:"currentTrackId"`
+	Error          string `json:"error,omitempty"`
+}
+
+// ReplayReport is the result of replaying a sample of archived queries
+type ReplayReport struct {
+	Date      string         `json:"date"`
+	Archived  int            `json:"archived"`
+	Replayed  int            `json:"replayed"`
+	Unchanged int            `json:"unchanged"`
+	Changed   int            `json:"changed"`
+	Errors    int            `json:"errors"`
+	Changes   []ReplayChange `json:"changes"`
+}
+
+// queryArchiver appends every resolved lookup to a daily JSONL file in QUERY_ARCHIVE_DIR,
+// so matching changes can later be checked against real traffic
+type queryArchiver struct {
+	mu sync.Mutex
+	// failed counts the lookups that couldn't be archived since the last retention run
+	failed int
+}
+
+var queryArchive = &queryArchiver{}
+
+func archivePath(date string) string {
+	return filepath.Join(conf.Configuration.QueryArchiveDir, "queries-"+date+".jsonl")
+}
+
+func (a *queryArchiver) record(songName, artistName, trackID string) {
+	if conf.Configuration.QueryArchiveDir == "" {
+		return
+	}
+
+	now := time.Now().UTC()
+	line, _ := json.Marshal(ArchivedQuery{Time: now, Song: songName, Artist: artistName, TrackID: trackID})
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	file, err := os.OpenFile(archivePath(now.Format(archiveDateFormat)), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		a.failed++
+		log.Errorf("[Archive] Error opening query archive: %v", err)
+		return
+	}
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		a.failed++
+		log.Errorf("[Archive] Error writing query archive: %v", err)
+	}
+}
+
+// purgeQueryArchive is the scheduler job deleting archive files older than
+// QUERY_ARCHIVE_RETENTION_DAYS, then the oldest files while the archive is larger than
+// QUERY_ARCHIVE_MAX_SIZE_IN_MB. It fails when lookups couldn't be archived since its last run, so
+// write failures show up in the job status.
+func purgeQueryArchive(ctx context.Context) error {
+	if conf.Configuration.QueryArchiveDir == "" {
+		return nil
+	}
+	paths, err := filepath.Glob(archivePath("*"))
+	if err != nil {
+		return err
+	}
+	// file names sort by date, oldest first
+	sort.Strings(paths)
+
+	cutoff := time.Now().UTC().AddDate(0, 0, -conf.Configuration.QueryArchiveRetentionDays).Format(archiveDateFormat)
+	var kept []string
+	sizes := map[string]int64{}
+	var total int64
+	for _, path := range paths {
+		date := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "queries-"), ".jsonl")
+		if conf.Configuration.QueryArchiveRetentionDays > 0 && date < cutoff {
+			removeArchiveFile(path)
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		kept = append(kept, path)
+		sizes[path] = info.Size()
+		total += info.Size()
+	}
+
+	maxSize := int64(conf.Configuration.QueryArchiveMaxSizeInMB) << 20
+	for maxSize > 0 && total > maxSize && len(kept) > 1 {
+		removeArchiveFile(kept[0])
+		total -= sizes[kept[0]]
+		kept = kept[1:]
+	}
+
+	queryArchive.mu.Lock()
+	failed := queryArchive.failed
+	queryArchive.failed = 0
+	queryArchive.mu.Unlock()
+	if failed > 0 {
+		return fmt.Errorf("%d lookups could not be archived since the last run", failed)
+	}
+	return nil
+}
+
+func removeArchiveFile(path string) {
+	if err := os.Remove(path); err != nil {
+		log.Errorf("[Archive] Error deleting %s: %v", path, err)
+		return
+	}
+	log.Infof("[Archive] Deleted %s", filepath.Base(path))
+}
+
+// readArchive returns the queries archived on date
+func readArchive(date string) ([]ArchivedQuery, error) {
+	file, err := os.Open(archivePath(date))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var queries []ArchivedQuery
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var query ArchivedQuery
+		if err := json.Unmarshal(scanner.Bytes(), &query); err == nil {
+			queries = append(queries, query)
+		}
+	}
+	return queries, scanner.Err()
+}
+
+func replayArchive(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminJobs) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if conf.Configuration.QueryArchiveDir == "" {
+		http.Error(w, "Query archive is not configured", http.StatusNotImplemented)
+		return
+	}
+
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = time.Now().UTC().AddDate(0, 0, -1).Format(archiveDateFormat)
+	}
+	if _, err := time.Parse(archiveDateFormat, date); err != nil {
+		http.Error(w, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	sample := conf.Configuration.ReplayDefaultSample
+	if value := r.URL.Query().Get("sample"); value != "" {
+		var err error
+		if sample, err = strconv.Atoi(value); err != nil || sample <= 0 {
+			http.Error(w, "Invalid sample size", http.StatusBadRequest)
+			return
+		}
+	}
+	if sample > conf.Configuration.ReplayMaxSample {
+		sample = conf.Configuration.ReplayMaxSample
+	}
+
+	queries, err := readArchive(date)
+	if os.IsNotExist(err) {
+		http.Error(w, "No queries archived on this date", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	report := replayQueries(r.Context(), queries, sample)
+	report.Date = date
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// replayQueries resolves a random sample of queries again, bypassing the track cache, and reports
+// which ones resolve to a different track than they did when archived
+func replayQueries(ctx context.Context, queries []ArchivedQuery, sample int) ReplayReport {
+	report := ReplayReport{Archived: len(queries), Changes: []ReplayChange{}}
+
+	rand.Shuffle(len(queries), func(i, j int) { queries[i], queries[j] = queries[j], queries[i] })
+	if len(queries) > sample {
+		queries = queries[:sample]
+	}
+
+	concurrency := conf.Configuration.AuditConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, query := range queries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(query ArchivedQuery) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			trackID, err := resolveTrackIDWith(ctx, query.Song, query.Artist, searchTrackIDUncached)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Replayed++
+			switch {
+			case err != nil:
+				report.Errors++
+				report.Changes = append(report.Changes, ReplayChange{ArchivedQuery: query, Error: err.Error()})
+			case trackID != query.TrackID:
+				report.Changed++
+				report.Changes = append(report.Changes, ReplayChange{ArchivedQuery: query, CurrentTrackID: trackID})
+			default:
+				report.Unchanged++
+			}
+		}(query)
+	}
+	wg.Wait()
+
+	log.Infof("[Archive] Replayed %d queries, %d changed, %d errors", report.Replayed, report.Changed, report.Errors)
+	return report
+}
+
+// searchTrackIDUncached searches the track like searchTrackID, without reading or writing the cache
+func searchTrackIDUncached(ctx context.Context, songName, artistName string) (string, error) {
+	query, artists := buildSearchQuery(songName, artistName)
+	match, err := fetchTrackID(ctx, query, artists)
+	return match.ID, err
+}
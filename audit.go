@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"lyrics-api-go/internal/provider"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AuditTrack is a single track to probe during a coverage audit. Either TrackID
+// or Song/Artist must be set.
+type AuditTrack struct {
+	Song    string `json:"song"`
+	Artist  string `json:"artist"`
+	TrackID string `json:"trackId"`
+}
+
+// AuditRequest is the body accepted by POST /admin/audit
+type AuditRequest struct {
+	Tracks []AuditTrack `json:"tracks"`
+}
+
+// AuditResult is the outcome of probing a single track against a single provider
+type AuditResult struct {
+	Provider  string `json:"provider"`
+	Song      string `json:"song,omitempty"`
+	Artist    string `json:"artist,omitempty"`
+	TrackID   string `json:"trackId,omitempty"`
+	Status    string `json:"status"`
+	SyncType  string `json:"syncType,omitempty"`
+	LineCount int    `json:"lineCount"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ProviderCoverage aggregates audit results for a single provider
+type ProviderCoverage struct {
+	Probed       int            `json:"probed"`
+	Available    int            `json:"available"`
+	NotFound     int            `json:"notFound"`
+	Errors       int            `json:"errors"`
+	SyncTypes    map[string]int `json:"syncTypes"`
+	Availability float64        `json:"availability"`
+}
+
+// AuditReport is the state of the latest coverage audit
+type AuditReport struct {
+	Status     string                       `json:"status"`
+	StartedAt  time.Time                    `json:"startedAt"`
+	FinishedAt *time.Time                   `json:"finishedAt,omitempty"`
+	Total      int                          `json:"total"`
+	Processed  int                          `json:"processed"`
+	Providers  map[string]*ProviderCoverage `json:"providers"`
+	Results    []AuditResult                `json:"results"`
+}
+
+const (
+	jobStatusRunning = "running"
+	jobStatusDone    = "done"
+	jobStatusAborted = "aborted"
+
+	auditResultAvailable = "available"
+	auditResultNotFound  = "not_found"
+	auditResultError     = "error"
+)
+
+// auditProbe probes a provider for a single track
+type auditProbe func(ctx context.Context, track AuditTrack) AuditResult
+
+// auditProviders are the providers probed by the coverage audit, keyed by name
+var auditProviders = map[string]auditProbe{
+	"spotify": probeSpotify,
+}
+
+var (
+	auditMu     sync.Mutex
+	auditReport *AuditReport
+)
+
+func startCoverageAudit(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminJobs) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req AuditRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Tracks) == 0 {
+		http.Error(w, "No tracks provided", http.StatusUnprocessableEntity)
+		return
+	}
+	if len(req.Tracks) > conf.Configuration.AuditMaxTracks {
+		http.Error(w, "Too many tracks provided", http.StatusUnprocessableEntity)
+		return
+	}
+
+	auditMu.Lock()
+	if auditReport != nil && auditReport.Status == jobStatusRunning {
+		auditMu.Unlock()
+		http.Error(w, "An audit is already running", http.StatusConflict)
+		return
+	}
+	report := &AuditReport{
+		Status:    jobStatusRunning,
+		StartedAt: time.Now(),
+		Total:     len(req.Tracks) * len(auditProviders),
+		Providers: map[string]*ProviderCoverage{},
+		Results:   []AuditResult{},
+	}
+	for name := range auditProviders {
+		report.Providers[name] = &ProviderCoverage{SyncTypes: map[string]int{}}
+	}
+	auditReport = report
+	auditMu.Unlock()
+
+	go runCoverageAudit(report, req.Tracks)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": report.Status,
+		"total":  report.Total,
+	})
+}
+
+func getCoverageAudit(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminJobs) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	if auditReport == nil {
+		http.Error(w, "No audit has been run", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(auditReport)
+}
+
+// runCoverageAudit probes every provider for every track with bounded concurrency
+func runCoverageAudit(report *AuditReport, tracks []AuditTrack) {
+	log.Infof("[Audit] Starting coverage audit of %d tracks", len(tracks))
+
+	concurrency := conf.Configuration.AuditConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for _, track := range tracks {
+		for name, probe := range auditProviders {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(name string, probe auditProbe, track AuditTrack) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				result := probe(context.Background(), track)
+				result.Provider = name
+				recordAuditResult(report, result)
+			}(name, probe, track)
+		}
+	}
+	wg.Wait()
+
+	auditMu.Lock()
+	finishedAt := time.Now()
+	report.FinishedAt = &finishedAt
+	report.Status = jobStatusDone
+	auditMu.Unlock()
+
+	log.Infof("[Audit] Coverage audit finished in %s", finishedAt.Sub(report.StartedAt))
+}
+
+func recordAuditResult(report *AuditReport, result AuditResult) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	coverage := report.Providers[result.Provider]
+	coverage.Probed++
+	switch result.Status {
+	case auditResultAvailable:
+		coverage.Available++
+		coverage.SyncTypes[result.SyncType]++
+	case auditResultNotFound:
+		coverage.NotFound++
+	default:
+		coverage.Errors++
+	}
+	coverage.Availability = float64(coverage.Available) / float64(coverage.Probed)
+
+	report.Processed++
+	report.Results = append(report.Results, result)
+}
+
+// probeSpotify checks lyrics availability and sync type for a track on Spotify, bypassing the lyrics cache
+func probeSpotify(ctx context.Context, track AuditTrack) AuditResult {
+	result := AuditResult{Song: track.Song, Artist: track.Artist, TrackID: track.TrackID}
+
+	if result.TrackID == "" {
+		trackID, err := resolveTrackID(ctx, track.Song, track.Artist)
+		if err != nil {
+			result.Status = auditResultError
+			result.Error = err.Error()
+			return result
+		}
+		if trackID == "" {
+			result.Status = auditResultNotFound
+			return result
+		}
+		result.TrackID = trackID
+	}
+
+	lyricsResp, err := spotifyClient.Lyrics(ctx, result.TrackID)
+	if err != nil {
+		var statusErr *provider.HTTPStatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			result.Status = auditResultNotFound
+			return result
+		}
+		result.Status = auditResultError
+		result.Error = err.Error()
+		return result
+	}
+	if lyricsResp == nil || len(lyricsResp.Lyrics.Lines) == 0 {
+		result.Status = auditResultNotFound
+		return result
+	}
+
+	result.Status = auditResultAvailable
+	result.SyncType = lyricsResp.Lyrics.SyncType
+	result.LineCount = len(lyricsResp.Lyrics.Lines)
+	return result
+}
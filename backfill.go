@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"lyrics-api-go/internal/cache"
+	"lyrics-api-go/lyrics"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// BackfillStatus is the progress of the line timing backfill job
+type BackfillStatus struct {
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Total      int        `json:"total"`
+	Migrated   int        `json:"migrated"`
+	UpToDate   int        `json:"upToDate"`
+	Skipped    int        `json:"skipped"`
+	Failed     int        `json:"failed"`
+}
+
+var (
+	backfillMu     sync.Mutex
+	backfillStatus *BackfillStatus
+)
+
+func startTimingBackfill(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminCache) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	status, ok := beginTimingBackfill()
+	if !ok {
+		http.Error(w, "A backfill is already running", http.StatusConflict)
+		return
+	}
+
+	go runTimingBackfill(context.Background(), status)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": status.Status,
+	})
+}
+
+// beginTimingBackfill sets up the status of a new backfill, unless one is already running
+func beginTimingBackfill() (*BackfillStatus, bool) {
+	backfillMu.Lock()
+	defer backfillMu.Unlock()
+	if backfillStatus != nil && backfillStatus.Status == jobStatusRunning {
+		return nil, false
+	}
+	backfillStatus = &BackfillStatus{Status: jobStatusRunning, StartedAt: time.Now()}
+	return backfillStatus, true
+}
+
+// scheduledTimingBackfill is the scheduler job running the backfill
+func scheduledTimingBackfill(ctx context.Context) error {
+	status, ok := beginTimingBackfill()
+	if !ok {
+		return errors.New("a backfill started from the admin endpoint is still running")
+	}
+	return runTimingBackfill(ctx, status)
+}
+
+func getTimingBackfill(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminCache) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	backfillMu.Lock()
+	defer backfillMu.Unlock()
+
+	if backfillStatus == nil {
+		http.Error(w, "No backfill has been run", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(backfillStatus)
+}
+
+// runTimingBackfill recomputes line timings of every cached lyrics entry stored under an older
+// timingVersion, throttled so it doesn't starve request handling. Entries keep their remaining TTL
+// and are only rewritten if nothing else wrote or purged them in the meantime. The backfill is
+// aborted when ctx is done.
+func runTimingBackfill(ctx context.Context, status *BackfillStatus) error {
+	var keys []string
+	rangeCacheEntries(func(key string, entry cache.Entry) bool {
+		if strings.HasPrefix(key, "lyrics:") {
+			keys = append(keys, key)
+		}
+		return true
+	})
+
+	backfillMu.Lock()
+	status.Total = len(keys)
+	backfillMu.Unlock()
+	log.Infof("[Backfill] Starting line timing backfill of %d entries", len(keys))
+
+	// a rate of 0 or less leaves the backfill unthrottled
+	limit := rate.Inf
+	if conf.Configuration.BackfillRatePerSecond > 0 {
+		limit = rate.Limit(conf.Configuration.BackfillRatePerSecond)
+	}
+	limiter := rate.NewLimiter(limit, 1)
+	var err error
+	for _, key := range keys {
+		if err = limiter.Wait(ctx); err != nil {
+			break
+		}
+		result := backfillEntry(key)
+
+		backfillMu.Lock()
+		switch result {
+		case "migrated":
+			status.Migrated++
+		case "upToDate":
+			status.UpToDate++
+		case "skipped":
+			status.Skipped++
+		default:
+			status.Failed++
+		}
+		backfillMu.Unlock()
+	}
+
+	backfillMu.Lock()
+	finishedAt := time.Now()
+	status.FinishedAt = &finishedAt
+	status.Status = jobStatusDone
+	if err != nil {
+		status.Status = jobStatusAborted
+	}
+	migrated, total := status.Migrated, status.Total
+	backfillMu.Unlock()
+
+	if err != nil {
+		log.Warnf("[Backfill] Aborted after migrating %d of %d entries: %v", migrated, total, err)
+		return err
+	}
+	log.Infof("[Backfill] Migrated %d of %d entries in %s", migrated, total, finishedAt.Sub(status.StartedAt))
+	return nil
+}
+
+// backfillEntry migrates a single cache entry and returns the outcome
+func backfillEntry(key string) string {
+	unlock := trackLocks.Lock(strings.TrimPrefix(key, "lyrics:"))
+	defer unlock()
+
+	entry, ok := cacheStore.Get(storageKey(key))
+	if !ok {
+		return "skipped"
+	}
+
+	value, err := decodeCacheValue(entry.Value)
+	if err != nil {
+		log.Errorf("[Backfill] Error decompressing %s: %v", key, err)
+		return "failed"
+	}
+
+	var cached CachedLyrics
+	if err := json.Unmarshal([]byte(value), &cached); err != nil {
+		log.Errorf("[Backfill] Error parsing %s: %v", key, err)
+		return "failed"
+	}
+	if cached.TimingVersion >= lyricsTimingVersion {
+		return "upToDate"
+	}
+
+	lyrics.ComputeTimings(cached.Lyrics)
+	cached.TimingVersion = lyricsTimingVersion
+
+	newValue, _ := json.Marshal(cached)
+	encodedValue, err := encodeCacheValue(string(newValue))
+	if err != nil {
+		log.Errorf("[Backfill] Error compressing %s: %v", key, err)
+		return "failed"
+	}
+
+	remaining := time.Until(time.Unix(0, entry.Expiration))
+	if remaining <= 0 || !cacheStore.CompareAndSet(storageKey(key), encodedValue, remaining, entry.Version) {
+		return "skipped"
+	}
+	return "migrated"
+}
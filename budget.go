@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"lyrics-api-go/middleware"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Optional enrichment steps that may be skipped when a client's time budget runs low
+const featureLanguageMatch = "languageMatch"
+
+// withinBudget reports whether an optional feature can run within the request's remaining time budget,
+// using its configured estimated cost. Skipped features are recorded so the response can flag them.
+func withinBudget(ctx context.Context, feature string) bool {
+	remaining, ok := middleware.BudgetRemaining(ctx)
+	if !ok {
+		return true
+	}
+
+	cost := time.Duration(conf.Configuration.EnrichmentCostsInMs[feature]) * time.Millisecond
+	if remaining >= cost {
+		return true
+	}
+
+	log.Infof("[Budget] Skipping %s, %s left of the time budget", feature, remaining)
+	middleware.SkipFeature(ctx, feature)
+	return false
+}
+
+// capToBudget shortens wait so it ends with the request's time budget
+func capToBudget(ctx context.Context, wait time.Duration) time.Duration {
+	remaining, ok := middleware.BudgetRemaining(ctx)
+	if ok && remaining < wait {
+		return remaining
+	}
+	return wait
+}
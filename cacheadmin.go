@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"lyrics-api-go/internal/cache"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// deleteCacheKey evicts a single cache entry, e.g. wrong lyrics reported by a user, from memory and
+// Redis. The key is URL-escaped in the path, so track keys are sent escaped twice.
+func deleteCacheKey(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminCache) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	key := mux.Vars(r)["key"]
+
+	unlock := lockCacheKey(key)
+	defer unlock()
+
+	if _, ok := loadCacheEntry(key); !ok {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+	deleteCacheEntry(key)
+	if trackID, ok := strings.CutPrefix(key, "lyrics:"); ok {
+		purgeCDNInBackground(trackSurrogateKey(trackID))
+	}
+	log.Infof("[Cache] Purged %s", key)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"key": key, "deleted": true})
+}
+
+// FlushResult is the outcome of a cache flush in every tier. A tier that failed reports its error
+// and the keys deleted until then; keys are deleted from every tier at once, so tiers never disagree.
+type FlushResult struct {
+	Prefix  string `json:"prefix"`
+	Deleted int    `json:"deleted"`
+	// RedisDeleted is how many keys were deleted from Redis, when the cache is tiered
+	RedisDeleted *int   `json:"redisDeleted,omitempty"`
+	RedisError   string `json:"redisError,omitempty"`
+	// CDNPurged is how many tracks were purged from the CDN, when CDN purging is configured
+	CDNPurged *int   `json:"cdnPurged,omitempty"`
+	CDNError  string `json:"cdnError,omitempty"`
+}
+
+// flushCache deletes every cache entry, or only those whose key starts with prefix=, e.g. to clear
+// entries poisoned by an upstream schema change without a restart. The tracks of flushed lyrics are
+// purged from the CDN. Provider tokens are kept.
+func flushCache(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminCache) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	result := FlushResult{Prefix: r.URL.Query().Get("prefix")}
+	flushed := func(key string) bool {
+		return !tokenCacheKey(key) && strings.HasPrefix(key, result.Prefix)
+	}
+
+	// keys maps the keys to flush to whether they are in memory
+	keys := map[string]bool{}
+	rangeCacheEntries(func(key string, entry cache.Entry) bool {
+		if flushed(key) {
+			keys[key] = true
+		}
+		return true
+	})
+	if cacheL2 != nil {
+		result.RedisDeleted = new(int)
+		stored, err := cacheL2.Keys(storageKey(result.Prefix))
+		if err != nil {
+			result.RedisError = err.Error()
+		}
+		for _, storedKey := range stored {
+			if version, key := cache.ParseStorageKey(storedKey); version == cacheKeySchemaVersion && flushed(key) {
+				if _, ok := keys[key]; !ok {
+					keys[key] = false
+				}
+			}
+		}
+	}
+
+	// without the full key set of Redis, nothing is deleted, so memory isn't refilled from Redis
+	tracks := map[string]bool{}
+	if result.RedisError == "" {
+		for key, inMemory := range keys {
+			if !flushCacheKey(key, inMemory, &result) {
+				break
+			}
+			if trackID, ok := strings.CutPrefix(key, "lyrics:"); ok {
+				tracks[trackID] = true
+			}
+		}
+	}
+
+	if cdnPurger != nil && len(tracks) > 0 {
+		surrogateKeys := make([]string, 0, len(tracks))
+		for trackID := range tracks {
+			surrogateKeys = append(surrogateKeys, trackSurrogateKey(trackID))
+		}
+		result.CDNPurged = new(int)
+		if err := cdnPurger.Purge(r.Context(), surrogateKeys); err != nil {
+			result.CDNError = err.Error()
+		} else {
+			*result.CDNPurged = len(surrogateKeys)
+		}
+	}
+
+	if result.RedisError != "" {
+		log.Errorf("[Cache:L2] Error flushing %q: %s", result.Prefix, result.RedisError)
+	}
+	if result.CDNError != "" {
+		log.Errorf("[CDN] Error purging the %d tracks flushed with prefix %q: %s", len(tracks), result.Prefix, result.CDNError)
+	}
+	log.Warnf("[Cache] Flushed %d entries with prefix %q", result.Deleted, result.Prefix)
+
+	w.Header().Set("Content-Type", "application/json")
+	if result.RedisError != "" && result.Deleted == 0 && *result.RedisDeleted == 0 {
+		// nothing was flushed, so the flush can be retried as is
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// flushCacheKey deletes key from Redis, then from memory, counting it in result. It returns false
+// when Redis fails, leaving the key in memory too.
+func flushCacheKey(key string, inMemory bool, result *FlushResult) bool {
+	unlock := lockCacheKey(key)
+	defer unlock()
+
+	if cacheL2 != nil {
+		deleted, err := cacheL2.Delete(storageKey(key))
+		if err != nil {
+			result.RedisError = err.Error()
+			return false
+		}
+		*result.RedisDeleted += deleted
+	}
+	if inMemory {
+		result.Deleted++
+	}
+	cacheStore.Delete(storageKey(key))
+	cacheStats.evict(key)
+	return true
+}
+
+// lockCacheKey locks the track of lyrics keys, so a refresh in flight can't write purged lyrics back
+func lockCacheKey(key string) (unlock func()) {
+	if trackID, ok := strings.CutPrefix(key, "lyrics:"); ok {
+		return trackLocks.Lock(trackID)
+	}
+	return func() {}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"lyrics-api-go/internal/cache"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// getCacheDump streams the cache dump as NDJSON, optionally only keys starting with ?prefix=, paged with
+// ?offset= and ?limit= over the keys in order
+func getCacheDump(w http.ResponseWriter, r *http.Request) {
+	// Check if the request is authorized by checking the access token
+	if !isAuthorized(r, scopeAdminCache) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	offset, limit := 0, 0
+	var err error
+	if value := query.Get("offset"); value != "" {
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	keys := cacheDumpKeys(query.Get("prefix"))
+	keys = keys[min(offset, len(keys)):]
+	if limit > 0 && limit < len(keys) {
+		keys = keys[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	if _, err := writeCacheDump(w, keys); err != nil {
+		log.Warnf("[Cache] Error writing the cache dump: %v", err)
+	}
+}
+
+// cacheDumpKeys returns the keys of the cache starting with prefix in order, leaving out the
+// provider tokens
+func cacheDumpKeys(prefix string) []string {
+	var keys []string
+	rangeCacheEntries(func(key string, entry cache.Entry) bool {
+		if !tokenCacheKey(key) && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	sort.Strings(keys)
+	return keys
+}
+
+// writeCacheDump writes an NDJSON cache dump of keys to w one entry at a time, so dumping a large
+// cache doesn't hold a copy of it in memory. Keys that expired or were deleted since they were
+// listed are left out. It returns the number of entries written.
+func writeCacheDump(w io.Writer, keys []string) (int, error) {
+	bw := bufio.NewWriter(w)
+	dump, err := cache.NewDumpWriter(bw, cacheKeySchemaVersion)
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		entry, ok := cacheStore.Get(storageKey(key))
+		if !ok {
+			continue
+		}
+		if err := dump.Write(key, entry); err != nil {
+			return 0, err
+		}
+	}
+	numberOfKeys, err := dump.Close()
+	if err != nil {
+		return numberOfKeys, err
+	}
+	return numberOfKeys, bw.Flush()
+}
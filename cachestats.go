@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Key classes cache counters are kept for
+const (
+	keyClassToken  = "token"
+	keyClassTrack  = "track"
+	keyClassLyrics = "lyrics"
+	keyClassOther  = "other"
+)
+
+// CacheClassStats counts the cache operations on one class of keys
+type CacheClassStats struct {
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Writes    int64   `json:"writes"`
+	Evictions int64   `json:"evictions"`
+	HitRate   float64 `json:"hitRate"`
+}
+
+// cacheCounters count cache hits, misses, writes and evictions per key class since startup
+type cacheCounters struct {
+	mu      sync.Mutex
+	since   time.Time
+	classes map[string]*CacheClassStats
+}
+
+var cacheStats = &cacheCounters{since: time.Now(), classes: map[string]*CacheClassStats{}}
+
+// cacheKeyClass returns the class of a cache key, the part before the first colon for the
+// classes that are counted separately
+func cacheKeyClass(key string) string {
+	if tokenCacheKey(key) {
+		return keyClassToken
+	}
+	switch prefix, _, _ := strings.Cut(key, ":"); prefix {
+	case keyClassTrack, keyClassLyrics:
+		return prefix
+	}
+	return keyClassOther
+}
+
+func (c *cacheCounters) count(key string, update func(stats *CacheClassStats)) {
+	class := cacheKeyClass(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats, ok := c.classes[class]
+	if !ok {
+		stats = &CacheClassStats{}
+		c.classes[class] = stats
+	}
+	update(stats)
+}
+
+func (c *cacheCounters) hit(key string)   { c.count(key, func(s *CacheClassStats) { s.Hits++ }) }
+func (c *cacheCounters) miss(key string)  { c.count(key, func(s *CacheClassStats) { s.Misses++ }) }
+func (c *cacheCounters) write(key string) { c.count(key, func(s *CacheClassStats) { s.Writes++ }) }
+func (c *cacheCounters) evict(key string) { c.count(key, func(s *CacheClassStats) { s.Evictions++ }) }
+
+// snapshot returns a copy of the counters with the hit rates filled in
+func (c *cacheCounters) snapshot() map[string]CacheClassStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	classes := make(map[string]CacheClassStats, len(c.classes))
+	for class, stats := range c.classes {
+		copied := *stats
+		if lookups := copied.Hits + copied.Misses; lookups > 0 {
+			copied.HitRate = float64(copied.Hits) / float64(lookups)
+		}
+		classes[class] = copied
+	}
+	return classes
+}
+
+func getCacheStats(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminJobs) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"since":   cacheStats.since,
+		"classes": cacheStats.snapshot(),
+	})
+}
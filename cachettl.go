@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// cacheTTLKey is the context key of the cache TTL a request asked for with ?ttl=
+type cacheTTLKey struct{}
+
+// withRequestCacheTTL returns r carrying the cache TTL it asks for with ?ttl= (in seconds), clamped
+// to CACHE_TTL_OVERRIDE_MIN_IN_SECONDS and CACHE_TTL_OVERRIDE_MAX_IN_SECONDS. Asking for a TTL
+// requires the cache:ttl scope.
+func withRequestCacheTTL(r *http.Request) (*http.Request, error) {
+	value := r.URL.Query().Get("ttl")
+	if value == "" {
+		return r, nil
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return r, requestError(http.StatusBadRequest, "Invalid ttl")
+	}
+	if err := scopeError(r, scopeCacheTTL); err != nil {
+		return r, err
+	}
+	seconds = min(max(seconds, conf.Configuration.CacheTTLOverrideMinInSeconds), conf.Configuration.CacheTTLOverrideMaxInSeconds)
+	return r.WithContext(context.WithValue(r.Context(), cacheTTLKey{}, time.Duration(seconds)*time.Second)), nil
+}
+
+// requestCacheTTL caps the TTL of an entry written for the request of ctx to the TTL it asked for.
+// A request can only shorten how long entries are kept, never lengthen it.
+func requestCacheTTL(ctx context.Context, ttl time.Duration) time.Duration {
+	if override, ok := ctx.Value(cacheTTLKey{}).(time.Duration); ok && override < ttl {
+		return override
+	}
+	return ttl
+}
+
+// hasRequestCacheTTL reports whether the request of ctx asked for a cache TTL
+func hasRequestCacheTTL(ctx context.Context) bool {
+	_, ok := ctx.Value(cacheTTLKey{}).(time.Duration)
+	return ok
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"lyrics-api-go/internal/captcha"
+	"lyrics-api-go/middleware"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// captchaTokenHeader carries the token of a solved captcha challenge
+const captchaTokenHeader = "X-Captcha-Token"
+
+// The headers telling challenged clients which widget to render
+const (
+	captchaProviderHeader = "X-Captcha-Provider"
+	captchaSiteKeyHeader  = "X-Captcha-Site-Key"
+)
+
+var (
+	// captchaVerifier is nil unless CAPTCHA_PROVIDER is configured
+	captchaVerifier *captcha.Verifier
+	// captchaLimiter is the soft limit of anonymous clients, past which they are challenged
+	captchaLimiter *middleware.IPRateLimiter
+	// captchaPasses are the clients that solved a challenge recently
+	captchaPasses *captcha.Passes
+)
+
+// setupCaptcha sets up challenging anonymous clients past the soft limit with a captcha
+func setupCaptcha() error {
+	if conf.Configuration.CaptchaProvider == "" {
+		return nil
+	}
+	verifier, err := captcha.New(captcha.Config{
+		Provider: conf.Configuration.CaptchaProvider,
+		URL:      conf.Configuration.CaptchaVerifyUrl,
+		Secret:   conf.Configuration.CaptchaSecret,
+	}, httpClient, setTracingHeaders)
+	if err != nil {
+		return err
+	}
+	perMinute := conf.Configuration.CaptchaSoftLimitPerMinute
+	captchaLimiter = middleware.NewIPRateLimiter(rate.Limit(float64(perMinute)/60), perMinute)
+	captchaPasses = captcha.NewPasses(time.Duration(conf.Configuration.CaptchaPassTTLInSeconds) * time.Second)
+	captchaVerifier = verifier
+	return nil
+}
+
+// isAnonymousRequest checks whether r has no valid credentials, an admin signature or token, a
+// verified JWT or a known API key, nor the extension's signature. Made-up credentials don't skip
+// the challenge.
+func isAnonymousRequest(r *http.Request) bool {
+	if isAdminRequest(r) || isExtensionRequest(r) {
+		return false
+	}
+	if token, ok := bearerToken(r); ok && jwtVerifier != nil {
+		_, verified := jwtScopes(r, token)
+		return !verified
+	}
+	_, known := apiKeys.Lookup(r.Header.Get(apiKeyHeader))
+	return !known
+}
+
+// captchaMiddleware lets anonymous clients through up to CAPTCHA_SOFT_LIMIT_PER_MINUTE, and past it
+// only with the token of a solved challenge, which gives them a pass for CAPTCHA_PASS_TTL_IN_SECONDS.
+// Challenged requests get 403 with the provider and site key to render the widget with. It runs
+// within the CORS handler so browsers can read them, within the admin signature verification so
+// signed requests are known, and within the abuse detection so clients that keep ignoring
+// challenges get banned.
+func captchaMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if captchaVerifier == nil || !isAnonymousRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client := middleware.ClientIP(r)
+		if captchaPasses.Valid(client) || captchaLimiter.GetLimiter(client).Allow() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := r.Header.Get(captchaTokenHeader)
+		if token != "" {
+			err := captchaVerifier.Verify(r.Context(), token, client)
+			if err == nil {
+				captchaPasses.Grant(client)
+				next.ServeHTTP(w, r)
+				return
+			}
+			log.Infof("[Captcha] Rejected token of %s: %v", client, err)
+		}
+
+		w.Header().Set(captchaProviderHeader, conf.Configuration.CaptchaProvider)
+		w.Header().Set(captchaSiteKeyHeader, conf.Configuration.CaptchaSiteKey)
+		http.Error(w, "Captcha required", http.StatusForbidden)
+	})
+}
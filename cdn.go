@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"lyrics-api-go/internal/cdn"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// cdnPurgeTimeout bounds the purges made in the background after an entry is deleted or refreshed
+const cdnPurgeTimeout = 30 * time.Second
+
+// trackSurrogateKey is the surrogate key of every response with the lyrics of a track. Responses of
+// linked re-releases also carry the key of their canonical track, so purging it purges them all.
+func trackSurrogateKey(trackID string) string {
+	return "track-" + trackID
+}
+
+// providerSurrogateKey is the surrogate key of every response with lyrics from a provider
+func providerSurrogateKey(name string) string {
+	return "provider-" + name
+}
+
+// setSurrogateKeys tags a successful lyrics response with its surrogate keys, in the header of the
+// CDN configured in CDN_PROVIDER
+func setSurrogateKeys(w http.ResponseWriter, trackID, provider string) {
+	header, separator := cdn.HeaderName(conf.Configuration.CdnProvider)
+	if header == "" {
+		return
+	}
+	keys := []string{trackSurrogateKey(trackID)}
+	if canonicalID := canonicalTrackID(trackID); canonicalID != trackID {
+		keys = append(keys, trackSurrogateKey(canonicalID))
+	}
+	if provider != "" {
+		keys = append(keys, providerSurrogateKey(provider))
+	}
+	w.Header().Set(header, strings.Join(keys, separator))
+}
+
+// purgeCDNInBackground purges the responses tagged with keys from the CDN, if purging is configured,
+// without holding up the request that changed them
+func purgeCDNInBackground(keys ...string) {
+	if cdnPurger == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cdnPurgeTimeout)
+		defer cancel()
+		if err := cdnPurger.Purge(ctx, keys); err != nil {
+			log.Errorf("[CDN] Error purging %v: %v", keys, err)
+			return
+		}
+		log.Infof("[CDN] Purged %v", keys)
+	}()
+}
+
+// purgeCDN purges the responses of the tracks in trackId= and the providers in provider=, both
+// repeatable, from the CDN, e.g. after lyrics were corrected outside the API
+func purgeCDN(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminCache) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if cdnPurger == nil {
+		http.Error(w, "CDN purging is not configured", http.StatusNotImplemented)
+		return
+	}
+
+	query := r.URL.Query()
+	var keys []string
+	for _, trackID := range query["trackId"] {
+		keys = append(keys, trackSurrogateKey(trackID))
+	}
+	for _, name := range query["provider"] {
+		keys = append(keys, providerSurrogateKey(name))
+	}
+	if len(keys) == 0 {
+		http.Error(w, "trackId or provider not provided", http.StatusBadRequest)
+		return
+	}
+
+	if err := cdnPurger.Purge(r.Context(), keys); err != nil {
+		log.Errorf("[CDN] Error purging %v: %v", keys, err)
+		http.Error(w, "Error purging the CDN", http.StatusBadGateway)
+		return
+	}
+	log.Infof("[CDN] Purged %v", keys)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys, "purged": true})
+}
@@ -0,0 +1,120 @@
+// Command migrate-cache moves the warm cache of an instance to another cache backend. It reads the
+// cache from a running instance (GET /cache) or from a dump saved from it, writes an import file for
+// the new backend and verifies the file against the dump's counts and checksums.
+//
+//	migrate-cache -source http://localhost:8080 -token $CACHE_ACCESS_TOKEN -backend redis -out cache.resp
+//	redis-cli --pipe < cache.resp
+//
+//	migrate-cache -snapshot cache-dump.json -backend sqlite -out cache.sql
+//	sqlite3 cache.db < cache.sql
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"lyrics-api-go/internal/adminauth"
+	"lyrics-api-go/internal/cache"
+	"lyrics-api-go/internal/migrate"
+)
+
+func main() {
+	source := flag.String("source", "", "base URL of a running instance to read the cache from")
+	token := flag.String("token", os.Getenv("CACHE_ACCESS_TOKEN"), "access token of the instance to sign the request with (defaults to $CACHE_ACCESS_TOKEN)")
+	snapshot := flag.String("snapshot", "", "cache dump saved from GET /cache, instead of -source")
+	backend := flag.String("backend", "", "backend to migrate to: redis or sqlite")
+	out := flag.String("out", "", "import file to write")
+	flag.Parse()
+
+	if (*source == "") == (*snapshot == "") || *backend == "" || *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dump, err := loadDump(*source, *token, *snapshot)
+	if err != nil {
+		fail("Error reading the cache: %v", err)
+	}
+	if dump.SchemaVersion > cache.DumpSchemaVersion {
+		fail("Unsupported dump schema version %d", dump.SchemaVersion)
+	}
+	if dump.SchemaVersion == 0 {
+		fmt.Println("The dump has no checksums, its entries can't be verified against the source")
+	}
+
+	records, expired, problems := migrate.Records(dump, time.Now())
+	for _, problem := range problems {
+		fmt.Println("Skipping", problem)
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		fail("Error creating %s: %v", *out, err)
+	}
+	if err := migrate.Write(file, *backend, records); err != nil {
+		fail("Error writing %s: %v", *out, err)
+	}
+	if err := file.Close(); err != nil {
+		fail("Error writing %s: %v", *out, err)
+	}
+
+	// the file is read back, so what gets imported is exactly what was verified
+	file, err = os.Open(*out)
+	if err != nil {
+		fail("Error reopening %s: %v", *out, err)
+	}
+	defer file.Close()
+	written, err := migrate.Read(file, *backend)
+	if err != nil {
+		fail("Error verifying %s: %v", *out, err)
+	}
+	if mismatches := migrate.Verify(records, written); len(mismatches) > 0 {
+		fail("Verification of %s failed:\n%s", *out, strings.Join(mismatches, "\n"))
+	}
+
+	fmt.Printf("Wrote %d entries to %s (%d expired and %d invalid skipped)\n", len(written), *out, expired, len(problems))
+	fmt.Printf("Digest of the migrated entries: %s\n", migrate.Digest(written))
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+// loadDump fetches the cache from a running instance, or reads it from a saved dump
+func loadDump(source, token, snapshot string) (cache.Dump, error) {
+	var dump cache.Dump
+	if snapshot != "" {
+		file, err := os.Open(snapshot)
+		if err != nil {
+			return dump, err
+		}
+		defer file.Close()
+		return cache.ReadDump(file)
+	}
+
+	req, err := http.NewRequest("GET", strings.TrimSuffix(source, "/")+"/cache", nil)
+	if err != nil {
+		return dump, err
+	}
+	// the token itself is never sent, so a logged request can't be replayed
+	if err := adminauth.Sign(req, token); err != nil {
+		return dump, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return dump, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return dump, fmt.Errorf("GET /cache returned %s", resp.Status)
+	}
+	return cache.ReadDump(resp.Body)
+}
+
+func fail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
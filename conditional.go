@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setCacheControl sets the Cache-Control header of a successful lyrics response from the max age
+// configured for its endpoint in CACHE_CONTROL_MAX_AGES_IN_SECONDS, so browsers and CDNs can absorb
+// repeat requests. Responses to requests with credentials are private, since rollouts and scopes can
+// make them differ per client and anonymous clients may not be allowed them at all, as are responses
+// shaped by a rollout covering only some clients, and stale lyrics served during an outage are never
+// cached.
+func setCacheControl(w http.ResponseWriter, r *http.Request, stale bool) {
+	maxAge, ok := conf.Configuration.CacheControlMaxAgesInSeconds[path.Base(r.URL.Path)]
+	switch {
+	case !ok:
+		return
+	case stale || maxAge <= 0:
+		w.Header().Set("Cache-Control", "no-cache")
+	case hasCredentials(r) || rolloutVaries(r):
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", maxAge))
+	default:
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+	}
+}
+
+// cacheStatus returns the X-Cache status of lyrics: STALE for lyrics past their TTL served during an
+// outage, HIT when they were read from the cache and MISS when they were fetched for the request
+func cacheStatus(data CachedLyrics) string {
+	switch {
+	case data.Stale:
+		return "STALE"
+	case data.FromCache:
+		return "HIT"
+	}
+	return "MISS"
+}
+
+// setCacheStatus sets X-Cache to status and X-Cache-Age to the seconds since the lyrics were fetched,
+// so support can tell at a glance whether a bad response came from the cache or upstream. Age is
+// left to caches, which add their own time to it.
+func setCacheStatus(w http.ResponseWriter, status string, fetchedAt int64) {
+	w.Header().Set("X-Cache", status)
+	if fetchedAt != 0 {
+		w.Header().Set("X-Cache-Age", strconv.FormatInt(max(time.Now().Unix()-fetchedAt, 0), 10))
+	}
+}
+
+// clearCacheHeaders removes the caching headers set for a lyrics response that ends up failing
+func clearCacheHeaders(w http.ResponseWriter) {
+	for _, header := range []string{"Cache-Control", "Last-Modified", "Surrogate-Key", "Cache-Tag", "X-Cache", "X-Cache-Age"} {
+		w.Header().Del(header)
+	}
+}
+
+// responseETag returns the strong ETag of a response body. Bodies depend on the request options as
+// much as on the cached lyrics, so the tag is taken over the body itself.
+func responseETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, compared weakly as RFC 9110
+// requires for If-None-Match
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// lyricsLastModified returns when the response of the lyrics of trackID last changed: when they were
+// fetched, or when the community offset of the track last got a submission if that is later. It is
+// the zero time for lyrics cached before fetch times were recorded.
+func lyricsLastModified(trackID string, data CachedLyrics) time.Time {
+	if data.FetchedAt == 0 {
+		return time.Time{}
+	}
+	modified := time.Unix(data.FetchedAt, 0)
+	if submitted := communityOffsets.LastSubmittedAt(canonicalTrackID(trackID)); submitted.After(modified) {
+		modified = submitted
+	}
+	return modified.UTC().Truncate(time.Second)
+}
+
+// setLastModified sets the Last-Modified header of a successful lyrics response, unless modified is
+// unknown
+func setLastModified(w http.ResponseWriter, modified time.Time) {
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// notModified reports whether the client already has the response, by its ETag or, only when the
+// request has no If-None-Match as RFC 9110 requires, by its Last-Modified date
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		return etagMatches(header, etag)
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(w.Header().Get("Last-Modified"))
+	return err == nil && !modified.After(since)
+}
+
+// writeLyricsBody writes a successful lyrics response with its ETag, answering 304 Not Modified
+// instead when a GET or HEAD request already has the body
+func writeLyricsBody(w http.ResponseWriter, r *http.Request, contentType string, body []byte) {
+	etag := responseETag(body)
+	w.Header().Set("ETag", etag)
+	if notModified(w, r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(body)
+}
@@ -10,28 +10,161 @@ var conf = mustLoad()
 
 type Config struct {
 	Configuration struct {
-		RateLimitPerSecond                 int    `envconfig:"RATE_LIMIT_PER_SECOND" default:"2"`
-		RateLimitBurstLimit                int    `envconfig:"RATE_LIMIT_BURST_LIMIT" default:"5"`
-		CacheInvalidationIntervalInSeconds int    `envconfig:"CACHE_INVALIDATION_INTERVAL_IN_SECONDS" default:"3600"`
-		LyricsCacheTTLInSeconds            int    `envconfig:"LYRICS_CACHE_TTL_IN_SECONDS" default:"86400"`
-		TrackCacheTTLInSeconds             int    `envconfig:"TRACK_CACHE_TTL_IN_SECONDS" default:"3600"`
-		CacheAccessToken                   string `envconfig:"CACHE_ACCESS_TOKEN" default:""`
-		LyricsUrl                          string `envconfig:"LYRICS_URL" default:""`
-		TrackUrl                           string `envconfig:"TRACK_URL" default:""`
-		TokenUrl                           string `envconfig:"TOKEN_URL" default:""`
-		TokenKey                           string `envconfig:"TOKEN_KEY"  default:""`
-		AppPlatform                        string `envconfig:"APP_PLATFORM" default:""`
-		UserAgent                          string `envconfig:"USER_AGENT" default:""`
-		CookieStringFormat                 string `envconfig:"COOKIE_STRING_FORMAT" default:""`
-		CookieValue                        string `envconfig:"COOKIE_VALUE" default:""`
-		ClientID                           string `envconfig:"CLIENT_ID" default:""`
-		ClientSecret                       string `envconfig:"CLIENT_SECRET" default:""`
-		OauthTokenUrl                      string `envconfig:"OAUTH_TOKEN_URL" default:""`
-		OauthTokenKey                      string `envconfig:"OAUTH_TOKEN_KEY" default:""`
+		RateLimitPerSecond                 int               `envconfig:"RATE_LIMIT_PER_SECOND" default:"2"`
+		RateLimitBurstLimit                int               `envconfig:"RATE_LIMIT_BURST_LIMIT" default:"5"`
+		OriginRateLimits                   string            `envconfig:"ORIGIN_RATE_LIMITS" default:""`
+		RateLimitRedisAddr                 string            `envconfig:"RATE_LIMIT_REDIS_ADDR" default:""`
+		RateLimitRedisPassword             string            `envconfig:"RATE_LIMIT_REDIS_PASSWORD" default:""`
+		RateLimitRedisTimeoutInMs          int               `envconfig:"RATE_LIMIT_REDIS_TIMEOUT_IN_MS" default:"100"`
+		ExtensionSigningSecret             string            `envconfig:"EXTENSION_SIGNING_SECRET" default:""`
+		ExtensionSignatureMaxSkewInSeconds int               `envconfig:"EXTENSION_SIGNATURE_MAX_SKEW_IN_SECONDS" default:"300"`
+		ExtensionRateLimitPerSecond        int               `envconfig:"EXTENSION_RATE_LIMIT_PER_SECOND" default:"10"`
+		ExtensionRateLimitBurstLimit       int               `envconfig:"EXTENSION_RATE_LIMIT_BURST_LIMIT" default:"20"`
+		CaptchaProvider                    string            `envconfig:"CAPTCHA_PROVIDER" default:""`
+		CaptchaSecret                      string            `envconfig:"CAPTCHA_SECRET" default:""`
+		CaptchaSiteKey                     string            `envconfig:"CAPTCHA_SITE_KEY" default:""`
+		CaptchaVerifyUrl                   string            `envconfig:"CAPTCHA_VERIFY_URL" default:""`
+		CaptchaSoftLimitPerMinute          int               `envconfig:"CAPTCHA_SOFT_LIMIT_PER_MINUTE" default:"60"`
+		CaptchaPassTTLInSeconds            int               `envconfig:"CAPTCHA_PASS_TTL_IN_SECONDS" default:"3600"`
+		CacheInvalidationIntervalInSeconds int               `envconfig:"CACHE_INVALIDATION_INTERVAL_IN_SECONDS" default:"3600"`
+		LyricsCacheTTLInSeconds            int               `envconfig:"LYRICS_CACHE_TTL_IN_SECONDS" default:"86400"`
+		LyricsTTLPolicies                  string            `envconfig:"LYRICS_TTL_POLICIES" default:""`
+		TrackCacheTTLInSeconds             int               `envconfig:"TRACK_CACHE_TTL_IN_SECONDS" default:"3600"`
+		StaleLyricsTTLInSeconds            int               `envconfig:"STALE_LYRICS_TTL_IN_SECONDS" default:"604800"`
+		CacheCodec                         string            `envconfig:"CACHE_CODEC" default:"gzip"`
+		CacheCompressionMinBytes           int               `envconfig:"CACHE_COMPRESSION_MIN_BYTES" default:"512"`
+		CacheFile                          string            `envconfig:"CACHE_FILE" default:""`
+		CacheEncryptionKey                 string            `envconfig:"CACHE_ENCRYPTION_KEY" default:""`
+		CacheRedisAddr                     string            `envconfig:"CACHE_REDIS_ADDR" default:""`
+		CacheRedisPassword                 string            `envconfig:"CACHE_REDIS_PASSWORD" default:""`
+		CacheRedisTimeoutInMs              int               `envconfig:"CACHE_REDIS_TIMEOUT_IN_MS" default:"500"`
+		CacheL1TTLInSeconds                int               `envconfig:"CACHE_L1_TTL_IN_SECONDS" default:"60"`
+		ResponseBodyTTLInSeconds           int               `envconfig:"RESPONSE_BODY_TTL_IN_SECONDS" default:"300"`
+		SnapshotLocation                   string            `envconfig:"SNAPSHOT_LOCATION" default:""`
+		SnapshotRestoreOnStartup           bool              `envconfig:"SNAPSHOT_RESTORE_ON_STARTUP" default:"false"`
+		SnapshotS3Region                   string            `envconfig:"SNAPSHOT_S3_REGION" default:""`
+		SnapshotS3Endpoint                 string            `envconfig:"SNAPSHOT_S3_ENDPOINT" default:""`
+		AWSAccessKeyID                     string            `envconfig:"AWS_ACCESS_KEY_ID" default:""`
+		AWSSecretAccessKey                 string            `envconfig:"AWS_SECRET_ACCESS_KEY" default:""`
+		AWSSessionToken                    string            `envconfig:"AWS_SESSION_TOKEN" default:""`
+		CacheAccessToken                   string            `envconfig:"CACHE_ACCESS_TOKEN" default:""`
+		AdminSignatureMaxSkewInSeconds     int               `envconfig:"ADMIN_SIGNATURE_MAX_SKEW_IN_SECONDS" default:"300"`
+		AdminAddr                          string            `envconfig:"ADMIN_ADDR" default:""`
+		LyricsUrl                          string            `envconfig:"LYRICS_URL" default:""`
+		TrackUrl                           string            `envconfig:"TRACK_URL" default:""`
+		TokenUrl                           string            `envconfig:"TOKEN_URL" default:""`
+		TokenKey                           string            `envconfig:"TOKEN_KEY"  default:""`
+		AppPlatform                        string            `envconfig:"APP_PLATFORM" default:""`
+		UserAgent                          string            `envconfig:"USER_AGENT" default:""`
+		CookieStringFormat                 string            `envconfig:"COOKIE_STRING_FORMAT" default:""`
+		CookieValue                        string            `envconfig:"COOKIE_VALUE" default:""`
+		ClientID                           string            `envconfig:"CLIENT_ID" default:""`
+		ClientSecret                       string            `envconfig:"CLIENT_SECRET" default:""`
+		SecretsFile                        string            `envconfig:"SECRETS_FILE" default:""`
+		SecretsWatchIntervalInSeconds      int               `envconfig:"SECRETS_WATCH_INTERVAL_IN_SECONDS" default:"30"`
+		SecretsBackend                     string            `envconfig:"SECRETS_BACKEND" default:"file"`
+		VaultAddr                          string            `envconfig:"VAULT_ADDR" default:""`
+		VaultToken                         string            `envconfig:"VAULT_TOKEN" default:""`
+		VaultSecretPath                    string            `envconfig:"VAULT_SECRET_PATH" default:""`
+		SecretsAWSSecretID                 string            `envconfig:"SECRETS_AWS_SECRET_ID" default:""`
+		SecretsAWSRegion                   string            `envconfig:"SECRETS_AWS_REGION" default:""`
+		SecretsAWSEndpoint                 string            `envconfig:"SECRETS_AWS_ENDPOINT" default:""`
+		AudioAnalysisUrl                   string            `envconfig:"AUDIO_ANALYSIS_URL" default:""`
+		AudioFeaturesUrl                   string            `envconfig:"AUDIO_FEATURES_URL" default:""`
+		PlaylistUrl                        string            `envconfig:"PLAYLIST_URL" default:""`
+		OauthTokenUrl                      string            `envconfig:"OAUTH_TOKEN_URL" default:""`
+		OauthTokenKey                      string            `envconfig:"OAUTH_TOKEN_KEY" default:""`
+		AuditConcurrency                   int               `envconfig:"AUDIT_CONCURRENCY" default:"4"`
+		AuditMaxTracks                     int               `envconfig:"AUDIT_MAX_TRACKS" default:"1000"`
+		WarmupConcurrency                  int               `envconfig:"WARMUP_CONCURRENCY" default:"4"`
+		WarmupMaxTracks                    int               `envconfig:"WARMUP_MAX_TRACKS" default:"5000"`
+		PopularityWindowInHours            int               `envconfig:"POPULARITY_WINDOW_IN_HOURS" default:"24"`
+		CacheTTLOverrideMinInSeconds       int               `envconfig:"CACHE_TTL_OVERRIDE_MIN_IN_SECONDS" default:"60"`
+		CacheTTLOverrideMaxInSeconds       int               `envconfig:"CACHE_TTL_OVERRIDE_MAX_IN_SECONDS" default:"86400"`
+		PopularRefreshTopN                 int               `envconfig:"POPULAR_REFRESH_TOP_N" default:"100"`
+		PopularRefreshAheadInSeconds       int               `envconfig:"POPULAR_REFRESH_AHEAD_IN_SECONDS" default:"1800"`
+		MusicBrainzUrl                     string            `envconfig:"MUSICBRAINZ_URL" default:"https://musicbrainz.org/ws/2/recording"`
+		MusicBrainzUserAgent               string            `envconfig:"MUSICBRAINZ_USER_AGENT" default:"BetterLyricsAPI/1.0 ( https://better-lyrics.boidu.dev )"`
+		MusicBrainzMinScore                int               `envconfig:"MUSICBRAINZ_MIN_SCORE" default:"90"`
+		MusicBrainzCacheTTLInSeconds       int               `envconfig:"MUSICBRAINZ_CACHE_TTL_IN_SECONDS" default:"604800"`
+		AcoustIDUrl                        string            `envconfig:"ACOUSTID_URL" default:"https://api.acoustid.org/v2/lookup"`
+		AcoustIDApiKey                     string            `envconfig:"ACOUSTID_API_KEY" default:""`
+		AcoustIDMinScore                   float64           `envconfig:"ACOUSTID_MIN_SCORE" default:"0.8"`
+		RequestIDPropagationHosts          []string          `envconfig:"REQUEST_ID_PROPAGATION_HOSTS" default:"musicbrainz.org,api.acoustid.org,api.spotify.com,spclient.wg.spotify.com"`
+		StoreExportRatePerSecond           int               `envconfig:"STORE_EXPORT_RATE_PER_SECOND" default:"500"`
+		BackfillRatePerSecond              int               `envconfig:"BACKFILL_RATE_PER_SECOND" default:"50"`
+		ExpectLanguageMaxCandidates        int               `envconfig:"EXPECT_LANGUAGE_MAX_CANDIDATES" default:"3"`
+		LongPollMaxWaitInSeconds           int               `envconfig:"LONG_POLL_MAX_WAIT_IN_SECONDS" default:"60"`
+		LongPollRecheckIntervalInSeconds   int               `envconfig:"LONG_POLL_RECHECK_INTERVAL_IN_SECONDS" default:"10"`
+		EnrichmentCostsInMs                map[string]int    `envconfig:"ENRICHMENT_COSTS_IN_MS" default:"languageMatch:1500,translation:2000,romanization:300,furigana:300,transliteration:300,estimatedSync:1000,introTrim:1000"`
+		TranslationBackend                 string            `envconfig:"TRANSLATION_BACKEND" default:""`
+		TranslationUrl                     string            `envconfig:"TRANSLATION_URL" default:""`
+		TranslationApiKey                  string            `envconfig:"TRANSLATION_API_KEY" default:""`
+		TranslationCacheTTLInSeconds       int               `envconfig:"TRANSLATION_CACHE_TTL_IN_SECONDS" default:"604800"`
+		CdnProvider                        string            `envconfig:"CDN_PROVIDER" default:""`
+		CdnApiUrl                          string            `envconfig:"CDN_API_URL" default:""`
+		CdnApiToken                        string            `envconfig:"CDN_API_TOKEN" default:""`
+		CdnServiceId                       string            `envconfig:"CDN_SERVICE_ID" default:""`
+		QueryArchiveDir                    string            `envconfig:"QUERY_ARCHIVE_DIR" default:""`
+		QueryArchiveRetentionDays          int               `envconfig:"QUERY_ARCHIVE_RETENTION_DAYS" default:"30"`
+		QueryArchiveMaxSizeInMB            int               `envconfig:"QUERY_ARCHIVE_MAX_SIZE_IN_MB" default:"1024"`
+		ReplayDefaultSample                int               `envconfig:"REPLAY_DEFAULT_SAMPLE" default:"100"`
+		ReplayMaxSample                    int               `envconfig:"REPLAY_MAX_SAMPLE" default:"1000"`
+		ResponseHeaders                    string            `envconfig:"RESPONSE_HEADERS" default:""`
+		CorsAllowedOrigins                 []string          `envconfig:"CORS_ALLOWED_ORIGINS" default:"https://music.youtube.com,http://localhost:3000"`
+		IPAllowList                        []string          `envconfig:"IP_ALLOW_LIST" default:""`
+		IPDenyList                         []string          `envconfig:"IP_DENY_LIST" default:""`
+		APIKeys                            string            `envconfig:"API_KEYS" default:""`
+		APIKeysFile                        string            `envconfig:"API_KEYS_FILE" default:"api-keys.json"`
+		UsageRetentionInDays               int               `envconfig:"USAGE_RETENTION_IN_DAYS" default:"30"`
+		AbuseWindowInSeconds               int               `envconfig:"ABUSE_WINDOW_IN_SECONDS" default:"60"`
+		AbuseMinRequests                   int               `envconfig:"ABUSE_MIN_REQUESTS" default:"30"`
+		AbuseMaxErrorRatio                 float64           `envconfig:"ABUSE_MAX_ERROR_RATIO" default:"0.8"`
+		AbuseMaxRateLimited                int               `envconfig:"ABUSE_MAX_RATE_LIMITED" default:"60"`
+		AbuseBanInSeconds                  int               `envconfig:"ABUSE_BAN_IN_SECONDS" default:"300"`
+		AbuseMaxBanInSeconds               int               `envconfig:"ABUSE_MAX_BAN_IN_SECONDS" default:"86400"`
+		AbuseOffenseMemoryInSeconds        int               `envconfig:"ABUSE_OFFENSE_MEMORY_IN_SECONDS" default:"86400"`
+		AnonymousScopes                    []string          `envconfig:"ANONYMOUS_SCOPES" default:"lyrics:read,translate"`
+		JwtSecret                          string            `envconfig:"JWT_SECRET" default:""`
+		JwtJwksUrl                         string            `envconfig:"JWT_JWKS_URL" default:""`
+		JwtJwksTTLInSeconds                int               `envconfig:"JWT_JWKS_TTL_IN_SECONDS" default:"3600"`
+		JwtIssuer                          string            `envconfig:"JWT_ISSUER" default:""`
+		JwtAudience                        string            `envconfig:"JWT_AUDIENCE" default:""`
+		JwtDefaultScopes                   []string          `envconfig:"JWT_DEFAULT_SCOPES" default:"lyrics:read,translate"`
+		AnnouncementsFile                  string            `envconfig:"ANNOUNCEMENTS_FILE" default:""`
+		InterludeMinGapMs                  int64             `envconfig:"INTERLUDE_MIN_GAP_MS" default:"8000"`
+		MinPlaybackRate                    float64           `envconfig:"MIN_PLAYBACK_RATE" default:"0.25"`
+		MaxPlaybackRate                    float64           `envconfig:"MAX_PLAYBACK_RATE" default:"4"`
+		RolloutPercentages                 map[string]int    `envconfig:"ROLLOUT_PERCENTAGES" default:"v2Shape:0"`
+		RolloutMinClientVersions           map[string]string `envconfig:"ROLLOUT_MIN_CLIENT_VERSIONS" default:""`
+		JobSchedules                       string            `envconfig:"JOB_SCHEDULES" default:""`
+		JobJitterInSeconds                 int               `envconfig:"JOB_JITTER_IN_SECONDS" default:"30"`
+		ProvidersStateFile                 string            `envconfig:"PROVIDERS_STATE_FILE" default:"providers.json"`
+		CommunityOffsetsFile               string            `envconfig:"COMMUNITY_OFFSETS_FILE" default:"offsets.json"`
+		CommunityOffsetMinAgreeing         int               `envconfig:"COMMUNITY_OFFSET_MIN_AGREEING" default:"3"`
+		CommunityOffsetToleranceMs         int64             `envconfig:"COMMUNITY_OFFSET_TOLERANCE_MS" default:"250"`
+		CommunityOffsetMaxMs               int64             `envconfig:"COMMUNITY_OFFSET_MAX_MS" default:"10000"`
+		CommunityOffsetMaxTracks           int               `envconfig:"COMMUNITY_OFFSET_MAX_TRACKS" default:"100000"`
+		PipelineStageTimeoutsInMs          map[string]int    `envconfig:"PIPELINE_STAGE_TIMEOUTS_IN_MS" default:"resolve:10000,fetch:15000,enrich:10000"`
+		UpstreamMaxConcurrencyPerHost      int               `envconfig:"UPSTREAM_MAX_CONCURRENCY_PER_HOST" default:"8"`
+		UpstreamMaxQueuedPerHost           int               `envconfig:"UPSTREAM_MAX_QUEUED_PER_HOST" default:"100"`
+		UpstreamHostConcurrency            map[string]int    `envconfig:"UPSTREAM_HOST_CONCURRENCY" default:""`
+		CacheControlMaxAgesInSeconds       map[string]int    `envconfig:"CACHE_CONTROL_MAX_AGES_IN_SECONDS" default:"getLyrics:3600,getLyricsByFingerprint:3600"`
+		MaxURLLength                       int               `envconfig:"MAX_URL_LENGTH" default:"2048"`
+		MaxQueryLength                     int               `envconfig:"MAX_QUERY_LENGTH" default:"1024"`
+		MaxHeaderBytes                     int               `envconfig:"MAX_HEADER_BYTES" default:"16384"`
+		MaxRequestBodyBytes                int64             `envconfig:"MAX_REQUEST_BODY_BYTES" default:"65536"`
+		IdempotencyTTLInSeconds            int               `envconfig:"IDEMPOTENCY_TTL_IN_SECONDS" default:"3600"`
+		IdempotencyMaxEntries              int               `envconfig:"IDEMPOTENCY_MAX_ENTRIES" default:"10000"`
+		IdempotencyMaxResponseBytes        int               `envconfig:"IDEMPOTENCY_MAX_RESPONSE_BYTES" default:"1048576"`
 	}
 
 	FeatureFlags struct {
-		CacheCompression bool `envconfig:"FF_CACHE_COMPRESSION" default:"true"`
+		CacheCompression            bool `envconfig:"FF_CACHE_COMPRESSION" default:"true"`
+		MusicBrainzCanonicalization bool `envconfig:"FF_MUSICBRAINZ_CANONICALIZATION" default:"false"`
+		Tracing                     bool `envconfig:"FF_TRACING" default:"false"`
+		AdminPlainToken             bool `envconfig:"FF_ADMIN_PLAIN_TOKEN" default:"false"`
+		AbuseBans                   bool `envconfig:"FF_ABUSE_BANS" default:"false"`
 	}
 }
 
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+
+	"lyrics-api-go/lyrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Re-releases of a song (remasters, deluxe editions, compilations) are separate tracks with the same
+// lyrics. The first track cached with some lyrics becomes the canonical track of that content, and
+// later tracks with the same content hash are linked to it, so per-track state kept under the
+// canonical track id and the canonical track's cached lyrics are shared by all duplicates.
+
+func contentCacheKey(hash string) string {
+	return fmt.Sprintf("content:%s", hash)
+}
+
+func canonicalCacheKey(trackID string) string {
+	return fmt.Sprintf("canonical:%s", trackID)
+}
+
+// canonicalTrackID returns the track trackID is a duplicate of, or trackID itself
+func canonicalTrackID(trackID string) string {
+	if canonicalID, ok := getCache(canonicalCacheKey(trackID)); ok {
+		return canonicalID
+	}
+	return trackID
+}
+
+// linkDuplicate records the content hash of the lyrics cached for trackID, linking the track to the
+// canonical track with the same lyrics if there is one
+func linkDuplicate(trackID string, data CachedLyrics) {
+	hash := lyrics.ContentHash(data.Lyrics)
+	if hash == "" {
+		return
+	}
+	ttl := lyricsTTLPolicy.MaxTTL()
+
+	key := contentCacheKey(hash)
+	canonicalID, ok := getCache(key)
+	if !ok {
+		// another track with the same content may claim it first, in which case we link to that one
+		if setCacheIfUnchanged(key, trackID, ttl, getCacheVersion(key)) {
+			return
+		}
+		if canonicalID, ok = getCache(key); !ok {
+			return
+		}
+	}
+	if canonicalID == trackID {
+		// keep the content claimed as long as the canonical track is cached
+		setCache(key, trackID, ttl)
+		return
+	}
+
+	log.Infof("[Duplicates] Linking %s to %s, they have the same lyrics", trackID, canonicalID)
+	setCache(canonicalCacheKey(trackID), canonicalID, ttl)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lyrics-api-go/lyrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const featureEstimatedSync = "estimatedSync"
+
+// Without an audio analysis, the lines of estimated lyrics are kept clear of an intro and outro of
+// this share of the track, up to the given length
+const (
+	estimatedIntroShare = 0.08
+	maxEstimatedIntroMs = 15000
+	estimatedOutroShare = 0.05
+	maxEstimatedOutroMs = 10000
+)
+
+// estimateSync times unsynced lyrics across the track on request (?estimateSync=1), marking them
+// SyncTypeEstimated. The track duration is taken from ?durationMs= or else the audio analysis. Lyrics
+// are returned unchanged when the duration is unknown.
+func estimateSync(r *http.Request, trackID string, data CachedLyrics) (CachedLyrics, error) {
+	if data.UnsyncedLyrics == "" || !isTruthy(r.URL.Query().Get("estimateSync")) {
+		return data, nil
+	}
+
+	var introEndMs, trackEndMs int64
+	if value := r.URL.Query().Get("durationMs"); value != "" {
+		durationMs, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || durationMs <= 0 {
+			return data, requestError(http.StatusBadRequest, "Invalid durationMs")
+		}
+		trackEndMs = durationMs
+	} else if withinBudget(r.Context(), featureEstimatedSync) {
+		introEndMs, trackEndMs = trackBounds(r, trackID)
+	}
+	if trackEndMs <= 0 {
+		return data, nil
+	}
+
+	if introEndMs <= 0 || introEndMs >= trackEndMs {
+		introEndMs = min(int64(float64(trackEndMs)*estimatedIntroShare), maxEstimatedIntroMs)
+	}
+	outroStartMs := trackEndMs - min(int64(float64(trackEndMs)*estimatedOutroShare), maxEstimatedOutroMs)
+	if outroStartMs <= introEndMs {
+		outroStartMs = trackEndMs
+	}
+
+	data.Lyrics = lyrics.EstimateTimings(data.UnsyncedLyrics, introEndMs, outroStartMs)
+	data.SyncType = lyrics.SyncTypeEstimated
+	data.UnsyncedLyrics = ""
+	return data, nil
+}
+
+func trackBoundsCacheKey(trackID string) string {
+	return fmt.Sprintf("bounds:%s", trackID)
+}
+
+// trackBounds returns where the intro of a track ends and its duration from the audio analysis,
+// both 0 when it isn't configured or available
+func trackBounds(r *http.Request, trackID string) (introEndMs, trackEndMs int64) {
+	if conf.Configuration.AudioAnalysisUrl == "" {
+		return 0, 0
+	}
+	if cached, ok := getCache(trackBoundsCacheKey(trackID)); ok {
+		fmt.Sscanf(cached, "%d,%d", &introEndMs, &trackEndMs)
+		return introEndMs, trackEndMs
+	}
+
+	analysis, err := spotifyClient.AudioAnalysis(r.Context(), trackID)
+	if err != nil {
+		log.Errorf("[Estimate] Error fetching audio analysis of %s: %v", trackID, err)
+		return 0, 0
+	}
+	introEndMs, trackEndMs = introEnd(analysis), secondsToMs(analysis.Track.Duration)
+	setCache(trackBoundsCacheKey(trackID), fmt.Sprintf("%d,%d", introEndMs, trackEndMs), time.Duration(conf.Configuration.TrackCacheTTLInSeconds)*time.Second)
+	return introEndMs, trackEndMs
+}
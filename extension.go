@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"lyrics-api-go/middleware"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	// extensionSignatures is nil unless EXTENSION_SIGNING_SECRET is configured
+	extensionSignatures *middleware.ExtensionVerifier
+	// extensionLimiter limits signed extension requests per IP instead of the anonymous limiter. It
+	// is nil when EXTENSION_RATE_LIMIT_PER_SECOND is 0, leaving them to the anonymous limits.
+	extensionLimiter middleware.RateLimiter
+)
+
+// setupExtensionSignatures sets up the recognition of requests signed by the official extension
+func setupExtensionSignatures() {
+	if conf.Configuration.ExtensionSigningSecret == "" {
+		return
+	}
+	maxSkew := time.Duration(conf.Configuration.ExtensionSignatureMaxSkewInSeconds) * time.Second
+	extensionSignatures = middleware.NewExtensionVerifier(conf.Configuration.ExtensionSigningSecret, maxSkew)
+	if conf.Configuration.ExtensionRateLimitPerSecond > 0 {
+		extensionLimiter = newRateLimiter("extension", rate.Limit(conf.Configuration.ExtensionRateLimitPerSecond), conf.Configuration.ExtensionRateLimitBurstLimit)
+	}
+}
+
+type extensionSignedKey struct{}
+
+// extensionSignatureMiddleware verifies the signature of extension requests once, as their nonce
+// can only be used once, and marks them in their context
+func extensionSignatureMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if extensionSignatures == nil || r.Header.Get(middleware.ExtensionSignatureHeader) == "" || !extensionSignatures.Verify(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), extensionSignedKey{}, true)))
+	})
+}
+
+// isExtensionRequest checks whether r was signed by the official extension
+func isExtensionRequest(r *http.Request) bool {
+	signed, _ := r.Context().Value(extensionSignedKey{}).(bool)
+	return signed
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"lyrics-api-go/lyrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// instrumentalnessThreshold is the audio features instrumentalness from which a track is treated as
+// instrumental; upstream documents values above 0.5 as likely instrumental
+const instrumentalnessThreshold = 0.5
+
+// instrumentalTitlePattern matches titles of instrumental versions, e.g. "Song (Instrumental)"
+var instrumentalTitlePattern = regexp.MustCompile(`(?i)\b(instrumental|karaoke|off vocal)\b|\binst\.`)
+
+// isInstrumentalTrack reports whether a matched track without lyrics is instrumental, going by its
+// audio features when they are configured and by its title otherwise. The verdict is cached with
+// the track id.
+func isInstrumentalTrack(ctx context.Context, trackID, songName string) bool {
+	if conf.Configuration.AudioFeaturesUrl == "" {
+		return instrumentalTitlePattern.MatchString(songName)
+	}
+
+	cacheKey := fmt.Sprintf("instrumental:%s", trackID)
+	if cached, ok := getCache(cacheKey); ok {
+		return cached == "1"
+	}
+
+	features, err := spotifyClient.AudioFeatures(ctx, trackID)
+	if err != nil {
+		log.Errorf("[Instrumental] Error fetching audio features of %s: %v", trackID, err)
+		return instrumentalTitlePattern.MatchString(songName)
+	}
+
+	instrumental := features.Instrumentalness > instrumentalnessThreshold
+	value := "0"
+	if instrumental {
+		value = "1"
+	}
+	setCache(cacheKey, value, time.Duration(conf.Configuration.TrackCacheTTLInSeconds)*time.Second)
+	return instrumental
+}
+
+// serveLyricsNotFound responds for a matched track without lyrics: instrumental tracks get an empty
+// lyrics response flagged with isInstrumental, so clients can tell them apart from missing lyrics
+func serveLyricsNotFound(w http.ResponseWriter, r *http.Request, trackID, songName string) {
+	if isInstrumentalTrack(r.Context(), trackID, songName) {
+		writeLyrics(w, r, trackID, CachedLyrics{Lyrics: []lyrics.Line{}})
+		return
+	}
+	http.Error(w, "Lyrics not available for this track", http.StatusNotFound)
+}
@@ -0,0 +1,187 @@
+// Package abuse detects clients that keep failing or hitting the rate limit and bans them for a
+// cooldown that doubles with every repeated offense.
+package abuse
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Config configures a detector. Zero thresholds disable their check.
+type Config struct {
+	// Window is the period over which the responses of a client are counted
+	Window time.Duration
+	// MinRequests is the number of requests in a window before the error ratio is checked
+	MinRequests int
+	// MaxErrorRatio is the highest share of malformed requests allowed in a window
+	MaxErrorRatio float64
+	// MaxRateLimited is the highest number of 429 responses allowed in a window
+	MaxRateLimited int
+	// BanDuration is the first ban of a client, doubled with every offense up to MaxBanDuration
+	BanDuration    time.Duration
+	MaxBanDuration time.Duration
+	// OffenseMemory is how long after its last ban a client's offenses still escalate its next ban
+	OffenseMemory time.Duration
+}
+
+// malformedStatuses are the responses to requests no legitimate client sends, which count as errors.
+// Other 4xx responses, like 404s for tracks without lyrics or 403s asking for a captcha, are part
+// of normal use.
+var malformedStatuses = map[int]bool{
+	400: true, // Bad Request
+	401: true, // Unauthorized
+	405: true, // Method Not Allowed
+	413: true, // Request Entity Too Large
+	414: true, // Request URI Too Long
+	431: true, // Request Header Fields Too Large
+}
+
+// Ban is a client banned until a time
+type Ban struct {
+	Client   string    `json:"client"`
+	Until    time.Time `json:"until"`
+	Offenses int       `json:"offenses"`
+	Reason   string    `json:"reason"`
+}
+
+type client struct {
+	windowStart time.Time
+	requests    int
+	errors      int
+	rateLimited int
+
+	offenses    int
+	bannedUntil time.Time
+	reason      string
+}
+
+// Detector counts the responses of clients and bans offenders
+type Detector struct {
+	config Config
+	now    func() time.Time
+
+	mu        sync.Mutex
+	clients   map[string]*client
+	lastSweep time.Time
+}
+
+// NewDetector creates a detector
+func NewDetector(config Config) *Detector {
+	return &Detector{config: config, now: time.Now, clients: map[string]*client{}}
+}
+
+// Banned returns when the ban of a client ends, and whether it is banned
+func (d *Detector) Banned(id string) (time.Time, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c, ok := d.clients[id]
+	if !ok || !d.now().Before(c.bannedUntil) {
+		return time.Time{}, false
+	}
+	return c.bannedUntil, true
+}
+
+// Observe counts a response to a client, banning it when the response makes it an offender. It
+// returns the ban, if any.
+func (d *Detector) Observe(id string, status int) (Ban, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	d.sweep(now)
+	c, ok := d.clients[id]
+	if !ok {
+		c = &client{windowStart: now}
+		d.clients[id] = c
+	}
+	if now.Before(c.bannedUntil) {
+		return Ban{}, false
+	}
+	if now.Sub(c.windowStart) >= d.config.Window {
+		c.windowStart, c.requests, c.errors, c.rateLimited = now, 0, 0, 0
+	}
+
+	c.requests++
+	switch {
+	case status == 429:
+		c.rateLimited++
+	case malformedStatuses[status]:
+		c.errors++
+	}
+
+	var reason string
+	switch {
+	case d.config.MaxRateLimited > 0 && c.rateLimited > d.config.MaxRateLimited:
+		reason = "rate limited too often"
+	case d.config.MaxErrorRatio > 0 && c.requests >= d.config.MinRequests &&
+		float64(c.errors)/float64(c.requests) > d.config.MaxErrorRatio:
+		reason = "too many client errors"
+	default:
+		return Ban{}, false
+	}
+
+	if !c.bannedUntil.IsZero() && now.Sub(c.bannedUntil) > d.config.OffenseMemory {
+		c.offenses = 0
+	}
+	c.offenses++
+	duration := d.config.BanDuration
+	for i := 1; i < c.offenses && duration < d.config.MaxBanDuration; i++ {
+		duration *= 2
+	}
+	duration = min(duration, d.config.MaxBanDuration)
+
+	c.bannedUntil, c.reason = now.Add(duration), reason
+	c.windowStart, c.requests, c.errors, c.rateLimited = now, 0, 0, 0
+	return d.ban(id, c), true
+}
+
+// sweep forgets clients with nothing left to remember, at most once per window. The detector must
+// be locked.
+func (d *Detector) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.config.Window {
+		return
+	}
+	d.lastSweep = now
+	for id, c := range d.clients {
+		idle := now.Sub(c.windowStart) >= d.config.Window
+		forgiven := c.bannedUntil.IsZero() || now.Sub(c.bannedUntil) > d.config.OffenseMemory
+		if idle && forgiven {
+			delete(d.clients, id)
+		}
+	}
+}
+
+func (d *Detector) ban(id string, c *client) Ban {
+	return Ban{Client: id, Until: c.bannedUntil, Offenses: c.offenses, Reason: c.reason}
+}
+
+// Bans returns the current bans, ending soonest first
+func (d *Detector) Bans() []Ban {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	bans := []Ban{}
+	for id, c := range d.clients {
+		if now.Before(c.bannedUntil) {
+			bans = append(bans, d.ban(id, c))
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Until.Before(bans[j].Until) })
+	return bans
+}
+
+// Lift ends the ban of a client and forgets its offenses, reporting whether it was banned
+func (d *Detector) Lift(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c, ok := d.clients[id]
+	if !ok || !d.now().Before(c.bannedUntil) {
+		return false
+	}
+	delete(d.clients, id)
+	return true
+}
@@ -0,0 +1,118 @@
+package abuse
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestDetector() (*Detector, *time.Time) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	detector := NewDetector(Config{
+		Window: time.Minute, MinRequests: 10, MaxErrorRatio: 0.5, MaxRateLimited: 5,
+		BanDuration: time.Minute, MaxBanDuration: 3 * time.Minute, OffenseMemory: time.Hour,
+	})
+	detector.now = func() time.Time { return now }
+	return detector, &now
+}
+
+func TestBanForRateLimits(t *testing.T) {
+	detector, now := newTestDetector()
+
+	for i := 0; i < 5; i++ {
+		if _, banned := detector.Observe("1.2.3.4", 429); banned {
+			t.Fatalf("Expected no ban after %d rate limited requests", i+1)
+		}
+	}
+	ban, banned := detector.Observe("1.2.3.4", 429)
+	if !banned || ban.Until != now.Add(time.Minute) || ban.Reason != "rate limited too often" {
+		t.Fatalf("Expected a one minute ban, got %+v, %v", ban, banned)
+	}
+	if _, banned := detector.Banned("1.2.3.4"); !banned {
+		t.Errorf("Expected the client to be banned")
+	}
+	if _, banned := detector.Banned("5.6.7.8"); banned {
+		t.Errorf("Expected other clients not to be banned")
+	}
+
+	*now = now.Add(time.Minute)
+	if _, banned := detector.Banned("1.2.3.4"); banned {
+		t.Errorf("Expected the ban to be over")
+	}
+}
+
+func TestBanForErrorRatio(t *testing.T) {
+	detector, _ := newTestDetector()
+
+	for i := 0; i < 4; i++ {
+		detector.Observe("1.2.3.4", 400)
+	}
+	if _, banned := detector.Observe("1.2.3.4", 401); banned {
+		t.Errorf("Expected no ban before the minimum number of requests")
+	}
+	for i := 0; i < 4; i++ {
+		detector.Observe("1.2.3.4", 200)
+	}
+	if _, banned := detector.Observe("1.2.3.4", 200); banned {
+		t.Errorf("Expected no ban at half errors")
+	}
+	if _, banned := detector.Observe("1.2.3.4", 400); !banned {
+		t.Errorf("Expected a ban above half errors")
+	}
+}
+
+func TestIgnoresNormalErrors(t *testing.T) {
+	detector, _ := newTestDetector()
+
+	// lookups of tracks without lyrics and captcha challenges aren't abusive
+	for i := 0; i < 20; i++ {
+		for _, status := range []int{404, 403} {
+			if _, banned := detector.Observe("1.2.3.4", status); banned {
+				t.Fatalf("Expected no ban for %d responses", status)
+			}
+		}
+	}
+}
+
+func TestBansEscalate(t *testing.T) {
+	detector, now := newTestDetector()
+	offend := func() Ban {
+		for {
+			if ban, banned := detector.Observe("1.2.3.4", 429); banned {
+				return ban
+			}
+		}
+	}
+
+	want := []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute}
+	for i, duration := range want {
+		ban := offend()
+		if ban.Until.Sub(*now) != duration || ban.Offenses != i+1 {
+			t.Errorf("Expected offense %d to be banned for %v, got %v (%d offenses)", i+1, duration, ban.Until.Sub(*now), ban.Offenses)
+		}
+		*now = ban.Until
+	}
+
+	*now = now.Add(2 * time.Hour)
+	if ban := offend(); ban.Offenses != 1 {
+		t.Errorf("Expected offenses to be forgotten, got %d", ban.Offenses)
+	}
+}
+
+func TestLift(t *testing.T) {
+	detector, _ := newTestDetector()
+	for i := 0; i < 6; i++ {
+		detector.Observe("1.2.3.4", 429)
+	}
+	if bans := detector.Bans(); len(bans) != 1 || bans[0].Client != "1.2.3.4" {
+		t.Fatalf("Expected one ban, got %v", bans)
+	}
+	if !detector.Lift("1.2.3.4") {
+		t.Errorf("Expected the ban to be lifted")
+	}
+	if detector.Lift("1.2.3.4") {
+		t.Errorf("Expected nothing to lift")
+	}
+	if len(detector.Bans()) != 0 {
+		t.Errorf("Expected no bans, got %v", detector.Bans())
+	}
+}
@@ -0,0 +1,105 @@
+package adminauth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	verifier := NewVerifier("secret", 5*time.Minute)
+
+	req := httptest.NewRequest("POST", "/cache/flush?prefix=lyrics:", strings.NewReader(`{"a":1}`))
+	if err := Sign(req, "secret"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !Signed(req) {
+		t.Fatalf("Expected the request to be signed")
+	}
+	if err := verifier.Verify(req); err != nil {
+		t.Fatalf("Expected the signature to verify, got %v", err)
+	}
+	if err := verifier.Verify(req); err != ErrReplayed {
+		t.Errorf("Expected a replay to be rejected, got %v", err)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != `{"a":1}` {
+		t.Errorf("Expected the body to be readable after verifying, got %q", body)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	signed := func(secret string) *http.Request {
+		req := httptest.NewRequest("DELETE", "/cache/lyrics%253Aabc?prefix=lyrics:", nil)
+		Sign(req, secret)
+		return req
+	}
+	tampered := signed("secret")
+	tampered.URL.RawQuery = "prefix="
+	forged := signed("secret")
+	forged.Header.Set(TimestampHeader, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+	oversized := httptest.NewRequest("POST", "/cache/flush", strings.NewReader(strings.Repeat("a", MaxBodyBytes+1)))
+	Sign(oversized, "secret")
+
+	tests := []struct {
+		name     string
+		req      *http.Request
+		verifyAt time.Time
+		want     error
+	}{
+		{"Wrong secret", signed("other"), time.Now(), ErrInvalidSignature},
+		{"Tampered query", tampered, time.Now(), ErrInvalidSignature},
+		{"Changed timestamp", forged, time.Now(), ErrInvalidSignature},
+		{"Stale timestamp", signed("secret"), time.Now().Add(10 * time.Minute), ErrExpired},
+		{"Unsigned", httptest.NewRequest("GET", "/cache", nil), time.Now(), ErrMissing},
+		{"Oversized body", oversized, time.Now(), ErrBodyTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := NewVerifier("secret", 5*time.Minute)
+			verifier.now = func() time.Time { return tt.verifyAt }
+			if err := verifier.Verify(tt.req); err != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestVerifyForgetsExpiredNonces(t *testing.T) {
+	verifier := NewVerifier("secret", time.Minute)
+	now := time.Now()
+	verifier.now = func() time.Time { return now }
+
+	req := httptest.NewRequest("GET", "/cache", nil)
+	Sign(req, "secret")
+	verifier.Verify(req)
+
+	now = now.Add(2 * time.Minute)
+	other := httptest.NewRequest("GET", "/cache", nil)
+	sign(other, "secret", now)
+	if err := verifier.Verify(other); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(verifier.nonces) != 1 {
+		t.Errorf("Expected nonces past the skew to be forgotten, got %d", len(verifier.nonces))
+	}
+}
+
+func TestSetSecret(t *testing.T) {
+	verifier := NewVerifier("old", time.Minute)
+	verifier.SetSecret("new")
+
+	old := httptest.NewRequest("GET", "/cache", nil)
+	Sign(old, "old")
+	if err := verifier.Verify(old); err != ErrInvalidSignature {
+		t.Errorf("Expected the previous secret to be rejected, got %v", err)
+	}
+	rotated := httptest.NewRequest("GET", "/cache", nil)
+	Sign(rotated, "new")
+	if err := verifier.Verify(rotated); err != nil {
+		t.Errorf("Expected the new secret to verify, got %v", err)
+	}
+}
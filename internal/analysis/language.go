@@ -0,0 +1,18 @@
+// Package analysis holds language and text analysis of lyrics.
+package analysis
+
+import "strings"
+
+// SameLanguage reports whether two language tags share the same primary language subtag,
+// e.g. "en" and "en-US"
+func SameLanguage(a, b string) bool {
+	return primarySubtag(a) != "" && primarySubtag(a) == primarySubtag(b)
+}
+
+func primarySubtag(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag
+}
@@ -0,0 +1,22 @@
+package analysis
+
+import "testing"
+
+func TestSameLanguage(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"en", "en", true},
+		{"en", "EN-us", true},
+		{"pt_BR", "pt", true},
+		{"en", "es", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		if got := SameLanguage(tt.a, tt.b); got != tt.want {
+			t.Errorf("SameLanguage(%q, %q): expected %v, got %v", tt.a, tt.b, tt.want, got)
+		}
+	}
+}
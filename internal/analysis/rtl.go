@@ -0,0 +1,20 @@
+package analysis
+
+// rtlLanguages are the languages written right-to-left
+var rtlLanguages = map[string]bool{
+	"ar": true, // Arabic
+	"fa": true, // Persian (Farsi)
+	"he": true, // Hebrew
+	"ur": true, // Urdu
+	"ps": true, // Pashto
+	"sd": true, // Sindhi
+	"ug": true, // Uyghur
+	"yi": true, // Yiddish
+	"ku": true, // Kurdish (some dialects)
+	"dv": true, // Divehi (Maldivian)
+}
+
+// IsRTLLanguage reports whether the language is written right-to-left
+func IsRTLLanguage(langCode string) bool {
+	return rtlLanguages[primarySubtag(langCode)]
+}
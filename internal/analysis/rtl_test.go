@@ -0,0 +1,22 @@
+package analysis
+
+import "testing"
+
+func TestIsRTLLanguage(t *testing.T) {
+	tests := []struct {
+		lang string
+		want bool
+	}{
+		{"ar", true},
+		{"he", true},
+		{"fa-IR", true},
+		{"en", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRTLLanguage(tt.lang); got != tt.want {
+			t.Errorf("IsRTLLanguage(%q): expected %v, got %v", tt.lang, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+// Package apikeys issues and validates API keys, each with its own scopes, rate limit and daily quota.
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	ErrRateLimited   = errors.New("rate limit of the API key exceeded")
+	ErrQuotaExceeded = errors.New("daily quota of the API key exceeded")
+	ErrUnknownKey    = errors.New("unknown API key")
+	ErrNotIssued     = errors.New("configured API keys can't be revoked")
+)
+
+// secretPrefix marks issued secrets, so leaked ones are easy to spot in logs and repositories
+const secretPrefix = "bl_"
+
+// Key is an API key, without its secret
+type Key struct {
+	// ID identifies the key in listings and revocations without revealing it
+	ID     string   `json:"id"`
+	Name   string   `json:"name,omitempty"`
+	Scopes []string `json:"scopes"`
+	// RatePerSecond and Burst limit the requests of the key in place of the per-IP limit; without a
+	// rate, requests with the key are limited per IP like anonymous ones
+	RatePerSecond float64 `json:"ratePerSecond,omitempty"`
+	Burst         int     `json:"burst,omitempty"`
+	// DailyQuota caps the requests of the key per UTC day, zero is unlimited
+	DailyQuota int `json:"dailyQuota,omitempty"`
+	// Issued is set on keys issued at runtime, which are persisted, rather than configured
+	Issued bool `json:"issued"`
+	// CreatedAt is when an issued key was issued
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	// Hash is the SHA-256 of the secret, the only form the secret is kept in
+	Hash string `json:"hash,omitempty"`
+}
+
+// Decision is how a request made with a key was counted
+type Decision struct {
+	// Limited reports whether the key has its own rate limit, replacing the per-IP one; Limit,
+	// Remaining and Reset describe it then
+	Limited   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration
+	// Quota is the daily quota of the key, zero for none; QuotaRemaining is what is left of it today,
+	// unless the request was rate limited before being counted, and QuotaReset the time until it
+	// resets at midnight UTC
+	Quota          int
+	QuotaRemaining int
+	QuotaReset     time.Duration
+	// RetryAfter is how long until the key may make another request, when this one was refused
+	RetryAfter time.Duration
+}
+
+// Store keeps the rate limits and daily usage of keys outside of the registry, e.g. in Redis so
+// every instance shares them
+type Store interface {
+	// AllowRate counts a request of the key with id against a limit of ratePerSecond with burst,
+	// returning whether it is allowed, the requests remaining right away, how long until the burst
+	// is available again and, when refused, until the next request is allowed
+	AllowRate(id string, ratePerSecond float64, burst int) (allowed bool, remaining int, reset, retryAfter time.Duration, err error)
+	// UseQuota counts a request of the key with id against quota on day, a UTC date whose count
+	// expires at expiresAt, returning whether it is within the quota and the requests counted
+	UseQuota(id, day string, quota int, expiresAt time.Time) (allowed bool, used int, err error)
+}
+
+type entry struct {
+	key     Key
+	limiter *rate.Limiter
+	// day is the UTC day used counts the requests of
+	day  string
+	used int
+}
+
+// Registry holds the API keys, keyed by the hash of their secret
+type Registry struct {
+	mu   sync.Mutex
+	keys map[string]*entry
+	now  func() time.Time
+	// store keeps the limits and usage of keys when set, and onError is called with its errors,
+	// after which the registry counts the request in memory
+	store   Store
+	onError func(err error)
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{keys: map[string]*entry{}, now: time.Now}
+}
+
+// SetStore keeps the rate limits and daily usage of keys in store instead of in memory, which is
+// only used while store fails. onError, if set, is called with its errors.
+func (r *Registry) SetStore(store Store, onError func(err error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.store, r.onError = store, onError
+}
+
+// HashSecret returns the hash an API key secret is stored under
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Add registers a configured key with its secret
+func (r *Registry) Add(secret string, key Key) error {
+	if secret == "" {
+		return fmt.Errorf("empty API key")
+	}
+	key.Hash = HashSecret(secret)
+	key.Issued = false
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.keys[key.Hash]; ok {
+		return fmt.Errorf("duplicate API key")
+	}
+	r.add(key)
+	return nil
+}
+
+// Issue creates a key with a new random secret, returning the secret, which isn't kept, and the key
+func (r *Registry) Issue(key Key) (string, Key, error) {
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return "", Key{}, fmt.Errorf("error generating API key: %v", err)
+	}
+	secret := secretPrefix + hex.EncodeToString(random)
+	key.Hash = HashSecret(secret)
+	key.Issued = true
+	createdAt := r.now().UTC()
+	key.CreatedAt = &createdAt
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return secret, r.add(key), nil
+}
+
+// add stores key, taking its ID from its hash. The registry must be locked.
+func (r *Registry) add(key Key) Key {
+	key.ID = key.Hash[:12]
+	e := &entry{key: key}
+	if key.RatePerSecond > 0 {
+		e.limiter = rate.NewLimiter(rate.Limit(key.RatePerSecond), max(key.Burst, 1))
+	}
+	r.keys[key.Hash] = e
+	return key
+}
+
+// Revoke removes the issued key with id
+func (r *Registry) Revoke(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for hash, e := range r.keys {
+		if e.key.ID != id {
+			continue
+		}
+		if !e.key.Issued {
+			return ErrNotIssued
+		}
+		delete(r.keys, hash)
+		return nil
+	}
+	return ErrUnknownKey
+}
+
+// Lookup returns the key of a secret
+func (r *Registry) Lookup(secret string) (Key, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.keys[HashSecret(secret)]
+	if !ok {
+		return Key{}, false
+	}
+	return e.key, true
+}
+
+// Allow counts a request made with secret against the rate limit and daily quota of its key. When
+// the request is refused, the decision's RetryAfter is how long until the key may make another.
+// Unknown secrets are left to the scope checks and never refused here.
+func (r *Registry) Allow(secret string) (Decision, error) {
+	r.mu.Lock()
+	e, ok := r.keys[HashSecret(secret)]
+	var key Key
+	if ok {
+		key = e.key
+	}
+	store, onError := r.store, r.onError
+	r.mu.Unlock()
+	if !ok {
+		return Decision{}, nil
+	}
+
+	now := r.now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	decision := Decision{Limited: key.RatePerSecond > 0, Quota: key.DailyQuota, QuotaReset: midnight.Sub(now)}
+
+	if decision.Limited {
+		decision.Limit = max(key.Burst, 1)
+		allowed, ok := false, false
+		if store != nil {
+			var err error
+			allowed, decision.Remaining, decision.Reset, decision.RetryAfter, err = store.AllowRate(key.ID, key.RatePerSecond, decision.Limit)
+			if ok = err == nil; !ok && onError != nil {
+				onError(err)
+			}
+		}
+		if !ok {
+			allowed = r.allowRateLocally(e, now, &decision)
+		}
+		if !allowed {
+			return decision, ErrRateLimited
+		}
+	}
+
+	if key.DailyQuota > 0 {
+		allowed, used, ok := false, 0, false
+		if store != nil {
+			var err error
+			allowed, used, err = store.UseQuota(key.ID, now.Format(time.DateOnly), key.DailyQuota, midnight)
+			if ok = err == nil; !ok && onError != nil {
+				onError(err)
+			}
+		}
+		if !ok {
+			allowed, used = r.useQuotaLocally(e, now)
+		}
+		decision.QuotaRemaining = max(key.DailyQuota-used, 0)
+		if !allowed {
+			decision.RetryAfter = decision.QuotaReset
+			return decision, ErrQuotaExceeded
+		}
+	}
+	return decision, nil
+}
+
+// allowRateLocally counts a request against the in-memory rate limit of e
+func (r *Registry) allowRateLocally(e *entry, now time.Time, decision *Decision) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	allowed := e.limiter.AllowN(now, 1)
+	if !allowed {
+		decision.RetryAfter = time.Duration(math.Ceil(float64(time.Second) / e.key.RatePerSecond))
+	}
+	tokens := e.limiter.TokensAt(now)
+	if missing := float64(decision.Limit) - tokens; missing > 0 {
+		decision.Reset = time.Duration(missing / e.key.RatePerSecond * float64(time.Second))
+	}
+	decision.Remaining = max(int(math.Floor(tokens)), 0)
+	return allowed
+}
+
+// useQuotaLocally counts a request against the in-memory daily usage of e
+func (r *Registry) useQuotaLocally(e *entry, now time.Time) (allowed bool, used int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if day := now.Format(time.DateOnly); day != e.day {
+		e.day, e.used = day, 0
+	}
+	if e.used >= e.key.DailyQuota {
+		return false, e.used
+	}
+	e.used++
+	return true, e.used
+}
+
+// List returns every key without its hash, ordered by ID
+func (r *Registry) List() []Key {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := make([]Key, 0, len(r.keys))
+	for _, e := range r.keys {
+		key := e.key
+		key.Hash = ""
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// Snapshot returns the issued keys with their hashes, for persisting
+func (r *Registry) Snapshot() []Key {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var keys []Key
+	for _, e := range r.keys {
+		if e.key.Issued {
+			keys = append(keys, e.key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// Restore adds persisted issued keys, skipping those without a hash
+func (r *Registry) Restore(keys []Key) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range keys {
+		if len(key.Hash) < 12 {
+			continue
+		}
+		key.Issued = true
+		r.add(key)
+	}
+}
@@ -0,0 +1,180 @@
+package apikeys
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIssueAndLookup(t *testing.T) {
+	registry := NewRegistry()
+	secret, key, err := registry.Issue(Key{Name: "bot", Scopes: []string{"lyrics:read"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.HasPrefix(secret, secretPrefix) || strings.Contains(key.Hash, secret) {
+		t.Errorf("Expected a prefixed secret kept only as a hash, got %q and %q", secret, key.Hash)
+	}
+
+	found, ok := registry.Lookup(secret)
+	if !ok || found.Name != "bot" || !found.Issued {
+		t.Errorf("Expected to find the issued key, got %+v %v", found, ok)
+	}
+	if _, ok := registry.Lookup("bl_unknown"); ok {
+		t.Errorf("Expected an unknown secret not to be found")
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	registry := NewRegistry()
+	secret, key, _ := registry.Issue(Key{})
+	registry.Add("configured", Key{})
+
+	if err := registry.Revoke(key.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, ok := registry.Lookup(secret); ok {
+		t.Errorf("Expected the revoked key not to be found")
+	}
+	configured, _ := registry.Lookup("configured")
+	if err := registry.Revoke(configured.ID); err != ErrNotIssued {
+		t.Errorf("Expected ErrNotIssued, got %v", err)
+	}
+	if err := registry.Revoke("missing"); err != ErrUnknownKey {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestAllowDailyQuota(t *testing.T) {
+	registry := NewRegistry()
+	now := time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC)
+	registry.now = func() time.Time { return now }
+	registry.Add("key", Key{DailyQuota: 2})
+
+	for i := 0; i < 2; i++ {
+		decision, err := registry.Allow("key")
+		if err != nil {
+			t.Fatalf("Expected request %d to be allowed, got %v", i+1, err)
+		}
+		if decision.Quota != 2 || decision.QuotaRemaining != 1-i || decision.QuotaReset != time.Hour {
+			t.Errorf("Expected %d of the quota to remain until midnight, got %+v", 1-i, decision)
+		}
+	}
+	decision, err := registry.Allow("key")
+	if err != ErrQuotaExceeded || decision.RetryAfter != time.Hour || decision.Limited {
+		t.Errorf("Expected the quota to be exceeded until midnight, got %+v %v", decision, err)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := registry.Allow("key"); err != nil {
+		t.Errorf("Expected the quota to reset the next day, got %v", err)
+	}
+}
+
+func TestAllowRateLimit(t *testing.T) {
+	registry := NewRegistry()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	registry.now = func() time.Time { return now }
+	registry.Add("key", Key{RatePerSecond: 2, Burst: 1})
+
+	if decision, err := registry.Allow("key"); !decision.Limited || err != nil {
+		t.Fatalf("Expected the first request to be allowed by the key limit, got %+v %v", decision, err)
+	}
+	decision, err := registry.Allow("key")
+	if err != ErrRateLimited || decision.RetryAfter != 500*time.Millisecond {
+		t.Errorf("Expected the second request to be rate limited for 500ms, got %+v %v", decision, err)
+	}
+	if decision.Limit != 1 || decision.Remaining != 0 || decision.Reset != 500*time.Millisecond {
+		t.Errorf("Expected no request remaining for 500ms, got %+v", decision)
+	}
+
+	now = now.Add(time.Second)
+	if _, err := registry.Allow("key"); err != nil {
+		t.Errorf("Expected a request a second later to be allowed, got %v", err)
+	}
+	if decision, err := registry.Allow("unknown"); decision.Limited || err != nil {
+		t.Errorf("Expected unknown keys to be left to the per-IP limit, got %+v %v", decision, err)
+	}
+}
+
+// fakeStore counts requests in maps, failing with err when set
+type fakeStore struct {
+	rates  map[string]int
+	quotas map[string]int
+	err    error
+}
+
+func (s *fakeStore) AllowRate(id string, ratePerSecond float64, burst int) (bool, int, time.Duration, time.Duration, error) {
+	if s.err != nil {
+		return false, 0, 0, 0, s.err
+	}
+	s.rates[id]++
+	if s.rates[id] > burst {
+		return false, 0, time.Second, time.Second, nil
+	}
+	return true, burst - s.rates[id], time.Second, 0, nil
+}
+
+func (s *fakeStore) UseQuota(id, day string, quota int, expiresAt time.Time) (bool, int, error) {
+	if s.err != nil {
+		return false, 0, s.err
+	}
+	if s.quotas[id+":"+day] >= quota {
+		return false, s.quotas[id+":"+day], nil
+	}
+	s.quotas[id+":"+day]++
+	return true, s.quotas[id+":"+day], nil
+}
+
+func TestAllowWithStore(t *testing.T) {
+	store := &fakeStore{rates: map[string]int{}, quotas: map[string]int{}}
+	var errs []error
+	registry := NewRegistry()
+	registry.SetStore(store, func(err error) { errs = append(errs, err) })
+	registry.Add("key", Key{RatePerSecond: 1, Burst: 2, DailyQuota: 10})
+	key, _ := registry.Lookup("key")
+
+	decision, err := registry.Allow("key")
+	if err != nil || !decision.Limited || decision.Remaining != 1 || decision.QuotaRemaining != 9 {
+		t.Errorf("Expected the request to be counted by the store, got %+v %v", decision, err)
+	}
+	registry.Allow("key")
+	if _, err := registry.Allow("key"); err != ErrRateLimited {
+		t.Errorf("Expected the store's rate limit to apply, got %v", err)
+	}
+	if store.rates[key.ID] != 3 || len(store.quotas) != 1 {
+		t.Errorf("Expected the limits to be kept by the store, got %v %v", store.rates, store.quotas)
+	}
+
+	// the registry counts requests in memory while the store fails
+	store.err = errors.New("connection refused")
+	if decision, err := registry.Allow("key"); err != nil || decision.QuotaRemaining != 9 {
+		t.Errorf("Expected the request to be counted in memory, got %+v %v", decision, err)
+	}
+	if len(errs) != 2 {
+		t.Errorf("Expected the errors of the store to be reported, got %v", errs)
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	registry := NewRegistry()
+	secret, _, _ := registry.Issue(Key{Name: "app", DailyQuota: 100})
+	registry.Add("configured", Key{})
+
+	snapshot := registry.Snapshot()
+	if len(snapshot) != 1 {
+		t.Fatalf("Expected only the issued key to be persisted, got %d keys", len(snapshot))
+	}
+
+	restored := NewRegistry()
+	restored.Restore(snapshot)
+	if key, ok := restored.Lookup(secret); !ok || key.DailyQuota != 100 {
+		t.Errorf("Expected the restored key with its quota, got %+v %v", key, ok)
+	}
+	for _, key := range restored.List() {
+		if key.Hash != "" {
+			t.Errorf("Expected listings without hashes, got %q", key.Hash)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Entry is a single cached value. Version increases monotonically on every write
+// or delete of any key, so it can be used for compare-and-set. It is local to the
+// instance, so it is left out of dumps.
+type Entry struct {
+	Value      []byte
+	Expiration int64
+	Version    uint64 `json:"-"`
+}
+
+// Expired reports whether the entry has expired at the given time
+func (e Entry) Expired(now time.Time) bool {
+	return now.UnixNano() > e.Expiration
+}
+
+// Cache is an in-memory key/value store with per-entry expiration and versioned writes.
+// Reads are lock-free; writes and deletes are serialized so compare-and-set is atomic.
+// Caches created with Open are also persisted to disk.
+type Cache struct {
+	entries    sync.Map
+	mu         sync.Mutex
+	version    atomic.Uint64
+	tombstones map[string]uint64
+	// purgedVersion is the version at the last purge, tombstones up to it are dropped by the next
+	purgedVersion uint64
+	// disk is nil unless the cache is persisted to disk, see Open
+	disk *disk
+	// onExpire is called with the expired entries deleted when read, see OnExpire
+	onExpire func(key string)
+}
+
+// New creates an empty cache
+func New() *Cache {
+	return &Cache{
+		tombstones: make(map[string]uint64),
+	}
+}
+
+// Get returns the entry for key if present and not expired. Expired entries are deleted.
+func (c *Cache) Get(key string) (Entry, bool) {
+	value, ok := c.entries.Load(key)
+	if !ok {
+		return Entry{}, false
+	}
+	entry := value.(Entry)
+	if entry.Expired(time.Now()) {
+		if c.deleteIfVersion(key, entry.Version) && c.onExpire != nil {
+			c.onExpire(key)
+		}
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// Version returns the current version of key, including the version of its deletion if it
+// was deleted. Pass it to CompareAndSet to only write if nothing changed in the meantime.
+func (c *Cache) Version(key string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.versionLocked(key)
+}
+
+func (c *Cache) versionLocked(key string) uint64 {
+	if value, ok := c.entries.Load(key); ok {
+		return value.(Entry).Version
+	}
+	return c.tombstones[key]
+}
+
+// Set unconditionally stores value under key and returns the new version
+func (c *Cache) Set(key string, value []byte, duration time.Duration) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.storeLocked(key, value, duration)
+}
+
+// CompareAndSet stores value under key only if the key's version still equals version.
+// It reports whether the value was stored.
+func (c *Cache) CompareAndSet(key string, value []byte, duration time.Duration, version uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.versionLocked(key) != version {
+		return false
+	}
+	c.storeLocked(key, value, duration)
+	return true
+}
+
+func (c *Cache) storeLocked(key string, value []byte, duration time.Duration) uint64 {
+	version := c.version.Add(1)
+	entry := Entry{
+		Value:      value,
+		Expiration: time.Now().Add(duration).UnixNano(),
+		Version:    version,
+	}
+	c.entries.Store(key, entry)
+	delete(c.tombstones, key)
+	if c.disk != nil {
+		c.disk.markDirty(key)
+	}
+	return version
+}
+
+// Delete removes key, recording a tombstone so in-flight writers holding an older version fail
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleteLocked(key)
+}
+
+// deleteIfVersion deletes key if its version still equals version, reporting whether it did
+func (c *Cache) deleteIfVersion(key string, version uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.versionLocked(key) != version {
+		return false
+	}
+	c.deleteLocked(key)
+	return true
+}
+
+// OnExpire sets fn to be called with every expired entry deleted when read, the expired entries
+// PurgeExpired doesn't see. It must be set before the cache is used.
+func (c *Cache) OnExpire(fn func(key string)) {
+	c.onExpire = fn
+}
+
+func (c *Cache) deleteLocked(key string) {
+	c.entries.Delete(key)
+	c.tombstones[key] = c.version.Add(1)
+	if c.disk != nil {
+		c.disk.markDirty(key)
+	}
+}
+
+// Range calls fn for every stored entry, including expired entries not yet purged
+func (c *Cache) Range(fn func(key string, entry Entry) bool) {
+	c.entries.Range(func(key, value interface{}) bool {
+		return fn(key.(string), value.(Entry))
+	})
+}
+
+// PurgeExpired deletes all expired entries, calling onDelete for each, and drops tombstones
+// recorded before the previous purge so they don't accumulate. Tombstones recorded since are
+// kept, so writers that read a version before a recent deletion still fail.
+func (c *Cache) PurgeExpired(onDelete func(key string)) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, version := range c.tombstones {
+		if version <= c.purgedVersion {
+			delete(c.tombstones, key)
+		}
+	}
+	c.purgedVersion = c.version.Load()
+	c.entries.Range(func(key, value interface{}) bool {
+		if value.(Entry).Expired(now) {
+			c.entries.Delete(key)
+			if c.disk != nil {
+				c.disk.markDirty(key.(string))
+			}
+			if onDelete != nil {
+				onDelete(key.(string))
+			}
+		}
+		return true
+	})
+}
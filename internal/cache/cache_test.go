@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetAndGet(t *testing.T) {
+	c := New()
+	c.Set("key", []byte("value"), time.Minute)
+
+	entry, ok := c.Get("key")
+	if !ok {
+		t.Fatalf("Expected key to be present")
+	}
+	if string(entry.Value) != "value" {
+		t.Errorf("Expected value %q, got %q", "value", entry.Value)
+	}
+}
+
+func TestGetExpired(t *testing.T) {
+	c := New()
+	c.Set("key", []byte("value"), -time.Second)
+
+	if _, ok := c.Get("key"); ok {
+		t.Errorf("Expected expired key to be missing")
+	}
+}
+
+func TestCompareAndSet(t *testing.T) {
+	c := New()
+	version := c.Version("key")
+
+	if !c.CompareAndSet("key", []byte("first"), time.Minute, version) {
+		t.Fatalf("Expected first write to succeed")
+	}
+	if c.CompareAndSet("key", []byte("stale"), time.Minute, version) {
+		t.Errorf("Expected write with stale version to fail")
+	}
+
+	entry, _ := c.Get("key")
+	if string(entry.Value) != "first" {
+		t.Errorf("Expected value %q, got %q", "first", entry.Value)
+	}
+}
+
+func TestCompareAndSetAfterDelete(t *testing.T) {
+	c := New()
+	c.Set("key", []byte("old"), time.Minute)
+	version := c.Version("key")
+
+	// a purge happens while a writer is fetching with the old version
+	c.Delete("key")
+
+	if c.CompareAndSet("key", []byte("refetched"), time.Minute, version) {
+		t.Errorf("Expected write to fail after the key was deleted")
+	}
+	if _, ok := c.Get("key"); ok {
+		t.Errorf("Expected deleted key to stay deleted")
+	}
+}
+
+func TestPurgeExpired(t *testing.T) {
+	c := New()
+	c.Set("expired", []byte("value"), -time.Second)
+	c.Set("fresh", []byte("value"), time.Minute)
+
+	var deleted []string
+	c.PurgeExpired(func(key string) {
+		deleted = append(deleted, key)
+	})
+
+	if len(deleted) != 1 || deleted[0] != "expired" {
+		t.Errorf("Expected only the expired key to be purged, got %v", deleted)
+	}
+	if _, ok := c.Get("fresh"); !ok {
+		t.Errorf("Expected fresh key to survive purge")
+	}
+}
+
+func TestPurgeExpiredKeepsRecentTombstones(t *testing.T) {
+	c := New()
+	c.Set("key", []byte("old"), time.Minute)
+	version := c.Version("key")
+	c.Delete("key")
+
+	// the purge right after the deletion keeps its tombstone
+	c.PurgeExpired(nil)
+	if c.CompareAndSet("key", []byte("refetched"), time.Minute, version) {
+		t.Errorf("Expected write to fail after the key was deleted and purged")
+	}
+
+	c.PurgeExpired(nil)
+	if version := c.Version("key"); version != 0 {
+		t.Errorf("Expected the tombstone to be dropped by the next purge, got version %d", version)
+	}
+}
+
+func TestEntryVersionNotEncoded(t *testing.T) {
+	c := New()
+	c.Set("key", []byte("value"), time.Minute)
+	entry, _ := c.Get("key")
+	encoded, _ := json.Marshal(entry)
+	if strings.Contains(string(encoded), "Version") {
+		t.Errorf("Expected the version to be left out, got %s", encoded)
+	}
+}
+
+func TestOnExpire(t *testing.T) {
+	c := New()
+	var expired []string
+	c.OnExpire(func(key string) { expired = append(expired, key) })
+	c.Set("expired", []byte("value"), -time.Second)
+	c.Set("live", []byte("value"), time.Minute)
+
+	c.Get("expired")
+	c.Get("expired")
+	c.Get("live")
+	if len(expired) != 1 || expired[0] != "expired" {
+		t.Errorf("Expected a single expiry of the expired key, got %v", expired)
+	}
+}
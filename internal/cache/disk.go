@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// diskBucket is the bucket of the entries in the database of a persisted cache
+var diskBucket = []byte("entries")
+
+// disk persists a cache to a bbolt database. Writes and deletions only mark their key dirty; a
+// background loop writes the current entry of the dirty keys in a single transaction, so the cache's
+// lock is never held during disk I/O and bursts of writes to a key are coalesced.
+type disk struct {
+	db        *bolt.DB
+	transient func(key string) bool
+
+	mu      sync.Mutex
+	dirty   map[string]struct{}
+	wake    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// Open creates a cache persisted to the bbolt database at path, so entries survive restarts. The
+// entries in the database are loaded, skipping expired ones. Keys for which transient reports true,
+// such as unencrypted credentials, are kept in memory only. A journal file of an older version at
+// path is moved aside to path.journal, starting with an empty cache.
+func Open(path string, transient func(key string) bool) (*Cache, error) {
+	db, err := openDB(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := New()
+	now := time.Now()
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(diskBucket)
+		if err != nil {
+			return err
+		}
+		var dropped [][]byte
+		err = bucket.ForEach(func(key, value []byte) error {
+			entry, ok := decodeDiskEntry(value)
+			if !ok || entry.Expired(now) || (transient != nil && transient(string(key))) {
+				dropped = append(dropped, append([]byte(nil), key...))
+				return nil
+			}
+			entry.Version = c.version.Add(1)
+			c.entries.Store(string(key), entry)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range dropped {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error loading %s: %w", path, err)
+	}
+
+	c.disk = &disk{
+		db:        db,
+		transient: transient,
+		dirty:     map[string]struct{}{},
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	go c.disk.run(c)
+	return c, nil
+}
+
+// openDB opens the database at path, moving a journal file of an older version out of the way
+func openDB(path string) (*bolt.DB, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if !errors.Is(err, bolt.ErrInvalid) {
+		return db, err
+	}
+	log.Warnf("[Cache:Disk] %s is not a cache database, moving it to %s.journal", path, path)
+	if err := os.Rename(path, path+".journal"); err != nil {
+		return nil, err
+	}
+	return bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+}
+
+// Close writes the pending entries of a persisted cache to disk and closes its database. The cache
+// must not be written afterwards.
+func (c *Cache) Close() error {
+	if c.disk == nil {
+		return nil
+	}
+	close(c.disk.done)
+	<-c.disk.stopped
+	return c.disk.db.Close()
+}
+
+// markDirty schedules the current entry of key to be written to disk
+func (d *disk) markDirty(key string) {
+	if d.transient != nil && d.transient(key) {
+		return
+	}
+	d.mu.Lock()
+	d.dirty[key] = struct{}{}
+	d.mu.Unlock()
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run writes the dirty keys whenever there are some, until the cache is closed
+func (d *disk) run(c *Cache) {
+	defer close(d.stopped)
+	for {
+		select {
+		case <-d.wake:
+			d.flush(c)
+		case <-d.done:
+			d.flush(c)
+			return
+		}
+	}
+}
+
+// flush writes the current entry of every dirty key, or deletes it when it is gone. Entries are read
+// when written, so a key written again in the meantime is simply dirty again. Keys that failed to be
+// written stay dirty.
+func (d *disk) flush(c *Cache) {
+	d.mu.Lock()
+	dirty := d.dirty
+	d.dirty = map[string]struct{}{}
+	d.mu.Unlock()
+	if len(dirty) == 0 {
+		return
+	}
+
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(diskBucket)
+		for key := range dirty {
+			value, ok := c.entries.Load(key)
+			if !ok {
+				if err := bucket.Delete([]byte(key)); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := bucket.Put([]byte(key), encodeDiskEntry(value.(Entry))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("[Cache:Disk] Error writing %d entries to %s: %v", len(dirty), d.db.Path(), err)
+		// the keys are written with the next flush, rather than left stale on disk until they change
+		d.mu.Lock()
+		for key := range dirty {
+			d.dirty[key] = struct{}{}
+		}
+		d.mu.Unlock()
+	}
+}
+
+// encodeDiskEntry encodes an entry as its expiration, 8 bytes big-endian, followed by its value
+func encodeDiskEntry(entry Entry) []byte {
+	encoded := make([]byte, 8+len(entry.Value))
+	binary.BigEndian.PutUint64(encoded, uint64(entry.Expiration))
+	copy(encoded[8:], entry.Value)
+	return encoded
+}
+
+func decodeDiskEntry(encoded []byte) (Entry, bool) {
+	if len(encoded) < 8 {
+		return Entry{}, false
+	}
+	// values of the database are only valid during their transaction
+	value := append([]byte(nil), encoded[8:]...)
+	return Entry{Value: value, Expiration: int64(binary.BigEndian.Uint64(encoded))}, true
+}
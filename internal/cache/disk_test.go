@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestOpenRestoresEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	c, err := Open(path, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	c.Set("kept", []byte("value"), time.Minute)
+	c.Set("overwritten", []byte("old"), time.Minute)
+	c.Set("overwritten", []byte("new"), time.Minute)
+	c.Set("deleted", []byte("value"), time.Minute)
+	c.Delete("deleted")
+	c.Set("expired", []byte("value"), -time.Second)
+	if err := c.Close(); err != nil {
+		t.Fatalf("Expected no error closing, got %v", err)
+	}
+
+	reopened, err := Open(path, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer reopened.Close()
+
+	for key, expected := range map[string]string{"kept": "value", "overwritten": "new"} {
+		entry, ok := reopened.Get(key)
+		if !ok || string(entry.Value) != expected {
+			t.Errorf("Expected %s to be %q, got %q (%v)", key, expected, entry.Value, ok)
+		}
+	}
+	for _, key := range []string{"deleted", "expired"} {
+		if _, ok := reopened.Get(key); ok {
+			t.Errorf("Expected %s to be missing", key)
+		}
+	}
+}
+
+func TestOpenKeepsTransientKeysInMemory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	transient := func(key string) bool { return strings.HasSuffix(key, "token") }
+	c, err := Open(path, transient)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	c.Set("access-token", []byte("secret"), time.Minute)
+	c.Set("lyrics", []byte("value"), time.Minute)
+	if entry, ok := c.Get("access-token"); !ok || string(entry.Value) != "secret" {
+		t.Errorf("Expected the transient key to be served from memory")
+	}
+	c.Close()
+
+	body, _ := os.ReadFile(path)
+	if strings.Contains(string(body), "secret") {
+		t.Errorf("Expected the transient value not to be written to disk")
+	}
+	reopened, err := Open(path, transient)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer reopened.Close()
+	if _, ok := reopened.Get("access-token"); ok {
+		t.Errorf("Expected the transient key not to be restored")
+	}
+	if _, ok := reopened.Get("lyrics"); !ok {
+		t.Errorf("Expected the other keys to be restored")
+	}
+}
+
+func TestOpenMovesLegacyJournalAside(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	if err := os.WriteFile(path, []byte(`{"k":"key","v":"value"}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Open(path, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer c.Close()
+
+	if _, err := os.Stat(path + ".journal"); err != nil {
+		t.Errorf("Expected the journal to be moved aside, got %v", err)
+	}
+	c.Set("key", []byte("value"), time.Minute)
+}
+
+func TestFlushKeepsFailedKeysDirty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	c, err := Open(path, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	c.Close()
+
+	// writes to a read-only database fail
+	db, err := bolt.Open(path, 0600, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer db.Close()
+	c = New()
+	d := &disk{db: db, dirty: map[string]struct{}{}}
+	c.Set("key", []byte("value"), time.Minute)
+	d.dirty["key"] = struct{}{}
+	d.flush(c)
+	if _, ok := d.dirty["key"]; !ok {
+		t.Errorf("Expected the key to stay dirty after a failed write")
+	}
+}
@@ -0,0 +1,175 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// DumpSchemaVersion is the layout of cache dumps. Bump it when entries change in a way older or
+// newer instances can't read.
+//
+// Version 4 dumps are NDJSON, see DumpWriter, older ones a single JSON object. Version 3 checksums
+// cover the expiration of entries along with their key and value. Version 2 holds values as bytes
+// (base64 in JSON), version 1 held them as strings.
+const DumpSchemaVersion = 4
+
+// Dump is a snapshot of the cache as served by GET /cache, read with ReadDump
+type Dump struct {
+	NumberOfKeys  int
+	SizeInKB      int
+	SchemaVersion int
+	// KeySchemaVersion is the KeySchemaVersion of the values, 0 for dumps from before keys were versioned
+	KeySchemaVersion int `json:",omitempty"`
+	Cache            map[string]Entry
+	Checksums        map[string]string
+}
+
+// ValuesVersion is the KeySchemaVersion of the values of the dump
+func (d Dump) ValuesVersion() int {
+	return max(d.KeySchemaVersion, 1)
+}
+
+// UnmarshalJSON reads dumps of every schema version, converting the string values of dumps older
+// than version 2 to their bytes so their checksums still match
+func (d *Dump) UnmarshalJSON(data []byte) error {
+	type dump Dump
+	var header struct{ SchemaVersion int }
+	if err := json.Unmarshal(data, &header); err != nil {
+		return err
+	}
+	if header.SchemaVersion >= 2 {
+		return json.Unmarshal(data, (*dump)(d))
+	}
+
+	var legacy struct {
+		NumberOfKeys     int
+		SizeInKB         int
+		SchemaVersion    int
+		KeySchemaVersion int
+		Cache            map[string]struct {
+			Value      string
+			Expiration int64
+		}
+		Checksums map[string]string
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+	*d = Dump{
+		NumberOfKeys:     legacy.NumberOfKeys,
+		SizeInKB:         legacy.SizeInKB,
+		SchemaVersion:    legacy.SchemaVersion,
+		KeySchemaVersion: legacy.KeySchemaVersion,
+		Checksums:        legacy.Checksums,
+	}
+	if legacy.Cache != nil {
+		d.Cache = make(map[string]Entry, len(legacy.Cache))
+		for key, entry := range legacy.Cache {
+			d.Cache[key] = Entry{Value: []byte(entry.Value), Expiration: entry.Expiration}
+		}
+	}
+	return nil
+}
+
+// dumpLine is any line of an NDJSON dump: the header, an entry with its checksum, or the counts
+// ending the dump
+type dumpLine struct {
+	SchemaVersion    int    `json:",omitempty"`
+	KeySchemaVersion int    `json:",omitempty"`
+	Key              string `json:",omitempty"`
+	Value            []byte `json:",omitempty"`
+	Expiration       int64  `json:",omitempty"`
+	Checksum         string `json:",omitempty"`
+	NumberOfKeys     *int   `json:",omitempty"`
+	SizeInKB         int    `json:",omitempty"`
+}
+
+// DumpWriter writes a dump of the current schema version one entry per line, so a large cache is
+// dumped without holding a copy of it. The first line holds the schema versions and the last one
+// the counts, whose absence tells a truncated dump.
+type DumpWriter struct {
+	encoder      *json.Encoder
+	numberOfKeys int
+	size         int
+}
+
+// NewDumpWriter writes the header of a dump of values of keySchemaVersion to w
+func NewDumpWriter(w io.Writer, keySchemaVersion int) (*DumpWriter, error) {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return &DumpWriter{encoder: encoder}, encoder.Encode(dumpLine{SchemaVersion: DumpSchemaVersion, KeySchemaVersion: keySchemaVersion})
+}
+
+// Write writes an entry with its checksum
+func (d *DumpWriter) Write(key string, entry Entry) error {
+	d.numberOfKeys++
+	d.size += len(key) + len(entry.Value) + 8
+	return d.encoder.Encode(dumpLine{Key: key, Value: entry.Value, Expiration: entry.Expiration, Checksum: EntryChecksum(key, entry)})
+}
+
+// Close writes the counts ending the dump and returns the number of entries written
+func (d *DumpWriter) Close() (int, error) {
+	return d.numberOfKeys, d.encoder.Encode(dumpLine{NumberOfKeys: &d.numberOfKeys, SizeInKB: d.size / 1024})
+}
+
+// ReadDump reads a dump of any schema version
+func ReadDump(r io.Reader) (Dump, error) {
+	decoder := json.NewDecoder(r)
+	var first json.RawMessage
+	if err := decoder.Decode(&first); err != nil {
+		return Dump{}, err
+	}
+	var dump Dump
+	if err := json.Unmarshal(first, &dump); err != nil || dump.SchemaVersion < 4 {
+		return dump, err
+	}
+
+	dump.Cache = map[string]Entry{}
+	dump.Checksums = map[string]string{}
+	for {
+		var line dumpLine
+		if err := decoder.Decode(&line); err == io.EOF {
+			return dump, fmt.Errorf("truncated dump, it ends after %d entries without its counts", len(dump.Cache))
+		} else if err != nil {
+			return dump, err
+		}
+		if line.NumberOfKeys != nil {
+			dump.NumberOfKeys, dump.SizeInKB = *line.NumberOfKeys, line.SizeInKB
+			if dump.NumberOfKeys != len(dump.Cache) {
+				return dump, fmt.Errorf("truncated dump, expected %d entries, got %d", dump.NumberOfKeys, len(dump.Cache))
+			}
+			return dump, nil
+		}
+		dump.Cache[line.Key] = Entry{Value: line.Value, Expiration: line.Expiration}
+		dump.Checksums[line.Key] = line.Checksum
+	}
+}
+
+// EntryChecksum is the checksum of an entry in a cache dump, covering its key, expiration and value
+func EntryChecksum(key string, entry Entry) string {
+	h := sha256.New()
+	h.Write([]byte(key + "\n" + strconv.FormatInt(entry.Expiration, 10) + "\n"))
+	h.Write(entry.Value)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// valueChecksum is the checksum of entries in dumps older than version 3, covering only the key
+// and value
+func valueChecksum(key string, value []byte) string {
+	h := sha256.New()
+	h.Write([]byte(key + "\n"))
+	h.Write(value)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Checksum is the checksum entry of key should have in the dump, for the dump's schema version
+func (d Dump) Checksum(key string, entry Entry) string {
+	if d.SchemaVersion < 3 {
+		return valueChecksum(key, entry.Value)
+	}
+	return EntryChecksum(key, entry)
+}
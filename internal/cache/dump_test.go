@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDumpRoundTrip(t *testing.T) {
+	value := []byte{0x01, 0x1f, 0x8b, 0x00, 0xff}
+	dump := Dump{
+		SchemaVersion: DumpSchemaVersion,
+		Cache:         map[string]Entry{"lyrics:abc": {Value: value, Expiration: 42}},
+		Checksums:     map[string]string{"lyrics:abc": EntryChecksum("lyrics:abc", Entry{Value: value, Expiration: 42})},
+	}
+	body, _ := json.Marshal(dump)
+
+	var decoded Dump
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	entry := decoded.Cache["lyrics:abc"]
+	if string(entry.Value) != string(value) || entry.Expiration != 42 {
+		t.Errorf("Expected the entry back, got %+v", entry)
+	}
+}
+
+func TestDumpReadsStringValuesOfVersion1(t *testing.T) {
+	body := `{"SchemaVersion":1,"NumberOfKeys":1,"Cache":{"track:abc":{"Value":"4uLU6hMCjMI75M1A2tKUQC","Expiration":42}},` +
+		`"Checksums":{"track:abc":"` + valueChecksum("track:abc", []byte("4uLU6hMCjMI75M1A2tKUQC")) + `"}}`
+
+	var dump Dump
+	if err := json.Unmarshal([]byte(body), &dump); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	entry := dump.Cache["track:abc"]
+	if string(entry.Value) != "4uLU6hMCjMI75M1A2tKUQC" || dump.NumberOfKeys != 1 {
+		t.Errorf("Expected the string value as bytes, got %+v", dump)
+	}
+	if dump.Checksums["track:abc"] != dump.Checksum("track:abc", entry) {
+		t.Error("Expected the version 1 checksum to still match")
+	}
+}
+
+func TestEntryChecksumCoversExpiration(t *testing.T) {
+	entry := Entry{Value: []byte("value"), Expiration: 42}
+	dump := Dump{SchemaVersion: DumpSchemaVersion}
+	if dump.Checksum("track:abc", entry) != EntryChecksum("track:abc", entry) {
+		t.Errorf("Expected the checksum of the current schema version")
+	}
+	if EntryChecksum("track:abc", entry) == EntryChecksum("track:abc", Entry{Value: entry.Value, Expiration: 43}) {
+		t.Errorf("Expected a different checksum for a different expiration")
+	}
+	if EntryChecksum("track:abc", entry) == EntryChecksum("track:abd", entry) {
+		t.Errorf("Expected a different checksum for a different key")
+	}
+}
+
+func TestDumpWriterRoundTrip(t *testing.T) {
+	var body bytes.Buffer
+	writer, err := NewDumpWriter(&body, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	entries := map[string]Entry{
+		"lyrics:abc": {Value: []byte{0x01, 0x1f, 0x8b}, Expiration: 42},
+		"track:<a>":  {Value: []byte("abc"), Expiration: 43},
+	}
+	for key, entry := range entries {
+		writer.Write(key, entry)
+	}
+	if n, err := writer.Close(); err != nil || n != 2 {
+		t.Fatalf("Expected 2 entries written, got %d, %v", n, err)
+	}
+	if lines := strings.Count(body.String(), "\n"); lines != 4 {
+		t.Errorf("Expected 4 lines, got %d", lines)
+	}
+
+	dump, err := ReadDump(bytes.NewReader(body.Bytes()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if dump.SchemaVersion != DumpSchemaVersion || dump.ValuesVersion() != 2 || dump.NumberOfKeys != 2 {
+		t.Errorf("Expected the header and counts back, got %+v", dump)
+	}
+	for key, entry := range entries {
+		got := dump.Cache[key]
+		if !bytes.Equal(got.Value, entry.Value) || got.Expiration != entry.Expiration {
+			t.Errorf("Expected %s to be %+v, got %+v", key, entry, got)
+		}
+		if dump.Checksums[key] != dump.Checksum(key, got) {
+			t.Errorf("Expected the checksum of %s to match", key)
+		}
+	}
+
+	truncated := body.Bytes()[:bytes.LastIndexByte(body.Bytes()[:body.Len()-1], '\n')+1]
+	if _, err := ReadDump(bytes.NewReader(truncated)); err == nil {
+		t.Errorf("Expected an error for a dump without its counts, got nil")
+	}
+}
+
+func TestReadDumpReadsObjectDumps(t *testing.T) {
+	body := `{"SchemaVersion":3,"NumberOfKeys":1,"Cache":{"track:abc":{"Value":"YWJj","Expiration":42}},"Checksums":{}}`
+	dump, err := ReadDump(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(dump.Cache["track:abc"].Value) != "abc" || dump.NumberOfKeys != 1 {
+		t.Errorf("Expected the entry of the object dump, got %+v", dump)
+	}
+}
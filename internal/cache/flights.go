@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+	"sync"
+)
+
+// Flights deduplicates concurrent calls for the same key (singleflight): while a call for a key is
+// in flight, later callers wait for its result instead of making the call again.
+type Flights struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// NewFlights creates an empty set of flights
+func NewFlights() *Flights {
+	return &Flights{flights: make(map[string]*flight)}
+}
+
+// Do calls fn for key unless a call for key is already in flight, and returns its result. shared
+// reports whether the caller joined a call already in flight. fn runs with ctx's values but not its
+// cancellation, since other callers may be waiting for it; a caller whose ctx is done stops waiting
+// and gets ctx's error.
+func (f *Flights) Do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (value interface{}, shared bool, err error) {
+	f.mu.Lock()
+	call, shared := f.flights[key]
+	if !shared {
+		call = &flight{done: make(chan struct{})}
+		f.flights[key] = call
+		go f.run(ctx, key, call, fn)
+	}
+	f.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, shared, call.err
+	case <-ctx.Done():
+		return nil, shared, ctx.Err()
+	}
+}
+
+func (f *Flights) run(ctx context.Context, key string, call *flight, fn func(ctx context.Context) (interface{}, error)) {
+	defer func() {
+		f.mu.Lock()
+		delete(f.flights, key)
+		f.mu.Unlock()
+		close(call.done)
+	}()
+	call.value, call.err = fn(context.WithoutCancel(ctx))
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlightsDeduplicatesConcurrentCalls(t *testing.T) {
+	flights := NewFlights()
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	var sharedCount atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, shared, err := flights.Do(context.Background(), "track:a", func(ctx context.Context) (interface{}, error) {
+				calls.Add(1)
+				<-release
+				return "id", nil
+			})
+			if err != nil || value != "id" {
+				t.Errorf("Expected id, got %v (%v)", value, err)
+			}
+			if shared {
+				sharedCount.Add(1)
+			}
+		}()
+	}
+
+	// wait until every caller joined the flight before letting it land
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		flights.mu.Lock()
+		inFlight := len(flights.flights)
+		flights.mu.Unlock()
+		if inFlight == 1 && calls.Load() == 1 {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 call, got %d", calls.Load())
+	}
+	if sharedCount.Load() != 9 {
+		t.Errorf("Expected 9 shared results, got %d", sharedCount.Load())
+	}
+}
+
+func TestFlightsCallsAgainAfterLanding(t *testing.T) {
+	flights := NewFlights()
+	calls := 0
+	for i := 0; i < 2; i++ {
+		_, shared, err := flights.Do(context.Background(), "k", func(ctx context.Context) (interface{}, error) {
+			calls++
+			return nil, errors.New("upstream down")
+		})
+		if err == nil || shared {
+			t.Errorf("Expected an unshared error, got shared=%v err=%v", shared, err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected errors not to be remembered, got %d calls", calls)
+	}
+}
+
+func TestFlightsCallerCancellation(t *testing.T) {
+	flights := NewFlights()
+	release := make(chan struct{})
+	landed := make(chan error, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_, _, err := flights.Do(context.Background(), "k", func(ctx context.Context) (interface{}, error) {
+			<-release
+			return "v", ctx.Err()
+		})
+		landed <- err
+	}()
+	for {
+		flights.mu.Lock()
+		inFlight := len(flights.flights)
+		flights.mu.Unlock()
+		if inFlight == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if _, _, err := flights.Do(ctx, "k", nil); err != context.Canceled {
+		t.Errorf("Expected the cancelled caller to stop waiting, got %v", err)
+	}
+
+	close(release)
+	if err := <-landed; err != nil {
+		t.Errorf("Expected the flight to finish for the other caller, got %v", err)
+	}
+}
+
+func TestFlightsDetachesCancellation(t *testing.T) {
+	flights := NewFlights()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// the leader gives up right away, but the call itself isn't cancelled with it
+	done := make(chan error, 1)
+	flights.Do(ctx, "k", func(ctx context.Context) (interface{}, error) {
+		done <- ctx.Err()
+		return nil, nil
+	})
+	if err := <-done; err != nil {
+		t.Errorf("Expected the call not to be cancelled, got %v", err)
+	}
+}
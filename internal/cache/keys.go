@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"strconv"
+	"strings"
+)
+
+// KeySchemaVersion is the version of the format of stored values. Keys are stored under it, so
+// instances sharing Redis or loading each other's dumps never read entries written in a format they
+// don't know. Bump it whenever stored values change in a way older or newer code can't read; entries
+// of other versions are left to expire.
+//
+// Keys of version 1 are stored as they are, the layout from before keys were versioned.
+const KeySchemaVersion = 1
+
+// StorageKey returns the key key is stored under in schema version
+func StorageKey(version int, key string) string {
+	if version <= 1 {
+		return key
+	}
+	return "v" + strconv.Itoa(version) + ":" + key
+}
+
+// ParseStorageKey returns the schema version and the key of a stored key
+func ParseStorageKey(storageKey string) (version int, key string) {
+	if rest, ok := strings.CutPrefix(storageKey, "v"); ok {
+		if digits, key, ok := strings.Cut(rest, ":"); ok {
+			if version, err := strconv.Atoi(digits); err == nil && version > 1 {
+				return version, key
+			}
+		}
+	}
+	return 1, storageKey
+}
@@ -0,0 +1,34 @@
+package cache
+
+import "testing"
+
+func TestStorageKeyRoundTrip(t *testing.T) {
+	tests := []struct {
+		version int
+		key     string
+		stored  string
+	}{
+		{1, "lyrics:abc", "lyrics:abc"},
+		{2, "lyrics:abc", "v2:lyrics:abc"},
+		{12, "track:never+gonna", "v12:track:never+gonna"},
+		{1, "accessToken", "accessToken"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stored, func(t *testing.T) {
+			if got := StorageKey(tt.version, tt.key); got != tt.stored {
+				t.Errorf("Expected %q, got %q", tt.stored, got)
+			}
+			if version, key := ParseStorageKey(tt.stored); version != tt.version || key != tt.key {
+				t.Errorf("Expected version %d and key %q, got %d and %q", tt.version, tt.key, version, key)
+			}
+		})
+	}
+}
+
+func TestParseStorageKeyOfUnversionedKeys(t *testing.T) {
+	for _, key := range []string{"v:abc", "vx:abc", "v1:abc", "value"} {
+		if version, parsed := ParseStorageKey(key); version != 1 || parsed != key {
+			t.Errorf("Expected %q to be an unversioned key, got %d and %q", key, version, parsed)
+		}
+	}
+}
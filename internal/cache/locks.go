@@ -0,0 +1,45 @@
+package cache
+
+import "sync"
+
+// KeyLocks hands out a mutex per key, so operations spanning a read, an upstream call and a write
+// (refreshes, purges, migrations) on the same key can't interleave. A key's mutex is dropped once
+// nobody holds or waits for it.
+type KeyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+// NewKeyLocks creates an empty set of key locks
+func NewKeyLocks() *KeyLocks {
+	return &KeyLocks{locks: make(map[string]*keyLock)}
+}
+
+// Lock blocks until key is free and returns the function releasing it
+func (l *KeyLocks) Lock(key string) (unlock func()) {
+	l.mu.Lock()
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &keyLock{}
+		l.locks[key] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		l.mu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(l.locks, key)
+		}
+		l.mu.Unlock()
+	}
+}
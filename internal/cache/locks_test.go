@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyLocksSerializeSameKey(t *testing.T) {
+	locks := NewKeyLocks()
+	unlock := locks.Lock("track:1")
+
+	acquired := make(chan struct{})
+	go func() {
+		release := locks.Lock("track:1")
+		close(acquired)
+		release()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatalf("Expected second lock of the same key to block")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected second lock to be acquired after unlock")
+	}
+}
+
+func TestKeyLocksIndependentKeys(t *testing.T) {
+	locks := NewKeyLocks()
+	unlock := locks.Lock("track:1")
+	defer unlock()
+
+	done := make(chan struct{})
+	go func() {
+		locks.Lock("track:2")()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected lock of another key not to block")
+	}
+}
+
+func TestKeyLocksCleanup(t *testing.T) {
+	locks := NewKeyLocks()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			locks.Lock("track:1")()
+		}()
+	}
+	wg.Wait()
+
+	if len(locks.locks) != 0 {
+		t.Errorf("Expected no locks left, got %d", len(locks.locks))
+	}
+}
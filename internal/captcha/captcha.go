@@ -0,0 +1,156 @@
+// Package captcha verifies Cloudflare Turnstile and hCaptcha tokens server-side, and remembers the
+// clients that solved a challenge for a while.
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrRejected is returned for tokens the provider didn't accept
+	ErrRejected = errors.New("captcha token rejected")
+	// ErrMalformed is returned for tokens that can't be from the provider, without asking it
+	ErrMalformed = errors.New("malformed captcha token")
+)
+
+// maxTokenLength is longer than the tokens of both providers; Turnstile's are at most 2048 bytes
+const maxTokenLength = 4096
+
+// Config selects and configures the captcha provider
+type Config struct {
+	// Provider is one of "turnstile" or "hcaptcha"
+	Provider string
+	// URL overrides the provider's default siteverify endpoint
+	URL    string
+	Secret string
+}
+
+// Verifier verifies the token of a solved challenge, once
+type Verifier struct {
+	url           string
+	secret        string
+	httpClient    *http.Client
+	beforeRequest func(req *http.Request)
+}
+
+// New creates the verifier of the configured provider. Both providers share the siteverify API.
+func New(config Config, httpClient *http.Client, beforeRequest func(req *http.Request)) (*Verifier, error) {
+	v := &Verifier{url: config.URL, secret: config.Secret, httpClient: httpClient, beforeRequest: beforeRequest}
+	if v.url == "" {
+		switch config.Provider {
+		case "turnstile":
+			v.url = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+		case "hcaptcha":
+			v.url = "https://api.hcaptcha.com/siteverify"
+		default:
+			return nil, fmt.Errorf("unknown captcha provider %q", config.Provider)
+		}
+	}
+	if config.Secret == "" {
+		return nil, fmt.Errorf("missing captcha secret")
+	}
+	return v, nil
+}
+
+// Verify checks token with the provider. remoteIP, when known, must match the client that solved
+// the challenge.
+func (v *Verifier) Verify(ctx context.Context, token, remoteIP string) error {
+	if !wellFormed(token) {
+		return ErrMalformed
+	}
+	form := url.Values{"secret": {v.secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", v.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("error creating siteverify request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if v.beforeRequest != nil {
+		v.beforeRequest(req)
+	}
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making siteverify request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("siteverify request failed with status code %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return fmt.Errorf("error reading siteverify response: %v", err)
+	}
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("error parsing siteverify response: %v", err)
+	}
+	if !result.Success {
+		return ErrRejected
+	}
+	return nil
+}
+
+// wellFormed reports whether token could be a token of a provider: printable ASCII without spaces,
+// of a plausible length. Other tokens aren't worth a request to the provider.
+func wellFormed(token string) bool {
+	if token == "" || len(token) > maxTokenLength {
+		return false
+	}
+	for i := 0; i < len(token); i++ {
+		if token[i] <= ' ' || token[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Passes remembers the clients that solved a challenge until their pass expires
+type Passes struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu     sync.Mutex
+	passes map[string]time.Time
+}
+
+// NewPasses creates passes lasting ttl
+func NewPasses(ttl time.Duration) *Passes {
+	return &Passes{ttl: ttl, now: time.Now, passes: map[string]time.Time{}}
+}
+
+// Grant gives client a pass, forgetting expired passes
+func (p *Passes) Grant(client string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	for c, expiration := range p.passes {
+		if !now.Before(expiration) {
+			delete(p.passes, c)
+		}
+	}
+	p.passes[client] = now.Add(p.ttl)
+}
+
+// Valid reports whether client has an unexpired pass
+func (p *Passes) Valid(client string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	expiration, ok := p.passes[client]
+	return ok && p.now().Before(expiration)
+}
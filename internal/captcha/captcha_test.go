@@ -0,0 +1,74 @@
+package captcha
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("secret") != "secret" || r.FormValue("remoteip") != "203.0.113.7" {
+			t.Errorf("Expected the secret and remote IP in the form, got %v", r.Form)
+		}
+		if r.FormValue("response") == "solved" {
+			w.Write([]byte(`{"success": true}`))
+			return
+		}
+		w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+	}))
+	defer server.Close()
+
+	verifier, err := New(Config{Provider: "turnstile", URL: server.URL, Secret: "secret"}, server.Client(), nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := verifier.Verify(context.Background(), "solved", "203.0.113.7"); err != nil {
+		t.Errorf("Expected the token to verify, got %v", err)
+	}
+	if err := verifier.Verify(context.Background(), "forged", "203.0.113.7"); err != ErrRejected {
+		t.Errorf("Expected ErrRejected, got %v", err)
+	}
+
+	// malformed tokens are rejected without a siteverify request
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Expected no siteverify request for a malformed token")
+	})
+	for _, token := range []string{"two words", "line\nbreak", strings.Repeat("a", maxTokenLength+1)} {
+		if err := verifier.Verify(context.Background(), token, "203.0.113.7"); err != ErrMalformed {
+			t.Errorf("Expected ErrMalformed, got %v", err)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{Provider: "recaptcha", Secret: "secret"}, http.DefaultClient, nil); err == nil {
+		t.Errorf("Expected an error for an unknown provider, got nil")
+	}
+	if _, err := New(Config{Provider: "hcaptcha"}, http.DefaultClient, nil); err == nil {
+		t.Errorf("Expected an error without a secret, got nil")
+	}
+}
+
+func TestPasses(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	passes := NewPasses(time.Hour)
+	passes.now = func() time.Time { return now }
+
+	passes.Grant("203.0.113.7")
+	if !passes.Valid("203.0.113.7") || passes.Valid("203.0.113.8") {
+		t.Errorf("Expected only the granted client to have a pass")
+	}
+
+	now = now.Add(time.Hour)
+	if passes.Valid("203.0.113.7") {
+		t.Errorf("Expected the pass to expire")
+	}
+	passes.Grant("203.0.113.8")
+	if len(passes.passes) != 1 {
+		t.Errorf("Expected expired passes to be forgotten, got %d", len(passes.passes))
+	}
+}
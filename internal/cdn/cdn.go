@@ -0,0 +1,151 @@
+// Package cdn purges responses cached at the edge by their surrogate keys.
+package cdn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Purger evicts every response tagged with any of the keys from the CDN
+type Purger interface {
+	Purge(ctx context.Context, keys []string) error
+}
+
+// Config selects and configures the CDN purged
+type Config struct {
+	// Provider is one of "fastly" or "cloudflare"
+	Provider string
+	// URL overrides the provider's default API endpoint
+	URL      string
+	APIToken string
+	// ServiceID is the Fastly service or the Cloudflare zone the API is serving
+	ServiceID string
+}
+
+// New creates the purger of the configured CDN
+func New(config Config, httpClient *http.Client, beforeRequest func(req *http.Request)) (Purger, error) {
+	c := client{url: config.URL, apiToken: config.APIToken, serviceID: config.ServiceID, httpClient: httpClient, beforeRequest: beforeRequest}
+	switch config.Provider {
+	case "fastly":
+		if c.url == "" {
+			c.url = "https://api.fastly.com"
+		}
+		return &Fastly{c}, nil
+	case "cloudflare":
+		if c.url == "" {
+			c.url = "https://api.cloudflare.com/client/v4"
+		}
+		return &Cloudflare{c}, nil
+	}
+	return nil, fmt.Errorf("unknown CDN provider %q", config.Provider)
+}
+
+// HeaderName returns the response header a CDN provider reads surrogate keys from, and the separator
+// of the keys in it, or an empty name for an unknown provider
+func HeaderName(provider string) (name, separator string) {
+	switch provider {
+	case "fastly":
+		return "Surrogate-Key", " "
+	case "cloudflare":
+		return "Cache-Tag", ","
+	}
+	return "", ""
+}
+
+// Fastly purges through the Fastly API, 256 surrogate keys per request
+type Fastly struct {
+	client
+}
+
+func (f *Fastly) Purge(ctx context.Context, keys []string) error {
+	for _, batch := range batches(keys, 256) {
+		header := http.Header{
+			"Fastly-Key":    {f.apiToken},
+			"Surrogate-Key": {strings.Join(batch, " ")},
+		}
+		if err := f.post(ctx, fmt.Sprintf("%s/service/%s/purge", f.url, f.serviceID), nil, header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cloudflare purges through the Cloudflare API, 30 cache tags per request
+type Cloudflare struct {
+	client
+}
+
+func (c *Cloudflare) Purge(ctx context.Context, keys []string) error {
+	for _, batch := range batches(keys, 30) {
+		payload := map[string]interface{}{"tags": batch}
+		header := http.Header{"Authorization": {"Bearer " + c.apiToken}}
+		if err := c.post(ctx, fmt.Sprintf("%s/zones/%s/purge_cache", c.url, c.serviceID), payload, header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type client struct {
+	url           string
+	apiToken      string
+	serviceID     string
+	httpClient    *http.Client
+	beforeRequest func(req *http.Request)
+}
+
+// post posts payload as JSON, or nothing if it is nil, to url
+func (c *client) post(ctx context.Context, url string, payload interface{}, header http.Header) error {
+	var body io.Reader = http.NoBody
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("error encoding purge request: %v", err)
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return fmt.Errorf("error creating purge request: %v", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if c.beforeRequest != nil {
+		c.beforeRequest(req)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making purge request: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("purge request failed with status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// batches splits keys into batches of at most size keys
+func batches(keys []string, size int) [][]string {
+	var split [][]string
+	for len(keys) > size {
+		split = append(split, keys[:size])
+		keys = keys[size:]
+	}
+	if len(keys) > 0 {
+		split = append(split, keys)
+	}
+	return split
+}
@@ -0,0 +1,80 @@
+package cdn
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFastlyPurge(t *testing.T) {
+	var paths, keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Fastly-Key") != "token" {
+			t.Errorf("Expected the API token in Fastly-Key, got %q", r.Header.Get("Fastly-Key"))
+		}
+		paths = append(paths, r.URL.Path)
+		keys = append(keys, r.Header.Get("Surrogate-Key"))
+	}))
+	defer server.Close()
+
+	purger, err := New(Config{Provider: "fastly", URL: server.URL, APIToken: "token", ServiceID: "service"}, server.Client(), nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := purger.Purge(context.Background(), []string{"track-a", "provider-spotify"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(paths, []string{"/service/service/purge"}) || !reflect.DeepEqual(keys, []string{"track-a provider-spotify"}) {
+		t.Errorf("Expected one purge of both keys, got %v %v", paths, keys)
+	}
+}
+
+func TestCloudflarePurgeBatches(t *testing.T) {
+	var batchSizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/zones/zone/purge_cache" {
+			t.Errorf("Expected the zone purge path, got %s", r.URL.Path)
+		}
+		var payload struct {
+			Tags []string `json:"tags"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		batchSizes = append(batchSizes, len(payload.Tags))
+	}))
+	defer server.Close()
+
+	keys := make([]string, 45)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("track-%d", i)
+	}
+	purger, _ := New(Config{Provider: "cloudflare", URL: server.URL, ServiceID: "zone"}, server.Client(), nil)
+	if err := purger.Purge(context.Background(), keys); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(batchSizes, []int{30, 15}) {
+		t.Errorf("Expected batches of 30 and 15 tags, got %v", batchSizes)
+	}
+}
+
+func TestPurgeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	purger, _ := New(Config{Provider: "fastly", URL: server.URL}, server.Client(), nil)
+	if err := purger.Purge(context.Background(), []string{"track-a"}); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Expected a 403 error, got %v", err)
+	}
+}
+
+func TestNewUnknownProvider(t *testing.T) {
+	if _, err := New(Config{Provider: "akamai"}, http.DefaultClient, nil); err == nil {
+		t.Errorf("Expected an error for an unknown provider, got nil")
+	}
+}
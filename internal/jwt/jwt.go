@@ -0,0 +1,365 @@
+// Package jwt verifies JSON Web Tokens signed with a shared secret (HS256, HS384, HS512) or with the
+// keys published at a JWKS URL (RS256, RS384, RS512, ES256, ES384, ES512).
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // SHA-256 for HS256, RS256 and ES256
+	_ "crypto/sha512" // SHA-384 and SHA-512 for the other algorithms
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	ErrMalformed        = errors.New("malformed token")
+	ErrUnsupportedAlg   = errors.New("unsupported signing algorithm")
+	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrExpired          = errors.New("token expired")
+	ErrNoExpiration     = errors.New("token has no expiration")
+	ErrNotYetValid      = errors.New("token not yet valid")
+	ErrInvalidIssuer    = errors.New("invalid token issuer")
+	ErrInvalidAudience  = errors.New("invalid token audience")
+	ErrUnknownKey       = errors.New("unknown signing key")
+)
+
+// leeway tolerates clock skew between the issuer and this server
+const leeway = time.Minute
+
+// minJWKSRefreshInterval bounds how often tokens signed with unknown keys can make the verifier
+// fetch the JWKS again
+const minJWKSRefreshInterval = time.Minute
+
+// Claims are the claims of a verified token
+type Claims map[string]interface{}
+
+// String returns a string claim, or "" if it is missing or not a string
+func (c Claims) String(name string) string {
+	value, _ := c[name].(string)
+	return value
+}
+
+// Strings returns a claim holding a space-separated string or an array of strings
+func (c Claims) Strings(name string) []string {
+	switch value := c[name].(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		var values []string
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// Config configures a verifier. Exactly one of Secret and JWKSURL is expected.
+type Config struct {
+	Secret  string
+	JWKSURL string
+	// JWKSTTL is how long the fetched keys are used before fetching them again
+	JWKSTTL time.Duration
+	// Issuer and Audience, when set, must match the iss and aud claims
+	Issuer   string
+	Audience string
+}
+
+// Verifier verifies tokens
+type Verifier struct {
+	config     Config
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	// fetches lets the requests needing the JWKS while it is fetched wait for that fetch
+	fetches singleflight.Group
+}
+
+// New creates a verifier
+func New(config Config, httpClient *http.Client) (*Verifier, error) {
+	if (config.Secret == "") == (config.JWKSURL == "") {
+		return nil, fmt.Errorf("exactly one of a shared secret and a JWKS URL must be configured")
+	}
+	return &Verifier{config: config, httpClient: httpClient, now: time.Now}, nil
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks the signature, lifetime, issuer and audience of token and returns its claims
+func (v *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, ErrMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if err := v.verifySignature(ctx, h, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformed
+	}
+	return claims, v.validate(claims)
+}
+
+func (v *Verifier) verifySignature(ctx context.Context, h header, signed string, signature []byte) error {
+	hashFunc, ok := algHashes[h.Alg[min(2, len(h.Alg)):]]
+	if !ok {
+		return ErrUnsupportedAlg
+	}
+
+	switch {
+	case strings.HasPrefix(h.Alg, "HS") && v.config.Secret != "":
+		mac := hmac.New(hashFunc.New, []byte(v.config.Secret))
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case (strings.HasPrefix(h.Alg, "RS") || strings.HasPrefix(h.Alg, "ES")) && v.config.JWKSURL != "":
+		key, err := v.key(ctx, h.Kid)
+		if err != nil {
+			return err
+		}
+		digest := hashOf(hashFunc.New(), signed)
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			if !strings.HasPrefix(h.Alg, "RS") || rsa.VerifyPKCS1v15(key, hashFunc, digest, signature) != nil {
+				return ErrInvalidSignature
+			}
+			return nil
+		case *ecdsa.PublicKey:
+			size := (key.Curve.Params().BitSize + 7) / 8
+			if !strings.HasPrefix(h.Alg, "ES") || len(signature) != 2*size {
+				return ErrInvalidSignature
+			}
+			r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+			if !ecdsa.Verify(key, digest, r, s) {
+				return ErrInvalidSignature
+			}
+			return nil
+		}
+		return ErrUnknownKey
+	}
+	// e.g. "none", or an HMAC token signed with the public key of a JWKS
+	return ErrUnsupportedAlg
+}
+
+var algHashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+func hashOf(h hash.Hash, data string) []byte {
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// validate checks the registered claims of a token. Tokens must expire, so a leaked one doesn't
+// grant access forever.
+func (v *Verifier) validate(claims Claims) error {
+	now := v.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return ErrNoExpiration
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(leeway)) {
+		return ErrExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(leeway).Before(time.Unix(int64(nbf), 0)) {
+		return ErrNotYetValid
+	}
+	if v.config.Issuer != "" && claims.String("iss") != v.config.Issuer {
+		return ErrInvalidIssuer
+	}
+	if v.config.Audience != "" {
+		for _, audience := range claims.Strings("aud") {
+			if audience == v.config.Audience {
+				return nil
+			}
+		}
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+// key returns the JWKS key with kid, fetching the JWKS again when it is older than its TTL or
+// doesn't have the key, e.g. after a rotation. The JWKS is fetched without holding the lock, once
+// for every request needing it meanwhile. Fetches are at least minJWKSRefreshInterval apart, so
+// tokens with made-up key IDs or a failing JWKS URL can't make every request fetch it.
+func (v *Verifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	key, ok := v.keys[kid]
+	stale := v.keys == nil || v.now().Sub(v.fetchedAt) > v.config.JWKSTTL
+	v.mu.Unlock()
+	if ok && !stale {
+		return key, nil
+	}
+
+	// the fetch is shared, so it isn't canceled with the request that happened to start it
+	keys, err, _ := v.fetches.Do("jwks", func() (interface{}, error) {
+		return v.refreshKeys(context.WithoutCancel(ctx))
+	})
+	if err != nil {
+		// keys that are known keep working while the JWKS URL is failing
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+	if key, ok = keys.(map[string]crypto.PublicKey)[kid]; !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// refreshKeys fetches the JWKS unless it was attempted within minJWKSRefreshInterval, and returns
+// the keys known afterwards
+func (v *Verifier) refreshKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	v.mu.Lock()
+	if v.now().Sub(v.attemptedAt) <= minJWKSRefreshInterval {
+		defer v.mu.Unlock()
+		return v.keys, nil
+	}
+	v.attemptedAt = v.now()
+	v.mu.Unlock()
+
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys, v.fetchedAt = keys, v.now()
+	return keys, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchKeys fetches the signing keys of the JWKS, skipping keys of other types or uses
+func (v *Verifier) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", v.config.JWKSURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating JWKS request: %v", err)
+	}
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching JWKS: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS request failed with status code %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("error reading JWKS: %v", err)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &set); err != nil {
+		return nil, fmt.Errorf("error parsing JWKS: %v", err)
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) > 4 {
+			return nil, ErrMalformed
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrUnsupportedAlg
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, ErrMalformed
+		}
+		return key, nil
+	}
+	return nil, ErrUnsupportedAlg
+}
+
+func decodeSegment(segment string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,187 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func encodeSegment(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signHS256(t *testing.T, secret string, claims map[string]interface{}) string {
+	t.Helper()
+	signed := encodeSegment(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encodeSegment(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySharedSecret(t *testing.T) {
+	verifier, err := New(Config{Secret: "secret", Issuer: "https://auth.example.com", Audience: "lyrics"}, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	verifier.now = func() time.Time { return now }
+	valid := map[string]interface{}{
+		"sub": "user", "iss": "https://auth.example.com", "aud": []string{"lyrics"},
+		"exp": now.Add(time.Hour).Unix(), "scope": "lyrics:read translate",
+	}
+
+	claims, err := verifier.Verify(context.Background(), signHS256(t, "secret", valid))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if claims.String("sub") != "user" || len(claims.Strings("scope")) != 2 {
+		t.Errorf("Expected the claims of the token, got %v", claims)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"Wrong secret", signHS256(t, "other", valid), ErrInvalidSignature},
+		{"Expired", signHS256(t, "secret", with(valid, "exp", now.Add(-time.Hour).Unix())), ErrExpired},
+		{"No expiration", signHS256(t, "secret", with(valid, "exp", nil)), ErrNoExpiration},
+		{"Not yet valid", signHS256(t, "secret", with(valid, "nbf", now.Add(time.Hour).Unix())), ErrNotYetValid},
+		{"Wrong issuer", signHS256(t, "secret", with(valid, "iss", "https://evil.example.com")), ErrInvalidIssuer},
+		{"Wrong audience", signHS256(t, "secret", with(valid, "aud", "other")), ErrInvalidAudience},
+		{"Unsigned", encodeSegment(map[string]string{"alg": "none"}) + "." + encodeSegment(valid) + ".", ErrUnsupportedAlg},
+		{"Malformed", "not-a-token", ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := verifier.Verify(context.Background(), tt.token); err != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func with(claims map[string]interface{}, name string, value interface{}) map[string]interface{} {
+	copied := map[string]interface{}{}
+	for k, v := range claims {
+		copied[k] = v
+	}
+	copied[name] = value
+	return copied
+}
+
+func TestVerifyJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{
+			{
+				"kty": "RSA", "kid": "rsa", "use": "sig",
+				"n": base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				"kty": "EC", "kid": "ec", "crv": "P-256",
+				"x": base64.RawURLEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))),
+				"y": base64.RawURLEncoding.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32))),
+			},
+		}})
+	}))
+	defer server.Close()
+
+	verifier, _ := New(Config{JWKSURL: server.URL, JWKSTTL: time.Hour}, server.Client())
+	claims := encodeSegment(map[string]interface{}{"sub": "user", "exp": time.Now().Add(time.Hour).Unix()})
+
+	rsaSigned := encodeSegment(map[string]string{"alg": "RS256", "kid": "rsa"}) + "." + claims
+	digest := sha256.Sum256([]byte(rsaSigned))
+	rsaSignature, _ := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	if _, err := verifier.Verify(context.Background(), rsaSigned+"."+base64.RawURLEncoding.EncodeToString(rsaSignature)); err != nil {
+		t.Errorf("Expected the RS256 token to verify, got %v", err)
+	}
+
+	ecSigned := encodeSegment(map[string]string{"alg": "ES256", "kid": "ec"}) + "." + claims
+	digest = sha256.Sum256([]byte(ecSigned))
+	r, s, _ := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+	ecSignature := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	if _, err := verifier.Verify(context.Background(), ecSigned+"."+base64.RawURLEncoding.EncodeToString(ecSignature)); err != nil {
+		t.Errorf("Expected the ES256 token to verify, got %v", err)
+	}
+
+	// an HMAC token keyed with public material must not verify against a JWKS
+	hsToken := signHS256(t, "anything", map[string]interface{}{"sub": "user"})
+	if _, err := verifier.Verify(context.Background(), hsToken); err != ErrUnsupportedAlg {
+		t.Errorf("Expected ErrUnsupportedAlg, got %v", err)
+	}
+
+	unknown := encodeSegment(map[string]string{"alg": "RS256", "kid": "missing"}) + "." + claims + ".c2ln"
+	if _, err := verifier.Verify(context.Background(), unknown); err != ErrUnknownKey {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+	if fetches != 1 {
+		t.Errorf("Expected unknown keys not to refetch the JWKS within a minute, got %d fetches", fetches)
+	}
+}
+
+func TestVerifyJWKSFetchesOnce(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		time.Sleep(50 * time.Millisecond)
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "EC", "kid": "ec", "crv": "P-256",
+			"x": base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y": base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}}})
+	}))
+	defer server.Close()
+
+	verifier, _ := New(Config{JWKSURL: server.URL, JWKSTTL: time.Hour}, server.Client())
+	signed := encodeSegment(map[string]string{"alg": "ES256", "kid": "ec"}) + "." +
+		encodeSegment(map[string]interface{}{"sub": "user", "exp": time.Now().Add(time.Hour).Unix()})
+	digest := sha256.Sum256([]byte(signed))
+	r, s, _ := ecdsa.Sign(rand.Reader, key, digest[:])
+	token := signed + "." + base64.RawURLEncoding.EncodeToString(append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := verifier.Verify(context.Background(), token); err != nil {
+				t.Errorf("Expected the token to verify, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if fetches != 1 {
+		t.Errorf("Expected concurrent requests to share one JWKS fetch, got %d fetches", fetches)
+	}
+}
+
+func TestNewRequiresOneKeySource(t *testing.T) {
+	if _, err := New(Config{}, http.DefaultClient); err == nil || !strings.Contains(err.Error(), "exactly one") {
+		t.Errorf("Expected an error without a key source, got %v", err)
+	}
+	if _, err := New(Config{Secret: "secret", JWKSURL: "https://example.com"}, http.DefaultClient); err == nil {
+		t.Errorf("Expected an error with both key sources, got nil")
+	}
+}
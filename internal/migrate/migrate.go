@@ -0,0 +1,122 @@
+// Package migrate converts cache dumps into import files for other cache backends, so an instance
+// can move its warm cache from the in-memory store, and verifies the files against the dump.
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"lyrics-api-go/internal/cache"
+)
+
+// Backends import files can be written for
+const (
+	// BackendRedis files are Redis protocol commands for `redis-cli --pipe`
+	BackendRedis = "redis"
+	// BackendSQLite files are SQL scripts for `sqlite3 <db> < file`
+	BackendSQLite = "sqlite"
+)
+
+// Record is a cache entry in the shape of the new backend. Value holds the stored bytes of the
+// entry, which are binary for compressed entries.
+type Record struct {
+	Key         string
+	Value       string
+	ExpiresAtMs int64
+}
+
+// Checksum is the dump checksum of the record, with its expiration in milliseconds
+func (r Record) Checksum() string {
+	_, key := cache.ParseStorageKey(r.Key)
+	return cache.EntryChecksum(key, cache.Entry{Value: []byte(r.Value), Expiration: r.ExpiresAtMs * int64(time.Millisecond)})
+}
+
+// Records returns the entries of dump that are still valid at now, sorted by key. Keys are those
+// the entries are stored under for the dump's key schema version. Entries whose checksum doesn't
+// match the dump's are reported as problems instead of being migrated.
+func Records(dump cache.Dump, now time.Time) (records []Record, expired int, problems []string) {
+	for key, entry := range dump.Cache {
+		if key == "accessToken" {
+			continue
+		}
+		if entry.Expired(now) {
+			expired++
+			continue
+		}
+		if dump.SchemaVersion > 0 && dump.Checksums[key] != dump.Checksum(key, entry) {
+			problems = append(problems, fmt.Sprintf("%s: checksum mismatch in the dump", key))
+			continue
+		}
+		records = append(records, Record{Key: cache.StorageKey(dump.ValuesVersion(), key), Value: string(entry.Value), ExpiresAtMs: entry.Expiration / int64(time.Millisecond)})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Key < records[j].Key })
+	sort.Strings(problems)
+	return records, expired, problems
+}
+
+// Write writes records as an import file for backend
+func Write(w io.Writer, backend string, records []Record) error {
+	switch backend {
+	case BackendRedis:
+		return writeRedis(w, records)
+	case BackendSQLite:
+		return writeSQLite(w, records)
+	}
+	return fmt.Errorf("unsupported backend %q", backend)
+}
+
+// Read reads back the records of an import file for backend
+func Read(r io.Reader, backend string) ([]Record, error) {
+	switch backend {
+	case BackendRedis:
+		return readRedis(r)
+	case BackendSQLite:
+		return readSQLite(r)
+	}
+	return nil, fmt.Errorf("unsupported backend %q", backend)
+}
+
+// Verify compares the records read back from an import file with the ones written, by count and by
+// the checksum and expiration of every key
+func Verify(expected, written []Record) []string {
+	var problems []string
+	if len(expected) != len(written) {
+		problems = append(problems, fmt.Sprintf("expected %d entries, found %d", len(expected), len(written)))
+	}
+
+	byKey := make(map[string]Record, len(written))
+	for _, record := range written {
+		byKey[record.Key] = record
+	}
+	for _, record := range expected {
+		got, ok := byKey[record.Key]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s: missing", record.Key))
+		case got.ExpiresAtMs != record.ExpiresAtMs:
+			problems = append(problems, fmt.Sprintf("%s: expiration mismatch", record.Key))
+		case got.Checksum() != record.Checksum():
+			problems = append(problems, fmt.Sprintf("%s: checksum mismatch", record.Key))
+		}
+	}
+	return problems
+}
+
+// Digest is a checksum over all records, to compare a migrated backend against the dump
+func Digest(records []Record) string {
+	checksums := make([]string, len(records))
+	for i, record := range records {
+		checksums[i] = record.Checksum()
+	}
+	sort.Strings(checksums)
+
+	h := sha256.New()
+	for _, checksum := range checksums {
+		io.WriteString(h, checksum+"\n")
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
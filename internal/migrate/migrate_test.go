@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"lyrics-api-go/internal/cache"
+)
+
+func testDump(now time.Time) cache.Dump {
+	entries := map[string]cache.Entry{
+		"lyrics:abc":  {Value: []byte(`{"lyrics":[{"words":"it's \"quoted\"\nand multiline"}]}`), Expiration: now.Add(time.Hour).UnixNano()},
+		"track:hello": {Value: []byte("\x01\x00binary\r\n"), Expiration: now.Add(2 * time.Hour).UnixNano()},
+		"track:old":   {Value: []byte("def"), Expiration: now.Add(-time.Hour).UnixNano()},
+		"track:bad":   {Value: []byte("tampered"), Expiration: now.Add(time.Hour).UnixNano()},
+		"accessToken": {Value: []byte("secret"), Expiration: now.Add(time.Hour).UnixNano()},
+	}
+	checksums := map[string]string{}
+	for key, entry := range entries {
+		checksums[key] = cache.EntryChecksum(key, entry)
+	}
+	checksums["track:bad"] = cache.EntryChecksum("track:bad", cache.Entry{Value: []byte("original"), Expiration: entries["track:bad"].Expiration})
+	return cache.Dump{SchemaVersion: cache.DumpSchemaVersion, Cache: entries, Checksums: checksums}
+}
+
+func TestRecords(t *testing.T) {
+	now := time.Now()
+	records, expired, problems := Records(testDump(now), now)
+
+	if len(records) != 2 || records[0].Key != "lyrics:abc" || records[1].Key != "track:hello" {
+		t.Errorf("Expected the two valid entries sorted by key, got %+v", records)
+	}
+	if expired != 1 {
+		t.Errorf("Expected 1 expired entry, got %d", expired)
+	}
+	if len(problems) != 1 || !strings.HasPrefix(problems[0], "track:bad") {
+		t.Errorf("Expected the tampered entry to be reported, got %v", problems)
+	}
+}
+
+func TestRecordsOfVersionedKeys(t *testing.T) {
+	// records keep expirations in milliseconds
+	now := time.Now().Truncate(time.Millisecond)
+	dump := testDump(now)
+	dump.KeySchemaVersion = 3
+	records, _, _ := Records(dump, now)
+
+	if len(records) != 2 || records[0].Key != "v3:lyrics:abc" {
+		t.Fatalf("Expected keys stored under version 3, got %+v", records)
+	}
+	if records[0].Checksum() != dump.Checksums["lyrics:abc"] {
+		t.Errorf("Expected the checksum of the unversioned key")
+	}
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	now := time.Now()
+	records, _, _ := Records(testDump(now), now)
+
+	for _, backend := range []string{BackendRedis, BackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, backend, records); err != nil {
+				t.Fatalf("Expected no error writing, got %v", err)
+			}
+			written, err := Read(&buf, backend)
+			if err != nil {
+				t.Fatalf("Expected no error reading, got %v", err)
+			}
+			if problems := Verify(records, written); len(problems) > 0 {
+				t.Errorf("Expected the written records to verify, got %v", problems)
+			}
+			if Digest(written) != Digest(records) {
+				t.Error("Expected matching digests")
+			}
+		})
+	}
+}
+
+func TestVerifyReportsDifferences(t *testing.T) {
+	expected := []Record{
+		{Key: "a", Value: "1", ExpiresAtMs: 10},
+		{Key: "b", Value: "2", ExpiresAtMs: 10},
+		{Key: "c", Value: "3", ExpiresAtMs: 10},
+	}
+	written := []Record{
+		{Key: "a", Value: "changed", ExpiresAtMs: 10},
+		{Key: "b", Value: "2", ExpiresAtMs: 20},
+	}
+
+	problems := Verify(expected, written)
+	want := []string{"expected 3 entries, found 2", "a: checksum mismatch", "b: expiration mismatch", "c: missing"}
+	if strings.Join(problems, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected %v, got %v", want, problems)
+	}
+}
+
+func TestUnsupportedBackend(t *testing.T) {
+	if err := Write(&bytes.Buffer{}, "memcached", nil); err == nil {
+		t.Error("Expected an error for an unsupported backend")
+	}
+}
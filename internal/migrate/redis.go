@@ -0,0 +1,72 @@
+package migrate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// writeRedis writes a SET command with an absolute expiration (PXAT) per record, in the Redis
+// protocol so values need no escaping
+func writeRedis(w io.Writer, records []Record) error {
+	bw := bufio.NewWriter(w)
+	for _, record := range records {
+		args := []string{"SET", record.Key, record.Value, "PXAT", strconv.FormatInt(record.ExpiresAtMs, 10)}
+		fmt.Fprintf(bw, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(bw, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	return bw.Flush()
+}
+
+func readRedis(r io.Reader) ([]Record, error) {
+	br := bufio.NewReader(r)
+	var records []Record
+	for {
+		header, err := br.ReadString('\n')
+		if err == io.EOF && header == "" {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		count, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(header, "*"), "\r\n"))
+		if err != nil || !strings.HasPrefix(header, "*") || count != 5 {
+			return nil, fmt.Errorf("unexpected command header %q", header)
+		}
+
+		args := make([]string, count)
+		for i := range args {
+			if args[i], err = readBulkString(br); err != nil {
+				return nil, err
+			}
+		}
+		if args[0] != "SET" || args[3] != "PXAT" {
+			return nil, fmt.Errorf("unexpected command %s", args[0])
+		}
+		expiresAt, err := strconv.ParseInt(args[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expiration %q", args[4])
+		}
+		records = append(records, Record{Key: args[1], Value: args[2], ExpiresAtMs: expiresAt})
+	}
+}
+
+func readBulkString(br *bufio.Reader) (string, error) {
+	header, err := br.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	length, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(header, "$"), "\r\n"))
+	if err != nil || !strings.HasPrefix(header, "$") || length < 0 {
+		return "", fmt.Errorf("unexpected bulk string header %q", header)
+	}
+	buf := make([]byte, length+2)
+	if _, err := io.ReadFull(br, buf); err != nil {
+		return "", err
+	}
+	return string(buf[:length]), nil
+}
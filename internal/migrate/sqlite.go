@@ -0,0 +1,57 @@
+package migrate
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS cache_entries (key TEXT PRIMARY KEY, value TEXT NOT NULL, expires_at INTEGER NOT NULL, checksum TEXT NOT NULL);`
+
+// sqliteInsert is the statement written per record. Keys and values are hex literals, so they need
+// no quoting and every statement stays on one line.
+const sqliteInsert = `INSERT OR REPLACE INTO cache_entries (key, value, expires_at, checksum) VALUES (CAST(X'%s' AS TEXT), CAST(X'%s' AS TEXT), %d, '%s');`
+
+var sqliteInsertPattern = regexp.MustCompile(`^INSERT OR REPLACE INTO cache_entries \(key, value, expires_at, checksum\) VALUES \(CAST\(X'([0-9a-f]*)' AS TEXT\), CAST\(X'([0-9a-f]*)' AS TEXT\), (\d+), '([0-9a-f]+)'\);$`)
+
+// writeSQLite writes a script creating the cache_entries table and inserting every record in a
+// single transaction. The checksum column allows verifying the imported rows.
+func writeSQLite(w io.Writer, records []Record) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "BEGIN TRANSACTION;")
+	fmt.Fprintln(bw, sqliteSchema)
+	for _, record := range records {
+		fmt.Fprintf(bw, sqliteInsert+"\n", hex.EncodeToString([]byte(record.Key)), hex.EncodeToString([]byte(record.Value)), record.ExpiresAtMs, record.Checksum())
+	}
+	fmt.Fprintln(bw, "COMMIT;")
+	return bw.Flush()
+}
+
+func readSQLite(r io.Reader) ([]Record, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	var records []Record
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "INSERT") {
+			continue
+		}
+		match := sqliteInsertPattern.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("unexpected statement %.80q", line)
+		}
+		key, _ := hex.DecodeString(match[1])
+		value, _ := hex.DecodeString(match[2])
+		expiresAt, _ := strconv.ParseInt(match[3], 10, 64)
+		record := Record{Key: string(key), Value: string(value), ExpiresAtMs: expiresAt}
+		if record.Checksum() != match[4] {
+			return nil, fmt.Errorf("%s: checksum column doesn't match the row", record.Key)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,191 @@
+// Package offsets aggregates timing offsets submitted by listeners into a per-track consensus.
+package offsets
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxSubmissionsPerTrack bounds the submissions kept per track, dropping the oldest
+const maxSubmissionsPerTrack = 100
+
+// Submission is the offset a listener found to line up the lyrics of a track
+type Submission struct {
+	// Submitter identifies the listener, who has one submission per track
+	Submitter   string    `json:"submitter"`
+	OffsetMs    int64     `json:"offsetMs"`
+	SubmittedAt time.Time `json:"submittedAt"`
+}
+
+// Consensus is the offset agreed on by the submissions of a track
+type Consensus struct {
+	OffsetMs int64 `json:"offsetMs"`
+	// Agreeing is the number of submissions within the tolerance of each other backing OffsetMs
+	Agreeing    int `json:"agreeing"`
+	Submissions int `json:"submissions"`
+	// Applied is set when enough submissions agree for the offset to be applied to responses
+	Applied bool `json:"applied"`
+}
+
+// Store keeps the submissions of every track
+type Store struct {
+	mu     sync.Mutex
+	tracks map[string]*list.Element
+	// recency orders the tracks by their latest submission, the most recent first
+	recency     *list.List
+	minAgreeing int
+	toleranceMs int64
+	maxTracks   int
+}
+
+type track struct {
+	id          string
+	submissions []Submission
+}
+
+// NewStore creates a store applying an offset once minAgreeing submissions, and a majority of those
+// of the track, lie within toleranceMs of each other. It keeps the submissions of at most maxTracks
+// tracks, 0 for no bound, dropping those of the track submitted to the longest ago past it.
+func NewStore(minAgreeing int, toleranceMs int64, maxTracks int) *Store {
+	return &Store{tracks: map[string]*list.Element{}, recency: list.New(), minAgreeing: minAgreeing, toleranceMs: toleranceMs, maxTracks: maxTracks}
+}
+
+// submissionsLocked returns the submissions of a track
+func (s *Store) submissionsLocked(trackID string) []Submission {
+	if element, ok := s.tracks[trackID]; ok {
+		return element.Value.(*track).submissions
+	}
+	return nil
+}
+
+// setLocked sets the submissions of a track, making it the most recently submitted to
+func (s *Store) setLocked(trackID string, submissions []Submission) {
+	if element, ok := s.tracks[trackID]; ok {
+		element.Value.(*track).submissions = submissions
+		s.recency.MoveToFront(element)
+		return
+	}
+	s.tracks[trackID] = s.recency.PushFront(&track{id: trackID, submissions: submissions})
+}
+
+// Submit records a submission, replacing the earlier one of the same submitter, and returns the
+// new consensus of the track
+func (s *Store) Submit(trackID string, submission Submission) Consensus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	submissions := s.submissionsLocked(trackID)
+	for i, existing := range submissions {
+		if existing.Submitter == submission.Submitter {
+			submissions = append(submissions[:i], submissions[i+1:]...)
+			break
+		}
+	}
+	submissions = append(submissions, submission)
+	if len(submissions) > maxSubmissionsPerTrack {
+		submissions = submissions[len(submissions)-maxSubmissionsPerTrack:]
+	}
+	s.setLocked(trackID, submissions)
+	s.evictLocked()
+	return s.consensus(submissions)
+}
+
+// evictLocked drops the tracks submitted to the longest ago until at most maxTracks are left
+func (s *Store) evictLocked() {
+	for s.maxTracks > 0 && len(s.tracks) > s.maxTracks {
+		oldest := s.recency.Remove(s.recency.Back()).(*track)
+		delete(s.tracks, oldest.id)
+	}
+}
+
+// Consensus returns the consensus of a track
+func (s *Store) Consensus(trackID string) Consensus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.consensus(s.submissionsLocked(trackID))
+}
+
+// LastSubmittedAt returns when the latest submission of a track was made, the last time its
+// consensus could have changed, or the zero time if it has none
+func (s *Store) LastSubmittedAt(trackID string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return lastSubmittedAt(s.submissionsLocked(trackID))
+}
+
+func lastSubmittedAt(submissions []Submission) time.Time {
+	var last time.Time
+	for _, submission := range submissions {
+		if submission.SubmittedAt.After(last) {
+			last = submission.SubmittedAt
+		}
+	}
+	return last
+}
+
+// consensus finds the largest group of submissions within the tolerance of each other and takes
+// its median, so a few outliers can't drag the offset
+func (s *Store) consensus(submissions []Submission) Consensus {
+	if len(submissions) == 0 {
+		return Consensus{}
+	}
+	values := make([]int64, len(submissions))
+	for i, submission := range submissions {
+		values[i] = submission.OffsetMs
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	bestStart, bestEnd := 0, 1
+	for start, end := 0, 0; start < len(values); start++ {
+		for end < len(values) && values[end]-values[start] <= s.toleranceMs {
+			end++
+		}
+		if end-start > bestEnd-bestStart {
+			bestStart, bestEnd = start, end
+		}
+	}
+
+	agreeing := bestEnd - bestStart
+	return Consensus{
+		OffsetMs:    values[bestStart+agreeing/2],
+		Agreeing:    agreeing,
+		Submissions: len(values),
+		Applied:     agreeing >= s.minAgreeing && agreeing*2 > len(values),
+	}
+}
+
+// Snapshot returns a copy of the submissions of every track, for persisting
+func (s *Store) Snapshot() map[string][]Submission {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := make(map[string][]Submission, len(s.tracks))
+	for trackID, element := range s.tracks {
+		snapshot[trackID] = append([]Submission(nil), element.Value.(*track).submissions...)
+	}
+	return snapshot
+}
+
+// Restore replaces the submissions of every track with a snapshot, keeping those of the tracks
+// submitted to last when it holds more than maxTracks
+func (s *Store) Restore(snapshot map[string][]Submission) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trackIDs := make([]string, 0, len(snapshot))
+	for trackID := range snapshot {
+		trackIDs = append(trackIDs, trackID)
+	}
+	sort.Slice(trackIDs, func(i, j int) bool {
+		return lastSubmittedAt(snapshot[trackIDs[i]]).Before(lastSubmittedAt(snapshot[trackIDs[j]]))
+	})
+
+	s.tracks = make(map[string]*list.Element, len(snapshot))
+	s.recency.Init()
+	for _, trackID := range trackIDs {
+		s.setLocked(trackID, append([]Submission(nil), snapshot[trackID]...))
+	}
+	s.evictLocked()
+}
@@ -0,0 +1,98 @@
+package offsets
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestConsensus(t *testing.T) {
+	tests := []struct {
+		name     string
+		offsets  []int64
+		expected Consensus
+	}{
+		{"No submissions", nil, Consensus{}},
+		{"Too few", []int64{1000, 1100}, Consensus{OffsetMs: 1100, Agreeing: 2, Submissions: 2}},
+		{"Agreeing", []int64{1000, 1100, 1200}, Consensus{OffsetMs: 1100, Agreeing: 3, Submissions: 3, Applied: true}},
+		{"Outliers", []int64{-5000, 1000, 1100, 1200, 9000}, Consensus{OffsetMs: 1100, Agreeing: 3, Submissions: 5, Applied: true}},
+		{"No majority", []int64{-2000, -1900, -1800, 1000, 1100, 1200}, Consensus{OffsetMs: -1900, Agreeing: 3, Submissions: 6}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewStore(3, 250, 0)
+			for i, offset := range tt.offsets {
+				store.Submit("track", Submission{Submitter: fmt.Sprint(i), OffsetMs: offset, SubmittedAt: time.Now()})
+			}
+			if got := store.Consensus("track"); got != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSubmitReplacesSubmitter(t *testing.T) {
+	store := NewStore(1, 250, 0)
+	store.Submit("track", Submission{Submitter: "a", OffsetMs: 1000})
+	consensus := store.Submit("track", Submission{Submitter: "a", OffsetMs: -500})
+
+	if consensus.Submissions != 1 || consensus.OffsetMs != -500 {
+		t.Errorf("Expected the second submission to replace the first, got %+v", consensus)
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	store := NewStore(1, 250, 0)
+	store.Submit("track", Submission{Submitter: "a", OffsetMs: 1000})
+
+	restored := NewStore(1, 250, 0)
+	restored.Restore(store.Snapshot())
+	if got := restored.Consensus("track"); got.OffsetMs != 1000 || !got.Applied {
+		t.Errorf("Expected the restored store to apply 1000ms, got %+v", got)
+	}
+}
+
+func TestLastSubmittedAt(t *testing.T) {
+	store := NewStore(1, 250, 0)
+	if got := store.LastSubmittedAt("track"); !got.IsZero() {
+		t.Errorf("Expected no submission time, got %v", got)
+	}
+
+	latest := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store.Submit("track", Submission{Submitter: "a", OffsetMs: 1000, SubmittedAt: latest})
+	store.Submit("track", Submission{Submitter: "b", OffsetMs: 1000, SubmittedAt: latest.Add(-time.Hour)})
+	if got := store.LastSubmittedAt("track"); !got.Equal(latest) {
+		t.Errorf("Expected %v, got %v", latest, got)
+	}
+}
+
+func TestStoreBoundsTracks(t *testing.T) {
+	store := NewStore(1, 250, 2)
+	now := time.Now()
+	store.Submit("a", Submission{Submitter: "x", OffsetMs: 100, SubmittedAt: now.Add(-2 * time.Minute)})
+	store.Submit("b", Submission{Submitter: "x", OffsetMs: 200, SubmittedAt: now.Add(-time.Minute)})
+	store.Submit("a", Submission{Submitter: "y", OffsetMs: 100, SubmittedAt: now})
+	store.Submit("c", Submission{Submitter: "x", OffsetMs: 300, SubmittedAt: now})
+
+	snapshot := store.Snapshot()
+	if len(snapshot) != 2 || snapshot["b"] != nil {
+		t.Errorf("Expected the track submitted to the longest ago to be dropped, got %v", snapshot)
+	}
+}
+
+func TestRestoreBoundsTracks(t *testing.T) {
+	store := NewStore(1, 250, 2)
+	now := time.Now()
+	store.Restore(map[string][]Submission{
+		"a": {{Submitter: "x", OffsetMs: 100, SubmittedAt: now}},
+		"b": {{Submitter: "x", OffsetMs: 200, SubmittedAt: now.Add(-time.Minute)}},
+		"c": {{Submitter: "x", OffsetMs: 300, SubmittedAt: now.Add(-time.Second)}},
+	})
+	store.Submit("d", Submission{Submitter: "x", OffsetMs: 400, SubmittedAt: now})
+
+	snapshot := store.Snapshot()
+	if len(snapshot) != 2 || snapshot["a"] == nil || snapshot["d"] == nil {
+		t.Errorf("Expected the tracks submitted to last to be kept, got %v", snapshot)
+	}
+}
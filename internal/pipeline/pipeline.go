@@ -0,0 +1,97 @@
+// Package pipeline runs the stages of a request, each with its own timeout, and keeps metrics per stage.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// StageFunc is the work of a stage. It should return once ctx is done.
+type StageFunc func(ctx context.Context) error
+
+// StageStats are the metrics of a stage since startup
+type StageStats struct {
+	Stage    string  `json:"stage"`
+	Runs     int     `json:"runs"`
+	Failures int     `json:"failures"`
+	TimedOut int     `json:"timedOut"`
+	AvgMs    float64 `json:"avgMs"`
+	MaxMs    int64   `json:"maxMs"`
+}
+
+type stageTotals struct {
+	runs, failures, timedOut int
+	total, max               time.Duration
+}
+
+// Metrics runs stages and records how they went
+type Metrics struct {
+	mu     sync.Mutex
+	stages map[string]*stageTotals
+}
+
+// NewMetrics creates empty metrics
+func NewMetrics() *Metrics {
+	return &Metrics{stages: map[string]*stageTotals{}}
+}
+
+// Run runs a stage with a context cancelled after timeout, or without a timeout of its own when
+// timeout is 0, and records its duration and outcome. A stage failing after its timeout passed is
+// counted as timed out.
+func (m *Metrics) Run(ctx context.Context, stage string, timeout time.Duration, fn StageFunc) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := fn(ctx)
+	m.record(stage, time.Since(start), err, err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded))
+	return err
+}
+
+func (m *Metrics) record(stage string, elapsed time.Duration, err error, timedOut bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	totals, ok := m.stages[stage]
+	if !ok {
+		totals = &stageTotals{}
+		m.stages[stage] = totals
+	}
+	totals.runs++
+	totals.total += elapsed
+	if elapsed > totals.max {
+		totals.max = elapsed
+	}
+	if err != nil {
+		totals.failures++
+	}
+	if timedOut {
+		totals.timedOut++
+	}
+}
+
+// Snapshot returns the metrics of every stage run so far, by stage name
+func (m *Metrics) Snapshot() []StageStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make([]StageStats, 0, len(m.stages))
+	for stage, totals := range m.stages {
+		stats = append(stats, StageStats{
+			Stage:    stage,
+			Runs:     totals.runs,
+			Failures: totals.failures,
+			TimedOut: totals.timedOut,
+			AvgMs:    float64(totals.total.Microseconds()) / 1000 / float64(totals.runs),
+			MaxMs:    totals.max.Milliseconds(),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Stage < stats[j].Stage })
+	return stats
+}
@@ -0,0 +1,40 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	metrics := NewMetrics()
+	ctx := context.Background()
+
+	if err := metrics.Run(ctx, "fetch", 0, func(ctx context.Context) error { return nil }); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	failure := errors.New("upstream failed")
+	if err := metrics.Run(ctx, "fetch", 0, func(ctx context.Context) error { return failure }); err != failure {
+		t.Errorf("Expected the stage error, got %v", err)
+	}
+	err := metrics.Run(ctx, "enrich", 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the stage to time out, got %v", err)
+	}
+
+	stats := metrics.Snapshot()
+	if len(stats) != 2 {
+		t.Fatalf("Expected metrics of 2 stages, got %d", len(stats))
+	}
+	enrich, fetch := stats[0], stats[1]
+	if fetch.Stage != "fetch" || fetch.Runs != 2 || fetch.Failures != 1 || fetch.TimedOut != 0 {
+		t.Errorf("Expected 2 fetch runs with 1 failure, got %+v", fetch)
+	}
+	if enrich.Stage != "enrich" || enrich.Runs != 1 || enrich.TimedOut != 1 || enrich.MaxMs < 10 {
+		t.Errorf("Expected 1 timed out enrich run of at least 10ms, got %+v", enrich)
+	}
+}
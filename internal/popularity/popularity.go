@@ -0,0 +1,93 @@
+// Package popularity counts lyrics requests per track over a rolling window to find the most
+// requested tracks.
+package popularity
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// TrackCount is the number of requests of a track
+type TrackCount struct {
+	TrackID  string `json:"trackId"`
+	Requests int    `json:"requests"`
+}
+
+// bucket holds the counts of one slice of the window
+type bucket struct {
+	start  time.Time
+	counts map[string]int
+}
+
+// Counter counts the requests of every track over a rolling window. The window is split into
+// buckets that are reset as they fall out of it, so tracks nobody requests anymore are forgotten.
+type Counter struct {
+	mu         sync.Mutex
+	window     time.Duration
+	bucketSize time.Duration
+	buckets    []bucket
+	now        func() time.Time
+}
+
+// NewCounter creates a counter over the given window, split into the given number of buckets
+func NewCounter(window time.Duration, buckets int) *Counter {
+	return &Counter{
+		window:     window,
+		bucketSize: window / time.Duration(buckets),
+		buckets:    make([]bucket, buckets),
+		now:        time.Now,
+	}
+}
+
+// Window returns the duration requests are counted over
+func (c *Counter) Window() time.Duration {
+	return c.window
+}
+
+// Record counts a request of trackID
+func (c *Counter) Record(trackID string) {
+	now := c.now()
+	start := now.Truncate(c.bucketSize)
+	index := int(start.UnixNano()/int64(c.bucketSize)) % len(c.buckets)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b := &c.buckets[index]
+	if !b.start.Equal(start) {
+		*b = bucket{start: start, counts: map[string]int{}}
+	}
+	b.counts[trackID]++
+}
+
+// Top returns the n most requested tracks of the window, most requested first. Ties are broken
+// by track id so the order is stable.
+func (c *Counter) Top(n int) []TrackCount {
+	cutoff := c.now().Truncate(c.bucketSize).Add(-c.window)
+	totals := map[string]int{}
+	c.mu.Lock()
+	for _, b := range c.buckets {
+		if !b.start.After(cutoff) {
+			continue
+		}
+		for trackID, requests := range b.counts {
+			totals[trackID] += requests
+		}
+	}
+	c.mu.Unlock()
+
+	top := make([]TrackCount, 0, len(totals))
+	for trackID, requests := range totals {
+		top = append(top, TrackCount{TrackID: trackID, Requests: requests})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Requests != top[j].Requests {
+			return top[i].Requests > top[j].Requests
+		}
+		return top[i].TrackID < top[j].TrackID
+	})
+	if n < len(top) {
+		top = top[:n]
+	}
+	return top
+}
@@ -0,0 +1,58 @@
+package popularity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTop(t *testing.T) {
+	c := NewCounter(time.Hour, 4)
+	for trackID, requests := range map[string]int{"a": 3, "b": 5, "c": 3, "d": 1} {
+		for i := 0; i < requests; i++ {
+			c.Record(trackID)
+		}
+	}
+
+	top := c.Top(3)
+	expected := []TrackCount{{"b", 5}, {"a", 3}, {"c", 3}}
+	if len(top) != len(expected) {
+		t.Fatalf("Expected %d tracks, got %v", len(expected), top)
+	}
+	for i := range expected {
+		if top[i] != expected[i] {
+			t.Errorf("Expected %v at %d, got %v", expected[i], i, top[i])
+		}
+	}
+
+	if all := c.Top(10); len(all) != 4 {
+		t.Errorf("Expected all 4 tracks, got %v", all)
+	}
+}
+
+func TestTopForgetsRequestsOutsideTheWindow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := NewCounter(time.Hour, 4)
+	c.now = func() time.Time { return now }
+
+	c.Record("old")
+	c.Record("old")
+	now = now.Add(30 * time.Minute)
+	c.Record("recent")
+
+	if top := c.Top(10); len(top) != 2 || top[0].TrackID != "old" {
+		t.Errorf("Expected both tracks within the window, got %v", top)
+	}
+
+	now = now.Add(45 * time.Minute)
+	top := c.Top(10)
+	if len(top) != 1 || top[0] != (TrackCount{"recent", 1}) {
+		t.Errorf("Expected only the recent track, got %v", top)
+	}
+
+	// the bucket of the old requests is reused once the ring wraps around
+	now = now.Add(45 * time.Minute)
+	c.Record("new")
+	if top := c.Top(10); len(top) != 1 || top[0] != (TrackCount{"new", 1}) {
+		t.Errorf("Expected only the new track, got %v", top)
+	}
+}
@@ -0,0 +1,124 @@
+// Package acoustid is the client for the AcoustID fingerprint lookup.
+package acoustid
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"lyrics-api-go/internal/provider"
+)
+
+type Artist struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type Recording struct {
+	ID      string   `json:"id"`
+	Title   string   `json:"title"`
+	Artists []Artist `json:"artists"`
+}
+
+type Result struct {
+	ID         string      `json:"id"`
+	Score      float64     `json:"score"`
+	Recordings []Recording `json:"recordings"`
+}
+
+type LookupResponse struct {
+	Status  string   `json:"status"`
+	Results []Result `json:"results"`
+	Error   *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Client resolves Chromaprint fingerprints to recordings
+type Client struct {
+	URL        string
+	APIKey     string
+	MinScore   float64
+	HTTPClient *http.Client
+
+	// BeforeRequest, when set, is called with every outgoing request before it is sent
+	BeforeRequest func(req *http.Request)
+}
+
+// Lookup resolves a fingerprint to the title and artist of the best matching recording.
+// The result is empty when nothing matched with at least MinScore.
+func (c *Client) Lookup(ctx context.Context, fingerprint string, duration int) (provider.CanonicalTrack, error) {
+	data := url.Values{}
+	data.Set("client", c.APIKey)
+	data.Set("meta", "recordings")
+	data.Set("duration", strconv.Itoa(duration))
+	data.Set("fingerprint", fingerprint)
+
+	// fingerprints are too long for a query string so the lookup is sent as a form post
+	req, err := http.NewRequestWithContext(ctx, "POST", c.URL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return provider.CanonicalTrack{}, fmt.Errorf("error creating fingerprint request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if c.BeforeRequest != nil {
+		c.BeforeRequest(req)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return provider.CanonicalTrack{}, fmt.Errorf("error making fingerprint request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return provider.CanonicalTrack{}, fmt.Errorf("error reading fingerprint response: %v", err)
+	}
+
+	var lookupResp LookupResponse
+	if err := json.Unmarshal(body, &lookupResp); err != nil {
+		return provider.CanonicalTrack{}, fmt.Errorf("error parsing fingerprint response: %v", err)
+	}
+	if lookupResp.Status != "ok" {
+		if lookupResp.Error != nil {
+			return provider.CanonicalTrack{}, fmt.Errorf("fingerprint lookup failed: %s", lookupResp.Error.Message)
+		}
+		return provider.CanonicalTrack{}, fmt.Errorf("fingerprint lookup failed with status code %d", resp.StatusCode)
+	}
+
+	return BestMatch(lookupResp.Results, c.MinScore), nil
+}
+
+// BestMatch picks the first titled recording of the highest scoring result above minScore
+func BestMatch(results []Result, minScore float64) provider.CanonicalTrack {
+	var best *Result
+	for i := range results {
+		if results[i].Score < minScore || len(results[i].Recordings) == 0 {
+			continue
+		}
+		if best == nil || results[i].Score > best.Score {
+			best = &results[i]
+		}
+	}
+	if best == nil {
+		return provider.CanonicalTrack{}
+	}
+
+	for _, rec := range best.Recordings {
+		if rec.Title == "" {
+			continue
+		}
+		artists := make([]string, 0, len(rec.Artists))
+		for _, artist := range rec.Artists {
+			artists = append(artists, artist.Name)
+		}
+		return provider.CanonicalTrack{Song: rec.Title, Artist: strings.Join(artists, ", ")}
+	}
+
+	return provider.CanonicalTrack{}
+}
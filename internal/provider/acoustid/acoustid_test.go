@@ -0,0 +1,23 @@
+package acoustid
+
+import "testing"
+
+func TestBestMatch(t *testing.T) {
+	results := []Result{
+		{Score: 0.5, Recordings: []Recording{{Title: "Low score"}}},
+		{Score: 0.95, Recordings: []Recording{
+			{Title: ""},
+			{Title: "Get Lucky", Artists: []Artist{{Name: "Daft Punk"}, {Name: "Pharrell Williams"}}},
+		}},
+		{Score: 0.99},
+	}
+
+	got := BestMatch(results, 0.8)
+	if got.Song != "Get Lucky" || got.Artist != "Daft Punk, Pharrell Williams" {
+		t.Errorf("Expected Get Lucky by Daft Punk, Pharrell Williams, got %q by %q", got.Song, got.Artist)
+	}
+
+	if got := BestMatch(results[:1], 0.8); got.Song != "" {
+		t.Errorf("Expected no match below min score, got %q", got.Song)
+	}
+}
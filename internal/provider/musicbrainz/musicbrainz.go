@@ -0,0 +1,119 @@
+// Package musicbrainz is the client for the MusicBrainz recording search.
+package musicbrainz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"lyrics-api-go/internal/provider"
+)
+
+type ArtistCredit struct {
+	Name       string `json:"name"`
+	JoinPhrase string `json:"joinphrase"`
+	Artist     struct {
+		Name string `json:"name"`
+	} `json:"artist"`
+}
+
+type Recording struct {
+	ID           string         `json:"id"`
+	Score        int            `json:"score"`
+	Title        string         `json:"title"`
+	ArtistCredit []ArtistCredit `json:"artist-credit"`
+}
+
+type SearchResponse struct {
+	Recordings []Recording `json:"recordings"`
+}
+
+// Client searches MusicBrainz recordings
+type Client struct {
+	URL        string
+	UserAgent  string
+	MinScore   int
+	HTTPClient *http.Client
+
+	// BeforeRequest, when set, is called with every outgoing request before it is sent
+	BeforeRequest func(req *http.Request)
+}
+
+var luceneEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// CanonicalArtist joins the canonical artist names of a recording's artist credit
+func (rec Recording) CanonicalArtist() string {
+	var sb strings.Builder
+	for _, credit := range rec.ArtistCredit {
+		name := credit.Artist.Name
+		if name == "" {
+			name = credit.Name
+		}
+		sb.WriteString(name)
+		sb.WriteString(credit.JoinPhrase)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// Canonicalize searches for the song and artist and returns the canonical title and artist of
+// the best recording scoring at least MinScore. The result is empty when nothing matched.
+func (c *Client) Canonicalize(ctx context.Context, songName, artistName string) (provider.CanonicalTrack, error) {
+	var clauses []string
+	if songName != "" {
+		clauses = append(clauses, fmt.Sprintf(`recording:"%s"`, luceneEscaper.Replace(songName)))
+	}
+	if artistName != "" {
+		clauses = append(clauses, fmt.Sprintf(`artist:"%s"`, luceneEscaper.Replace(artistName)))
+	}
+
+	params := url.Values{}
+	params.Set("query", strings.Join(clauses, " AND "))
+	params.Set("fmt", "json")
+	params.Set("limit", "5")
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.URL+"?"+params.Encode(), nil)
+	if err != nil {
+		return provider.CanonicalTrack{}, err
+	}
+	req.Header.Set("User-Agent", c.UserAgent)
+	req.Header.Set("Accept", "application/json")
+	if c.BeforeRequest != nil {
+		c.BeforeRequest(req)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return provider.CanonicalTrack{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return provider.CanonicalTrack{}, &provider.HTTPStatusError{StatusCode: resp.StatusCode}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return provider.CanonicalTrack{}, err
+	}
+
+	var searchResp SearchResponse
+	if err := json.Unmarshal(body, &searchResp); err != nil {
+		return provider.CanonicalTrack{}, err
+	}
+
+	for _, rec := range searchResp.Recordings {
+		if rec.Score < c.MinScore {
+			break
+		}
+		if rec.Title == "" {
+			continue
+		}
+		return provider.CanonicalTrack{Song: rec.Title, Artist: rec.CanonicalArtist()}, nil
+	}
+
+	return provider.CanonicalTrack{}, nil
+}
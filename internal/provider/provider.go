@@ -0,0 +1,29 @@
+// Package provider holds what is shared between the upstream lyric and metadata providers.
+// Each provider lives in its own subpackage and only exposes a client and its response types.
+package provider
+
+import (
+	"fmt"
+	"time"
+)
+
+// HTTPStatusError is returned by providers when upstream responds with a non-200 status
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP request failed with status code %d", e.StatusCode)
+}
+
+// TokenCache stores upstream access tokens between requests
+type TokenCache interface {
+	Get(key string) (string, bool)
+	Set(key, value string, duration time.Duration)
+}
+
+// CanonicalTrack is the canonical title and artist of a recording, as resolved by a metadata provider
+type CanonicalTrack struct {
+	Song   string `json:"song"`
+	Artist string `json:"artist"`
+}
@@ -0,0 +1,438 @@
+// Package spotify is the client for the Spotify lyrics, search and token endpoints.
+package spotify
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"lyrics-api-go/internal/provider"
+	"lyrics-api-go/lyrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Config holds the upstream endpoints and credentials of the client
+type Config struct {
+	LyricsURL          string
+	TrackURL           string
+	TokenURL           string
+	TokenKey           string
+	AppPlatform        string
+	UserAgent          string
+	CookieStringFormat string
+	CookieValue        string
+	ClientID           string
+	ClientSecret       string
+	OauthTokenURL      string
+	OauthTokenKey      string
+	AudioAnalysisURL   string
+	AudioFeaturesURL   string
+	PlaylistURL        string
+}
+
+// Credentials are the secrets of the client, which can be rotated while it is in use
+type Credentials struct {
+	CookieValue  string
+	ClientID     string
+	ClientSecret string
+}
+
+// Client talks to Spotify. Access tokens are kept in the given token cache.
+type Client struct {
+	config     Config
+	httpClient *http.Client
+	tokens     provider.TokenCache
+
+	mu          sync.RWMutex
+	credentials Credentials
+
+	// BeforeRequest, when set, is called with every outgoing request before it is sent
+	BeforeRequest func(req *http.Request)
+}
+
+type TokenData struct {
+	AccessToken                      string `json:"accessToken"`
+	AccessTokenExpirationTimestampMs int64  `json:"accessTokenExpirationTimestampMs"`
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+type LyricsResponse struct {
+	Lyrics struct {
+		SyncType      string        `json:"syncType"`
+		Lines         []lyrics.Line `json:"lines"`
+		IsRtlLanguage bool          `json:"isRtlLanguage"`
+		Language      string        `json:"language"`
+	} `json:"lyrics"`
+}
+
+// syllableTiming is the syllable timing of the lines of a LyricsResponse with syllable-level sync,
+// every syllable given by its start time and the number of characters of the line it covers
+type syllableTiming struct {
+	Lyrics struct {
+		Lines []struct {
+			Syllables []struct {
+				StartTimeMs string `json:"startTimeMs"`
+				NumChars    int    `json:"numChars"`
+			} `json:"syllables"`
+		} `json:"lines"`
+	} `json:"lyrics"`
+}
+
+type TrackResponse struct {
+	Tracks struct {
+		Items []struct {
+			ID      string `json:"id"`
+			Artists []struct {
+				Name string `json:"name"`
+			} `json:"artists"`
+			Album struct {
+				ReleaseDate string `json:"release_date"`
+			} `json:"album"`
+		} `json:"items"`
+	} `json:"tracks"`
+}
+
+// PlaylistTracksResponse is a page of the tracks of a playlist. Track is null for removed tracks.
+type PlaylistTracksResponse struct {
+	Items []struct {
+		Track *struct {
+			ID string `json:"id"`
+		} `json:"track"`
+	} `json:"items"`
+	Next string `json:"next"`
+}
+
+// AudioAnalysis is the part of a track's audio analysis used to place lyrics. Times are in seconds.
+type AudioAnalysis struct {
+	Track struct {
+		Duration       float64 `json:"duration"`
+		Loudness       float64 `json:"loudness"`
+		EndOfFadeIn    float64 `json:"end_of_fade_in"`
+		StartOfFadeOut float64 `json:"start_of_fade_out"`
+	} `json:"track"`
+	Sections []struct {
+		Start      float64 `json:"start"`
+		Duration   float64 `json:"duration"`
+		Loudness   float64 `json:"loudness"`
+		Confidence float64 `json:"confidence"`
+	} `json:"sections"`
+}
+
+// AudioFeatures is the part of a track's audio features used to classify it
+type AudioFeatures struct {
+	// Instrumentalness is the confidence, from 0 to 1, that the track has no vocals
+	Instrumentalness float64 `json:"instrumentalness"`
+}
+
+// Track is a search result
+type Track struct {
+	ID      string
+	Artists []string
+	// ReleaseDate is the album release date, as precise as upstream knows it (YYYY, YYYY-MM or YYYY-MM-DD)
+	ReleaseDate string
+}
+
+// New creates a client
+func New(config Config, httpClient *http.Client, tokens provider.TokenCache) *Client {
+	return &Client{
+		config:      config,
+		httpClient:  httpClient,
+		tokens:      tokens,
+		credentials: Credentials{CookieValue: config.CookieValue, ClientID: config.ClientID, ClientSecret: config.ClientSecret},
+	}
+}
+
+// SetCredentials replaces the credentials used by the next requests. Tokens already obtained with
+// the previous credentials stay in the token cache until they expire or are removed.
+func (c *Client) SetCredentials(credentials Credentials) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.credentials = credentials
+}
+
+func (c *Client) currentCredentials() Credentials {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.credentials
+}
+
+func (c *Client) setCommonHeaders(req *http.Request) {
+	req.Header.Set("App-Platform", c.config.AppPlatform)
+	req.Header.Set("User-Agent", c.config.UserAgent)
+	req.Header.Set("cookie", fmt.Sprintf(c.config.CookieStringFormat, c.currentCredentials().CookieValue))
+}
+
+func (c *Client) makeHTTPRequest(ctx context.Context, method, url string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setCommonHeaders(req)
+	if c.BeforeRequest != nil {
+		c.BeforeRequest(req)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &provider.HTTPStatusError{StatusCode: resp.StatusCode}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// OauthAccessToken returns a client credentials token for the public Web API
+func (c *Client) OauthAccessToken(ctx context.Context) (string, error) {
+	if token, ok := c.tokens.Get(c.config.OauthTokenKey); ok {
+		log.Info("[Cache:OAuthToken] Using cached token")
+		return token, nil
+	}
+
+	credentials := c.currentCredentials()
+	auth := base64.StdEncoding.EncodeToString([]byte(credentials.ClientID + ":" + credentials.ClientSecret))
+
+	data := url.Values{}
+	data.Set("grant_type", "client_credentials")
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.config.OauthTokenURL,
+		strings.NewReader(data.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("error creating token request: %v", err)
+	}
+
+	req.Header.Set("Authorization", "Basic "+auth)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if c.BeforeRequest != nil {
+		c.BeforeRequest(req)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error making token request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading token response: %v", err)
+	}
+
+	var tokenResp TokenResponse
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", fmt.Errorf("error parsing token response: %v", err)
+	}
+
+	log.Warn("[Cache:OAuthToken] Caching token")
+	c.tokens.Set(c.config.OauthTokenKey, tokenResp.AccessToken, time.Duration(tokenResp.ExpiresIn)*time.Second)
+
+	return tokenResp.AccessToken, nil
+}
+
+// AccessToken returns the cookie-authenticated token used for the lyrics endpoint
+func (c *Client) AccessToken(ctx context.Context) (string, error) {
+	if token, ok := c.tokens.Get(c.config.TokenKey); ok {
+		log.Info("[Cache:Token] Using cached token")
+		return token, nil
+	}
+
+	body, err := c.makeHTTPRequest(ctx, "GET", c.config.TokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var tokenData TokenData
+	if err := json.Unmarshal(body, &tokenData); err != nil {
+		return "", err
+	}
+
+	expiresInSeconds := int64((tokenData.AccessTokenExpirationTimestampMs - time.Now().UnixNano()/int64(time.Millisecond)) / 1000)
+	c.tokens.Set(c.config.TokenKey, tokenData.AccessToken, time.Duration(expiresInSeconds)*time.Second)
+
+	return tokenData.AccessToken, nil
+}
+
+// SearchTracks searches for an escaped query and returns the results in upstream order
+func (c *Client) SearchTracks(ctx context.Context, query string) ([]Track, error) {
+	accessToken, err := c.OauthAccessToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting access token: %v", err)
+	}
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + accessToken,
+	}
+
+	body, err := c.makeHTTPRequest(ctx, "GET", c.config.TrackURL+query, headers)
+	if err != nil {
+		return nil, fmt.Errorf("error making search request: %v", err)
+	}
+
+	var trackResp TrackResponse
+	if err := json.Unmarshal(body, &trackResp); err != nil {
+		return nil, fmt.Errorf("error parsing search response: %v", err)
+	}
+
+	tracks := make([]Track, 0, len(trackResp.Tracks.Items))
+	for _, item := range trackResp.Tracks.Items {
+		track := Track{ID: item.ID, ReleaseDate: item.Album.ReleaseDate}
+		for _, artist := range item.Artists {
+			track.Artists = append(track.Artists, artist.Name)
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, nil
+}
+
+// LyricsRaw fetches the raw upstream lyrics response body of a track
+func (c *Client) LyricsRaw(ctx context.Context, trackID string) ([]byte, error) {
+	accessToken, err := c.AccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + accessToken,
+	}
+	return c.makeHTTPRequest(ctx, "GET", c.config.LyricsURL+trackID+"?format=json&market=from_token", headers)
+}
+
+// Lyrics fetches and parses the lyrics of a track
+func (c *Client) Lyrics(ctx context.Context, trackID string) (*LyricsResponse, error) {
+	body, err := c.LyricsRaw(ctx, trackID)
+	if err != nil {
+		return nil, err
+	}
+
+	if body == nil {
+		return nil, nil
+	}
+
+	var lyricsResp LyricsResponse
+	if err := json.Unmarshal(body, &lyricsResp); err != nil {
+		return nil, err
+	}
+	var timing syllableTiming
+	if err := json.Unmarshal(body, &timing); err != nil {
+		return nil, err
+	}
+	for i, line := range timing.Lyrics.Lines {
+		if i >= len(lyricsResp.Lyrics.Lines) || len(line.Syllables) == 0 {
+			continue
+		}
+		starts, lengths := make([]int64, len(line.Syllables)), make([]int, len(line.Syllables))
+		for j, syllable := range line.Syllables {
+			starts[j], _ = strconv.ParseInt(syllable.StartTimeMs, 10, 64)
+			lengths[j] = syllable.NumChars
+		}
+		lyricsResp.Lyrics.Lines[i].Syllables = lyrics.SplitSyllables(lyricsResp.Lyrics.Lines[i].Words, starts, lengths)
+	}
+
+	return &lyricsResp, nil
+}
+
+// AudioAnalysis fetches the audio analysis of a track
+func (c *Client) AudioAnalysis(ctx context.Context, trackID string) (*AudioAnalysis, error) {
+	accessToken, err := c.OauthAccessToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting access token: %v", err)
+	}
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + accessToken,
+	}
+
+	body, err := c.makeHTTPRequest(ctx, "GET", c.config.AudioAnalysisURL+trackID, headers)
+	if err != nil {
+		return nil, fmt.Errorf("error making audio analysis request: %v", err)
+	}
+
+	var analysis AudioAnalysis
+	if err := json.Unmarshal(body, &analysis); err != nil {
+		return nil, fmt.Errorf("error parsing audio analysis response: %v", err)
+	}
+	return &analysis, nil
+}
+
+// AudioFeatures fetches the audio features of a track
+func (c *Client) AudioFeatures(ctx context.Context, trackID string) (*AudioFeatures, error) {
+	accessToken, err := c.OauthAccessToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting access token: %v", err)
+	}
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + accessToken,
+	}
+
+	body, err := c.makeHTTPRequest(ctx, "GET", c.config.AudioFeaturesURL+trackID, headers)
+	if err != nil {
+		return nil, fmt.Errorf("error making audio features request: %v", err)
+	}
+
+	var features AudioFeatures
+	if err := json.Unmarshal(body, &features); err != nil {
+		return nil, fmt.Errorf("error parsing audio features response: %v", err)
+	}
+	return &features, nil
+}
+
+// PlaylistTrackIDs returns the ids of the tracks of a playlist in playlist order, following the
+// pages of the playlist. Removed tracks and local files, which have no id, are left out.
+func (c *Client) PlaylistTrackIDs(ctx context.Context, playlistID string) ([]string, error) {
+	accessToken, err := c.OauthAccessToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting access token: %v", err)
+	}
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + accessToken,
+	}
+
+	var ids []string
+	pageURL := c.config.PlaylistURL + url.PathEscape(playlistID) + "/tracks?fields=items(track(id)),next&limit=100"
+	for pageURL != "" {
+		body, err := c.makeHTTPRequest(ctx, "GET", pageURL, headers)
+		if err != nil {
+			return nil, fmt.Errorf("error making playlist request: %v", err)
+		}
+
+		var page PlaylistTracksResponse
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("error parsing playlist response: %v", err)
+		}
+		for _, item := range page.Items {
+			if item.Track != nil && item.Track.ID != "" {
+				ids = append(ids, item.Track.ID)
+			}
+		}
+		pageURL = page.Next
+	}
+	return ids, nil
+}
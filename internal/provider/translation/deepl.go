@@ -0,0 +1,35 @@
+package translation
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// DeepL translates through the DeepL API
+type DeepL struct {
+	client
+}
+
+func (d *DeepL) Translate(ctx context.Context, texts []string, target string) ([]string, error) {
+	payload := map[string]interface{}{
+		"text":        texts,
+		"target_lang": strings.ToUpper(target),
+	}
+	header := http.Header{"Authorization": {"DeepL-Auth-Key " + d.apiKey}}
+
+	var resp struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+	if err := d.postJSON(ctx, d.url, payload, header, &resp); err != nil {
+		return nil, err
+	}
+
+	translations := make([]string, len(resp.Translations))
+	for i, t := range resp.Translations {
+		translations[i] = t.Text
+	}
+	return translations, checkCount(texts, translations)
+}
@@ -0,0 +1,38 @@
+package translation
+
+import (
+	"context"
+	"html"
+	"net/url"
+)
+
+// Google translates through the Google Cloud Translation v2 API
+type Google struct {
+	client
+}
+
+func (g *Google) Translate(ctx context.Context, texts []string, target string) ([]string, error) {
+	payload := map[string]interface{}{
+		"q":      texts,
+		"target": target,
+		"format": "text",
+	}
+
+	var resp struct {
+		Data struct {
+			Translations []struct {
+				TranslatedText string `json:"translatedText"`
+			} `json:"translations"`
+		} `json:"data"`
+	}
+	if err := g.postJSON(ctx, g.url+"?key="+url.QueryEscape(g.apiKey), payload, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	translations := make([]string, len(resp.Data.Translations))
+	for i, t := range resp.Data.Translations {
+		// entities can still show up for some language pairs even with format=text
+		translations[i] = html.UnescapeString(t.TranslatedText)
+	}
+	return translations, checkCount(texts, translations)
+}
@@ -0,0 +1,28 @@
+package translation
+
+import "context"
+
+// LibreTranslate translates through a LibreTranslate instance
+type LibreTranslate struct {
+	client
+}
+
+func (l *LibreTranslate) Translate(ctx context.Context, texts []string, target string) ([]string, error) {
+	payload := map[string]interface{}{
+		"q":      texts,
+		"source": "auto",
+		"target": target,
+		"format": "text",
+	}
+	if l.apiKey != "" {
+		payload["api_key"] = l.apiKey
+	}
+
+	var resp struct {
+		TranslatedText []string `json:"translatedText"`
+	}
+	if err := l.postJSON(ctx, l.url, payload, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.TranslatedText, checkCount(texts, resp.TranslatedText)
+}
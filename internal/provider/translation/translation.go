@@ -0,0 +1,103 @@
+// Package translation holds the clients of the machine translation backends.
+package translation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"lyrics-api-go/internal/provider"
+)
+
+// Translator translates a batch of texts into a target language, returning one translation per text
+type Translator interface {
+	Translate(ctx context.Context, texts []string, target string) ([]string, error)
+}
+
+// Config selects and configures a translation backend
+type Config struct {
+	// Backend is one of "libretranslate", "deepl" or "google"
+	Backend string
+	// URL overrides the backend's default endpoint, e.g. for a self-hosted LibreTranslate
+	URL    string
+	APIKey string
+}
+
+// New creates the translator of the configured backend
+func New(config Config, httpClient *http.Client, beforeRequest func(req *http.Request)) (Translator, error) {
+	c := client{url: config.URL, apiKey: config.APIKey, httpClient: httpClient, beforeRequest: beforeRequest}
+	switch config.Backend {
+	case "libretranslate":
+		if c.url == "" {
+			c.url = "https://libretranslate.com/translate"
+		}
+		return &LibreTranslate{c}, nil
+	case "deepl":
+		if c.url == "" {
+			c.url = "https://api-free.deepl.com/v2/translate"
+		}
+		return &DeepL{c}, nil
+	case "google":
+		if c.url == "" {
+			c.url = "https://translation.googleapis.com/language/translate/v2"
+		}
+		return &Google{c}, nil
+	}
+	return nil, fmt.Errorf("unknown translation backend %q", config.Backend)
+}
+
+type client struct {
+	url           string
+	apiKey        string
+	httpClient    *http.Client
+	beforeRequest func(req *http.Request)
+}
+
+// postJSON posts payload to url and decodes the JSON response into out
+func (c *client) postJSON(ctx context.Context, url string, payload interface{}, header http.Header, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding translation request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating translation request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if c.beforeRequest != nil {
+		c.beforeRequest(req)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making translation request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &provider.HTTPStatusError{StatusCode: resp.StatusCode}
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading translation response: %v", err)
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("error parsing translation response: %v", err)
+	}
+	return nil
+}
+
+func checkCount(texts, translations []string) error {
+	if len(translations) != len(texts) {
+		return fmt.Errorf("translation backend returned %d translations for %d texts", len(translations), len(texts))
+	}
+	return nil
+}
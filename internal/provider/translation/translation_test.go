@@ -0,0 +1,63 @@
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		backend  string
+		response interface{}
+	}{
+		{"libretranslate", map[string]interface{}{"translatedText": []string{"Hallo", "Welt"}}},
+		{"deepl", map[string]interface{}{"translations": []map[string]string{{"text": "Hallo"}, {"text": "Welt"}}}},
+		{"google", map[string]interface{}{"data": map[string]interface{}{
+			"translations": []map[string]string{{"translatedText": "Hallo"}, {"translatedText": "Welt"}},
+		}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.backend, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(tt.response)
+			}))
+			defer server.Close()
+
+			translator, err := New(Config{Backend: tt.backend, URL: server.URL, APIKey: "key"}, server.Client(), nil)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			got, err := translator.Translate(context.Background(), []string{"Hello", "World"}, "de")
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(got, []string{"Hallo", "Welt"}) {
+				t.Errorf("Expected [Hallo Welt], got %v", got)
+			}
+		})
+	}
+}
+
+func TestTranslateCountMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"translatedText": []string{"Hallo"}})
+	}))
+	defer server.Close()
+
+	translator, _ := New(Config{Backend: "libretranslate", URL: server.URL}, server.Client(), nil)
+	if _, err := translator.Translate(context.Background(), []string{"Hello", "World"}, "de"); err == nil {
+		t.Errorf("Expected an error for a missing translation, got nil")
+	}
+}
+
+func TestNewUnknownBackend(t *testing.T) {
+	if _, err := New(Config{Backend: "babelfish"}, http.DefaultClient, nil); err == nil {
+		t.Errorf("Expected an error for an unknown backend, got nil")
+	}
+}
@@ -0,0 +1,354 @@
+// Package redis is a minimal Redis client covering the commands the tiered cache and the
+// distributed rate limiter need, speaking the Redis protocol (RESP) over a pool of connections.
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Error is an error reply from the server
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// errNil is the reply to reads of keys that don't exist
+var errNil = errors.New("redis: nil reply")
+
+// ErrUnavailable is returned without contacting the server while it is backed off from after
+// failing to connect or timing out
+var ErrUnavailable = errors.New("redis: server unavailable, backing off")
+
+const (
+	// maxIdleConns is the number of connections kept open between commands
+	maxIdleConns = 8
+	// minBackoff and maxBackoff bound how long a failing server is left alone, doubling with every
+	// failure in a row
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 10 * time.Second
+)
+
+// conn is a connection with its buffered reader
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// Client sends commands to a Redis server. Concurrent commands use their own connections, up to
+// maxIdleConns of which are kept open; a connection is dropped after any error. Once connecting
+// fails or a command times out, commands fail with ErrUnavailable for a backoff, so requests
+// don't each wait for the timeout while Redis is down.
+type Client struct {
+	addr     string
+	password string
+	timeout  time.Duration
+	now      func() time.Time
+
+	mu   sync.Mutex
+	idle []*conn
+	// retryAt is when the server is contacted again after failures, backoff the next wait
+	retryAt time.Time
+	backoff time.Duration
+}
+
+// New creates a client for the server at addr. Every command, including connecting, must finish
+// within timeout. Connections are opened lazily.
+func New(addr, password string, timeout time.Duration) *Client {
+	return &Client{addr: addr, password: password, timeout: timeout, now: time.Now}
+}
+
+// Get returns the value of key and when it expires, which is the zero time for keys without expiration
+func (c *Client) Get(key string) (value string, expiresAt time.Time, found bool, err error) {
+	replies, err := c.do([]string{"GET", key}, []string{"PTTL", key})
+	if err != nil {
+		return "", time.Time{}, false, err
+	}
+	if replies[0] == errNil {
+		return "", time.Time{}, false, nil
+	}
+	if err, ok := replies[0].(error); ok {
+		return "", time.Time{}, false, err
+	}
+	value, _ = replies[0].(string)
+
+	ttl, _ := replies[1].(int64)
+	switch {
+	case ttl == -2:
+		// the key expired between the two commands
+		return "", time.Time{}, false, nil
+	case ttl > 0:
+		expiresAt = time.Now().Add(time.Duration(ttl) * time.Millisecond)
+	}
+	return value, expiresAt, true, nil
+}
+
+// Set stores value under key until expiresAt, in the same form as the migration import files
+func (c *Client) Set(key, value string, expiresAt time.Time) error {
+	return c.single("SET", key, value, "PXAT", strconv.FormatInt(expiresAt.UnixMilli(), 10))
+}
+
+// Delete removes keys and returns how many existed
+func (c *Client) Delete(keys ...string) (int, error) {
+	replies, err := c.do(append([]string{"DEL"}, keys...))
+	if err != nil {
+		return 0, err
+	}
+	if err, ok := replies[0].(error); ok {
+		return 0, err
+	}
+	count, _ := replies[0].(int64)
+	return int(count), nil
+}
+
+// Keys returns every key starting with prefix. Keys are found with SCAN, so keys written
+// meanwhile may be missed.
+func (c *Client) Keys(prefix string) ([]string, error) {
+	pattern := globEscaper.Replace(prefix) + "*"
+	var keys []string
+	cursor := "0"
+	for {
+		replies, err := c.do([]string{"SCAN", cursor, "MATCH", pattern, "COUNT", "100"})
+		if err != nil {
+			return nil, err
+		}
+		if err, ok := replies[0].(error); ok {
+			return nil, err
+		}
+		page, ok := replies[0].([]interface{})
+		if !ok || len(page) != 2 {
+			return nil, fmt.Errorf("redis: unexpected SCAN reply %v", replies[0])
+		}
+		cursor, _ = page[0].(string)
+		items, _ := page[1].([]interface{})
+		for _, item := range items {
+			if key, ok := item.(string); ok {
+				keys = append(keys, key)
+			}
+		}
+		if cursor == "0" || cursor == "" {
+			return keys, nil
+		}
+	}
+}
+
+// Eval runs a Lua script with keys and args and returns its reply, a string, int64 or
+// []interface{} of them, or nil for a nil reply
+func (c *Client) Eval(script string, keys []string, args ...string) (interface{}, error) {
+	command := append([]string{"EVAL", script, strconv.Itoa(len(keys))}, keys...)
+	replies, err := c.do(append(command, args...))
+	if err != nil {
+		return nil, err
+	}
+	if replies[0] == errNil {
+		return nil, nil
+	}
+	if err, ok := replies[0].(error); ok {
+		return nil, err
+	}
+	return replies[0], nil
+}
+
+// globEscaper escapes the characters SCAN MATCH patterns treat specially
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// Close closes the idle connections, a later command opens a new one
+func (c *Client) Close() error {
+	c.mu.Lock()
+	idle := c.idle
+	c.idle = nil
+	c.mu.Unlock()
+
+	var err error
+	for _, cn := range idle {
+		if closeErr := cn.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (c *Client) single(args ...string) error {
+	replies, err := c.do(args)
+	if err != nil {
+		return err
+	}
+	if err, ok := replies[0].(error); ok && err != errNil {
+		return err
+	}
+	return nil
+}
+
+// do pipelines commands and returns their replies. Error replies are returned as replies, only
+// connection and protocol errors fail the call.
+func (c *Client) do(commands ...[]string) ([]interface{}, error) {
+	cn, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+	cn.SetDeadline(time.Now().Add(c.timeout))
+
+	replies, err := roundTrip(cn, commands)
+	if err != nil {
+		cn.Close()
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			c.failed()
+		}
+		return nil, err
+	}
+	c.put(cn)
+	return replies, nil
+}
+
+// get returns an idle connection, or a new one unless the server is backed off from
+func (c *Client) get() (*conn, error) {
+	c.mu.Lock()
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	backedOff := c.now().Before(c.retryAt)
+	c.mu.Unlock()
+	if backedOff {
+		return nil, ErrUnavailable
+	}
+
+	cn, err := c.connect()
+	if err != nil {
+		c.failed()
+		return nil, err
+	}
+	return cn, nil
+}
+
+// put keeps cn open for the next command, unless enough connections are
+func (c *Client) put(cn *conn) {
+	c.mu.Lock()
+	c.backoff = 0
+	if len(c.idle) < maxIdleConns {
+		c.idle = append(c.idle, cn)
+		cn = nil
+	}
+	c.mu.Unlock()
+	if cn != nil {
+		cn.Close()
+	}
+}
+
+// failed backs off from the server, for twice as long as the last time up to maxBackoff
+func (c *Client) failed() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.backoff = min(max(c.backoff*2, minBackoff), maxBackoff)
+	c.retryAt = c.now().Add(c.backoff)
+}
+
+func (c *Client) connect() (*conn, error) {
+	netConn, err := net.DialTimeout("tcp", c.addr, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: netConn, reader: bufio.NewReader(netConn)}
+
+	if c.password == "" {
+		return cn, nil
+	}
+	cn.SetDeadline(time.Now().Add(c.timeout))
+	replies, err := roundTrip(cn, [][]string{{"AUTH", c.password}})
+	if err == nil {
+		err, _ = replies[0].(error)
+	}
+	if err != nil {
+		cn.Close()
+		return nil, fmt.Errorf("authenticating: %w", err)
+	}
+	return cn, nil
+}
+
+func roundTrip(cn *conn, commands [][]string) ([]interface{}, error) {
+	w := bufio.NewWriter(cn)
+	for _, args := range commands {
+		writeCommand(w, args)
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, len(commands))
+	for i := range replies {
+		reply, err := readReply(cn.reader)
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+func writeCommand(w *bufio.Writer, args []string) {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+}
+
+// readReply reads a reply as a string, int64, []interface{}, Error or errNil
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return Error(payload), nil
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		length, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", payload)
+		}
+		if length < 0 {
+			return errNil, nil
+		}
+		buf := make([]byte, length+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:length]), nil
+	case '*':
+		count, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed array length %q", payload)
+		}
+		if count < 0 {
+			return errNil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply type %q", kind)
+}
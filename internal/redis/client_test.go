@@ -0,0 +1,284 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer implements GET, PTTL, SET PXAT, DEL, SCAN and AUTH over a map, and EVAL by replying
+// with the keys and args of the script
+type fakeServer struct {
+	listener net.Listener
+	password string
+
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]int64
+}
+
+func newFakeServer(t *testing.T, password string) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Unable to listen on loopback: %v", err)
+	}
+	s := &fakeServer{listener: listener, password: password, values: map[string]string{}, expires: map[string]int64{}}
+	t.Cleanup(func() { listener.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authenticated := s.password == ""
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			return
+		}
+		items := reply.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i] = item.(string)
+		}
+
+		if args[0] == "AUTH" {
+			if args[1] != s.password {
+				fmt.Fprint(conn, "-WRONGPASS invalid password\r\n")
+				continue
+			}
+			authenticated = true
+			fmt.Fprint(conn, "+OK\r\n")
+			continue
+		}
+		if !authenticated {
+			fmt.Fprint(conn, "-NOAUTH Authentication required\r\n")
+			continue
+		}
+		fmt.Fprint(conn, s.execute(args))
+	}
+}
+
+func (s *fakeServer) execute(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch args[0] {
+	case "GET":
+		value, ok := s.values[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "PTTL":
+		if _, ok := s.values[args[1]]; !ok {
+			return ":-2\r\n"
+		}
+		return fmt.Sprintf(":%d\r\n", s.expires[args[1]]-time.Now().UnixMilli())
+	case "SET":
+		s.values[args[1]] = args[2]
+		s.expires[args[1]], _ = strconv.ParseInt(args[4], 10, 64)
+		return "+OK\r\n"
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, ok := s.values[key]; ok {
+				delete(s.values, key)
+				deleted++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", deleted)
+	case "SCAN":
+		// every match is returned in one page, patterns are only ever escaped prefixes
+		prefix := strings.ReplaceAll(strings.TrimSuffix(args[3], "*"), `\`, "")
+		var keys []string
+		for key := range s.values {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, fmt.Sprintf("$%d\r\n%s\r\n", len(key), key))
+			}
+		}
+		return fmt.Sprintf("*2\r\n$1\r\n0\r\n*%d\r\n%s", len(keys), strings.Join(keys, ""))
+	case "EVAL":
+		if args[1] == "return nil" {
+			return "$-1\r\n"
+		}
+		reply := fmt.Sprintf("*%d\r\n", len(args)-3)
+		for _, arg := range args[3:] {
+			reply += fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)
+		}
+		return reply
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func TestClientSetGetDelete(t *testing.T) {
+	server := newFakeServer(t, "")
+	client := New(server.listener.Addr().String(), "", time.Second)
+	defer client.Close()
+
+	expiresAt := time.Now().Add(time.Hour)
+	if err := client.Set("lyrics:1", "multi\r\nline", expiresAt); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	value, gotExpiresAt, found, err := client.Get("lyrics:1")
+	if err != nil || !found {
+		t.Fatalf("Expected lyrics:1 to be found, got found=%v err=%v", found, err)
+	}
+	if value != "multi\r\nline" {
+		t.Errorf("Expected value %q, got %q", "multi\r\nline", value)
+	}
+	if diff := gotExpiresAt.Sub(expiresAt); diff < -time.Second || diff > time.Second {
+		t.Errorf("Expected expiration near %v, got %v", expiresAt, gotExpiresAt)
+	}
+
+	if deleted, err := client.Delete("lyrics:1", "lyrics:missing"); err != nil || deleted != 1 {
+		t.Fatalf("Expected 1 key deleted, got %d, %v", deleted, err)
+	}
+	if _, _, found, err := client.Get("lyrics:1"); err != nil || found {
+		t.Errorf("Expected lyrics:1 to be deleted, got found=%v err=%v", found, err)
+	}
+}
+
+func TestClientKeys(t *testing.T) {
+	server := newFakeServer(t, "")
+	client := New(server.listener.Addr().String(), "", time.Second)
+	defer client.Close()
+
+	expiresAt := time.Now().Add(time.Hour)
+	for _, key := range []string{"lyrics:1", "lyrics:2", "track:a", "lyricsX"} {
+		if err := client.Set(key, "v", expiresAt); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	keys, err := client.Keys("lyrics:")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sort.Strings(keys)
+	if strings.Join(keys, ",") != "lyrics:1,lyrics:2" {
+		t.Errorf("Expected lyrics:1 and lyrics:2, got %v", keys)
+	}
+}
+
+func TestClientEval(t *testing.T) {
+	server := newFakeServer(t, "")
+	client := New(server.listener.Addr().String(), "", time.Second)
+	defer client.Close()
+
+	reply, err := client.Eval("return {KEYS[1], ARGV[1]}", []string{"ratelimit:ip"}, "1000")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	items, ok := reply.([]interface{})
+	if !ok || len(items) != 2 || items[0] != "ratelimit:ip" || items[1] != "1000" {
+		t.Errorf("Expected the keys and args back, got %v", reply)
+	}
+
+	if reply, err := client.Eval("return nil", nil); err != nil || reply != nil {
+		t.Errorf("Expected a nil reply, got %v, %v", reply, err)
+	}
+}
+
+func TestGlobEscaper(t *testing.T) {
+	if got := globEscaper.Replace(`a*b?[c]\`); got != `a\*b\?\[c\]\\` {
+		t.Errorf("Expected escaped pattern, got %q", got)
+	}
+}
+
+func TestClientAuth(t *testing.T) {
+	server := newFakeServer(t, "secret")
+
+	client := New(server.listener.Addr().String(), "secret", time.Second)
+	defer client.Close()
+	if err := client.Set("k", "v", time.Now().Add(time.Minute)); err != nil {
+		t.Errorf("Expected authenticated command to succeed, got %v", err)
+	}
+
+	wrong := New(server.listener.Addr().String(), "wrong", time.Second)
+	defer wrong.Close()
+	if err := wrong.Set("k", "v", time.Now().Add(time.Minute)); err == nil {
+		t.Error("Expected an error with the wrong password")
+	}
+}
+
+func TestClientReconnects(t *testing.T) {
+	server := newFakeServer(t, "")
+	client := New(server.listener.Addr().String(), "", time.Second)
+	defer client.Close()
+
+	if err := client.Set("k", "v", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// a dropped connection is replaced on the next command
+	client.idle[0].Close()
+	if _, _, found, err := client.Get("k"); err == nil {
+		t.Fatalf("Expected an error on the closed connection, got found=%v", found)
+	}
+	if _, _, found, err := client.Get("k"); err != nil || !found {
+		t.Errorf("Expected k after reconnecting, got found=%v err=%v", found, err)
+	}
+}
+
+func TestClientUnreachable(t *testing.T) {
+	client := New("127.0.0.1:1", "", 100*time.Millisecond)
+	now := time.Now()
+	client.now = func() time.Time { return now }
+	if _, _, _, err := client.Get("k"); err == nil || err == ErrUnavailable {
+		t.Errorf("Expected a connection error for an unreachable server, got %v", err)
+	}
+
+	// the server is left alone for a backoff doubling with every failure
+	if _, _, _, err := client.Get("k"); err != ErrUnavailable {
+		t.Errorf("Expected ErrUnavailable while backing off, got %v", err)
+	}
+	now = now.Add(minBackoff)
+	if _, _, _, err := client.Get("k"); err == nil || err == ErrUnavailable {
+		t.Errorf("Expected another connection attempt after the backoff, got %v", err)
+	}
+	now = now.Add(minBackoff)
+	if _, _, _, err := client.Get("k"); err != ErrUnavailable {
+		t.Errorf("Expected the backoff to double, got %v", err)
+	}
+}
+
+func TestClientConcurrentCommands(t *testing.T) {
+	server := newFakeServer(t, "")
+	client := New(server.listener.Addr().String(), "", time.Second)
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2*maxIdleConns; i++ {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			if err := client.Set(key, "v", time.Now().Add(time.Minute)); err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if _, _, found, err := client.Get(key); err != nil || !found {
+				t.Errorf("Expected %s, got found=%v err=%v", key, found, err)
+			}
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+	if len(client.idle) > maxIdleConns {
+		t.Errorf("Expected at most %d idle connections, got %d", maxIdleConns, len(client.idle))
+	}
+}
@@ -0,0 +1,78 @@
+// Package rollout decides which gradually launched features are enabled for a client
+package rollout
+
+import (
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// Controller enables features for a percentage of clients, and for every client from a minimum
+// version on
+type Controller struct {
+	percentages map[string]int
+	minVersions map[string]string
+}
+
+// New creates a controller from the rollout percentage and minimum client version of each feature.
+// Features without a percentage are enabled for everyone.
+func New(percentages map[string]int, minVersions map[string]string) *Controller {
+	return &Controller{percentages: percentages, minVersions: minVersions}
+}
+
+// Enabled reports whether feature is enabled for the client identified by subject. Clients are
+// bucketed by a hash of the feature and subject, so a client consistently falls in or out of a
+// rollout and ramping up only adds clients.
+func (c *Controller) Enabled(feature, subject, clientVersion string) bool {
+	percentage, ok := c.percentages[feature]
+	if !ok || percentage >= 100 {
+		return true
+	}
+	if minVersion, ok := c.minVersions[feature]; ok && clientVersion != "" && CompareVersions(clientVersion, minVersion) >= 0 {
+		return true
+	}
+	return bucket(feature, subject) < percentage
+}
+
+// Partial reports whether feature is enabled for some clients only, so whether a client gets it
+// depends on who the client is
+func (c *Controller) Partial(feature string) bool {
+	percentage, ok := c.percentages[feature]
+	if !ok || percentage >= 100 {
+		return false
+	}
+	_, hasMinVersion := c.minVersions[feature]
+	return percentage > 0 || hasMinVersion
+}
+
+// bucket maps a client to one of 100 buckets, independently for every feature
+func bucket(feature, subject string) int {
+	h := fnv.New32a()
+	h.Write([]byte(feature + "\n" + subject))
+	return int(h.Sum32() % 100)
+}
+
+// CompareVersions compares dotted numeric versions such as "2.10.1", ignoring a leading "v" and
+// treating missing or non-numeric parts as 0. It returns -1, 0 or 1.
+func CompareVersions(a, b string) int {
+	aParts := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bParts := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		x, y := versionPart(aParts, i), versionPart(bParts, i)
+		if x < y {
+			return -1
+		}
+		if x > y {
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionPart(parts []string, i int) int {
+	if i >= len(parts) {
+		return 0
+	}
+	n, _ := strconv.Atoi(parts[i])
+	return n
+}
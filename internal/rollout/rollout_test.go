@@ -0,0 +1,94 @@
+package rollout
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEnabled(t *testing.T) {
+	controller := New(map[string]int{"off": 0, "on": 100, "v2Shape": 0}, map[string]string{"v2Shape": "2.3.0"})
+
+	tests := []struct {
+		name          string
+		feature       string
+		clientVersion string
+		expected      bool
+	}{
+		{"unconfigured feature", "translations", "", true},
+		{"fully rolled out", "on", "", true},
+		{"not rolled out", "off", "", false},
+		{"client at minimum version", "v2Shape", "2.3.0", true},
+		{"client above minimum version", "v2Shape", "v2.10", true},
+		{"client below minimum version", "v2Shape", "2.2.9", false},
+		{"client without version", "v2Shape", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := controller.Enabled(tt.feature, "203.0.113.7", tt.clientVersion); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEnabledPercentage(t *testing.T) {
+	controller := New(map[string]int{"feature": 25}, nil)
+
+	enabled := 0
+	for i := 0; i < 10000; i++ {
+		subject := fmt.Sprintf("client-%d", i)
+		first := controller.Enabled("feature", subject, "")
+		if first != controller.Enabled("feature", subject, "") {
+			t.Fatalf("Expected %s to be bucketed consistently", subject)
+		}
+		if first {
+			enabled++
+		}
+	}
+
+	if enabled < 2200 || enabled > 2800 {
+		t.Errorf("Expected about 25%% of clients to be enabled, got %d of 10000", enabled)
+	}
+}
+
+func TestEnabledRampUpKeepsClients(t *testing.T) {
+	low := New(map[string]int{"feature": 10}, nil)
+	high := New(map[string]int{"feature": 50}, nil)
+
+	for i := 0; i < 1000; i++ {
+		subject := fmt.Sprintf("client-%d", i)
+		if low.Enabled("feature", subject, "") && !high.Enabled("feature", subject, "") {
+			t.Errorf("Expected %s to stay enabled when ramping up", subject)
+		}
+	}
+}
+
+func TestPartial(t *testing.T) {
+	c := New(map[string]int{"half": 50, "all": 100, "none": 0, "upgraded": 0}, map[string]string{"upgraded": "2.0.0"})
+	for feature, expected := range map[string]bool{"half": true, "all": false, "none": false, "upgraded": true, "unknown": false} {
+		if got := c.Partial(feature); got != expected {
+			t.Errorf("Expected Partial(%s) to be %v, got %v", feature, expected, got)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"2.3.0", "2.3.0", 0},
+		{"2.3", "2.3.0", 0},
+		{"2.10.0", "2.9.9", 1},
+		{"v1.0.0", "1.0.1", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			if got := CompareVersions(tt.a, tt.b); got != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package romanize
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+var pinyinArgs = func() pinyin.Args {
+	args := pinyin.NewArgs()
+	args.Style = pinyin.Tone
+	return args
+}()
+
+// chinese renders Han characters as tone-marked pinyin, one syllable per character
+func chinese(text string) string {
+	var words []string
+	var other strings.Builder
+	for _, r := range text {
+		if !unicode.Is(unicode.Han, r) {
+			other.WriteRune(r)
+			continue
+		}
+		if other.Len() > 0 {
+			words = append(words, attachPunctuation(&words, other.String()))
+			other.Reset()
+		}
+		syllables := pinyin.SinglePinyin(r, pinyinArgs)
+		if len(syllables) == 0 {
+			words = append(words, string(r))
+			continue
+		}
+		words = append(words, syllables[0])
+	}
+	if other.Len() > 0 {
+		words = append(words, attachPunctuation(&words, other.String()))
+	}
+	return joinWords(words)
+}
+
+// attachPunctuation appends leading punctuation of s to the last word, so "你好，世界" reads
+// "nǐ hǎo, shì jiè" rather than "nǐ hǎo ， shì jiè", and returns the rest of s
+func attachPunctuation(words *[]string, s string) string {
+	rest := strings.TrimLeftFunc(s, unicode.IsPunct)
+	if len(*words) > 0 && rest != s {
+		(*words)[len(*words)-1] += toASCIIPunctuation(s[:len(s)-len(rest)])
+		return rest
+	}
+	return toASCIIPunctuation(s)
+}
+
+var fullwidthPunctuation = strings.NewReplacer(
+	"，", ",", "。", ".", "！", "!", "？", "?", "：", ":", "；", ";", "、", ",",
+	"（", "(", "）", ")", "「", "\"", "」", "\"", "『", "\"", "』", "\"", "…", "...", "～", "~",
+)
+
+func toASCIIPunctuation(s string) string {
+	return fullwidthPunctuation.Replace(s)
+}
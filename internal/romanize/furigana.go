@@ -0,0 +1,95 @@
+package romanize
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Ruby is a piece of a line with its kana reading. Reading is empty for text that needs no furigana.
+type Ruby struct {
+	Text    string `json:"text"`
+	Reading string `json:"reading,omitempty"`
+}
+
+// Furigana splits Japanese text into ruby pairs, giving every run of kanji its hiragana reading.
+// Kana around the kanji of a word (okurigana) are kept as separate pairs without a reading. It returns
+// nil when text isn't Japanese, using the same rules as Romanize.
+func Furigana(text, language string) []Ruby {
+	isJapanese := hasScript(text, unicode.Hiragana, unicode.Katakana) ||
+		(hasScript(text, unicode.Han) && primarySubtag(language) == "ja")
+	if !isJapanese {
+		return nil
+	}
+	t := japaneseTokenizer()
+	if t == nil {
+		return nil
+	}
+
+	var rubies []Ruby
+	for _, token := range t.Tokenize(text) {
+		reading, ok := token.Reading()
+		if !ok || reading == "*" || !hasScript(token.Surface, unicode.Han) {
+			rubies = appendRuby(rubies, Ruby{Text: token.Surface})
+			continue
+		}
+		rubies = append(rubies, alignReading(token.Surface, hiragana(reading))...)
+	}
+	return rubies
+}
+
+// appendRuby appends r, merging it into the previous pair when neither has a reading
+func appendRuby(rubies []Ruby, r Ruby) []Ruby {
+	if n := len(rubies); n > 0 && r.Reading == "" && rubies[n-1].Reading == "" {
+		rubies[n-1].Text += r.Text
+		return rubies
+	}
+	return append(rubies, r)
+}
+
+// alignReading splits the reading of a word between its kanji runs by matching the word's kana
+// against the reading, e.g. 取り扱い/とりあつかい gives 取/と り 扱/あつか い
+func alignReading(surface, reading string) []Ruby {
+	var runs []string
+	var kanji []bool
+	for _, r := range surface {
+		isKanji := unicode.Is(unicode.Han, r) || r == '々'
+		if len(runs) == 0 || kanji[len(kanji)-1] != isKanji {
+			runs = append(runs, "")
+			kanji = append(kanji, isKanji)
+		}
+		runs[len(runs)-1] += string(r)
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for i, run := range runs {
+		if kanji[i] {
+			pattern.WriteString("(.+?)")
+		} else {
+			pattern.WriteString("(" + regexp.QuoteMeta(hiragana(run)) + ")")
+		}
+	}
+	pattern.WriteString("$")
+
+	match := regexp.MustCompile(pattern.String()).FindStringSubmatch(reading)
+	if match == nil {
+		// the reading doesn't line up with the kana of the word, annotate the word as a whole
+		return []Ruby{{Text: surface, Reading: reading}}
+	}
+
+	var rubies []Ruby
+	for i, run := range runs {
+		if kanji[i] {
+			rubies = append(rubies, Ruby{Text: run, Reading: match[i+1]})
+		} else {
+			rubies = appendRuby(rubies, Ruby{Text: run})
+		}
+	}
+	return rubies
+}
+
+// hiragana converts the katakana of s to hiragana
+func hiragana(s string) string {
+	return strings.Map(toHiragana, s)
+}
@@ -0,0 +1,43 @@
+package romanize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFurigana(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		language string
+		expected []Ruby
+	}{
+		{"kanji and particle", "私は猫", "ja", []Ruby{{"私", "わたし"}, {"は", ""}, {"猫", "ねこ"}}},
+		{"okurigana", "食べる", "ja", []Ruby{{"食", "た"}, {"べる", ""}}},
+		{"kana only", "ありがとう", "ja", []Ruby{{"ありがとう", ""}}},
+		{"chinese is not annotated", "我爱你", "zh", nil},
+		{"latin is not annotated", "Hello", "ja", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Furigana(tt.text, tt.language); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestAlignReading(t *testing.T) {
+	got := alignReading("取り扱い", "とりあつかい")
+	expected := []Ruby{{"取", "と"}, {"り", ""}, {"扱", "あつか"}, {"い", ""}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	got = alignReading("今日", "きょう")
+	expected = []Ruby{{"今日", "きょう"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,150 @@
+package romanize
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/ikawaha/kagome-dict/ipa"
+	"github.com/ikawaha/kagome/v2/tokenizer"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	tokenizerOnce sync.Once
+	jaTokenizer   *tokenizer.Tokenizer
+)
+
+// japaneseTokenizer loads the morphological dictionary on first use, as it takes a while and
+// most instances never see Japanese lyrics. It returns nil if the dictionary failed to load.
+func japaneseTokenizer() *tokenizer.Tokenizer {
+	tokenizerOnce.Do(func() {
+		var err error
+		if jaTokenizer, err = tokenizer.New(ipa.Dict(), tokenizer.OmitBosEos()); err != nil {
+			log.Errorf("[Romanization] Unable to load the Japanese dictionary, only kana will be romanized: %v", err)
+		}
+	})
+	return jaTokenizer
+}
+
+// particleReadings are particles pronounced differently from how they are written
+var particleReadings = map[string]string{"は": "wa", "へ": "e", "を": "o"}
+
+// japanese renders text as Hepburn romaji, one word per morpheme. Kanji are read through the
+// morphological dictionary; kana are converted directly.
+func japanese(text string) string {
+	t := japaneseTokenizer()
+	if t == nil {
+		return kanaToRomaji(text)
+	}
+
+	var words []string
+	for _, token := range t.Tokenize(text) {
+		surface := token.Surface
+		if pos := token.POS(); len(pos) > 0 && pos[0] == "助詞" {
+			if reading, ok := particleReadings[surface]; ok {
+				words = append(words, reading)
+				continue
+			}
+		}
+		if pos := token.POS(); len(pos) > 0 && pos[0] == "記号" {
+			words = append(words, attachPunctuation(&words, surface))
+			continue
+		}
+
+		reading, ok := token.Reading()
+		if !ok || reading == "*" || !hasScript(surface, unicode.Han, unicode.Hiragana, unicode.Katakana) {
+			reading = surface
+		}
+		words = append(words, kanaToRomaji(reading))
+	}
+	return joinWords(words)
+}
+
+var kanaRomaji = map[string]string{
+	"あ": "a", "い": "i", "う": "u", "え": "e", "お": "o",
+	"か": "ka", "き": "ki", "く": "ku", "け": "ke", "こ": "ko",
+	"さ": "sa", "し": "shi", "す": "su", "せ": "se", "そ": "so",
+	"た": "ta", "ち": "chi", "つ": "tsu", "て": "te", "と": "to",
+	"な": "na", "に": "ni", "ぬ": "nu", "ね": "ne", "の": "no",
+	"は": "ha", "ひ": "hi", "ふ": "fu", "へ": "he", "ほ": "ho",
+	"ま": "ma", "み": "mi", "む": "mu", "め": "me", "も": "mo",
+	"や": "ya", "ゆ": "yu", "よ": "yo",
+	"ら": "ra", "り": "ri", "る": "ru", "れ": "re", "ろ": "ro",
+	"わ": "wa", "ゐ": "i", "ゑ": "e", "を": "o", "ん": "n",
+	"が": "ga", "ぎ": "gi", "ぐ": "gu", "げ": "ge", "ご": "go",
+	"ざ": "za", "じ": "ji", "ず": "zu", "ぜ": "ze", "ぞ": "zo",
+	"だ": "da", "ぢ": "ji", "づ": "zu", "で": "de", "ど": "do",
+	"ば": "ba", "び": "bi", "ぶ": "bu", "べ": "be", "ぼ": "bo",
+	"ぱ": "pa", "ぴ": "pi", "ぷ": "pu", "ぺ": "pe", "ぽ": "po",
+	"ゔ": "vu",
+	"ぁ": "a", "ぃ": "i", "ぅ": "u", "ぇ": "e", "ぉ": "o", "ゃ": "ya", "ゅ": "yu", "ょ": "yo", "ゎ": "wa",
+	"きゃ": "kya", "きゅ": "kyu", "きょ": "kyo", "しゃ": "sha", "しゅ": "shu", "しょ": "sho",
+	"ちゃ": "cha", "ちゅ": "chu", "ちょ": "cho", "にゃ": "nya", "にゅ": "nyu", "にょ": "nyo",
+	"ひゃ": "hya", "ひゅ": "hyu", "ひょ": "hyo", "みゃ": "mya", "みゅ": "myu", "みょ": "myo",
+	"りゃ": "rya", "りゅ": "ryu", "りょ": "ryo", "ぎゃ": "gya", "ぎゅ": "gyu", "ぎょ": "gyo",
+	"じゃ": "ja", "じゅ": "ju", "じょ": "jo", "びゃ": "bya", "びゅ": "byu", "びょ": "byo",
+	"ぴゃ": "pya", "ぴゅ": "pyu", "ぴょ": "pyo",
+	"しぇ": "she", "ちぇ": "che", "じぇ": "je", "てぃ": "ti", "でぃ": "di", "とぅ": "tu",
+	"ふぁ": "fa", "ふぃ": "fi", "ふぇ": "fe", "ふぉ": "fo", "うぃ": "wi", "うぇ": "we", "うぉ": "wo",
+	"ゔぁ": "va", "ゔぃ": "vi", "ゔぇ": "ve", "ゔぉ": "vo", "つぁ": "tsa",
+}
+
+// toHiragana maps katakana to the corresponding hiragana, leaving other characters as they are
+func toHiragana(r rune) rune {
+	if r >= 'ァ' && r <= 'ヶ' {
+		return r - ('ァ' - 'ぁ')
+	}
+	return r
+}
+
+// kanaToRomaji converts hiragana and katakana to Hepburn romaji. Small tsu doubles the following
+// consonant and the long vowel mark repeats the preceding vowel.
+func kanaToRomaji(text string) string {
+	runes := []rune(text)
+	for i, r := range runes {
+		runes[i] = toHiragana(r)
+	}
+
+	var b strings.Builder
+	geminate := false
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch r {
+		case 'っ':
+			geminate = true
+			continue
+		case 'ー':
+			if s := b.String(); len(s) > 0 && strings.ContainsRune("aeiou", rune(s[len(s)-1])) {
+				b.WriteByte(s[len(s)-1])
+			}
+			continue
+		}
+
+		romaji, ok := "", false
+		if i+1 < len(runes) {
+			if romaji, ok = kanaRomaji[string(runes[i:i+2])]; ok {
+				i++
+			}
+		}
+		if !ok {
+			if romaji, ok = kanaRomaji[string(r)]; !ok {
+				geminate = false
+				b.WriteString(toASCIIPunctuation(string(r)))
+				continue
+			}
+		}
+
+		if geminate {
+			if strings.HasPrefix(romaji, "ch") {
+				b.WriteByte('t')
+			} else if romaji[0] != 'a' && romaji[0] != 'i' && romaji[0] != 'u' && romaji[0] != 'e' && romaji[0] != 'o' {
+				b.WriteByte(romaji[0])
+			}
+			geminate = false
+		}
+		b.WriteString(romaji)
+	}
+	return b.String()
+}
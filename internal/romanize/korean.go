@@ -0,0 +1,61 @@
+package romanize
+
+import "strings"
+
+const (
+	hangulBase  = 0xAC00
+	hangulLast  = 0xD7A3
+	jungCount   = 21
+	jongCount   = 28
+	rieulChoIx  = 5  // ㄹ as an initial
+	silentChoIx = 11 // ㅇ as an initial is silent
+	rieulJongIx = 8  // ㄹ as a final
+)
+
+var (
+	choseong  = []string{"g", "kk", "n", "d", "tt", "r", "m", "b", "pp", "s", "ss", "", "j", "jj", "ch", "k", "t", "p", "h"}
+	jungseong = []string{"a", "ae", "ya", "yae", "eo", "e", "yeo", "ye", "o", "wa", "wae", "oe", "yo", "u", "wo", "we", "wi", "yu", "eu", "ui", "i"}
+	jongseong = []string{"", "k", "k", "k", "n", "n", "n", "t", "l", "k", "m", "p", "t", "t", "p", "l", "m", "p", "p", "t", "t", "ng", "t", "t", "k", "t", "p", "t"}
+	// jongseongLiaison is how a final is read when the next syllable starts with a silent ㅇ
+	jongseongLiaison = []string{"", "g", "kk", "ks", "n", "nj", "n", "d", "r", "lg", "lm", "lb", "ls", "lt", "lp", "r", "m", "b", "bs", "s", "ss", "ng", "j", "ch", "k", "t", "p", ""}
+)
+
+// korean renders Hangul in Revised Romanization, including the carry-over of a final consonant into
+// a following vowel-initial syllable and ㄹㄹ as "ll"
+func korean(text string) string {
+	runes := []rune(text)
+	var b strings.Builder
+	for i, r := range runes {
+		if r < hangulBase || r > hangulLast {
+			b.WriteString(toASCIIPunctuation(string(r)))
+			continue
+		}
+
+		s := int(r - hangulBase)
+		cho, jung, jong := s/(jungCount*jongCount), (s%(jungCount*jongCount))/jongCount, s%jongCount
+
+		if cho == rieulChoIx && i > 0 && isHangul(runes[i-1]) && int(runes[i-1]-hangulBase)%jongCount == rieulJongIx {
+			b.WriteString("l")
+		} else {
+			b.WriteString(choseong[cho])
+		}
+		b.WriteString(jungseong[jung])
+
+		if jong == 0 {
+			continue
+		}
+		if i+1 < len(runes) && isHangul(runes[i+1]) {
+			nextCho := int(runes[i+1]-hangulBase) / (jungCount * jongCount)
+			if nextCho == silentChoIx {
+				b.WriteString(jongseongLiaison[jong])
+				continue
+			}
+		}
+		b.WriteString(jongseong[jong])
+	}
+	return b.String()
+}
+
+func isHangul(r rune) bool {
+	return r >= hangulBase && r <= hangulLast
+}
@@ -0,0 +1,48 @@
+// Package romanize renders lyrics in Latin script (pinyin for Chinese,
+// Hepburn romaji for Japanese, Revised Romanization for Korean and letter-by-letter transliteration
+// for other alphabets) and annotates Japanese with furigana.
+package romanize
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Romanize returns text in Latin script. language is the lyrics language (e.g. "ja") and decides
+// whether text written only in Han characters is read as Japanese or Chinese; text that contains
+// kana is always read as Japanese. Characters of other scripts are kept as they are.
+func Romanize(text, language string) string {
+	switch {
+	case hasScript(text, unicode.Hiragana, unicode.Katakana):
+		return japanese(text)
+	case hasScript(text, unicode.Han) && primarySubtag(language) == "ja":
+		return japanese(text)
+	case hasScript(text, unicode.Han):
+		return chinese(text)
+	case hasScript(text, unicode.Hangul):
+		return korean(text)
+	}
+	return text
+}
+
+func hasScript(text string, tables ...*unicode.RangeTable) bool {
+	for _, r := range text {
+		if unicode.In(r, tables...) {
+			return true
+		}
+	}
+	return false
+}
+
+func primarySubtag(language string) string {
+	language = strings.ToLower(language)
+	if i := strings.IndexAny(language, "-_"); i >= 0 {
+		return language[:i]
+	}
+	return language
+}
+
+// joinWords joins romanized words with single spaces, dropping empty ones
+func joinWords(words []string) string {
+	return strings.Join(strings.Fields(strings.Join(words, " ")), " ")
+}
@@ -0,0 +1,53 @@
+package romanize
+
+import "testing"
+
+func TestRomanize(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		language string
+		expected string
+	}{
+		{"chinese", "我爱你", "zh", "wǒ ài nǐ"},
+		{"chinese punctuation", "你好，世界", "zh", "nǐ hǎo, shì jiè"},
+		{"chinese with latin", "我爱你baby", "zh", "wǒ ài nǐ baby"},
+		{"japanese kana", "ありがとう", "ja", "arigatou"},
+		{"japanese kanji and particle", "私は猫", "ja", "watashi wa neko"},
+		{"japanese kanji only", "東京", "ja", "toukyou"},
+		{"korean", "사랑해", "ko", "saranghae"},
+		{"korean liaison", "한국어", "ko", "hangugeo"},
+		{"korean double rieul", "빨리", "ko", "ppalli"},
+		{"korean with spaces", "안녕 세상", "ko", "annyeong sesang"},
+		{"latin untouched", "Hello world", "en", "Hello world"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Romanize(tt.text, tt.language); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestKanaToRomaji(t *testing.T) {
+	tests := []struct {
+		kana     string
+		expected string
+	}{
+		{"きょう", "kyou"},
+		{"がっこう", "gakkou"},
+		{"まっちゃ", "matcha"},
+		{"ラーメン", "raamen"},
+		{"シャツ", "shatsu"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kana, func(t *testing.T) {
+			if got := kanaToRomaji(tt.kana); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package romanize
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// transliterations map lowercase letters of alphabetic scripts to Latin. Combining vowel marks of
+// abjads map to their vowel, or to nothing when they don't change the reading.
+var transliterations = map[rune]string{
+	// Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh", 'з': "z",
+	'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r",
+	'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya", 'і': "i", 'ї': "yi", 'є': "ye",
+	'ґ': "g", 'ў': "u", 'ђ': "dj", 'ј': "j", 'љ': "lj", 'њ': "nj", 'ћ': "c", 'џ': "dz", 'ѓ': "gj",
+	'ќ': "kj", 'ѕ': "dz",
+
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i",
+	'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s",
+	'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o", 'ά': "a", 'έ': "e",
+	'ή': "i", 'ί': "i", 'ό': "o", 'ύ': "y", 'ώ': "o", 'ϊ': "i", 'ϋ': "y", 'ΐ': "i", 'ΰ': "y",
+
+	// Arabic and Persian
+	'ا': "a", 'أ': "a", 'إ': "i", 'آ': "aa", 'ب': "b", 'ت': "t", 'ث': "th", 'ج': "j", 'ح': "h",
+	'خ': "kh", 'د': "d", 'ذ': "dh", 'ر': "r", 'ز': "z", 'س': "s", 'ش': "sh", 'ص': "s", 'ض': "d",
+	'ط': "t", 'ظ': "z", 'ع': "'", 'غ': "gh", 'ف': "f", 'ق': "q", 'ك': "k", 'ل': "l", 'م': "m",
+	'ن': "n", 'ه': "h", 'و': "w", 'ي': "y", 'ى': "a", 'ة': "a", 'ء': "'", 'ؤ': "'", 'ئ': "'",
+	'پ': "p", 'چ': "ch", 'ژ': "zh", 'گ': "g", 'ک': "k", 'ی': "y", 'ـ': "",
+	'َ': "a", 'ِ': "i", 'ُ': "u", 'ً': "an", 'ٍ': "in", 'ٌ': "un", 'ْ': "",
+	'،': ",", '؛': ";", '؟': "?",
+
+	// Hebrew
+	'א': "", 'ב': "v", 'ג': "g", 'ד': "d", 'ה': "h", 'ו': "v", 'ז': "z", 'ח': "ch", 'ט': "t",
+	'י': "y", 'כ': "kh", 'ך': "kh", 'ל': "l", 'מ': "m", 'ם': "m", 'נ': "n", 'ן': "n", 'ס': "s",
+	'ע': "", 'פ': "f", 'ף': "f", 'צ': "ts", 'ץ': "ts", 'ק': "k", 'ר': "r", 'ש': "sh", 'ת': "t",
+	'ַ': "a", 'ָ': "a", 'ֶ': "e", 'ֵ': "e", 'ִ': "i", 'ֹ': "o",
+	'ֻ': "u", 'ְ': "", 'ּ': "", 'ׁ': "", 'ׂ': "", '־': "-",
+
+	// Armenian
+	'ա': "a", 'բ': "b", 'գ': "g", 'դ': "d", 'ե': "e", 'զ': "z", 'է': "e", 'ը': "y", 'թ': "t",
+	'ժ': "zh", 'ի': "i", 'լ': "l", 'խ': "kh", 'ծ': "ts", 'կ': "k", 'հ': "h", 'ձ': "dz", 'ղ': "gh",
+	'ճ': "ch", 'մ': "m", 'յ': "y", 'ն': "n", 'շ': "sh", 'ո': "o", 'չ': "ch", 'պ': "p", 'ջ': "j",
+	'ռ': "r", 'ս': "s", 'վ': "v", 'տ': "t", 'ր': "r", 'ց': "ts", 'ւ': "w", 'փ': "p", 'ք': "k",
+	'օ': "o", 'ֆ': "f", 'և': "ev",
+
+	// Georgian
+	'ა': "a", 'ბ': "b", 'გ': "g", 'დ': "d", 'ე': "e", 'ვ': "v", 'ზ': "z", 'თ': "t", 'ი': "i",
+	'კ': "k", 'ლ': "l", 'მ': "m", 'ნ': "n", 'ო': "o", 'პ': "p", 'ჟ': "zh", 'რ': "r", 'ს': "s",
+	'ტ': "t", 'უ': "u", 'ფ': "p", 'ქ': "k", 'ღ': "gh", 'ყ': "q", 'შ': "sh", 'ჩ': "ch", 'ც': "ts",
+	'ძ': "dz", 'წ': "ts", 'ჭ': "ch", 'ხ': "kh", 'ჯ': "j", 'ჰ': "h",
+}
+
+// languageTransliterations override letters read differently in a specific language
+var languageTransliterations = map[string]map[rune]string{
+	"uk": {'г': "h", 'и': "y"},
+	"be": {'г': "h"},
+	"sr": {'ц': "c", 'ч': "č", 'ш': "š", 'ж': "ž"},
+}
+
+// greekDigraphs are letter pairs read as a single sound
+var greekDigraphs = map[string]string{"ου": "ou", "ού": "ou"}
+
+// Transliterate renders text in Latin script. Chinese, Japanese and Korean are romanized as by
+// Romanize; Cyrillic, Greek, Arabic, Hebrew, Armenian and Georgian are transliterated letter by
+// letter. Text is normalized to NFC first, so letters typed as a base letter and a combining mark
+// match their precomposed form, and marks left on transliterated letters, like stress accents or
+// cantillation, are dropped. Text in other scripts is kept as it is.
+func Transliterate(text, language string) string {
+	if hasScript(text, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+		return Romanize(text, language)
+	}
+
+	overrides := languageTransliterations[primarySubtag(language)]
+	runes := []rune(norm.NFC.String(text))
+	var b strings.Builder
+	transliterated := false
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		lower := unicode.ToLower(r)
+
+		if i+1 < len(runes) {
+			if latin, ok := greekDigraphs[string([]rune{lower, unicode.ToLower(runes[i+1])})]; ok {
+				b.WriteString(matchCase(r, latin))
+				transliterated = true
+				i++
+				continue
+			}
+		}
+
+		latin, ok := overrides[lower]
+		if !ok {
+			latin, ok = transliterations[lower]
+		}
+		if !ok {
+			if !unicode.Is(unicode.Mn, r) {
+				transliterated = false
+				b.WriteRune(r)
+			} else if !transliterated {
+				b.WriteRune(r)
+			}
+			continue
+		}
+		if !unicode.Is(unicode.Mn, r) {
+			transliterated = true
+			if hasShadda(runes[i+1:]) {
+				latin += latin
+			}
+		}
+		b.WriteString(matchCase(r, latin))
+	}
+	return b.String()
+}
+
+// shadda marks a doubled Arabic consonant
+const shadda = '\u0651'
+
+// hasShadda reports whether the combining marks at the start of marks include a shadda
+func hasShadda(marks []rune) bool {
+	for _, r := range marks {
+		if !unicode.Is(unicode.Mn, r) {
+			return false
+		}
+		if r == shadda {
+			return true
+		}
+	}
+	return false
+}
+
+// matchCase capitalizes latin when the source letter is uppercase
+func matchCase(source rune, latin string) string {
+	if latin == "" || !unicode.IsUpper(source) {
+		return latin
+	}
+	return strings.ToUpper(latin[:1]) + latin[1:]
+}
@@ -0,0 +1,35 @@
+package romanize
+
+import "testing"
+
+func TestTransliterate(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		language string
+		expected string
+	}{
+		{"russian", "Привет, мир", "ru", "Privet, mir"},
+		{"russian soft sign", "Жизнь", "ru", "Zhizn"},
+		{"russian decomposed", "Е\u0308лка", "ru", "Yolka"},
+		{"russian stress marks", "Приве́т", "ru", "Privet"},
+		{"ukrainian", "Ганна", "uk", "Hanna"},
+		{"greek", "Σ' αγαπώ", "el", "S' agapo"},
+		{"greek digraph", "Μου λείπεις", "el", "Mou leipeis"},
+		{"arabic", "حبيبي", "ar", "hbyby"},
+		{"arabic with harakat and shadda", "حَبِيبِي مُحَمَّد", "ar", "habiybiy muhammad"},
+		{"hebrew", "שלום", "he", "shlvm"},
+		{"georgian", "გამარჯობა", "ka", "gamarjoba"},
+		{"armenian", "Բարեւ", "hy", "Barew"},
+		{"cjk falls back to romanization", "사랑해", "ko", "saranghae"},
+		{"latin untouched", "Hello world", "en", "Hello world"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Transliterate(tt.text, tt.language); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleNext(t *testing.T) {
+	after := time.Date(2024, time.March, 15, 10, 17, 30, 0, time.UTC) // a Friday
+
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"@every 90m", after.Add(90 * time.Minute)},
+		{"* * * * *", time.Date(2024, time.March, 15, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, time.March, 15, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"30 3 * * *", time.Date(2024, time.March, 16, 3, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2024, time.March, 15, 13, 0, 0, 0, time.UTC)},
+		{"0,45 10 * * *", time.Date(2024, time.March, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 0 * * 1", time.Date(2024, time.March, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, time.March, 17, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 1", time.Date(2024, time.March, 18, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got := schedule.Next(after); !got.Equal(tt.expected) {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseScheduleOnDemand(t *testing.T) {
+	schedule, err := ParseSchedule("")
+	if err != nil || schedule != nil {
+		t.Errorf("Expected no schedule and no error, got %v and %v", schedule, err)
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "a * * * *", "5-1 * * * *", "@every -1h", "@every soon"} {
+		t.Run(spec, func(t *testing.T) {
+			if _, err := ParseSchedule(spec); err == nil {
+				t.Errorf("Expected an error for %q", spec)
+			}
+		})
+	}
+}
+
+func TestCronScheduleNeverMatching(t *testing.T) {
+	schedule, _ := ParseSchedule("0 0 30 2 *")
+	if got := schedule.Next(time.Now()); !got.IsZero() {
+		t.Errorf("Expected no next run, got %s", got)
+	}
+}
@@ -0,0 +1,199 @@
+// Package scheduler runs named background jobs on cron-like schedules, never overlapping runs of
+// the same job, and keeps per-job run metrics.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrRunning is returned when triggering a job that is still running
+	ErrRunning = errors.New("job is already running")
+	// ErrUnknownJob is returned when triggering a job that isn't registered
+	ErrUnknownJob = errors.New("unknown job")
+)
+
+// Job is a named background job
+type Job struct {
+	Name string
+	// Spec is the schedule of the job as accepted by ParseSchedule; empty for jobs only run on demand
+	Spec string
+	// Jitter is the longest random delay added to every scheduled run, so instances sharing a
+	// schedule don't all run at once
+	Jitter time.Duration
+	Run    func(ctx context.Context) error
+}
+
+// Status is the state and run metrics of a job
+type Status struct {
+	Name           string     `json:"name"`
+	Schedule       string     `json:"schedule,omitempty"`
+	Running        bool       `json:"running"`
+	Runs           int        `json:"runs"`
+	Failures       int        `json:"failures"`
+	Skipped        int        `json:"skipped"`
+	LastStartedAt  *time.Time `json:"lastStartedAt,omitempty"`
+	LastFinishedAt *time.Time `json:"lastFinishedAt,omitempty"`
+	LastDurationMs int64      `json:"lastDurationMs"`
+	LastError      string     `json:"lastError,omitempty"`
+	NextRunAt      *time.Time `json:"nextRunAt,omitempty"`
+}
+
+type job struct {
+	Job
+	schedule Schedule
+	status   Status
+}
+
+// Scheduler runs registered jobs
+type Scheduler struct {
+	mu    sync.Mutex
+	jobs  map[string]*job
+	order []string
+	ctx   context.Context
+	wg    sync.WaitGroup
+}
+
+// New creates a scheduler without jobs
+func New() *Scheduler {
+	return &Scheduler{jobs: map[string]*job{}, ctx: context.Background()}
+}
+
+// Add registers a job. It fails when the name is taken or the schedule is invalid.
+func (s *Scheduler) Add(j Job) error {
+	schedule, err := ParseSchedule(j.Spec)
+	if err != nil {
+		return fmt.Errorf("job %s: %v", j.Name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[j.Name]; ok {
+		return fmt.Errorf("job %s is already registered", j.Name)
+	}
+	s.jobs[j.Name] = &job{Job: j, schedule: schedule, status: Status{Name: j.Name, Schedule: j.Spec}}
+	s.order = append(s.order, j.Name)
+	return nil
+}
+
+// Start runs every scheduled job on its schedule until ctx is done. Runs already in progress are
+// passed ctx, so they can stop early too.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	var scheduled []*job
+	for _, name := range s.order {
+		if j := s.jobs[name]; j.schedule != nil {
+			scheduled = append(scheduled, j)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, j := range scheduled {
+		s.wg.Add(1)
+		go s.loop(ctx, j)
+	}
+}
+
+// Wait blocks until the scheduling loops have stopped and the runs they started have finished
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	defer s.wg.Done()
+	for {
+		next := j.schedule.Next(time.Now())
+		if next.IsZero() {
+			return
+		}
+		if j.Jitter > 0 {
+			next = next.Add(time.Duration(rand.Int63n(int64(j.Jitter))))
+		}
+		s.mu.Lock()
+		j.status.NextRunAt = &next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !s.begin(j) {
+			// the previous run is still going, so this one is skipped rather than piled up
+			s.mu.Lock()
+			j.status.Skipped++
+			s.mu.Unlock()
+			continue
+		}
+		s.wg.Add(1)
+		go s.run(ctx, j)
+	}
+}
+
+// Trigger starts a run of the named job now, in the background
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	ctx := s.ctx
+	s.mu.Unlock()
+	if !ok {
+		return ErrUnknownJob
+	}
+	if !s.begin(j) {
+		return ErrRunning
+	}
+	s.wg.Add(1)
+	go s.run(ctx, j)
+	return nil
+}
+
+// begin marks j as running, unless it already is
+func (s *Scheduler) begin(j *job) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j.status.Running {
+		return false
+	}
+	startedAt := time.Now()
+	j.status.Running = true
+	j.status.LastStartedAt = &startedAt
+	return true
+}
+
+func (s *Scheduler) run(ctx context.Context, j *job) {
+	defer s.wg.Done()
+	err := j.Run(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	finishedAt := time.Now()
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastFinishedAt = &finishedAt
+	j.status.LastDurationMs = finishedAt.Sub(*j.status.LastStartedAt).Milliseconds()
+	j.status.LastError = ""
+	if err != nil {
+		j.status.Failures++
+		j.status.LastError = err.Error()
+	}
+}
+
+// Statuses returns the status of every job in registration order
+func (s *Scheduler) Statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]Status, 0, len(s.order))
+	for _, name := range s.order {
+		statuses = append(statuses, s.jobs[name].status)
+	}
+	return statuses
+}
@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTrigger(t *testing.T) {
+	s := New()
+	release := make(chan struct{})
+	s.Add(Job{Name: "slow", Run: func(ctx context.Context) error {
+		<-release
+		return nil
+	}})
+
+	if err := s.Trigger("slow"); err != nil {
+		t.Fatalf("Expected the first trigger to start the job, got %v", err)
+	}
+	if err := s.Trigger("slow"); err != ErrRunning {
+		t.Errorf("Expected ErrRunning while the job runs, got %v", err)
+	}
+	if err := s.Trigger("missing"); err != ErrUnknownJob {
+		t.Errorf("Expected ErrUnknownJob, got %v", err)
+	}
+
+	close(release)
+	s.Wait()
+
+	status := s.Statuses()[0]
+	if status.Running || status.Runs != 1 || status.Failures != 0 || status.LastStartedAt == nil || status.LastFinishedAt == nil {
+		t.Errorf("Expected one finished run, got %+v", status)
+	}
+}
+
+func TestTriggerRecordsFailures(t *testing.T) {
+	s := New()
+	s.Add(Job{Name: "failing", Run: func(ctx context.Context) error {
+		return errors.New("upstream down")
+	}})
+
+	s.Trigger("failing")
+	s.Wait()
+
+	status := s.Statuses()[0]
+	if status.Failures != 1 || status.LastError != "upstream down" {
+		t.Errorf("Expected a recorded failure, got %+v", status)
+	}
+}
+
+func TestAddRejectsDuplicatesAndInvalidSchedules(t *testing.T) {
+	s := New()
+	run := func(ctx context.Context) error { return nil }
+
+	if err := s.Add(Job{Name: "job", Spec: "@hourly", Run: run}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := s.Add(Job{Name: "job", Run: run}); err == nil {
+		t.Error("Expected an error for a duplicate job")
+	}
+	if err := s.Add(Job{Name: "other", Spec: "every hour", Run: run}); err == nil {
+		t.Error("Expected an error for an invalid schedule")
+	}
+}
+
+func TestScheduledRunsDontOverlap(t *testing.T) {
+	s := New()
+	var running, overlaps, runs int32
+	s.Add(Job{Name: "ticker", Spec: "@every 10ms", Run: func(ctx context.Context) error {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		atomic.AddInt32(&runs, 1)
+		time.Sleep(35 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	s.Start(ctx)
+	<-ctx.Done()
+	s.Wait()
+
+	status := s.Statuses()[0]
+	if overlaps > 0 {
+		t.Errorf("Expected no overlapping runs, got %d", overlaps)
+	}
+	if runs == 0 || status.Skipped == 0 {
+		t.Errorf("Expected runs and skipped ticks, got %d runs and %d skipped", runs, status.Skipped)
+	}
+	if status.NextRunAt == nil {
+		t.Error("Expected the next run time to be reported")
+	}
+}
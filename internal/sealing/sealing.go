@@ -0,0 +1,72 @@
+// Package sealing encrypts values with AES-256-GCM before they are stored, so a leaked cache file,
+// snapshot or Redis dump doesn't expose provider tokens.
+package sealing
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix marks sealed values, and the version of their format
+const prefix = "sealed:v1:"
+
+// ErrNotSealed is returned by Open for values that weren't sealed
+var ErrNotSealed = errors.New("value is not sealed")
+
+// Sealer seals and opens values with a single key
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+// New creates the sealer of key, 32 base64 encoded bytes
+func New(key string) (*Sealer, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key, expected base64: %v", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("invalid encryption key, expected 32 bytes, got %d", len(raw))
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Sealer{aead: aead}, nil
+}
+
+// Seal encrypts value, bound to name so it can't be opened under another name
+func (s *Sealer) Seal(name, value string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(value), []byte(name))
+	return prefix + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value of Seal under the same name
+func (s *Sealer) Open(name, sealed string) (string, error) {
+	encoded, ok := strings.CutPrefix(sealed, prefix)
+	if !ok {
+		return "", ErrNotSealed
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(raw) < s.aead.NonceSize() {
+		return "", errors.New("malformed sealed value")
+	}
+	nonce, ciphertext := raw[:s.aead.NonceSize()], raw[s.aead.NonceSize():]
+	value, err := s.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", errors.New("unable to open sealed value, it was sealed with another key or name")
+	}
+	return string(value), nil
+}
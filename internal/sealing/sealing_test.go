@@ -0,0 +1,50 @@
+package sealing
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+var testKey = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+func TestSealOpen(t *testing.T) {
+	sealer, err := New(testKey)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	sealed, err := sealer.Seal("spotify:token", "BQD-access-token")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.Contains(sealed, "BQD-access-token") {
+		t.Errorf("Expected the value not to appear in %s", sealed)
+	}
+	if again, _ := sealer.Seal("spotify:token", "BQD-access-token"); again == sealed {
+		t.Errorf("Expected every seal to use a new nonce")
+	}
+
+	value, err := sealer.Open("spotify:token", sealed)
+	if err != nil || value != "BQD-access-token" {
+		t.Errorf("Expected the value back, got %q, %v", value, err)
+	}
+	if _, err := sealer.Open("spotify:oauth", sealed); err == nil {
+		t.Errorf("Expected an error opening under another name, got nil")
+	}
+
+	other, _ := New(base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210")))
+	if _, err := other.Open("spotify:token", sealed); err == nil {
+		t.Errorf("Expected an error opening with another key, got nil")
+	}
+	if _, err := sealer.Open("spotify:token", "BQD-access-token"); err != ErrNotSealed {
+		t.Errorf("Expected ErrNotSealed, got %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, key := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := New(key); err == nil {
+			t.Errorf("Expected an error for key %q, got nil", key)
+		}
+	}
+}
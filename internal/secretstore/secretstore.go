@@ -0,0 +1,261 @@
+// Package secretstore reads rotatable secrets, by their environment variable names, from an env
+// file, a HashiCorp Vault KV secret or an AWS Secrets Manager secret.
+package secretstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"lyrics-api-go/internal/awssig"
+
+	"github.com/joho/godotenv"
+)
+
+// Store is where secrets are read from
+type Store interface {
+	// Read returns the secrets of the store by their names, like COOKIE_VALUE
+	Read(ctx context.Context) (map[string]string, error)
+	String() string
+}
+
+// Config holds the settings of every backend, only those of the selected one are used
+type Config struct {
+	// File is the env file of the file backend
+	File string
+
+	// VaultAddr is the address of the Vault server, like https://vault.example.com:8200
+	VaultAddr  string
+	VaultToken string
+	// VaultPath is the API path of the secret under /v1/, like secret/data/lyrics-api for the KV
+	// version 2 engine mounted at secret/
+	VaultPath string
+
+	AWSSecretID string
+	AWSRegion   string
+	// AWSEndpoint is only needed for Secrets Manager compatible services
+	AWSEndpoint    string
+	AWSCredentials awssig.Credentials
+
+	HTTPClient *http.Client
+}
+
+// Open returns the store of backend, one of "file", "vault" or "aws"
+func Open(backend string, config Config) (Store, error) {
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	switch backend {
+	case "", "file":
+		return &FileStore{Path: config.File}, nil
+	case "vault":
+		if config.VaultAddr == "" || config.VaultToken == "" || config.VaultPath == "" {
+			return nil, fmt.Errorf("the vault secrets backend needs an address, a token and a secret path")
+		}
+		return &VaultStore{Addr: config.VaultAddr, Path: config.VaultPath, token: config.VaultToken, httpClient: config.HTTPClient, now: time.Now, renewable: true}, nil
+	case "aws":
+		if config.AWSSecretID == "" || config.AWSRegion == "" {
+			return nil, fmt.Errorf("the aws secrets backend needs a secret ID and a region")
+		}
+		return &AWSStore{SecretID: config.AWSSecretID, config: config, now: time.Now}, nil
+	}
+	return nil, fmt.Errorf("unknown secrets backend %q", backend)
+}
+
+// FileStore reads the secrets of an env file, like .env
+type FileStore struct {
+	Path string
+}
+
+func (s *FileStore) Read(ctx context.Context) (map[string]string, error) {
+	return godotenv.Read(s.Path)
+}
+
+func (s *FileStore) String() string {
+	return s.Path
+}
+
+// VaultStore reads the secrets of a Vault KV secret, of either version of the engine. Tokens with a
+// TTL are renewed when half of it has passed, on the reads that follow, so secrets must be read
+// more often than the TTL for the token not to expire.
+type VaultStore struct {
+	Addr       string
+	Path       string
+	token      string
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu sync.Mutex
+	// renewAt is when the token is renewed next, zero until it is first renewed
+	renewAt time.Time
+	// renewable is false once Vault refused to renew the token, e.g. a token without a TTL
+	renewable bool
+}
+
+func (s *VaultStore) Read(ctx context.Context) (map[string]string, error) {
+	renewErr := s.renew(ctx)
+
+	url := strings.TrimSuffix(s.Addr, "/") + "/v1/" + strings.TrimPrefix(s.Path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", s.token)
+
+	body, err := readResponse(s.httpClient, req, "Vault")
+	if err != nil {
+		// a failed renewal is the likely reason the token no longer works
+		if renewErr != nil {
+			return nil, fmt.Errorf("%v, after failing to renew the token: %v", err, renewErr)
+		}
+		return nil, err
+	}
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, fmt.Errorf("error parsing Vault secret: %v", err)
+	}
+	data := secret.Data
+	// the KV version 2 engine nests the secret under data along with its metadata
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, versioned := data["metadata"]; versioned {
+			data = nested
+		}
+	}
+	return stringValues(data), nil
+}
+
+func (s *VaultStore) String() string {
+	return fmt.Sprintf("vault %s", s.Path)
+}
+
+// renew renews the token when it is due, keeping it from expiring while it is used
+func (s *VaultStore) renew(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.renewable || s.now().Before(s.renewAt) {
+		return nil
+	}
+
+	url := strings.TrimSuffix(s.Addr, "/") + "/v1/auth/token/renew-self"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader("{}"))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", s.token)
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error renewing Vault token: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("error renewing Vault token: %v", err)
+	}
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		// tokens that can't be renewed are used until they expire, without asking again
+		s.renewable = false
+		return fmt.Errorf("renewal of the Vault token refused with %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("renewal of the Vault token failed with %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var renewed struct {
+		Auth struct {
+			LeaseDuration int  `json:"lease_duration"`
+			Renewable     bool `json:"renewable"`
+		} `json:"auth"`
+	}
+	if err := json.Unmarshal(body, &renewed); err != nil {
+		return fmt.Errorf("error parsing Vault token renewal: %v", err)
+	}
+	if !renewed.Auth.Renewable || renewed.Auth.LeaseDuration <= 0 {
+		s.renewable = false
+		return nil
+	}
+	s.renewAt = s.now().Add(time.Duration(renewed.Auth.LeaseDuration) * time.Second / 2)
+	return nil
+}
+
+// AWSStore reads the secrets of an AWS Secrets Manager secret, whose value is a JSON object, with
+// requests signed with AWS Signature Version 4
+type AWSStore struct {
+	SecretID string
+	config   Config
+	now      func() time.Time
+}
+
+func (s *AWSStore) Read(ctx context.Context) (map[string]string, error) {
+	payload, err := json.Marshal(map[string]string{"SecretId": s.SecretID})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := s.config.AWSEndpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com/", s.config.AWSRegion)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	awssig.Sign(req, payload, s.config.AWSCredentials, s.config.AWSRegion, "secretsmanager", s.now())
+
+	body, err := readResponse(s.config.HTTPClient, req, "Secrets Manager")
+	if err != nil {
+		return nil, err
+	}
+	var secret struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, fmt.Errorf("error parsing Secrets Manager response: %v", err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(secret.SecretString), &data); err != nil {
+		return nil, fmt.Errorf("the secret %s is not a JSON object of secrets: %v", s.SecretID, err)
+	}
+	return stringValues(data), nil
+}
+
+func (s *AWSStore) String() string {
+	return fmt.Sprintf("aws %s", s.SecretID)
+}
+
+func readResponse(client *http.Client, req *http.Request, service string) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with %d: %s", service, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// stringValues keeps the values of data as strings, formatting those of other types
+func stringValues(data map[string]interface{}) map[string]string {
+	values := make(map[string]string, len(data))
+	for name, value := range data {
+		if s, ok := value.(string); ok {
+			values[name] = s
+		} else if value != nil {
+			values[name] = fmt.Sprint(value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,158 @@
+package secretstore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("COOKIE_VALUE=\"cookie\"\nCLIENT_SECRET=secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := Open("file", Config{File: path})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	values, err := store.Read(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if values["COOKIE_VALUE"] != "cookie" || values["CLIENT_SECRET"] != "secret" {
+		t.Errorf("Expected the secrets of the file, got %v", values)
+	}
+}
+
+func TestVaultStore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/lyrics-api":
+			w.Write([]byte(`{"data": {"data": {"COOKIE_VALUE": "cookie", "CACHE_ACCESS_TOKEN": "token"}, "metadata": {"version": 3}}}`))
+		case "/v1/kv/lyrics-api":
+			w.Write([]byte(`{"data": {"COOKIE_VALUE": "cookie", "CLIENT_ID": 42}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path     string
+		expected map[string]string
+	}{
+		{"secret/data/lyrics-api", map[string]string{"COOKIE_VALUE": "cookie", "CACHE_ACCESS_TOKEN": "token"}},
+		{"kv/lyrics-api", map[string]string{"COOKIE_VALUE": "cookie", "CLIENT_ID": "42"}},
+	}
+	for _, tt := range tests {
+		store, err := Open("vault", Config{VaultAddr: server.URL, VaultToken: "token", VaultPath: tt.path, HTTPClient: server.Client()})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		values, err := store.Read(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error for %s, got %v", tt.path, err)
+		}
+		if len(values) != len(tt.expected) {
+			t.Errorf("Expected %v for %s, got %v", tt.expected, tt.path, values)
+		}
+		for name, value := range tt.expected {
+			if values[name] != value {
+				t.Errorf("Expected %s to be %q for %s, got %q", name, value, tt.path, values[name])
+			}
+		}
+	}
+
+	store, _ := Open("vault", Config{VaultAddr: server.URL, VaultToken: "token", VaultPath: "secret/data/missing", HTTPClient: server.Client()})
+	if _, err := store.Read(context.Background()); err == nil {
+		t.Errorf("Expected an error for a missing secret, got nil")
+	}
+}
+
+func TestVaultStoreRenewsToken(t *testing.T) {
+	renewals, renewable := 0, true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/auth/token/renew-self" {
+			renewals++
+			if !renewable {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors": ["lease is not renewable"]}`))
+				return
+			}
+			w.Write([]byte(`{"auth": {"lease_duration": 60, "renewable": true}}`))
+			return
+		}
+		w.Write([]byte(`{"data": {"COOKIE_VALUE": "cookie"}}`))
+	}))
+	defer server.Close()
+
+	store, _ := Open("vault", Config{VaultAddr: server.URL, VaultToken: "token", VaultPath: "kv/lyrics-api", HTTPClient: server.Client()})
+	vault := store.(*VaultStore)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	vault.now = func() time.Time { return now }
+
+	for _, elapsed := range []time.Duration{0, 10 * time.Second, 20 * time.Second} {
+		now = now.Add(elapsed)
+		if _, err := store.Read(context.Background()); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if renewals != 2 {
+		t.Errorf("Expected the token to be renewed on the first read and after half its TTL, got %d renewals", renewals)
+	}
+
+	renewable = false
+	for i := 0; i < 2; i++ {
+		now = now.Add(time.Minute)
+		if _, err := store.Read(context.Background()); err != nil {
+			t.Fatalf("Expected reads to go on when the token can't be renewed, got %v", err)
+		}
+	}
+	if renewals != 3 {
+		t.Errorf("Expected no renewals once refused, got %d renewals", renewals)
+	}
+}
+
+func TestAWSStore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" || string(body) != `{"SecretId":"lyrics-api"}` {
+			t.Errorf("Expected a GetSecretValue request, got %s %s", r.Header.Get("X-Amz-Target"), body)
+		}
+		if !strings.Contains(r.Header.Get("Authorization"), "/us-east-1/secretsmanager/aws4_request") {
+			t.Errorf("Expected a signed request, got %q", r.Header.Get("Authorization"))
+		}
+		w.Write([]byte(`{"Name": "lyrics-api", "SecretString": "{\"CLIENT_SECRET\": \"secret\"}"}`))
+	}))
+	defer server.Close()
+
+	store, err := Open("aws", Config{AWSSecretID: "lyrics-api", AWSRegion: "us-east-1", AWSEndpoint: server.URL, HTTPClient: server.Client()})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	values, err := store.Read(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if values["CLIENT_SECRET"] != "secret" {
+		t.Errorf("Expected the secrets of the secret string, got %v", values)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for _, backend := range []string{"consul", "vault", "aws"} {
+		if _, err := Open(backend, Config{}); err == nil {
+			t.Errorf("Expected an error for %s without its settings, got nil", backend)
+		}
+	}
+}
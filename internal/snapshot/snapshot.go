@@ -0,0 +1,159 @@
+// Package snapshot stores cache snapshots in a local file or an S3 object, so a warm cache survives
+// host migrations and blue/green deploys.
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"lyrics-api-go/internal/awssig"
+)
+
+// ErrNotFound is returned by Read when no snapshot has been written yet
+var ErrNotFound = errors.New("snapshot not found")
+
+// Store is where snapshots are written to and read back from
+type Store interface {
+	Write(ctx context.Context, body []byte) error
+	Read(ctx context.Context) ([]byte, error)
+	String() string
+}
+
+// S3Config holds the settings of S3 locations. Endpoint is only needed for S3-compatible services,
+// objects are then addressed path-style under it.
+type S3Config struct {
+	Region      string
+	Endpoint    string
+	Credentials awssig.Credentials
+	HTTPClient  *http.Client
+}
+
+// Open returns the store of a location, either an s3://bucket/key URL or a file path
+func Open(location string, config S3Config) (Store, error) {
+	if !strings.HasPrefix(location, "s3://") {
+		return &FileStore{Path: location}, nil
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(location, "s3://"), "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("invalid S3 location %q, expected s3://bucket/key", location)
+	}
+	if config.Region == "" {
+		return nil, fmt.Errorf("an S3 region is required for %s", location)
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	return &S3Store{Bucket: bucket, Key: key, config: config, now: time.Now}, nil
+}
+
+// FileStore keeps the snapshot in a local file, replaced atomically on every write
+type FileStore struct {
+	Path string
+}
+
+func (s *FileStore) Write(ctx context.Context, body []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+func (s *FileStore) Read(ctx context.Context) ([]byte, error) {
+	body, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return body, err
+}
+
+func (s *FileStore) String() string {
+	return s.Path
+}
+
+// S3Store keeps the snapshot in an S3 object, with requests signed with AWS Signature Version 4
+type S3Store struct {
+	Bucket string
+	Key    string
+	config S3Config
+	now    func() time.Time
+}
+
+func (s *S3Store) Write(ctx context.Context, body []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func (s *S3Store) Read(ctx context.Context) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	}
+	return nil, s3Error(resp)
+}
+
+func (s *S3Store) String() string {
+	return fmt.Sprintf("s3://%s/%s", s.Bucket, s.Key)
+}
+
+// objectURL is the virtual-hosted URL of the object on AWS, or its path-style URL under a custom endpoint
+func (s *S3Store) objectURL() string {
+	key := (&url.URL{Path: "/" + s.Key}).EscapedPath()
+	if s.config.Endpoint != "" {
+		return strings.TrimSuffix(s.config.Endpoint, "/") + "/" + url.PathEscape(s.Bucket) + key
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com%s", s.Bucket, s.config.Region, key)
+}
+
+func (s *S3Store) do(ctx context.Context, method string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		req.Body, req.ContentLength = http.NoBody, 0
+	}
+	awssig.Sign(req, body, s.config.Credentials, s.config.Region, "s3", s.now())
+	return s.config.HTTPClient.Do(req)
+}
+
+func s3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("S3 responded with %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
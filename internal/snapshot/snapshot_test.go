@@ -0,0 +1,104 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lyrics-api-go/internal/awssig"
+)
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "snapshot.json.gz"), S3Config{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := store.Read(context.Background()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound before the first write, got %v", err)
+	}
+
+	for _, body := range []string{"first", "second"} {
+		if err := store.Write(context.Background(), []byte(body)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		got, err := store.Read(context.Background())
+		if err != nil || string(got) != body {
+			t.Errorf("Expected %q, got %q (%v)", body, got, err)
+		}
+	}
+}
+
+func TestOpenS3Location(t *testing.T) {
+	tests := []struct {
+		location string
+		region   string
+		wantErr  bool
+	}{
+		{"s3://bucket/snapshots/cache.json.gz", "eu-west-1", false},
+		{"s3://bucket", "eu-west-1", true},
+		{"s3:///key", "eu-west-1", true},
+		{"s3://bucket/key", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.location, func(t *testing.T) {
+			store, err := Open(tt.location, S3Config{Region: tt.region})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && store.String() != tt.location {
+				t.Errorf("Expected %s, got %s", tt.location, store.String())
+			}
+		})
+	}
+}
+
+func TestS3StoreRoundTrip(t *testing.T) {
+	objects := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = string(body)
+		case http.MethodGet:
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			io.WriteString(w, body)
+		}
+	}))
+	defer server.Close()
+
+	store, err := Open("s3://bucket/cache/snapshot.json.gz", S3Config{
+		Region:      "us-east-1",
+		Endpoint:    server.URL,
+		Credentials: awssig.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+		HTTPClient:  server.Client(),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := store.Read(context.Background()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound before the first write, got %v", err)
+	}
+	if err := store.Write(context.Background(), []byte("dump")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if objects["/bucket/cache/snapshot.json.gz"] != "dump" {
+		t.Errorf("Expected the object to be written path-style, got %v", objects)
+	}
+	if got, err := store.Read(context.Background()); err != nil || string(got) != "dump" {
+		t.Errorf("Expected dump, got %q (%v)", got, err)
+	}
+}
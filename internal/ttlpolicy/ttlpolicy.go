@@ -0,0 +1,122 @@
+// Package ttlpolicy picks how long lyrics are cached from attributes of their track, such as how
+// recently it was released.
+package ttlpolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Rule gives lyrics matching all of its conditions a TTL. Unset conditions match everything. Rules
+// with a release age condition never match tracks whose release date is unknown.
+type Rule struct {
+	Name string `json:"name"`
+	// MaxReleaseAgeDays matches tracks released at most this many days ago
+	MaxReleaseAgeDays int `json:"maxReleaseAgeDays,omitempty"`
+	// MinReleaseAgeDays matches tracks released at least this many days ago
+	MinReleaseAgeDays int      `json:"minReleaseAgeDays,omitempty"`
+	SyncTypes         []string `json:"syncTypes,omitempty"`
+	Languages         []string `json:"languages,omitempty"`
+	TTLSeconds        int      `json:"ttlSeconds"`
+}
+
+// Attributes are what is known about a track when its lyrics are cached
+type Attributes struct {
+	// ReleaseDate is the zero time when unknown
+	ReleaseDate time.Time
+	SyncType    string
+	Language    string
+}
+
+// Policy picks the TTL of the first matching rule, or the fallback TTL when none match
+type Policy struct {
+	rules    []Rule
+	fallback time.Duration
+}
+
+// Parse parses rules from a JSON array. An empty string is no rules.
+func Parse(data string) ([]Rule, error) {
+	var rules []Rule
+	if strings.TrimSpace(data) == "" {
+		return rules, nil
+	}
+	if err := json.Unmarshal([]byte(data), &rules); err != nil {
+		return nil, err
+	}
+	for i, rule := range rules {
+		if rule.TTLSeconds <= 0 {
+			return nil, fmt.Errorf("rule %d (%s) has no positive ttlSeconds", i, rule.Name)
+		}
+		if rule.MaxReleaseAgeDays > 0 && rule.MinReleaseAgeDays > rule.MaxReleaseAgeDays {
+			return nil, fmt.Errorf("rule %d (%s) has minReleaseAgeDays above maxReleaseAgeDays", i, rule.Name)
+		}
+	}
+	return rules, nil
+}
+
+// New creates a policy evaluating rules in order
+func New(rules []Rule, fallback time.Duration) *Policy {
+	return &Policy{rules: rules, fallback: fallback}
+}
+
+// TTL returns the TTL for lyrics with attrs and the name of the rule it comes from, "" for the fallback
+func (p *Policy) TTL(attrs Attributes, now time.Time) (time.Duration, string) {
+	for _, rule := range p.rules {
+		if rule.matches(attrs, now) {
+			return time.Duration(rule.TTLSeconds) * time.Second, rule.Name
+		}
+	}
+	return p.fallback, ""
+}
+
+// MaxTTL returns the longest TTL the policy can pick
+func (p *Policy) MaxTTL() time.Duration {
+	maxTTL := p.fallback
+	for _, rule := range p.rules {
+		if ttl := time.Duration(rule.TTLSeconds) * time.Second; ttl > maxTTL {
+			maxTTL = ttl
+		}
+	}
+	return maxTTL
+}
+
+func (r Rule) matches(attrs Attributes, now time.Time) bool {
+	if r.MaxReleaseAgeDays > 0 || r.MinReleaseAgeDays > 0 {
+		if attrs.ReleaseDate.IsZero() {
+			return false
+		}
+		age := int(now.Sub(attrs.ReleaseDate).Hours() / 24)
+		if r.MaxReleaseAgeDays > 0 && age > r.MaxReleaseAgeDays {
+			return false
+		}
+		if age < r.MinReleaseAgeDays {
+			return false
+		}
+	}
+	return matchesAny(r.SyncTypes, attrs.SyncType) && matchesAny(r.Languages, attrs.Language)
+}
+
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseReleaseDate parses a release date as precise as upstream knows it (YYYY, YYYY-MM or
+// YYYY-MM-DD). Imprecise dates are taken as the start of their year or month.
+func ParseReleaseDate(s string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
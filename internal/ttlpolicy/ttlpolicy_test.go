@@ -0,0 +1,80 @@
+package ttlpolicy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolicyTTL(t *testing.T) {
+	rules, err := Parse(`[
+		{"name": "newRelease", "maxReleaseAgeDays": 30, "ttlSeconds": 3600},
+		{"name": "unsynced", "syncTypes": ["UNSYNCED"], "ttlSeconds": 7200},
+		{"name": "catalog", "minReleaseAgeDays": 365, "ttlSeconds": 604800}
+	]`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	policy := New(rules, 24*time.Hour)
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		attrs        Attributes
+		expectedTTL  time.Duration
+		expectedRule string
+	}{
+		{"New release", Attributes{ReleaseDate: now.AddDate(0, 0, -10), SyncType: "LINE_SYNCED"}, time.Hour, "newRelease"},
+		{"Unsynced catalog", Attributes{ReleaseDate: now.AddDate(-5, 0, 0), SyncType: "UNSYNCED"}, 2 * time.Hour, "unsynced"},
+		{"Catalog", Attributes{ReleaseDate: now.AddDate(-5, 0, 0), SyncType: "LINE_SYNCED"}, 7 * 24 * time.Hour, "catalog"},
+		{"Between", Attributes{ReleaseDate: now.AddDate(0, -3, 0), SyncType: "LINE_SYNCED"}, 24 * time.Hour, ""},
+		{"Unknown release date", Attributes{SyncType: "LINE_SYNCED"}, 24 * time.Hour, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ttl, rule := policy.TTL(tt.attrs, now)
+			if ttl != tt.expectedTTL || rule != tt.expectedRule {
+				t.Errorf("Expected %v from %q, got %v from %q", tt.expectedTTL, tt.expectedRule, ttl, rule)
+			}
+		})
+	}
+
+	if maxTTL := policy.MaxTTL(); maxTTL != 7*24*time.Hour {
+		t.Errorf("Expected max TTL of a week, got %v", maxTTL)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, data := range []string{
+		`{}`,
+		`[{"name": "noTTL"}]`,
+		`[{"name": "inverted", "minReleaseAgeDays": 60, "maxReleaseAgeDays": 30, "ttlSeconds": 60}]`,
+	} {
+		if _, err := Parse(data); err == nil {
+			t.Errorf("Expected an error parsing %s", data)
+		}
+	}
+}
+
+func TestParseReleaseDate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Time
+		ok       bool
+	}{
+		{"2011-05-17", time.Date(2011, 5, 17, 0, 0, 0, 0, time.UTC), true},
+		{"2011-05", time.Date(2011, 5, 1, 0, 0, 0, 0, time.UTC), true},
+		{"2011", time.Date(2011, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{"", time.Time{}, false},
+		{"soon", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := ParseReleaseDate(tt.input)
+			if ok != tt.ok || !got.Equal(tt.expected) {
+				t.Errorf("Expected %v (%v), got %v (%v)", tt.expected, tt.ok, got, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+// Package upstream bounds the number of simultaneous requests to every upstream host, queueing
+// the requests past the bound, so bursts of traffic reach providers smoothed out.
+package upstream
+
+import (
+	"container/list"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ErrQueueFull is returned for requests to a host whose queue is full
+var ErrQueueFull = errors.New("too many queued requests to the upstream host")
+
+// Config sets the bounds of every host
+type Config struct {
+	// MaxConcurrent is the number of requests in flight to a host, 0 for no bound
+	MaxConcurrent int
+	// MaxQueued is the number of requests waiting for one of them to complete, past which requests
+	// fail right away
+	MaxQueued int
+	// Hosts overrides MaxConcurrent for some hosts
+	Hosts map[string]int
+}
+
+// HostStats are the requests in flight to a host and those waiting
+type HostStats struct {
+	InFlight int `json:"inFlight"`
+	Queued   int `json:"queued"`
+	Rejected int `json:"rejected"`
+}
+
+// host holds the slots of an upstream host. Slots freed while requests are queued are handed to the
+// first of them, so requests are served in order and new ones can't jump the queue.
+type host struct {
+	limit    int
+	inFlight int
+	// waiters are the channels of the queued requests, closed when they are handed a slot
+	waiters  *list.List
+	rejected int
+}
+
+// Transport is a round tripper holding a slot of the host of every request until its response
+// body is closed
+type Transport struct {
+	next   http.RoundTripper
+	config Config
+
+	mu    sync.Mutex
+	hosts map[string]*host
+}
+
+// NewTransport bounds the requests made with next
+func NewTransport(next http.RoundTripper, config Config) *Transport {
+	return &Transport{next: next, config: config, hosts: map[string]*host{}}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	h := t.host(req.URL.Host)
+	if h == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	if err := t.acquire(req, h); err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.release(h)
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { t.release(h) }}
+	return resp, nil
+}
+
+// acquire takes a slot of h for req, queueing it behind the requests already waiting until a slot
+// is handed to it or its context is done
+func (t *Transport) acquire(req *http.Request, h *host) error {
+	t.mu.Lock()
+	if h.inFlight < h.limit && h.waiters.Len() == 0 {
+		h.inFlight++
+		t.mu.Unlock()
+		return nil
+	}
+	if h.waiters.Len() >= t.config.MaxQueued {
+		h.rejected++
+		t.mu.Unlock()
+		return ErrQueueFull
+	}
+	ready := make(chan struct{})
+	waiter := h.waiters.PushBack(ready)
+	t.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-req.Context().Done():
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		select {
+		case <-ready:
+			// the slot was handed over meanwhile, so it goes to the next request
+			t.releaseLocked(h)
+		default:
+			h.waiters.Remove(waiter)
+		}
+		return req.Context().Err()
+	}
+}
+
+// release frees a slot of h, handing it to the first queued request if any
+func (t *Transport) release(h *host) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.releaseLocked(h)
+}
+
+func (t *Transport) releaseLocked(h *host) {
+	if first := h.waiters.Front(); first != nil {
+		close(h.waiters.Remove(first).(chan struct{}))
+		return
+	}
+	h.inFlight--
+}
+
+// host returns the slots of name, or nil when its requests aren't bounded
+func (t *Transport) host(name string) *host {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if h, ok := t.hosts[name]; ok {
+		return h
+	}
+	limit := t.config.MaxConcurrent
+	if override, ok := t.config.Hosts[name]; ok {
+		limit = override
+	}
+	if limit <= 0 {
+		return nil
+	}
+	h := &host{limit: limit, waiters: list.New()}
+	t.hosts[name] = h
+	return h
+}
+
+// Stats returns the stats of every host requests were made to
+func (t *Transport) Stats() map[string]HostStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := make(map[string]HostStats, len(t.hosts))
+	for name, h := range t.hosts {
+		stats[name] = HostStats{InFlight: h.inFlight, Queued: h.waiters.Len(), Rejected: h.rejected}
+	}
+	return stats
+}
+
+// releasingBody frees the slot of its request once closed
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
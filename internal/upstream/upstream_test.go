@@ -0,0 +1,167 @@
+package upstream
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingTransport responds once released, counting the requests in flight
+type blockingTransport struct {
+	release  chan struct{}
+	inFlight int32
+	max      int32
+}
+
+func (t *blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	n := atomic.AddInt32(&t.inFlight, 1)
+	for {
+		max := atomic.LoadInt32(&t.max)
+		if n <= max || atomic.CompareAndSwapInt32(&t.max, max, n) {
+			break
+		}
+	}
+	<-t.release
+	atomic.AddInt32(&t.inFlight, -1)
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+}
+
+func TestTransportBoundsConcurrency(t *testing.T) {
+	next := &blockingTransport{release: make(chan struct{})}
+	transport := NewTransport(next, Config{MaxConcurrent: 2, MaxQueued: 10})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", "https://api.example.com/lyrics", nil)
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for transport.Stats()["api.example.com"].Queued != 4 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if stats := transport.Stats()["api.example.com"]; stats.InFlight != 2 || stats.Queued != 4 {
+		t.Errorf("Expected 2 requests in flight and 4 queued, got %+v", stats)
+	}
+	close(next.release)
+	wg.Wait()
+
+	if next.max != 2 {
+		t.Errorf("Expected at most 2 concurrent requests, got %d", next.max)
+	}
+	if stats := transport.Stats()["api.example.com"]; stats.InFlight != 0 || stats.Queued != 0 {
+		t.Errorf("Expected every slot to be freed, got %+v", stats)
+	}
+}
+
+func TestTransportRejectsPastQueue(t *testing.T) {
+	next := &blockingTransport{release: make(chan struct{})}
+	transport := NewTransport(next, Config{MaxConcurrent: 1, MaxQueued: 0})
+
+	done := make(chan struct{})
+	go func() {
+		req, _ := http.NewRequest("GET", "https://api.example.com/lyrics", nil)
+		resp, _ := transport.RoundTrip(req)
+		resp.Body.Close()
+		close(done)
+	}()
+	for transport.Stats()["api.example.com"].InFlight != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	req, _ := http.NewRequest("GET", "https://api.example.com/lyrics", nil)
+	if _, err := transport.RoundTrip(req); err != ErrQueueFull {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+	close(next.release)
+	<-done
+	if stats := transport.Stats()["api.example.com"]; stats.Rejected != 1 {
+		t.Errorf("Expected 1 rejected request, got %+v", stats)
+	}
+}
+
+func TestTransportQueueHonorsContext(t *testing.T) {
+	next := &blockingTransport{release: make(chan struct{})}
+	defer close(next.release)
+	transport := NewTransport(next, Config{MaxConcurrent: 1, MaxQueued: 1, Hosts: map[string]int{"unbounded.example.com": 0}})
+
+	go func() {
+		req, _ := http.NewRequest("GET", "https://api.example.com/lyrics", nil)
+		if resp, err := transport.RoundTrip(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	for transport.Stats()["api.example.com"].InFlight != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://api.example.com/lyrics", nil)
+	if _, err := transport.RoundTrip(req); err != context.DeadlineExceeded {
+		t.Errorf("Expected the deadline to be exceeded, got %v", err)
+	}
+	if _, ok := transport.Stats()["unbounded.example.com"]; ok {
+		t.Errorf("Expected hosts without a bound not to be tracked")
+	}
+}
+
+// orderedTransport records the order requests reach it in, by their query
+type orderedTransport struct {
+	mu    sync.Mutex
+	order []string
+}
+
+func (t *orderedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.order = append(t.order, req.URL.RawQuery)
+	t.mu.Unlock()
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+}
+
+func TestTransportServesQueueInOrder(t *testing.T) {
+	next := &orderedTransport{}
+	transport := NewTransport(next, Config{MaxConcurrent: 1, MaxQueued: 10})
+
+	first, _ := http.NewRequest("GET", "https://api.example.com/lyrics?0", nil)
+	held, err := transport.RoundTrip(first)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 3; i++ {
+		wg.Add(1)
+		go func(query string) {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", "https://api.example.com/lyrics?"+query, nil)
+			if resp, err := transport.RoundTrip(req); err == nil {
+				resp.Body.Close()
+			}
+		}(strconv.Itoa(i))
+		for transport.Stats()["api.example.com"].Queued != i {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	held.Body.Close()
+	wg.Wait()
+
+	if got := strings.Join(next.order, ","); got != "0,1,2,3" {
+		t.Errorf("Expected queued requests to be served in order, got %s", got)
+	}
+}
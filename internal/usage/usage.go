@@ -0,0 +1,148 @@
+// Package usage accounts the requests, cache hits and upstream calls made with each API key, per
+// UTC day.
+package usage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kind is a kind of usage counted
+type Kind int
+
+const (
+	Request Kind = iota
+	CacheHit
+	UpstreamCall
+)
+
+// Counts is the usage of a key over a period
+type Counts struct {
+	Requests      int64 `json:"requests"`
+	CacheHits     int64 `json:"cacheHits"`
+	UpstreamCalls int64 `json:"upstreamCalls"`
+}
+
+// Add adds other to c
+func (c *Counts) Add(other Counts) {
+	c.Requests += other.Requests
+	c.CacheHits += other.CacheHits
+	c.UpstreamCalls += other.UpstreamCalls
+}
+
+// Day is the usage of a key on a UTC day
+type Day struct {
+	Date string `json:"date"`
+	Counts
+}
+
+// Report is the usage of a key over the retained days, oldest first
+type Report struct {
+	KeyID string `json:"keyId"`
+	Total Counts `json:"total"`
+	Days  []Day  `json:"days"`
+}
+
+// Tracker keeps the daily usage of every key for a number of days
+type Tracker struct {
+	mu   sync.Mutex
+	days int
+	// keys maps key IDs to their counts by date
+	keys map[string]map[string]*Counts
+	now  func() time.Time
+}
+
+// NewTracker creates a tracker retaining the usage of the last days days, today included
+func NewTracker(days int) *Tracker {
+	return &Tracker{days: max(days, 1), keys: map[string]map[string]*Counts{}, now: time.Now}
+}
+
+// Record counts one usage of kind by keyID today
+func (t *Tracker) Record(keyID string, kind Kind) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	date := t.now().UTC().Format(time.DateOnly)
+	days, ok := t.keys[keyID]
+	if !ok {
+		days = map[string]*Counts{}
+		t.keys[keyID] = days
+	}
+	counts, ok := days[date]
+	if !ok {
+		counts = &Counts{}
+		days[date] = counts
+		t.prune(days)
+	}
+
+	switch kind {
+	case Request:
+		counts.Requests++
+	case CacheHit:
+		counts.CacheHits++
+	case UpstreamCall:
+		counts.UpstreamCalls++
+	}
+}
+
+// prune drops the days of a key that fell out of the retention. The tracker must be locked.
+func (t *Tracker) prune(days map[string]*Counts) {
+	oldest := t.now().UTC().AddDate(0, 0, 1-t.days).Format(time.DateOnly)
+	for date := range days {
+		if date < oldest {
+			delete(days, date)
+		}
+	}
+}
+
+// Report returns the usage of keyID over the retained days
+func (t *Tracker) Report(keyID string) Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.report(keyID)
+}
+
+// Reports returns the usage of every key that was used, ordered by key ID
+func (t *Tracker) Reports() []Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	reports := make([]Report, 0, len(t.keys))
+	for keyID := range t.keys {
+		if report := t.report(keyID); len(report.Days) > 0 {
+			reports = append(reports, report)
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].KeyID < reports[j].KeyID })
+	return reports
+}
+
+// report returns the usage of keyID. The tracker must be locked.
+func (t *Tracker) report(keyID string) Report {
+	report := Report{KeyID: keyID, Days: []Day{}}
+	oldest := t.now().UTC().AddDate(0, 0, 1-t.days).Format(time.DateOnly)
+	for date, counts := range t.keys[keyID] {
+		if date < oldest {
+			continue
+		}
+		report.Days = append(report.Days, Day{Date: date, Counts: *counts})
+		report.Total.Add(*counts)
+	}
+	sort.Slice(report.Days, func(i, j int) bool { return report.Days[i].Date < report.Days[j].Date })
+	return report
+}
+
+type keyIDKey struct{}
+
+// WithKeyID returns a copy of ctx attributing usage to keyID
+func WithKeyID(ctx context.Context, keyID string) context.Context {
+	return context.WithValue(ctx, keyIDKey{}, keyID)
+}
+
+// KeyIDFromContext returns the key ID usage in ctx is attributed to, or "" for anonymous requests
+func KeyIDFromContext(ctx context.Context) string {
+	keyID, _ := ctx.Value(keyIDKey{}).(string)
+	return keyID
+}
@@ -0,0 +1,60 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRecordAndReport(t *testing.T) {
+	tracker := NewTracker(7)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	tracker.Record("a", Request)
+	tracker.Record("a", Request)
+	tracker.Record("a", CacheHit)
+	now = now.Add(24 * time.Hour)
+	tracker.Record("a", Request)
+	tracker.Record("a", UpstreamCall)
+	tracker.Record("b", Request)
+
+	report := tracker.Report("a")
+	if len(report.Days) != 2 || report.Days[0].Date != "2024-05-01" || report.Days[1].Date != "2024-05-02" {
+		t.Fatalf("Expected two days oldest first, got %+v", report.Days)
+	}
+	if want := (Counts{Requests: 3, CacheHits: 1, UpstreamCalls: 1}); report.Total != want {
+		t.Errorf("Expected total %+v, got %+v", want, report.Total)
+	}
+
+	reports := tracker.Reports()
+	if len(reports) != 2 || reports[0].KeyID != "a" || reports[1].Total.Requests != 1 {
+		t.Errorf("Expected the reports of a and b, got %+v", reports)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	tracker := NewTracker(2)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	tracker.Record("a", Request)
+	now = now.Add(48 * time.Hour)
+	if report := tracker.Report("a"); len(report.Days) != 0 || report.Total.Requests != 0 {
+		t.Errorf("Expected days past the retention to be left out, got %+v", report)
+	}
+
+	tracker.Record("a", Request)
+	if days := len(tracker.keys["a"]); days != 1 {
+		t.Errorf("Expected days past the retention to be pruned, got %d days", days)
+	}
+}
+
+func TestKeyIDFromContext(t *testing.T) {
+	if got := KeyIDFromContext(context.Background()); got != "" {
+		t.Errorf("Expected no key ID, got %q", got)
+	}
+	if got := KeyIDFromContext(WithKeyID(context.Background(), "a")); got != "a" {
+		t.Errorf("Expected a, got %q", got)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math"
+	"net/http"
+
+	"lyrics-api-go/internal/provider/spotify"
+	"lyrics-api-go/lyrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// quietIntroDb is how much quieter than the whole track the first section must be to count as an
+// instrumental intro
+const quietIntroDb = 6
+
+const featureIntroTrim = "introTrim"
+
+// trimSilence corrects lyrics whose first line starts at 0ms, a known upstream quirk for tracks with
+// an instrumental intro, on request (?trimIntro=1). The intro is read from the track's audio
+// analysis, which is only fetched for requests asking for it and then cached with the track bounds.
+func trimSilence(r *http.Request, trackID string, lines []lyrics.Line) []lyrics.Line {
+	if !isTruthy(r.URL.Query().Get("trimIntro")) || len(lines) == 0 || lines[0].StartMs() != 0 {
+		return lines
+	}
+	if !withinBudget(r.Context(), featureIntroTrim) {
+		return lines
+	}
+
+	introEndMs, trackEndMs := trackBounds(r, trackID)
+	if trackEndMs <= 0 {
+		return lines
+	}
+	if introEndMs > 0 {
+		log.Debugf("[Intro] Intro of %s ends at %dms", trackID, introEndMs)
+	}
+	return lyrics.TrimSilence(lines, introEndMs, trackEndMs)
+}
+
+// introEnd estimates where the instrumental intro ends: at the end of the fade-in, or at the end of
+// the first section when it is much quieter than the track
+func introEnd(analysis *spotify.AudioAnalysis) int64 {
+	end := analysis.Track.EndOfFadeIn
+	if len(analysis.Sections) > 1 && analysis.Sections[0].Loudness <= analysis.Track.Loudness-quietIntroDb {
+		end = math.Max(end, analysis.Sections[1].Start)
+	}
+	return secondsToMs(end)
+}
+
+func secondsToMs(seconds float64) int64 {
+	return int64(math.Round(seconds * 1000))
+}
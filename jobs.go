@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"lyrics-api-go/internal/cache"
+	"lyrics-api-go/internal/scheduler"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+var jobScheduler = scheduler.New()
+
+// backgroundJob is a job run by the scheduler. defaultSpec is its schedule unless overridden in
+// JOB_SCHEDULES; an empty schedule only runs the job on demand.
+type backgroundJob struct {
+	name        string
+	defaultSpec func() string
+	run         func(ctx context.Context) error
+}
+
+var backgroundJobs = []backgroundJob{
+	{
+		name: "cacheInvalidation",
+		defaultSpec: func() string {
+			return fmt.Sprintf("@every %ds", conf.Configuration.CacheInvalidationIntervalInSeconds)
+		},
+		run: purgeExpiredCache,
+	},
+	{
+		name:        "timingBackfill",
+		defaultSpec: func() string { return "" },
+		run:         scheduledTimingBackfill,
+	},
+	{
+		name:        "cacheSnapshot",
+		defaultSpec: func() string { return "" },
+		run:         scheduledCacheSnapshot,
+	},
+	{
+		name:        "popularRefresh",
+		defaultSpec: func() string { return "@every 5m" },
+		run:         refreshPopularLyrics,
+	},
+	{
+		name: "secretsReload",
+		defaultSpec: func() string {
+			if conf.Configuration.SecretsWatchIntervalInSeconds <= 0 {
+				return ""
+			}
+			return fmt.Sprintf("@every %ds", conf.Configuration.SecretsWatchIntervalInSeconds)
+		},
+		run: scheduledSecretsReload,
+	},
+	{
+		name: "queryArchiveRetention",
+		defaultSpec: func() string {
+			if conf.Configuration.QueryArchiveDir == "" {
+				return ""
+			}
+			return "@every 1h"
+		},
+		run: purgeQueryArchive,
+	},
+}
+
+// registerJobs adds the background jobs to the scheduler with their configured schedules
+func registerJobs() error {
+	specs := map[string]string{}
+	if conf.Configuration.JobSchedules != "" {
+		if err := json.Unmarshal([]byte(conf.Configuration.JobSchedules), &specs); err != nil {
+			return fmt.Errorf("invalid JOB_SCHEDULES: %v", err)
+		}
+	}
+
+	known := map[string]bool{}
+	for _, job := range backgroundJobs {
+		known[job.name] = true
+		spec, ok := specs[job.name]
+		if !ok {
+			spec = job.defaultSpec()
+		}
+		err := jobScheduler.Add(scheduler.Job{
+			Name:   job.name,
+			Spec:   spec,
+			Jitter: time.Duration(conf.Configuration.JobJitterInSeconds) * time.Second,
+			Run:    job.run,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for name := range specs {
+		if !known[name] {
+			return fmt.Errorf("unknown job %s in JOB_SCHEDULES", name)
+		}
+	}
+	return nil
+}
+
+// purgeExpiredCache deletes expired cache entries
+func purgeExpiredCache(ctx context.Context) error {
+	cacheStore.PurgeExpired(func(key string) {
+		log.Debugf("[Cache:Invalidation] Deleted key: %s", key)
+		_, unversioned := cache.ParseStorageKey(key)
+		cacheStats.evict(unversioned)
+	})
+	responseBodies.PurgeExpired(nil)
+	return nil
+}
+
+func getJobs(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminJobs) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobScheduler.Statuses())
+}
+
+func runJob(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminJobs) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	switch err := jobScheduler.Trigger(name); err {
+	case nil:
+	case scheduler.ErrUnknownJob:
+		http.Error(w, "Unknown job", http.StatusNotFound)
+		return
+	case scheduler.ErrRunning:
+		http.Error(w, "The job is already running", http.StatusConflict)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof("[Jobs] Started %s on demand", name)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": jobStatusRunning,
+	})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"lyrics-api-go/internal/jwt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// jwtVerifier is nil unless JWT_SECRET or JWT_JWKS_URL is configured
+	jwtVerifier *jwt.Verifier
+	// jwtDefaultScopes are the scopes of tokens without a scope claim
+	jwtDefaultScopes map[string]bool
+)
+
+// setupJWT sets up the verification of bearer tokens issued by an existing auth provider, as an
+// alternative to API keys
+func setupJWT() error {
+	if conf.Configuration.JwtSecret == "" && conf.Configuration.JwtJwksUrl == "" {
+		return nil
+	}
+	verifier, err := jwt.New(jwt.Config{
+		Secret:   conf.Configuration.JwtSecret,
+		JWKSURL:  conf.Configuration.JwtJwksUrl,
+		JWKSTTL:  time.Duration(conf.Configuration.JwtJwksTTLInSeconds) * time.Second,
+		Issuer:   conf.Configuration.JwtIssuer,
+		Audience: conf.Configuration.JwtAudience,
+	}, httpClient)
+	if err != nil {
+		return err
+	}
+	if jwtDefaultScopes, err = scopeSet(conf.Configuration.JwtDefaultScopes); err != nil {
+		return fmt.Errorf("invalid JWT_DEFAULT_SCOPES: %v", err)
+	}
+	jwtVerifier = verifier
+	return nil
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// jwtScopes verifies the bearer token of r and returns its scopes: the known scopes of its scope
+// (space-separated) or scp (array) claim, or JWT_DEFAULT_SCOPES when it has neither. ok is false
+// when the token doesn't verify.
+func jwtScopes(r *http.Request, token string) (scopes map[string]bool, ok bool) {
+	claims, err := jwtVerifier.Verify(r.Context(), token)
+	if err != nil {
+		// anyone can send tokens, so rejections are only logged when debugging
+		log.Debugf("[JWT] Rejected bearer token: %v", err)
+		return nil, false
+	}
+
+	claimed := claims.Strings("scope")
+	if claimed == nil {
+		claimed = claims.Strings("scp")
+	}
+	if claimed == nil {
+		return jwtDefaultScopes, true
+	}
+	scopes = map[string]bool{}
+	for _, scope := range claimed {
+		// scopes the auth provider uses for other services are ignored
+		if knownScope(scope) {
+			scopes[scope] = true
+		}
+	}
+	return scopes, true
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// minLongPollRecheckIntervalInSeconds is the lowest LONG_POLL_RECHECK_INTERVAL_IN_SECONDS, as a
+// poller re-checks upstream once per interval
+const minLongPollRecheckIntervalInSeconds = 1
+
+// lyricsWatch tracks requests waiting for lyrics of a track to become available. A waiter of a track
+// without a poller starts one that re-checks upstream while anyone is still waiting, so many clients
+// long-polling the same track cost one upstream request per interval.
+type lyricsWatch struct {
+	mu      sync.Mutex
+	waiters map[string][]chan struct{}
+	// polling holds the tracks with a running poller, which may outlive their waiters by an interval
+	polling map[string]bool
+}
+
+var lyricsWatcher = &lyricsWatch{waiters: make(map[string][]chan struct{}), polling: make(map[string]bool)}
+
+// parseWait parses the ?wait= parameter, accepting durations ("30s") or seconds ("30"), capped by config
+func parseWait(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	wait, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			return 0
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+
+	maxWait := time.Duration(conf.Configuration.LongPollMaxWaitInSeconds) * time.Second
+	if wait > maxWait {
+		wait = maxWait
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// waitForLyrics blocks until lyrics of the track land in the cache, the wait elapses or ctx is done
+func (lw *lyricsWatch) waitForLyrics(ctx context.Context, trackID string, wait time.Duration) (CachedLyrics, bool) {
+	ch := make(chan struct{}, 1)
+
+	lw.mu.Lock()
+	lw.waiters[trackID] = append(lw.waiters[trackID], ch)
+	start := !lw.polling[trackID]
+	lw.polling[trackID] = true
+	lw.mu.Unlock()
+
+	if start {
+		go lw.poll(trackID)
+	}
+	defer lw.remove(trackID, ch)
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ch:
+		data, found, err := getLyricsForTrack(ctx, trackID)
+		return data, found && err == nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return CachedLyrics{}, false
+}
+
+// notify wakes up everyone waiting on the track
+func (lw *lyricsWatch) notify(trackID string) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	for _, ch := range lw.waiters[trackID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (lw *lyricsWatch) remove(trackID string, ch chan struct{}) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	waiters := lw.waiters[trackID]
+	for i, c := range waiters {
+		if c == ch {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(lw.waiters, trackID)
+	} else {
+		lw.waiters[trackID] = waiters
+	}
+}
+
+// keepPolling reports whether anyone still waits on the track, stopping its poller if not
+func (lw *lyricsWatch) keepPolling(trackID string) bool {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if len(lw.waiters[trackID]) > 0 {
+		return true
+	}
+	delete(lw.polling, trackID)
+	return false
+}
+
+// stopPolling marks the poller of the track as stopped
+func (lw *lyricsWatch) stopPolling(trackID string) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	delete(lw.polling, trackID)
+}
+
+// poll re-checks upstream for the track's lyrics until they are found or nobody is waiting anymore
+func (lw *lyricsWatch) poll(trackID string) {
+	interval := time.Duration(conf.Configuration.LongPollRecheckIntervalInSeconds) * time.Second
+	for {
+		time.Sleep(interval)
+		if !lw.keepPolling(trackID) {
+			return
+		}
+
+		_, found, err := getLyricsForTrack(context.Background(), trackID)
+		if err != nil {
+			log.Errorf("[LongPoll] Error re-checking lyrics for %s: %v", trackID, err)
+			continue
+		}
+		if found {
+			lw.stopPolling(trackID)
+			lw.notify(trackID)
+			return
+		}
+	}
+}
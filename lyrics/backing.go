@@ -0,0 +1,72 @@
+package lyrics
+
+import "strings"
+
+// SplitBackingVocals separates parenthetical backing vocals and ad-libs, like "(ooh)", from the
+// lead words of a line. Unbalanced parentheses are left in the lead words.
+func SplitBackingVocals(words string) (lead, backing string) {
+	var leadBuilder strings.Builder
+	var parts []string
+	var current strings.Builder
+	depth := 0
+	start := 0
+
+	runes := []rune(words)
+	for i, r := range runes {
+		switch {
+		case r == '(' || r == '（':
+			if depth == 0 {
+				start = i
+				current.Reset()
+			} else {
+				current.WriteRune(r)
+			}
+			depth++
+		case (r == ')' || r == '）') && depth > 0:
+			depth--
+			if depth == 0 {
+				if part := strings.TrimSpace(current.String()); part != "" {
+					parts = append(parts, part)
+				}
+				leadBuilder.WriteRune(' ')
+			} else {
+				current.WriteRune(r)
+			}
+		case depth > 0:
+			current.WriteRune(r)
+		default:
+			leadBuilder.WriteRune(r)
+		}
+	}
+	if depth > 0 {
+		leadBuilder.WriteString(string(runes[start:]))
+	}
+
+	return tidySpaces(leadBuilder.String()), strings.Join(parts, " ")
+}
+
+// tidySpaces collapses runs of spaces and removes spaces left before punctuation
+func tidySpaces(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	for _, p := range []string{",", ".", "!", "?", ";", ":"} {
+		s = strings.ReplaceAll(s, " "+p, p)
+	}
+	return strings.TrimLeft(s, ",.;: ")
+}
+
+// StripBackingVocals removes parenthetical backing vocals from the words of every line. When separate
+// is set they are kept in the line's BackingVocals instead of being dropped.
+func StripBackingVocals(lines []Line, separate bool) []Line {
+	stripped := make([]Line, len(lines))
+	for i, line := range lines {
+		stripped[i] = line
+		var backing string
+		stripped[i].Words, backing = SplitBackingVocals(line.Words)
+		stripped[i].WordTimings = stripBackingWords(line.WordTimings)
+		stripped[i].Syllables = stripBackingSyllables(line.Syllables)
+		if separate {
+			stripped[i].BackingVocals = backing
+		}
+	}
+	return stripped
+}
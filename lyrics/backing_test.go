@@ -0,0 +1,48 @@
+package lyrics
+
+import "testing"
+
+func TestSplitBackingVocals(t *testing.T) {
+	tests := []struct {
+		name    string
+		words   string
+		lead    string
+		backing string
+	}{
+		{"trailing ad-lib", "I love you (yeah)", "I love you", "yeah"},
+		{"leading ad-lib", "(Oh) baby, baby", "baby, baby", "Oh"},
+		{"middle with punctuation", "Hold me (hold me), tonight", "Hold me, tonight", "hold me"},
+		{"several", "(Ooh) say it (say it)", "say it", "Ooh say it"},
+		{"whole line", "(Ooh, ooh)", "", "Ooh, ooh"},
+		{"nested", "Go (go (go)) now", "Go now", "go (go)"},
+		{"fullwidth", "愛してる（愛してる）", "愛してる", "愛してる"},
+		{"unbalanced", "Wait (for me", "Wait (for me", ""},
+		{"none", "Just words", "Just words", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lead, backing := SplitBackingVocals(tt.words)
+			if lead != tt.lead || backing != tt.backing {
+				t.Errorf("Expected %q and %q, got %q and %q", tt.lead, tt.backing, lead, backing)
+			}
+		})
+	}
+}
+
+func TestStripBackingVocals(t *testing.T) {
+	lines := []Line{{StartTimeMs: "1000", Words: "I love you (yeah)"}}
+
+	stripped := StripBackingVocals(lines, false)
+	if stripped[0].Words != "I love you" || stripped[0].BackingVocals != "" {
+		t.Errorf("Expected stripped line without backing vocals, got %+v", stripped[0])
+	}
+
+	separated := StripBackingVocals(lines, true)
+	if separated[0].Words != "I love you" || separated[0].BackingVocals != "yeah" {
+		t.Errorf("Expected separated backing vocals, got %+v", separated[0])
+	}
+	if lines[0].Words != "I love you (yeah)" {
+		t.Errorf("Expected input lines to be unchanged, got %q", lines[0].Words)
+	}
+}
@@ -0,0 +1,122 @@
+package lyrics
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Text directions
+const (
+	DirLTR = "ltr"
+	DirRTL = "rtl"
+	// DirMixed is the direction of lyrics with both left-to-right and right-to-left lines
+	DirMixed = "mixed"
+)
+
+const (
+	rlm = "\u200f" // right-to-left mark
+	rli = "\u2067" // right-to-left isolate
+	pdi = "\u2069" // pop directional isolate
+)
+
+// sentencePunctuation is punctuation that belongs at the end of a line in logical order
+const sentencePunctuation = ".,!?:;…،؛؟"
+
+// isBidiControl reports whether r is an explicit directional mark or formatting character
+func isBidiControl(r rune) bool {
+	switch {
+	case r == '\u200e' || r == '\u200f' || r == '\u061c':
+		return true
+	case r >= '\u202a' && r <= '\u202e':
+		return true
+	case r >= '\u2066' && r <= '\u2069':
+		return true
+	}
+	return false
+}
+
+func isRTLLetter(r rune) bool {
+	return unicode.In(r, unicode.Hebrew, unicode.Arabic, unicode.Syriac, unicode.Thaana, unicode.Nko)
+}
+
+// NormalizeRTL fixes punctuation placement in a line of right-to-left text: existing directional
+// marks are dropped, sentence punctuation stored in visual order at the start of the line is moved
+// to the end, and spaces before punctuation are removed.
+func NormalizeRTL(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if isBidiControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+	text = strings.TrimSpace(text)
+
+	leading := strings.TrimLeft(text, sentencePunctuation+" ")
+	if leading != text {
+		if first := []rune(leading); len(first) > 0 && isRTLLetter(first[0]) {
+			punctuation := text[:len(text)-len(leading)]
+			text = leading + strings.ReplaceAll(punctuation, " ", "")
+		}
+	}
+
+	var b strings.Builder
+	runes := []rune(text)
+	for i, r := range runes {
+		if r == ' ' && i+1 < len(runes) && strings.ContainsRune(sentencePunctuation, runes[i+1]) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// EmbedRTL normalizes every right-to-left line and wraps it in a directional isolate so it is
+// displayed right to left, with brackets mirrored and punctuation on the correct side, even by
+// clients rendering it as LTR. Unlike an embedding, the isolate doesn't affect the text around the
+// line when clients concatenate it. Left-to-right lines of mixed-direction lyrics are left as they are.
+func EmbedRTL(lines []Line) []Line {
+	embedded := make([]Line, len(lines))
+	for i, line := range lines {
+		embedded[i] = line
+		if line.Words != "" && Direction(line.Words) != DirLTR {
+			embedded[i].Words = rlm + rli + NormalizeRTL(line.Words) + pdi
+		}
+	}
+	return embedded
+}
+
+// Direction returns the direction of text from its first strongly directional letter, like
+// dir="auto" in HTML, or "" when it has no letters
+func Direction(text string) string {
+	for _, r := range text {
+		if isRTLLetter(r) {
+			return DirRTL
+		}
+		if unicode.IsLetter(r) {
+			return DirLTR
+		}
+	}
+	return ""
+}
+
+// Directions sets Dir on every line and returns the direction of the lyrics as a whole: DirLTR or
+// DirRTL when all lines with letters agree, DirMixed otherwise
+func Directions(lines []Line) ([]Line, string) {
+	directed := make([]Line, len(lines))
+	var ltr, rtl bool
+	for i, line := range lines {
+		directed[i] = line
+		directed[i].Dir = Direction(line.Words)
+		ltr = ltr || directed[i].Dir == DirLTR
+		rtl = rtl || directed[i].Dir == DirRTL
+	}
+
+	switch {
+	case ltr && rtl:
+		return directed, DirMixed
+	case rtl:
+		return directed, DirRTL
+	default:
+		return directed, DirLTR
+	}
+}
@@ -0,0 +1,101 @@
+package lyrics
+
+import "testing"
+
+func TestNormalizeRTL(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"already logical", "שלום עולם.", "שלום עולם."},
+		{"visual order punctuation", ".שלום עולם", "שלום עולם."},
+		{"arabic question mark", "؟ كيف حالك", "كيف حالك؟"},
+		{"space before punctuation", "שלום , עולם !", "שלום, עולם!"},
+		{"existing marks dropped", "\u200fשלום\u200e", "שלום"},
+		{"leading punctuation before latin kept", "...and more", "...and more"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeRTL(tt.text); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEmbedRTL(t *testing.T) {
+	lines := []Line{{Words: ".שלום"}, {Words: ""}}
+	got := EmbedRTL(lines)
+
+	if got[0].Words != "\u200f\u2067שלום.\u2069" {
+		t.Errorf("Expected embedded line, got %q", got[0].Words)
+	}
+	if got[1].Words != "" {
+		t.Errorf("Expected empty line to stay empty, got %q", got[1].Words)
+	}
+	if lines[0].Words != ".שלום" {
+		t.Errorf("Expected input lines to be unchanged, got %q", lines[0].Words)
+	}
+}
+
+func TestDirection(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"hebrew", "שלום עולם", DirRTL},
+		{"arabic after punctuation", "... حبيبي", DirRTL},
+		{"english", "hello", DirLTR},
+		{"english first", "baby حبيبي", DirLTR},
+		{"embedded marks", "\u200f\u202bשלום\u202c", DirRTL},
+		{"no letters", "♪ 123", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Direction(tt.text); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDirections(t *testing.T) {
+	tests := []struct {
+		name     string
+		words    []string
+		expected string
+	}{
+		{"rtl", []string{"שלום", "♪"}, DirRTL},
+		{"ltr", []string{"hello", ""}, DirLTR},
+		{"mixed", []string{"hello", "حبيبي"}, DirMixed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []Line
+			for _, words := range tt.words {
+				lines = append(lines, Line{Words: words})
+			}
+			directed, summary := Directions(lines)
+			if summary != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, summary)
+			}
+			for i, line := range directed {
+				if line.Dir != Direction(line.Words) {
+					t.Errorf("Line %d: expected dir %q, got %q", i, Direction(line.Words), line.Dir)
+				}
+			}
+		})
+	}
+}
+
+func TestEmbedRTLSkipsLTRLines(t *testing.T) {
+	got := EmbedRTL([]Line{{Words: "hello"}})
+	if got[0].Words != "hello" {
+		t.Errorf("Expected LTR line to be left unchanged, got %q", got[0].Words)
+	}
+}
@@ -0,0 +1,10 @@
+package lyrics
+
+// Compact returns lines as [startMs, words] pairs, roughly halving the JSON payload
+func Compact(lines []Line) [][]interface{} {
+	compact := make([][]interface{}, 0, len(lines))
+	for _, line := range lines {
+		compact = append(compact, []interface{}{line.StartMs(), line.Words})
+	}
+	return compact
+}
@@ -0,0 +1,19 @@
+package lyrics
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCompact(t *testing.T) {
+	lines := []Line{
+		{StartTimeMs: "1000", DurationMs: "2000", Words: "Hello"},
+		{StartTimeMs: "3000", DurationMs: "0", Words: "World"},
+	}
+
+	got, _ := json.Marshal(Compact(lines))
+	expected := `[[1000,"Hello"],[3000,"World"]]`
+	if string(got) != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
@@ -0,0 +1,58 @@
+package lyrics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FormatELRC renders lines as enhanced (A2) LRC. Every line carries its start tag and inline word
+// tags marking where the line starts and ends, so karaoke-capable clients can highlight it. Lines
+// with word or syllable timing get a tag before every word or syllable instead. The voice part of
+// duet lines follows the line tag, e.g. "v1:".
+func FormatELRC(lines []Line) string {
+	var sb strings.Builder
+	for _, line := range lines {
+		start, end := line.StartMs(), line.EndMs()
+		if parts := timedParts(line); len(parts) > 0 {
+			writeTimedParts(&sb, line, parts)
+			continue
+		}
+		fmt.Fprintf(&sb, "%s<%s> %s", lrcLineTag(line), lrcTimestamp(start), line.Words)
+		if end > start {
+			fmt.Fprintf(&sb, " <%s>", lrcTimestamp(end))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// writeTimedParts writes a line tagging the start of every word or syllable and the end of the last one
+func writeTimedParts(sb *strings.Builder, line Line, parts []WordTiming) {
+	sb.WriteString(lrcLineTag(line))
+	for i, part := range parts {
+		text := strings.TrimSpace(part.Text)
+		fmt.Fprintf(sb, "<%s> %s", lrcTimestamp(part.StartMs), text)
+		// parts written without spaces, like syllables or Chinese characters, stay joined
+		if text != part.Text || i == len(parts)-1 {
+			sb.WriteString(" ")
+		}
+	}
+	fmt.Fprintf(sb, "<%s>\n", lrcTimestamp(parts[len(parts)-1].EndMs))
+}
+
+// lrcTimestamp formats milliseconds as mm:ss.xx
+func lrcTimestamp(ms int64) string {
+	if ms < 0 {
+		ms = 0
+	}
+	centiseconds := ms / 10
+	return fmt.Sprintf("%02d:%02d.%02d", centiseconds/6000, (centiseconds/100)%60, centiseconds%100)
+}
+
+// lrcLineTag returns the start tag of a line followed by its voice part marker, if any
+func lrcLineTag(line Line) string {
+	if line.Voice == "" {
+		return fmt.Sprintf("[%s] ", lrcTimestamp(line.StartMs()))
+	}
+	return fmt.Sprintf("[%s] %s: ", lrcTimestamp(line.StartMs()), voiceLabel(line.Voice))
+}
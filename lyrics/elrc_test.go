@@ -0,0 +1,34 @@
+package lyrics
+
+import "testing"
+
+func TestFormatELRC(t *testing.T) {
+	lines := []Line{
+		{StartTimeMs: "1230", EndTimeMs: "65400", Words: "Hello"},
+		{StartTimeMs: "65400", EndTimeMs: "65400", Words: "World"},
+	}
+
+	expected := "[00:01.23] <00:01.23> Hello <01:05.40>\n[01:05.40] <01:05.40> World\n"
+	if got := FormatELRC(lines); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestFormatELRCWordTimings(t *testing.T) {
+	lines := []Line{
+		{StartTimeMs: "1000", EndTimeMs: "2000", Words: "Hello world", WordTimings: []WordTiming{
+			{Text: "Hello ", StartMs: 1000, EndMs: 1400},
+			{Text: "world", StartMs: 1500, EndMs: 1900},
+		}},
+		{StartTimeMs: "2000", EndTimeMs: "3000", Words: "你好", WordTimings: []WordTiming{
+			{Text: "你", StartMs: 2000, EndMs: 2500},
+			{Text: "好", StartMs: 2500, EndMs: 3000},
+		}},
+	}
+
+	expected := "[00:01.00] <00:01.00> Hello <00:01.50> world <00:01.90>\n" +
+		"[00:02.00] <00:02.00> 你<00:02.50> 好 <00:03.00>\n"
+	if got := FormatELRC(lines); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
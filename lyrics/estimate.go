@@ -0,0 +1,53 @@
+package lyrics
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SyncTypeEstimated lyrics have timing estimated from plain lyrics rather than synced by a provider
+const SyncTypeEstimated = "ESTIMATED"
+
+// estimatePauseWidth is the width added to every line for the pause after it, and the width of a blank
+// line between stanzas, so short lines still get a reasonable share of the time
+const estimatePauseWidth = 8
+
+// EstimateTimings times plain lyrics, one line per row, by spreading the lines between startMs and
+// endMs in proportion to their width. Blank rows become gaps between stanzas and are dropped.
+func EstimateTimings(text string, startMs, endMs int64) []Line {
+	rows := strings.Split(strings.TrimSpace(text), "\n")
+	weights := make([]int64, len(rows))
+	var total int64
+	for i, row := range rows {
+		weights[i] = estimatePauseWidth
+		if words := strings.TrimSpace(row); words != "" {
+			weights[i] += int64(Width(words))
+		}
+		total += weights[i]
+	}
+
+	lines := []Line{}
+	if endMs <= startMs || total == 0 {
+		return lines
+	}
+
+	var offset int64
+	for i, row := range rows {
+		lineStart := startMs + (endMs-startMs)*offset/total
+		offset += weights[i]
+		lineEnd := startMs + (endMs-startMs)*offset/total
+
+		words := strings.TrimSpace(row)
+		if words == "" {
+			continue
+		}
+		lines = append(lines, Line{
+			StartTimeMs: strconv.FormatInt(lineStart, 10),
+			DurationMs:  strconv.FormatInt(lineEnd-lineStart, 10),
+			Words:       words,
+			Syllables:   []Syllable{},
+			EndTimeMs:   strconv.FormatInt(lineEnd, 10),
+		})
+	}
+	return lines
+}
@@ -0,0 +1,30 @@
+package lyrics
+
+import "testing"
+
+func TestEstimateTimings(t *testing.T) {
+	// widths of 2 and 10 plus the pause of 8 each, and a blank row of 8: 10, 8 and 18 of 36 parts
+	lines := EstimateTimings("Hi\n\nHello ther", 1000, 37000)
+
+	expected := []struct {
+		words      string
+		start, end int64
+	}{
+		{"Hi", 1000, 11000},
+		{"Hello ther", 19000, 37000},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %d", len(expected), len(lines))
+	}
+	for i, e := range expected {
+		if lines[i].Words != e.words || lines[i].StartMs() != e.start || lines[i].EndMs() != e.end {
+			t.Errorf("Line %d: expected %q at %d-%d, got %q at %s-%s", i, e.words, e.start, e.end, lines[i].Words, lines[i].StartTimeMs, lines[i].EndTimeMs)
+		}
+	}
+}
+
+func TestEstimateTimingsWithoutDuration(t *testing.T) {
+	if lines := EstimateTimings("Hi", 1000, 1000); len(lines) != 0 {
+		t.Errorf("Expected no lines without a duration, got %d", len(lines))
+	}
+}
@@ -0,0 +1,28 @@
+package lyrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ContentHash returns a hash identifying lyrics by their lines' start times and words, ignoring case,
+// spacing and filler lines, so re-releases of a song with the same synced lyrics (remasters, deluxe
+// editions) hash the same. Lines without any words hash to "".
+func ContentHash(lines []Line) string {
+	h := sha256.New()
+	empty := true
+	for _, line := range lines {
+		words := strings.Join(strings.Fields(strings.ToLower(line.Words)), " ")
+		if isFiller(words) {
+			continue
+		}
+		fmt.Fprintf(h, "%d\t%s\n", line.StartMs(), words)
+		empty = false
+	}
+	if empty {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
@@ -0,0 +1,29 @@
+package lyrics
+
+import "testing"
+
+func TestContentHash(t *testing.T) {
+	original := []Line{
+		{StartTimeMs: "0", Words: "♪"},
+		{StartTimeMs: "1000", Words: "Hello  world"},
+		{StartTimeMs: "2000", Words: "Goodbye"},
+	}
+	remaster := []Line{
+		{StartTimeMs: "1000", Words: "hello world "},
+		{StartTimeMs: "2000", Words: "Goodbye"},
+	}
+	shifted := []Line{
+		{StartTimeMs: "1500", Words: "Hello world"},
+		{StartTimeMs: "2500", Words: "Goodbye"},
+	}
+
+	if ContentHash(original) != ContentHash(remaster) {
+		t.Errorf("Expected lyrics differing in case, spacing and filler lines to hash the same")
+	}
+	if ContentHash(original) == ContentHash(shifted) {
+		t.Errorf("Expected lyrics with different timing to hash differently")
+	}
+	if hash := ContentHash([]Line{{StartTimeMs: "0", Words: "♪"}}); hash != "" {
+		t.Errorf("Expected filler-only lyrics to have no hash, got %q", hash)
+	}
+}
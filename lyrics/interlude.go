@@ -0,0 +1,63 @@
+package lyrics
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Without word timing, a line is assumed to be sung for this long per column of text plus a pause
+const (
+	sungMsPerColumn = 150
+	sungPaddingMs   = 1000
+)
+
+// MarkInterludes flags the instrumental breaks between lines. Upstream lines last until the next one
+// starts, so a line followed by a solo stays on screen throughout. Lines held at least minGapMs longer
+// than they are sung (going by their word timing, or else their length) are cut where the singing
+// ends and followed by an IntroMarker line flagged IsInterlude covering the break. Filler lines
+// upstream already has for breaks are flagged as they are.
+func MarkInterludes(lines []Line, minGapMs int64) []Line {
+	marked := make([]Line, 0, len(lines))
+	for i, line := range lines {
+		if isFiller(strings.TrimSpace(line.Words)) {
+			line.IsInterlude = line.EndMs() > line.StartMs()
+			marked = append(marked, line)
+			continue
+		}
+
+		// the break after the last line is the outro, not an interlude
+		last := i == len(lines)-1
+		sungEnd := sungEndMs(line)
+		if last || line.EndMs()-sungEnd < minGapMs || isFiller(strings.TrimSpace(lines[i+1].Words)) {
+			marked = append(marked, line)
+			continue
+		}
+
+		breakEnd := line.EndMs()
+		line.EndTimeMs = strconv.FormatInt(sungEnd, 10)
+		line.DurationMs = strconv.FormatInt(sungEnd-line.StartMs(), 10)
+		marked = append(marked, line, Line{
+			StartTimeMs: strconv.FormatInt(sungEnd, 10),
+			DurationMs:  strconv.FormatInt(breakEnd-sungEnd, 10),
+			Words:       IntroMarker,
+			Syllables:   []Syllable{},
+			EndTimeMs:   strconv.FormatInt(breakEnd, 10),
+			IsInterlude: true,
+		})
+	}
+	return marked
+}
+
+// sungEndMs returns when the singing of a line ends, never after the line itself ends
+func sungEndMs(line Line) int64 {
+	end := line.StartMs() + int64(Width(strings.TrimSpace(line.Words)))*sungMsPerColumn + sungPaddingMs
+	if len(line.WordTimings) > 0 {
+		end = line.WordTimings[len(line.WordTimings)-1].EndMs
+	} else if len(line.Syllables) > 0 {
+		end = line.Syllables[len(line.Syllables)-1].EndMs()
+	}
+	if end > line.EndMs() {
+		end = line.EndMs()
+	}
+	return end
+}
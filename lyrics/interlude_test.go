@@ -0,0 +1,61 @@
+package lyrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMarkInterludes(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []Line
+		expected []Line
+	}{
+		{
+			name: "break after a line",
+			lines: []Line{
+				{StartTimeMs: "1000", DurationMs: "20000", EndTimeMs: "21000", Words: "Hello"},
+				{StartTimeMs: "21000", DurationMs: "3000", EndTimeMs: "24000", Words: "World"},
+			},
+			// "Hello" is sung for 5 columns * 150ms + 1000ms
+			expected: []Line{
+				{StartTimeMs: "1000", DurationMs: "1750", EndTimeMs: "2750", Words: "Hello"},
+				{StartTimeMs: "2750", DurationMs: "18250", EndTimeMs: "21000", Words: IntroMarker, Syllables: []Syllable{}, IsInterlude: true},
+				{StartTimeMs: "21000", DurationMs: "3000", EndTimeMs: "24000", Words: "World"},
+			},
+		},
+		{
+			name: "word timing",
+			lines: []Line{
+				{StartTimeMs: "1000", DurationMs: "9000", EndTimeMs: "10000", Words: "Hello", WordTimings: []WordTiming{{Text: "Hello", StartMs: 1000, EndMs: 1500}}},
+				{StartTimeMs: "10000", DurationMs: "3000", EndTimeMs: "13000", Words: "World"},
+			},
+			expected: []Line{
+				{StartTimeMs: "1000", DurationMs: "500", EndTimeMs: "1500", Words: "Hello", WordTimings: []WordTiming{{Text: "Hello", StartMs: 1000, EndMs: 1500}}},
+				{StartTimeMs: "1500", DurationMs: "8500", EndTimeMs: "10000", Words: IntroMarker, Syllables: []Syllable{}, IsInterlude: true},
+				{StartTimeMs: "10000", DurationMs: "3000", EndTimeMs: "13000", Words: "World"},
+			},
+		},
+		{
+			name: "short gap and upstream filler",
+			lines: []Line{
+				{StartTimeMs: "1000", DurationMs: "5000", EndTimeMs: "6000", Words: "Hello"},
+				{StartTimeMs: "6000", DurationMs: "20000", EndTimeMs: "26000", Words: "♪"},
+				{StartTimeMs: "26000", DurationMs: "30000", EndTimeMs: "56000", Words: "World"},
+			},
+			expected: []Line{
+				{StartTimeMs: "1000", DurationMs: "5000", EndTimeMs: "6000", Words: "Hello"},
+				{StartTimeMs: "6000", DurationMs: "20000", EndTimeMs: "26000", Words: "♪", IsInterlude: true},
+				{StartTimeMs: "26000", DurationMs: "30000", EndTimeMs: "56000", Words: "World"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MarkInterludes(tt.lines, 8000); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
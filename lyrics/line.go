@@ -0,0 +1,114 @@
+package lyrics
+
+import (
+	"math"
+	"strconv"
+)
+
+// Sync types reported by providers
+const (
+	SyncTypeLineSynced = "LINE_SYNCED"
+	// SyncTypeUnsynced lyrics have no timing, only the text of each line
+	SyncTypeUnsynced = "UNSYNCED"
+)
+
+type Line struct {
+	StartTimeMs string     `json:"startTimeMs"`
+	DurationMs  string     `json:"durationMs"`
+	Words       string     `json:"words"`
+	Syllables   []Syllable `json:"syllables"`
+	EndTimeMs   string     `json:"endTimeMs"`
+	// BackingVocals are the parenthetical backing vocals separated from Words on request
+	BackingVocals string `json:"backingVocals,omitempty"`
+	// Dir is the direction of the line, DirLTR or DirRTL, set on request
+	Dir string `json:"dir,omitempty"`
+	// IsInterlude flags marker lines covering an instrumental break, see MarkInterludes
+	IsInterlude bool `json:"isInterlude,omitempty"`
+	// Voice is the voice part singing the line in duets, e.g. v1 or v2, see ParseVoices
+	Voice string `json:"voice,omitempty"`
+	// WordTimings is the timing of every word, for providers with word-level sync
+	WordTimings []WordTiming `json:"wordTimings,omitempty"`
+}
+
+// StartMs returns the parsed start time of the line
+func (l Line) StartMs() int64 {
+	ms, _ := strconv.ParseInt(l.StartTimeMs, 10, 64)
+	return ms
+}
+
+// EndMs returns the parsed end time of the line
+func (l Line) EndMs() int64 {
+	ms, _ := strconv.ParseInt(l.EndTimeMs, 10, 64)
+	return ms
+}
+
+// ComputeTimings sets DurationMs and EndTimeMs of every line. A line ends when the next one starts;
+// the last line keeps the end time reported upstream when there is one. The timing of syllables and
+// words is then completed within their line, see timeParts.
+func ComputeTimings(lines []Line) {
+	for i := 0; i < len(lines); i++ {
+		startTime := lines[i].StartMs()
+		var endTime int64
+
+		if i == len(lines)-1 {
+			endTime = lines[i].EndMs()
+			if endTime < startTime {
+				endTime = startTime
+			}
+		} else {
+			endTime = lines[i+1].StartMs()
+		}
+
+		duration := endTime - startTime
+		lines[i].DurationMs = strconv.FormatInt(duration, 10)
+		lines[i].EndTimeMs = strconv.FormatInt(endTime, 10)
+		timeParts(&lines[i])
+	}
+}
+
+// Shift moves the start and end times of every line by offsetMs, clamping at zero so lines that
+// would start before the track are pinned to its beginning. Durations are recomputed from the
+// shifted times.
+func Shift(lines []Line, offsetMs int64) []Line {
+	shifted := make([]Line, len(lines))
+	for i, line := range lines {
+		start := clampMs(line.StartMs() + offsetMs)
+		end := clampMs(line.EndMs() + offsetMs)
+		if end < start {
+			end = start
+		}
+		line.StartTimeMs = strconv.FormatInt(start, 10)
+		line.EndTimeMs = strconv.FormatInt(end, 10)
+		line.DurationMs = strconv.FormatInt(end-start, 10)
+		shift := func(ms int64) int64 { return clampMs(ms + offsetMs) }
+		line.WordTimings = mapWordTimings(line.WordTimings, shift)
+		line.Syllables = mapSyllables(line.Syllables, shift)
+		shifted[i] = line
+	}
+	return shifted
+}
+
+// Scale divides every start and end time by rate, for playback at rate times the original speed
+// (e.g. 1.25 for nightcore edits, 0.8 for slowed ones). rate must be positive.
+func Scale(lines []Line, rate float64) []Line {
+	scaled := make([]Line, len(lines))
+	for i, line := range lines {
+		start := int64(math.Round(float64(line.StartMs()) / rate))
+		end := int64(math.Round(float64(line.EndMs()) / rate))
+		line.StartTimeMs = strconv.FormatInt(start, 10)
+		line.EndTimeMs = strconv.FormatInt(end, 10)
+		line.DurationMs = strconv.FormatInt(end-start, 10)
+		scale := func(ms int64) int64 { return int64(math.Round(float64(ms) / rate)) }
+		line.WordTimings = mapWordTimings(line.WordTimings, scale)
+		line.Syllables = mapSyllables(line.Syllables, scale)
+		scaled[i] = line
+	}
+	return scaled
+}
+
+func clampMs(ms int64) int64 {
+	if ms < 0 {
+		return 0
+	}
+	return ms
+}
@@ -0,0 +1,96 @@
+package lyrics
+
+import "testing"
+
+func TestComputeTimings(t *testing.T) {
+	lines := []Line{
+		{StartTimeMs: "1000", Words: "first"},
+		{StartTimeMs: "3500", Words: "second"},
+		{StartTimeMs: "6000", Words: "last", EndTimeMs: "9000"},
+	}
+	ComputeTimings(lines)
+
+	expected := []struct{ duration, end string }{
+		{"2500", "3500"},
+		{"2500", "6000"},
+		{"3000", "9000"},
+	}
+	for i, want := range expected {
+		if lines[i].DurationMs != want.duration || lines[i].EndTimeMs != want.end {
+			t.Errorf("Line %d: expected duration %s and end %s, got %s and %s",
+				i, want.duration, want.end, lines[i].DurationMs, lines[i].EndTimeMs)
+		}
+	}
+}
+
+func TestComputeTimingsLastLineWithoutEnd(t *testing.T) {
+	lines := []Line{{StartTimeMs: "1000", EndTimeMs: "0"}}
+	ComputeTimings(lines)
+
+	if lines[0].DurationMs != "0" || lines[0].EndTimeMs != "1000" {
+		t.Errorf("Expected zero duration ending at start, got %s and %s", lines[0].DurationMs, lines[0].EndTimeMs)
+	}
+}
+
+func TestShift(t *testing.T) {
+	lines := []Line{
+		{StartTimeMs: "300", DurationMs: "700", EndTimeMs: "1000", Words: "first"},
+		{StartTimeMs: "1000", DurationMs: "2000", EndTimeMs: "3000", Words: "second"},
+	}
+
+	tests := []struct {
+		name     string
+		offsetMs int64
+		expected []struct{ start, duration, end string }
+	}{
+		{"later", 500, []struct{ start, duration, end string }{{"800", "700", "1500"}, {"1500", "2000", "3500"}}},
+		{"earlier", -500, []struct{ start, duration, end string }{{"0", "500", "500"}, {"500", "2000", "2500"}}},
+		{"before start", -5000, []struct{ start, duration, end string }{{"0", "0", "0"}, {"0", "0", "0"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shifted := Shift(lines, tt.offsetMs)
+			for i, want := range tt.expected {
+				got := shifted[i]
+				if got.StartTimeMs != want.start || got.DurationMs != want.duration || got.EndTimeMs != want.end {
+					t.Errorf("Line %d: expected %s+%s=%s, got %s+%s=%s",
+						i, want.start, want.duration, want.end, got.StartTimeMs, got.DurationMs, got.EndTimeMs)
+				}
+			}
+			if lines[0].StartTimeMs != "300" {
+				t.Errorf("Expected the original lines to be left unchanged, got start %s", lines[0].StartTimeMs)
+			}
+		})
+	}
+}
+
+func TestScale(t *testing.T) {
+	lines := []Line{
+		{StartTimeMs: "1000", DurationMs: "1500", EndTimeMs: "2500", Words: "first"},
+		{StartTimeMs: "2500", DurationMs: "2500", EndTimeMs: "5000", Words: "second"},
+	}
+
+	tests := []struct {
+		name     string
+		rate     float64
+		expected []struct{ start, duration, end string }
+	}{
+		{"faster", 1.25, []struct{ start, duration, end string }{{"800", "1200", "2000"}, {"2000", "2000", "4000"}}},
+		{"slower", 0.8, []struct{ start, duration, end string }{{"1250", "1875", "3125"}, {"3125", "3125", "6250"}}},
+		{"unchanged", 1, []struct{ start, duration, end string }{{"1000", "1500", "2500"}, {"2500", "2500", "5000"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scaled := Scale(lines, tt.rate)
+			for i, want := range tt.expected {
+				got := scaled[i]
+				if got.StartTimeMs != want.start || got.DurationMs != want.duration || got.EndTimeMs != want.end {
+					t.Errorf("Line %d: expected %s+%s=%s, got %s+%s=%s",
+						i, want.start, want.duration, want.end, got.StartTimeMs, got.DurationMs, got.EndTimeMs)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package lyrics
+
+import (
+	"strings"
+)
+
+// FormatLRC renders lines as line-level LRC, one "[mm:ss.xx] words" row per line, for players
+// without enhanced LRC support. The voice part of duet lines follows the line tag, e.g. "v1:".
+func FormatLRC(lines []Line) string {
+	var sb strings.Builder
+	for _, line := range lines {
+		sb.WriteString(lrcLineTag(line))
+		sb.WriteString(line.Words)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
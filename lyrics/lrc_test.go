@@ -0,0 +1,15 @@
+package lyrics
+
+import "testing"
+
+func TestFormatLRC(t *testing.T) {
+	lines := []Line{
+		{StartTimeMs: "1230", Words: "Hello"},
+		{StartTimeMs: "65000", Words: "World", Voice: "v2"},
+	}
+
+	expected := "[00:01.23] Hello\n[01:05.00] v2: World\n"
+	if got := FormatLRC(lines); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
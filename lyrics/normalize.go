@@ -0,0 +1,57 @@
+package lyrics
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Normalize cleans up lines from sources with duplicated or filler lines. Zero-duration lines that
+// repeat a neighbouring line are dropped, runs of consecutive identical lines are collapsed into a
+// single line spanning the whole run, and empty or "♪"-only lines are dropped when they have no
+// duration to mark.
+func Normalize(lines []Line) []Line {
+	normalized := make([]Line, 0, len(lines))
+	for i, line := range lines {
+		words := strings.TrimSpace(line.Words)
+		zeroDuration := line.EndMs() <= line.StartMs()
+
+		if zeroDuration && isFiller(words) {
+			continue
+		}
+		// a zero-duration copy of the next line is dropped in favour of the timed one
+		if zeroDuration && i+1 < len(lines) && strings.TrimSpace(lines[i+1].Words) == words {
+			continue
+		}
+		if n := len(normalized); n > 0 && strings.TrimSpace(normalized[n-1].Words) == words {
+			previous := &normalized[n-1]
+			if line.EndMs() > previous.EndMs() {
+				previous.EndTimeMs = line.EndTimeMs
+				previous.DurationMs = strconv.FormatInt(previous.EndMs()-previous.StartMs(), 10)
+			}
+			continue
+		}
+		normalized = append(normalized, line)
+	}
+	return normalized
+}
+
+// isFiller reports whether words carry no lyrics, only whitespace and musical notes
+func isFiller(words string) bool {
+	for _, r := range words {
+		if r != '♪' && r != '♫' && r != '♬' && r != '♩' && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsInstrumental reports whether lines carry no lyrics, only musical notes or nothing at all
+func IsInstrumental(lines []Line) bool {
+	for _, line := range lines {
+		if !isFiller(strings.TrimSpace(line.Words)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package lyrics
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []Line
+		expected []Line
+	}{
+		{
+			name: "zero-duration duplicate",
+			lines: []Line{
+				{StartTimeMs: "1000", DurationMs: "0", EndTimeMs: "1000", Words: "hello"},
+				{StartTimeMs: "1000", DurationMs: "2000", EndTimeMs: "3000", Words: "hello"},
+				{StartTimeMs: "3000", DurationMs: "1000", EndTimeMs: "4000", Words: "world"},
+			},
+			expected: []Line{
+				{StartTimeMs: "1000", DurationMs: "2000", EndTimeMs: "3000", Words: "hello"},
+				{StartTimeMs: "3000", DurationMs: "1000", EndTimeMs: "4000", Words: "world"},
+			},
+		},
+		{
+			name: "consecutive identical lines",
+			lines: []Line{
+				{StartTimeMs: "1000", DurationMs: "1000", EndTimeMs: "2000", Words: "hey"},
+				{StartTimeMs: "2000", DurationMs: "1000", EndTimeMs: "3000", Words: "hey "},
+				{StartTimeMs: "3000", DurationMs: "1000", EndTimeMs: "4000", Words: "hey"},
+				{StartTimeMs: "4000", DurationMs: "1000", EndTimeMs: "5000", Words: "ho"},
+			},
+			expected: []Line{
+				{StartTimeMs: "1000", DurationMs: "3000", EndTimeMs: "4000", Words: "hey"},
+				{StartTimeMs: "4000", DurationMs: "1000", EndTimeMs: "5000", Words: "ho"},
+			},
+		},
+		{
+			name: "untimed filler",
+			lines: []Line{
+				{StartTimeMs: "0", DurationMs: "0", EndTimeMs: "0", Words: "♪"},
+				{StartTimeMs: "0", DurationMs: "5000", EndTimeMs: "5000", Words: "♪"},
+				{StartTimeMs: "5000", DurationMs: "0", EndTimeMs: "5000", Words: ""},
+				{StartTimeMs: "5000", DurationMs: "1000", EndTimeMs: "6000", Words: "verse"},
+			},
+			expected: []Line{
+				{StartTimeMs: "0", DurationMs: "5000", EndTimeMs: "5000", Words: "♪"},
+				{StartTimeMs: "5000", DurationMs: "1000", EndTimeMs: "6000", Words: "verse"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Normalize(tt.lines)
+			if len(result) != len(tt.expected) {
+				t.Fatalf("Expected %d lines, got %d: %+v", len(tt.expected), len(result), result)
+			}
+			for i, want := range tt.expected {
+				got := result[i]
+				if got.StartTimeMs != want.StartTimeMs || got.DurationMs != want.DurationMs ||
+					got.EndTimeMs != want.EndTimeMs || got.Words != want.Words {
+					t.Errorf("Line %d: expected %+v, got %+v", i, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestIsInstrumental(t *testing.T) {
+	tests := []struct {
+		name     string
+		words    []string
+		expected bool
+	}{
+		{"no lines", nil, true},
+		{"only notes", []string{"♪", " ♫ ", ""}, true},
+		{"lyrics", []string{"♪", "hello"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []Line
+			for _, words := range tt.words {
+				lines = append(lines, Line{Words: words})
+			}
+			if got := IsInstrumental(lines); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
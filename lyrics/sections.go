@@ -0,0 +1,136 @@
+package lyrics
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Section labels
+const (
+	SectionVerse        = "verse"
+	SectionChorus       = "chorus"
+	SectionInstrumental = "instrumental"
+)
+
+// Chorus candidates are repeated blocks of this many lines
+const (
+	minChorusLines = 2
+	maxChorusLines = 12
+)
+
+// Section is a group of consecutive lines
+type Section struct {
+	Label     string `json:"label"`
+	StartLine int    `json:"startLine"`
+	LineCount int    `json:"lineCount"`
+	StartMs   int64  `json:"startMs"`
+	EndMs     int64  `json:"endMs"`
+}
+
+// Sections groups lines into verses, choruses and instrumental breaks. The chorus is the block of
+// consecutive lines repeated most often, weighted by its length; every repetition of it is a chorus
+// section. Filler lines form instrumental sections and the remaining runs of lines are verses.
+func Sections(lines []Line) []Section {
+	keys := make([]string, len(lines))
+	for i, line := range lines {
+		keys[i] = sectionKey(line.Words)
+	}
+
+	labels := make([]string, len(lines))
+	for i, key := range keys {
+		if key == "" {
+			labels[i] = SectionInstrumental
+		}
+	}
+	// every repetition of the chorus starts a section of its own, even right after another one
+	chorusStarts := map[int]bool{}
+	if start, length := findChorus(keys); length > 0 {
+		for _, at := range occurrences(keys, keys[start:start+length]) {
+			chorusStarts[at] = true
+			for i := at; i < at+length; i++ {
+				labels[i] = SectionChorus
+			}
+		}
+	}
+
+	sections := []Section{}
+	for i := range lines {
+		label := labels[i]
+		if label == "" {
+			label = SectionVerse
+		}
+		if n := len(sections); n > 0 && sections[n-1].Label == label && !chorusStarts[i] {
+			sections[n-1].LineCount++
+			sections[n-1].EndMs = lines[i].EndMs()
+			continue
+		}
+		sections = append(sections, Section{Label: label, StartLine: i, LineCount: 1, StartMs: lines[i].StartMs(), EndMs: lines[i].EndMs()})
+	}
+	return sections
+}
+
+// findChorus returns the start and length of the repeated block with the best repetitions times
+// length score, preferring the earliest and then longest block on ties. length is 0 when no block of
+// at least minChorusLines lines repeats.
+func findChorus(keys []string) (start, length int) {
+	bestScore := 0
+	for i := range keys {
+		for n := minChorusLines; n <= maxChorusLines && i+n <= len(keys); n++ {
+			block := keys[i : i+n]
+			if containsEmpty(block) {
+				break
+			}
+			count := len(occurrences(keys, block))
+			if count < 2 {
+				break
+			}
+			if score := count * n; score > bestScore {
+				bestScore, start, length = score, i, n
+			}
+		}
+	}
+	return start, length
+}
+
+// occurrences returns where block occurs in keys, without overlaps
+func occurrences(keys, block []string) []int {
+	var found []int
+	for i := 0; i+len(block) <= len(keys); {
+		if equalKeys(keys[i:i+len(block)], block) {
+			found = append(found, i)
+			i += len(block)
+			continue
+		}
+		i++
+	}
+	return found
+}
+
+func equalKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsEmpty(keys []string) bool {
+	for _, key := range keys {
+		if key == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// sectionKey reduces a line to its letters and digits in lower case, so repeats differing only in
+// punctuation or case match. Filler lines have an empty key.
+func sectionKey(words string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(words), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
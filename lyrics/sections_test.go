@@ -0,0 +1,46 @@
+package lyrics
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestSections(t *testing.T) {
+	words := []string{
+		"Walking down the road", "Nothing on my mind",
+		"Oh, sing it loud", "Sing it proud",
+		"Another day goes by", "Another line",
+		"♪",
+		"Oh sing it loud!", "Sing it proud",
+		"Oh, sing it loud", "Sing it proud",
+	}
+	lines := make([]Line, len(words))
+	for i, w := range words {
+		lines[i] = Line{StartTimeMs: strconv.Itoa(i * 1000), EndTimeMs: strconv.Itoa((i + 1) * 1000), Words: w}
+	}
+
+	expected := []Section{
+		{Label: SectionVerse, StartLine: 0, LineCount: 2, StartMs: 0, EndMs: 2000},
+		{Label: SectionChorus, StartLine: 2, LineCount: 2, StartMs: 2000, EndMs: 4000},
+		{Label: SectionVerse, StartLine: 4, LineCount: 2, StartMs: 4000, EndMs: 6000},
+		{Label: SectionInstrumental, StartLine: 6, LineCount: 1, StartMs: 6000, EndMs: 7000},
+		{Label: SectionChorus, StartLine: 7, LineCount: 2, StartMs: 7000, EndMs: 9000},
+		{Label: SectionChorus, StartLine: 9, LineCount: 2, StartMs: 9000, EndMs: 11000},
+	}
+	if got := Sections(lines); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestSectionsWithoutRepeats(t *testing.T) {
+	lines := []Line{
+		{StartTimeMs: "0", EndTimeMs: "1000", Words: "One"},
+		{StartTimeMs: "1000", EndTimeMs: "2000", Words: "Two"},
+	}
+
+	expected := []Section{{Label: SectionVerse, StartLine: 0, LineCount: 2, StartMs: 0, EndMs: 2000}}
+	if got := Sections(lines); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
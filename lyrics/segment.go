@@ -0,0 +1,180 @@
+package lyrics
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// token is a unit lines can be broken at. Chinese and Japanese are written without spaces,
+// so every Han or kana character is its own token and is joined to its neighbours without a space.
+type token struct {
+	text        string
+	spaceBefore bool
+}
+
+// isUnspaced reports whether r belongs to a script written without spaces between words
+func isUnspaced(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+// isWide reports whether r takes two columns when displayed
+func isWide(r rune) bool {
+	return isUnspaced(r) || unicode.Is(unicode.Hangul, r) ||
+		(r >= 0x3000 && r <= 0x303F) || (r >= 0xFF01 && r <= 0xFF60)
+}
+
+// attachesToPrevious reports whether r must not start a token, like closing punctuation or the
+// katakana prolonged sound mark
+func attachesToPrevious(r rune) bool {
+	switch r {
+	case 'ー', '〜', '～':
+		return true
+	}
+	return unicode.IsPunct(r) && !strings.ContainsRune("(（「『【〈《\"'", r)
+}
+
+func tokenize(text string) []token {
+	var tokens []token
+	var current strings.Builder
+	spaceBefore := false
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, token{text: current.String(), spaceBefore: spaceBefore})
+			current.Reset()
+			spaceBefore = false
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+			spaceBefore = len(tokens) > 0
+		case isUnspaced(r):
+			flush()
+			current.WriteRune(r)
+			flush()
+		case attachesToPrevious(r) && current.Len() == 0 && len(tokens) > 0 && !spaceBefore:
+			tokens[len(tokens)-1].text += string(r)
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+func joinTokens(tokens []token) string {
+	var b strings.Builder
+	for i, t := range tokens {
+		if i > 0 && t.spaceBefore {
+			b.WriteByte(' ')
+		}
+		b.WriteString(t.text)
+	}
+	return b.String()
+}
+
+// Segment splits text into words. Space-separated scripts are split on whitespace; Chinese and
+// Japanese text is split per character, with punctuation kept on the preceding word.
+func Segment(text string) []string {
+	tokens := tokenize(text)
+	words := make([]string, len(tokens))
+	for i, t := range tokens {
+		words[i] = t.text
+	}
+	return words
+}
+
+// WordCount returns the number of words of text as split by Segment
+func WordCount(text string) int {
+	return len(tokenize(text))
+}
+
+// Width returns the display width of text, counting CJK and fullwidth characters as two columns
+func Width(text string) int {
+	width := 0
+	for _, r := range text {
+		if isWide(r) {
+			width += 2
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// Rechunk splits lines wider than maxWidth at word boundaries, dividing each line's time between
+// its chunks in proportion to their width. Split lines lose their syllables.
+func Rechunk(lines []Line, maxWidth int) []Line {
+	if maxWidth <= 0 {
+		return lines
+	}
+	chunked := make([]Line, 0, len(lines))
+	for _, line := range lines {
+		if Width(line.Words) <= maxWidth {
+			chunked = append(chunked, line)
+			continue
+		}
+		if len(line.WordTimings) > 0 {
+			chunked = append(chunked, splitTimedLine(line, maxWidth)...)
+			continue
+		}
+		chunked = append(chunked, splitLine(line, maxWidth)...)
+	}
+	return chunked
+}
+
+func splitLine(line Line, maxWidth int) []Line {
+	var chunks [][]token
+	var current []token
+	for _, t := range tokenize(line.Words) {
+		if len(current) > 0 && Width(joinTokens(append(current, t))) > maxWidth {
+			chunks = append(chunks, current)
+			current = nil
+		}
+		t.spaceBefore = t.spaceBefore && len(current) > 0
+		current = append(current, t)
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+
+	start := line.StartMs()
+	duration := line.EndMs() - start
+	if duration < 0 {
+		duration = 0
+	}
+	widths := make([]int64, len(chunks))
+	var total int64
+	for i, chunk := range chunks {
+		widths[i] = int64(Width(joinTokens(chunk)))
+		total += widths[i]
+	}
+
+	lines := make([]Line, 0, len(chunks))
+	var offset int64
+	for i, chunk := range chunks {
+		words := joinTokens(chunk)
+		chunkStart := start + duration*offset/total
+		offset += widths[i]
+		chunkEnd := start + duration*offset/total
+
+		chunkLine := Line{
+			StartTimeMs: strconv.FormatInt(chunkStart, 10),
+			DurationMs:  strconv.FormatInt(chunkEnd-chunkStart, 10),
+			Words:       words,
+			Syllables:   syllablesBetween(line.Syllables, chunkStart, chunkEnd),
+			EndTimeMs:   strconv.FormatInt(chunkEnd, 10),
+			Voice:       line.Voice,
+		}
+		// backing vocals can't be split with the words, they stay with the first part
+		if i == 0 {
+			chunkLine.BackingVocals = line.BackingVocals
+		}
+		lines = append(lines, chunkLine)
+	}
+	return lines
+}
@@ -0,0 +1,70 @@
+package lyrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSegment(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{"english", "Shape of you", []string{"Shape", "of", "you"}},
+		{"chinese", "我爱你。", []string{"我", "爱", "你。"}},
+		{"japanese with latin", "君の名はyes", []string{"君", "の", "名", "は", "yes"}},
+		{"katakana prolonged sound", "ラーメン", []string{"ラー", "メ", "ン"}},
+		{"korean uses spaces", "사랑해 너를", []string{"사랑해", "너를"}},
+		{"empty", "", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Segment(tt.text)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestWidth(t *testing.T) {
+	if got := Width("abc"); got != 3 {
+		t.Errorf("Expected width 3, got %d", got)
+	}
+	if got := Width("我爱你"); got != 6 {
+		t.Errorf("Expected width 6, got %d", got)
+	}
+	if got := Width("사랑 a"); got != 6 {
+		t.Errorf("Expected width 6, got %d", got)
+	}
+}
+
+func TestRechunk(t *testing.T) {
+	lines := []Line{
+		{StartTimeMs: "0", EndTimeMs: "1000", Words: "short"},
+		{StartTimeMs: "1000", EndTimeMs: "4000", Words: "我爱你，你爱我"},
+		{StartTimeMs: "4000", EndTimeMs: "6000", Words: "one two three four"},
+	}
+
+	got := Rechunk(lines, 8)
+
+	expected := []struct{ words, start, end string }{
+		{"short", "0", "1000"},
+		{"我爱你，", "1000", "2714"},
+		{"你爱我", "2714", "4000"},
+		{"one two", "4000", "4875"},
+		{"three", "4875", "5500"},
+		{"four", "5500", "6000"},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d lines, got %d: %+v", len(expected), len(got), got)
+	}
+	for i, want := range expected {
+		if got[i].Words != want.words || got[i].StartTimeMs != want.start || got[i].EndTimeMs != want.end {
+			t.Errorf("Line %d: expected %q %s-%s, got %q %s-%s",
+				i, want.words, want.start, want.end, got[i].Words, got[i].StartTimeMs, got[i].EndTimeMs)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package lyrics
+
+import (
+	"strconv"
+	"strings"
+)
+
+// IntroMarker is the words of the line inserted to cover an instrumental intro
+const IntroMarker = "♪"
+
+// minIntroGapMs is the shortest intro worth a marker line
+const minIntroGapMs = 2000
+
+// TrimSilence places lyrics around the instrumental parts of a track. Upstream sometimes starts the
+// first line at 0ms despite a long intro; when the intro ends (introEndMs) within that line, the line
+// is moved to the end of the intro and a marker line covers the intro. Lines running past the end of
+// the track (trackEndMs) are cut at it. Either bound may be 0 when unknown.
+func TrimSilence(lines []Line, introEndMs, trackEndMs int64) []Line {
+	if len(lines) == 0 {
+		return lines
+	}
+
+	trimmed := make([]Line, 0, len(lines)+1)
+	first := lines[0]
+	if first.StartMs() == 0 && introEndMs >= minIntroGapMs && first.EndMs() > introEndMs && !isFiller(strings.TrimSpace(first.Words)) {
+		trimmed = append(trimmed, Line{
+			StartTimeMs: "0",
+			DurationMs:  strconv.FormatInt(introEndMs, 10),
+			EndTimeMs:   strconv.FormatInt(introEndMs, 10),
+			Words:       IntroMarker,
+			Syllables:   []Syllable{},
+		})
+		first.StartTimeMs = strconv.FormatInt(introEndMs, 10)
+		first.DurationMs = strconv.FormatInt(first.EndMs()-introEndMs, 10)
+	}
+	trimmed = append(trimmed, first)
+	trimmed = append(trimmed, lines[1:]...)
+
+	if trackEndMs > 0 {
+		last := &trimmed[len(trimmed)-1]
+		if last.EndMs() > trackEndMs && last.StartMs() <= trackEndMs {
+			last.EndTimeMs = strconv.FormatInt(trackEndMs, 10)
+			last.DurationMs = strconv.FormatInt(trackEndMs-last.StartMs(), 10)
+		}
+	}
+	return trimmed
+}
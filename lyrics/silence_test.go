@@ -0,0 +1,79 @@
+package lyrics
+
+import "testing"
+
+func TestTrimSilence(t *testing.T) {
+	lines := []Line{
+		{StartTimeMs: "0", DurationMs: "20000", EndTimeMs: "20000", Words: "first"},
+		{StartTimeMs: "20000", DurationMs: "5000", EndTimeMs: "25000", Words: "last"},
+	}
+
+	tests := []struct {
+		name       string
+		lines      []Line
+		introEndMs int64
+		trackEndMs int64
+		expected   []Line
+	}{
+		{
+			name:       "intro detected",
+			lines:      lines,
+			introEndMs: 15000,
+			expected: []Line{
+				{StartTimeMs: "0", DurationMs: "15000", EndTimeMs: "15000", Words: IntroMarker},
+				{StartTimeMs: "15000", DurationMs: "5000", EndTimeMs: "20000", Words: "first"},
+				{StartTimeMs: "20000", DurationMs: "5000", EndTimeMs: "25000", Words: "last"},
+			},
+		},
+		{
+			name:       "short intro",
+			lines:      lines,
+			introEndMs: 1000,
+			expected:   lines,
+		},
+		{
+			name:       "intro past the first line",
+			lines:      lines,
+			introEndMs: 22000,
+			expected:   lines,
+		},
+		{
+			name: "first line after the start",
+			lines: []Line{
+				{StartTimeMs: "12000", DurationMs: "8000", EndTimeMs: "20000", Words: "first"},
+			},
+			introEndMs: 15000,
+			expected: []Line{
+				{StartTimeMs: "12000", DurationMs: "8000", EndTimeMs: "20000", Words: "first"},
+			},
+		},
+		{
+			name:       "last line past the end of the track",
+			lines:      lines,
+			trackEndMs: 23000,
+			expected: []Line{
+				{StartTimeMs: "0", DurationMs: "20000", EndTimeMs: "20000", Words: "first"},
+				{StartTimeMs: "20000", DurationMs: "3000", EndTimeMs: "23000", Words: "last"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := TrimSilence(tt.lines, tt.introEndMs, tt.trackEndMs)
+			if len(result) != len(tt.expected) {
+				t.Fatalf("Expected %d lines, got %d: %+v", len(tt.expected), len(result), result)
+			}
+			for i, want := range tt.expected {
+				got := result[i]
+				if got.StartTimeMs != want.StartTimeMs || got.DurationMs != want.DurationMs ||
+					got.EndTimeMs != want.EndTimeMs || got.Words != want.Words {
+					t.Errorf("Line %d: expected %+v, got %+v", i, want, got)
+				}
+			}
+			if lines[1].EndTimeMs != "25000" {
+				t.Errorf("Expected the original lines to be left unchanged, got end %s", lines[1].EndTimeMs)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package lyrics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FormatSRT renders lines as SubRip subtitles, one cue per non-empty line ending at the line's end time
+func FormatSRT(lines []Line) string {
+	var sb strings.Builder
+	cue := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line.Words) == "" {
+			continue
+		}
+		cue++
+		start, end := line.StartMs(), line.EndMs()
+		if end < start {
+			end = start
+		}
+		fmt.Fprintf(&sb, "%d\n%s --> %s\n%s\n\n", cue, srtTimestamp(start), srtTimestamp(end), line.Words)
+	}
+	return sb.String()
+}
+
+// srtTimestamp formats milliseconds as hh:mm:ss,mmm
+func srtTimestamp(ms int64) string {
+	if ms < 0 {
+		ms = 0
+	}
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", ms/3600000, (ms/60000)%60, (ms/1000)%60, ms%1000)
+}
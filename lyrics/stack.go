@@ -0,0 +1,23 @@
+package lyrics
+
+import "strings"
+
+// Stack adds rows of aligned text, such as translations, below the words of every line, one per
+// text line, so formats without per-line fields can carry them with the line's timing. rows are
+// either nil or have one entry per line; empty entries and entries equal to the words are left out.
+func Stack(lines []Line, rows ...[]string) []Line {
+	stacked := make([]Line, len(lines))
+	for i, line := range lines {
+		stacked[i] = line
+		if strings.TrimSpace(line.Words) == "" {
+			continue
+		}
+		for _, row := range rows {
+			if row == nil || row[i] == "" || row[i] == line.Words {
+				continue
+			}
+			stacked[i].Words += "\n" + row[i]
+		}
+	}
+	return stacked
+}
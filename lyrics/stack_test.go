@@ -0,0 +1,28 @@
+package lyrics
+
+import "testing"
+
+func TestStack(t *testing.T) {
+	lines := []Line{
+		{StartTimeMs: "1000", Words: "我爱你"},
+		{StartTimeMs: "2000", Words: ""},
+		{StartTimeMs: "3000", Words: "baby"},
+	}
+	translations := []string{"I love you", "", "baby"}
+	romanizations := []string{"wǒ ài nǐ", "", "baby"}
+
+	got := Stack(lines, translations, nil, romanizations)
+
+	expected := []string{"我爱你\nI love you\nwǒ ài nǐ", "", "baby"}
+	for i, want := range expected {
+		if got[i].Words != want {
+			t.Errorf("Line %d: expected %q, got %q", i, want, got[i].Words)
+		}
+		if got[i].StartTimeMs != lines[i].StartTimeMs {
+			t.Errorf("Line %d: expected timing to be kept, got %s", i, got[i].StartTimeMs)
+		}
+	}
+	if lines[0].Words != "我爱你" {
+		t.Errorf("Expected input lines to be unchanged, got %q", lines[0].Words)
+	}
+}
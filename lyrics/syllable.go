@@ -0,0 +1,172 @@
+package lyrics
+
+import (
+	"encoding/json"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Syllable is a timed syllable of a line, for providers with syllable-level sync. As with word
+// timing, the texts of a line's syllables joined together give its Words.
+type Syllable struct {
+	Text       string `json:"text"`
+	StartMs    int64  `json:"startMs"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// EndMs returns the end time of the syllable
+func (s Syllable) EndMs() int64 {
+	return s.StartMs + s.DurationMs
+}
+
+// UnmarshalJSON also accepts a bare string, the untimed placeholder syllables were stored as before
+// they carried timing
+func (s *Syllable) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*s = Syllable{Text: text}
+		return nil
+	}
+
+	type syllable Syllable
+	var decoded syllable
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*s = Syllable(decoded)
+	return nil
+}
+
+// timedParts returns the timed parts of a line, its words or else its syllables, for the formats
+// rendering them the same way
+func timedParts(line Line) []WordTiming {
+	if len(line.WordTimings) > 0 || len(line.Syllables) == 0 {
+		return line.WordTimings
+	}
+	return syllableParts(line.Syllables)
+}
+
+// syllableParts returns syllables as timed parts, so they share the helpers of word timing
+func syllableParts(syllables []Syllable) []WordTiming {
+	if syllables == nil {
+		return nil
+	}
+	parts := make([]WordTiming, len(syllables))
+	for i, syllable := range syllables {
+		parts[i] = WordTiming{Text: syllable.Text, StartMs: syllable.StartMs, EndMs: syllable.EndMs()}
+	}
+	return parts
+}
+
+// partSyllables turns the timed parts of syllableParts back into syllables
+func partSyllables(parts []WordTiming) []Syllable {
+	if parts == nil {
+		return nil
+	}
+	syllables := make([]Syllable, len(parts))
+	for i, part := range parts {
+		syllables[i] = Syllable{Text: part.Text, StartMs: part.StartMs, DurationMs: part.EndMs - part.StartMs}
+	}
+	return syllables
+}
+
+// mapSyllables returns a copy of syllables with fn applied to every start and end time
+func mapSyllables(syllables []Syllable, fn func(ms int64) int64) []Syllable {
+	return partSyllables(mapWordTimings(syllableParts(syllables), fn))
+}
+
+// timeParts completes the timing of the syllables and words of a line. Syllables given only a start
+// time last until the next one starts or the line ends, and lines with timed syllables but no word
+// timing get their words timed from them, split at spaces.
+func timeParts(line *Line) {
+	if !syllablesTimed(line.Syllables) {
+		return
+	}
+	for i := range line.Syllables {
+		if line.Syllables[i].DurationMs > 0 {
+			continue
+		}
+		end := line.EndMs()
+		if i+1 < len(line.Syllables) {
+			end = line.Syllables[i+1].StartMs
+		}
+		if end > line.Syllables[i].StartMs {
+			line.Syllables[i].DurationMs = end - line.Syllables[i].StartMs
+		}
+	}
+	if len(line.WordTimings) == 0 {
+		line.WordTimings = syllableWords(line.Syllables)
+	}
+}
+
+// syllablesTimed reports whether syllables carry timing, unlike the untimed syllables of entries
+// cached before they were timed
+func syllablesTimed(syllables []Syllable) bool {
+	for _, syllable := range syllables {
+		if syllable.StartMs > 0 || syllable.DurationMs > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// syllableWords joins syllables into the words they spell, a word ending with a syllable followed by
+// a space
+func syllableWords(syllables []Syllable) []WordTiming {
+	var words []WordTiming
+	for _, part := range syllableParts(syllables) {
+		if n := len(words); n > 0 && !endsWithSpace(words[n-1].Text) {
+			words[n-1].Text += part.Text
+			words[n-1].EndMs = max(words[n-1].EndMs, part.EndMs)
+			continue
+		}
+		words = append(words, part)
+	}
+	return words
+}
+
+func endsWithSpace(text string) bool {
+	r, _ := utf8.DecodeLastRuneInString(text)
+	return unicode.IsSpace(r)
+}
+
+// SplitSyllables splits words into the syllables of providers timing them by start time and number
+// of characters. The spaces following a syllable are kept with it and the characters left over go to
+// the last syllable, so the texts still join to words. Durations are set by ComputeTimings.
+func SplitSyllables(words string, starts []int64, lengths []int) []Syllable {
+	runes := []rune(words)
+	syllables := make([]Syllable, len(starts))
+	offset := 0
+	for i, start := range starts {
+		end := min(offset+max(lengths[i], 0), len(runes))
+		for end < len(runes) && unicode.IsSpace(runes[end]) {
+			end++
+		}
+		if i == len(starts)-1 {
+			end = len(runes)
+		}
+		syllables[i] = Syllable{Text: string(runes[offset:end]), StartMs: start}
+		offset = end
+	}
+	return syllables
+}
+
+// syllablesBetween returns the syllables starting within [start, end)
+func syllablesBetween(syllables []Syllable, start, end int64) []Syllable {
+	between := []Syllable{}
+	for _, syllable := range syllables {
+		if syllable.StartMs >= start && syllable.StartMs < end {
+			between = append(between, syllable)
+		}
+	}
+	return between
+}
+
+func hasSyllables(lines []Line) bool {
+	for _, line := range lines {
+		if len(line.Syllables) > 0 {
+			return true
+		}
+	}
+	return false
+}
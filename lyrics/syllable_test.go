@@ -0,0 +1,99 @@
+package lyrics
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSyllableUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []Syllable
+	}{
+		{"Empty", `[]`, []Syllable{}},
+		{"Legacy strings", `["Hel", "lo"]`, []Syllable{{Text: "Hel"}, {Text: "lo"}}},
+		{"Timed", `[{"text": "Hel", "startMs": 1000, "durationMs": 200}]`, []Syllable{{Text: "Hel", StartMs: 1000, DurationMs: 200}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Syllable
+			if err := json.Unmarshal([]byte(tt.input), &got); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestShiftSyllables(t *testing.T) {
+	lines := []Line{{StartTimeMs: "1000", EndTimeMs: "2000", Words: "Hello", Syllables: []Syllable{
+		{Text: "Hel", StartMs: 1000, DurationMs: 300},
+		{Text: "lo", StartMs: 1300, DurationMs: 500},
+	}}}
+
+	got := Shift(lines, -1200)[0].Syllables
+	expected := []Syllable{{Text: "Hel", StartMs: 0, DurationMs: 100}, {Text: "lo", StartMs: 100, DurationMs: 500}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestStripBackingVocalsSyllables(t *testing.T) {
+	lines := []Line{{StartTimeMs: "1000", EndTimeMs: "3000", Words: "Hello (hey)", Syllables: []Syllable{
+		{Text: "Hel", StartMs: 1000, DurationMs: 300},
+		{Text: "lo ", StartMs: 1300, DurationMs: 500},
+		{Text: "(hey)", StartMs: 2000, DurationMs: 500},
+	}}}
+
+	got := StripBackingVocals(lines, false)[0].Syllables
+	if len(got) != 2 || got[1].Text != "lo " {
+		t.Errorf("Expected the lead syllables only, got %+v", got)
+	}
+}
+
+func TestFormatELRCSyllables(t *testing.T) {
+	lines := []Line{{StartTimeMs: "1000", EndTimeMs: "2000", Words: "Hello", Syllables: []Syllable{
+		{Text: "Hel", StartMs: 1000, DurationMs: 300},
+		{Text: "lo", StartMs: 1300, DurationMs: 500},
+	}}}
+
+	expected := "[00:01.00] <00:01.00> Hel<00:01.30> lo <00:01.80>\n"
+	if got := FormatELRC(lines); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestSplitSyllables(t *testing.T) {
+	got := SplitSyllables("Hello world!", []int64{1000, 1300, 1600}, []int{3, 2, 5})
+	expected := []Syllable{{Text: "Hel", StartMs: 1000}, {Text: "lo ", StartMs: 1300}, {Text: "world!", StartMs: 1600}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestComputeTimingsTimesParts(t *testing.T) {
+	lines := []Line{
+		{StartTimeMs: "1000", Words: "Hello world", Syllables: []Syllable{
+			{Text: "Hel", StartMs: 1000}, {Text: "lo ", StartMs: 1300}, {Text: "world", StartMs: 1600},
+		}},
+		{StartTimeMs: "2500", Words: "Legacy", Syllables: []Syllable{{Text: "Le"}, {Text: "gacy"}}},
+	}
+	ComputeTimings(lines)
+
+	expectedSyllables := []Syllable{{Text: "Hel", StartMs: 1000, DurationMs: 300}, {Text: "lo ", StartMs: 1300, DurationMs: 300}, {Text: "world", StartMs: 1600, DurationMs: 900}}
+	if !reflect.DeepEqual(lines[0].Syllables, expectedSyllables) {
+		t.Errorf("Expected %+v, got %+v", expectedSyllables, lines[0].Syllables)
+	}
+	expectedWords := []WordTiming{{Text: "Hello ", StartMs: 1000, EndMs: 1600}, {Text: "world", StartMs: 1600, EndMs: 2500}}
+	if !reflect.DeepEqual(lines[0].WordTimings, expectedWords) {
+		t.Errorf("Expected %+v, got %+v", expectedWords, lines[0].WordTimings)
+	}
+	if lines[1].WordTimings != nil || lines[1].Syllables[1].DurationMs != 0 {
+		t.Errorf("Expected untimed syllables to be left alone, got %+v", lines[1])
+	}
+}
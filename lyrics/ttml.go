@@ -0,0 +1,98 @@
+package lyrics
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// FormatTTML renders lines as a timed-text (TTML) document with one paragraph per line, and a span
+// per word or syllable for lines with word or syllable timing, in the shape Apple-style karaoke renderers expect.
+// The voice parts of duet lines are set as their agent.
+func FormatTTML(lines []Line, language string) string {
+	var sb strings.Builder
+	sb.WriteString(xml.Header)
+	timing := "Line"
+	if HasWordTimings(lines) {
+		timing = "Word"
+	} else if hasSyllables(lines) {
+		timing = "Syllable"
+	}
+	fmt.Fprintf(&sb, `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata" xmlns:itunes="http://music.apple.com/lyric-ttml-internal" itunes:timing="%s"`, timing)
+	if language != "" {
+		fmt.Fprintf(&sb, ` xml:lang="%s"`, escapeXML(language))
+	}
+	sb.WriteString(">")
+	writeTTMLAgents(&sb, lines)
+
+	var end int64
+	if len(lines) > 0 {
+		end = lines[len(lines)-1].EndMs()
+	}
+	fmt.Fprintf(&sb, `<body dur="%s"><div>`, ttmlTimestamp(end))
+	for _, line := range lines {
+		start, lineEnd := line.StartMs(), line.EndMs()
+		if lineEnd < start {
+			lineEnd = start
+		}
+		fmt.Fprintf(&sb, `<p begin="%s" end="%s"`, ttmlTimestamp(start), ttmlTimestamp(lineEnd))
+		if line.Voice != "" {
+			fmt.Fprintf(&sb, ` ttm:agent="%s"`, escapeXML(line.Voice))
+		}
+		sb.WriteString(">")
+		if parts := timedParts(line); len(parts) > 0 {
+			writeTTMLWords(&sb, parts)
+		} else {
+			sb.WriteString(escapeXML(line.Words))
+		}
+		sb.WriteString("</p>")
+	}
+	sb.WriteString("</div></body></tt>\n")
+	return sb.String()
+}
+
+// writeTTMLAgents declares an agent per voice part in the document head, which paragraphs of duet
+// lines refer to
+func writeTTMLAgents(sb *strings.Builder, lines []Line) {
+	if !HasVoices(lines) {
+		return
+	}
+	sb.WriteString("<head><metadata>")
+	declared := map[string]bool{}
+	for _, line := range lines {
+		if line.Voice == "" || declared[line.Voice] {
+			continue
+		}
+		declared[line.Voice] = true
+		agentType := "person"
+		if line.Voice == VoiceDuet {
+			agentType = "group"
+		}
+		fmt.Fprintf(sb, `<ttm:agent type="%s" xml:id="%s"/>`, agentType, escapeXML(line.Voice))
+	}
+	sb.WriteString("</metadata></head>")
+}
+
+// writeTTMLWords writes a span per word or syllable, keeping the spaces between words outside the spans
+func writeTTMLWords(sb *strings.Builder, words []WordTiming) {
+	for _, word := range words {
+		text := strings.TrimRightFunc(word.Text, unicode.IsSpace)
+		fmt.Fprintf(sb, `<span begin="%s" end="%s">%s</span>`, ttmlTimestamp(word.StartMs), ttmlTimestamp(word.EndMs), escapeXML(text))
+		sb.WriteString(word.Text[len(text):])
+	}
+}
+
+// ttmlTimestamp formats milliseconds as a TTML clock time hh:mm:ss.mmm
+func ttmlTimestamp(ms int64) string {
+	if ms < 0 {
+		ms = 0
+	}
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, (ms/60000)%60, (ms/1000)%60, ms%1000)
+}
+
+func escapeXML(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
@@ -0,0 +1,53 @@
+package lyrics
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestFormatTTML(t *testing.T) {
+	lines := []Line{
+		{StartTimeMs: "1230", EndTimeMs: "4000", Words: "Rock & roll"},
+		{StartTimeMs: "4000", EndTimeMs: "61500", Words: "<again>"},
+	}
+
+	got := FormatTTML(lines, "en")
+
+	if !strings.Contains(got, `<p begin="00:00:01.230" end="00:00:04.000">Rock &amp; roll</p>`) {
+		t.Errorf("Expected escaped first paragraph, got %s", got)
+	}
+	if !strings.Contains(got, `<body dur="00:01:01.500">`) {
+		t.Errorf("Expected body duration of last line end, got %s", got)
+	}
+	if !strings.Contains(got, `xml:lang="en"`) {
+		t.Errorf("Expected language attribute, got %s", got)
+	}
+	if err := xml.Unmarshal([]byte(got), new(interface{})); err != nil {
+		t.Errorf("Expected well-formed XML, got error: %v", err)
+	}
+}
+
+func TestFormatTTMLWordTimings(t *testing.T) {
+	lines := []Line{
+		{StartTimeMs: "1000", EndTimeMs: "2000", Words: "Rock & roll", WordTimings: []WordTiming{
+			{Text: "Rock ", StartMs: 1000, EndMs: 1300},
+			{Text: "& ", StartMs: 1300, EndMs: 1500},
+			{Text: "roll", StartMs: 1500, EndMs: 2000},
+		}},
+	}
+
+	got := FormatTTML(lines, "en")
+
+	expected := `<p begin="00:00:01.000" end="00:00:02.000"><span begin="00:00:01.000" end="00:00:01.300">Rock</span> ` +
+		`<span begin="00:00:01.300" end="00:00:01.500">&amp;</span> <span begin="00:00:01.500" end="00:00:02.000">roll</span></p>`
+	if !strings.Contains(got, expected) {
+		t.Errorf("Expected word spans %s, got %s", expected, got)
+	}
+	if !strings.Contains(got, `itunes:timing="Word"`) {
+		t.Errorf("Expected word timing attribute, got %s", got)
+	}
+	if err := xml.Unmarshal([]byte(got), new(interface{})); err != nil {
+		t.Errorf("Expected well-formed XML, got error: %v", err)
+	}
+}
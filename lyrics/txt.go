@@ -0,0 +1,13 @@
+package lyrics
+
+import "strings"
+
+// FormatText renders the words of every line joined by newlines, without any timing
+func FormatText(lines []Line) string {
+	var sb strings.Builder
+	for _, line := range lines {
+		sb.WriteString(line.Words)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
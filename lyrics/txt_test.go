@@ -0,0 +1,16 @@
+package lyrics
+
+import "testing"
+
+func TestFormatText(t *testing.T) {
+	lines := []Line{
+		{StartTimeMs: "1000", Words: "Hello"},
+		{StartTimeMs: "2000", Words: ""},
+		{StartTimeMs: "3000", Words: "World"},
+	}
+
+	expected := "Hello\n\nWorld\n"
+	if got := FormatText(lines); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,101 @@
+package lyrics
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Voice parts of duet lines, as marked in enhanced LRC
+const (
+	VoiceMale   = "m"
+	VoiceFemale = "f"
+	// VoiceDuet lines are sung by all voices together
+	VoiceDuet = "d"
+)
+
+// voiceMarker matches a voice part prefix such as "v1:", "v2:", "M:" or "F:". The single letter markers
+// are only matched in upper case, so lyrics starting with e.g. "m: " stay untouched.
+var voiceMarker = regexp.MustCompile(`^\s*([vV][1-9][0-9]?|[MFD])\s*:\s*`)
+
+// ParseVoices strips voice part markers from the start of the lines and records them in Voice,
+// normalized to v1, v2, ... or VoiceMale, VoiceFemale and VoiceDuet. A marker applies to the
+// following unmarked lines too, as duet LRC files usually only mark where the singer changes.
+// Lines are returned unchanged when none is marked.
+func ParseVoices(lines []Line) []Line {
+	parsed := make([]Line, len(lines))
+	voice, found := "", false
+	for i, line := range lines {
+		parsed[i] = line
+		if line.Voice != "" {
+			voice, found = line.Voice, true
+			continue
+		}
+		match := voiceMarker.FindStringSubmatch(line.Words)
+		if match != nil {
+			voice, found = strings.ToLower(match[1]), true
+			parsed[i].Words = line.Words[len(match[0]):]
+			parsed[i].WordTimings = trimWordTimings(line.WordTimings, len(match[0]))
+			parsed[i].Syllables = trimSyllables(line.Syllables, len(match[0]))
+		}
+		parsed[i].Voice = voice
+	}
+	if !found {
+		return lines
+	}
+	return parsed
+}
+
+// HasVoices reports whether any line has a voice part
+func HasVoices(lines []Line) bool {
+	for _, line := range lines {
+		if line.Voice != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// trimWordTimings drops the first n bytes of text from words, whose texts concatenate to the line
+func trimWordTimings(words []WordTiming, n int) []WordTiming {
+	if words == nil {
+		return nil
+	}
+	trimmed := make([]WordTiming, 0, len(words))
+	for _, word := range words {
+		if n >= len(word.Text) {
+			n -= len(word.Text)
+			continue
+		}
+		word.Text = word.Text[n:]
+		n = 0
+		trimmed = append(trimmed, word)
+	}
+	return trimmed
+}
+
+// trimSyllables drops the first n bytes of text from syllables, whose texts concatenate to the line
+func trimSyllables(syllables []Syllable, n int) []Syllable {
+	if syllables == nil {
+		return nil
+	}
+	trimmed := make([]Syllable, 0, len(syllables))
+	for _, syllable := range syllables {
+		if n >= len(syllable.Text) {
+			n -= len(syllable.Text)
+			continue
+		}
+		syllable.Text = syllable.Text[n:]
+		n = 0
+		trimmed = append(trimmed, syllable)
+	}
+	return trimmed
+}
+
+// voiceLabel returns the marker a voice part is written with in LRC
+func voiceLabel(voice string) string {
+	switch voice {
+	case VoiceMale, VoiceFemale, VoiceDuet:
+		return strings.ToUpper(voice)
+	}
+	return voice
+}
@@ -0,0 +1,66 @@
+package lyrics
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseVoices(t *testing.T) {
+	lines := []Line{
+		{StartTimeMs: "0", Words: "Intro"},
+		{StartTimeMs: "1000", Words: "v1: Hello"},
+		{StartTimeMs: "2000", Words: "there"},
+		{StartTimeMs: "3000", Words: "F: Hi"},
+		{StartTimeMs: "4000", Words: "D:Together"},
+		{StartTimeMs: "5000", Words: "m: not a marker"},
+	}
+
+	parsed := ParseVoices(lines)
+	expectedWords := []string{"Intro", "Hello", "there", "Hi", "Together", "m: not a marker"}
+	expectedVoices := []string{"", "v1", "v1", VoiceFemale, VoiceDuet, VoiceDuet}
+	for i, line := range parsed {
+		if line.Words != expectedWords[i] || line.Voice != expectedVoices[i] {
+			t.Errorf("Line %d: expected %q by %q, got %q by %q", i, expectedWords[i], expectedVoices[i], line.Words, line.Voice)
+		}
+	}
+	if lines[1].Words != "v1: Hello" {
+		t.Errorf("Expected the input lines to be left unchanged, got %q", lines[1].Words)
+	}
+}
+
+func TestParseVoicesWordTimings(t *testing.T) {
+	lines := []Line{{StartTimeMs: "1000", Words: "V2: Hello world", WordTimings: []WordTiming{
+		{Text: "V2: ", StartMs: 1000, EndMs: 1000},
+		{Text: "Hello ", StartMs: 1000, EndMs: 1400},
+		{Text: "world", StartMs: 1500, EndMs: 1900},
+	}}}
+
+	parsed := ParseVoices(lines)[0]
+	expected := []WordTiming{{Text: "Hello ", StartMs: 1000, EndMs: 1400}, {Text: "world", StartMs: 1500, EndMs: 1900}}
+	if parsed.Voice != "v2" || !reflect.DeepEqual(parsed.WordTimings, expected) {
+		t.Errorf("Expected %+v by v2, got %+v by %q", expected, parsed.WordTimings, parsed.Voice)
+	}
+}
+
+func TestFormatVoices(t *testing.T) {
+	lines := []Line{
+		{StartTimeMs: "1000", EndTimeMs: "2000", Words: "Hello", Voice: VoiceMale},
+		{StartTimeMs: "2000", EndTimeMs: "3000", Words: "Hi", Voice: VoiceFemale},
+	}
+
+	expectedELRC := "[00:01.00] M: <00:01.00> Hello <00:02.00>\n[00:02.00] F: <00:02.00> Hi <00:03.00>\n"
+	if got := FormatELRC(lines); got != expectedELRC {
+		t.Errorf("Expected %q, got %q", expectedELRC, got)
+	}
+
+	ttml := FormatTTML(lines, "en")
+	for _, expected := range []string{
+		`<ttm:agent type="person" xml:id="m"/><ttm:agent type="person" xml:id="f"/>`,
+		`<p begin="00:00:01.000" end="00:00:02.000" ttm:agent="m">Hello</p>`,
+	} {
+		if !strings.Contains(ttml, expected) {
+			t.Errorf("Expected %q in %q", expected, ttml)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package lyrics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FormatVTT renders lines as WebVTT subtitles, one cue per non-empty line ending at the line's end
+// time, like FormatSRT. The voice part of duet lines is set as the cue's voice span.
+func FormatVTT(lines []Line) string {
+	var sb strings.Builder
+	sb.WriteString("WEBVTT\n\n")
+	for _, line := range lines {
+		if strings.TrimSpace(line.Words) == "" {
+			continue
+		}
+		start, end := line.StartMs(), line.EndMs()
+		if end < start {
+			end = start
+		}
+		words := line.Words
+		if line.Voice != "" {
+			words = fmt.Sprintf("<v %s>%s", line.Voice, words)
+		}
+		fmt.Fprintf(&sb, "%s --> %s\n%s\n\n", vttTimestamp(start), vttTimestamp(end), words)
+	}
+	return sb.String()
+}
+
+// vttTimestamp formats milliseconds as hh:mm:ss.mmm
+func vttTimestamp(ms int64) string {
+	return strings.Replace(srtTimestamp(ms), ",", ".", 1)
+}
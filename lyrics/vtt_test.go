@@ -0,0 +1,16 @@
+package lyrics
+
+import "testing"
+
+func TestFormatVTT(t *testing.T) {
+	lines := []Line{
+		{StartTimeMs: "1230", EndTimeMs: "3723456", Words: "Hello"},
+		{StartTimeMs: "3723456", EndTimeMs: "3723456", Words: ""},
+		{StartTimeMs: "3723456", EndTimeMs: "3725000", Words: "World", Voice: "v2"},
+	}
+
+	expected := "WEBVTT\n\n00:00:01.230 --> 01:02:03.456\nHello\n\n01:02:03.456 --> 01:02:05.000\n<v v2>World\n\n"
+	if got := FormatVTT(lines); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
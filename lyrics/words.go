@@ -0,0 +1,145 @@
+package lyrics
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SyncTypeWordSynced lyrics have per-word timing in WordTimings
+const SyncTypeWordSynced = "WORD_SYNCED"
+
+// WordTiming is the timing of a word of a line for karaoke-style highlighting. The texts of a line's
+// words joined together give its Words, so Text carries the space following the word, if any.
+type WordTiming struct {
+	Text    string `json:"text"`
+	StartMs int64  `json:"startMs"`
+	EndMs   int64  `json:"endMs"`
+}
+
+// HasWordTimings reports whether any line has per-word timing
+func HasWordTimings(lines []Line) bool {
+	for _, line := range lines {
+		if len(line.WordTimings) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// StripWordTimings drops the per-word and per-syllable timing of every line
+func StripWordTimings(lines []Line) []Line {
+	stripped := make([]Line, len(lines))
+	for i, line := range lines {
+		stripped[i] = line
+		stripped[i].WordTimings = nil
+		stripped[i].Syllables = []Syllable{}
+	}
+	return stripped
+}
+
+// mapWordTimings returns a copy of words, or any other timed parts, with fn applied to every start
+// and end time
+func mapWordTimings(words []WordTiming, fn func(ms int64) int64) []WordTiming {
+	if words == nil {
+		return nil
+	}
+	mapped := make([]WordTiming, len(words))
+	for i, word := range words {
+		mapped[i] = WordTiming{Text: word.Text, StartMs: fn(word.StartMs), EndMs: fn(word.EndMs)}
+		if mapped[i].EndMs < mapped[i].StartMs {
+			mapped[i].EndMs = mapped[i].StartMs
+		}
+	}
+	return mapped
+}
+
+// splitTimedLine breaks a line with word timing between words, so every part starts and ends with
+// the timing of its own words
+func splitTimedLine(line Line, maxWidth int) []Line {
+	var chunks [][]WordTiming
+	var current []WordTiming
+	var text string
+	for _, word := range line.WordTimings {
+		if len(current) > 0 && Width(strings.TrimSpace(text+word.Text)) > maxWidth {
+			chunks = append(chunks, current)
+			current, text = nil, ""
+		}
+		current = append(current, word)
+		text += word.Text
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+
+	lines := make([]Line, 0, len(chunks))
+	for i, chunk := range chunks {
+		start, end := chunk[0].StartMs, chunk[len(chunk)-1].EndMs
+		chunkLine := Line{
+			StartTimeMs: strconv.FormatInt(start, 10),
+			DurationMs:  strconv.FormatInt(end-start, 10),
+			Words:       strings.TrimSpace(wordsText(chunk)),
+			Syllables:   syllablesBetween(line.Syllables, start, end),
+			EndTimeMs:   strconv.FormatInt(end, 10),
+			Voice:       line.Voice,
+			WordTimings: chunk,
+		}
+		if i == 0 {
+			chunkLine.BackingVocals = line.BackingVocals
+		}
+		lines = append(lines, chunkLine)
+	}
+	return lines
+}
+
+func wordsText(words []WordTiming) string {
+	var sb strings.Builder
+	for _, word := range words {
+		sb.WriteString(word.Text)
+	}
+	return sb.String()
+}
+
+// stripBackingWords drops the words, or any other timed parts, inside parentheses, matching
+// SplitBackingVocals
+func stripBackingWords(words []WordTiming) []WordTiming {
+	if words == nil {
+		return nil
+	}
+	texts := make([]string, len(words))
+	for i, word := range words {
+		texts[i] = word.Text
+	}
+	lead := make([]WordTiming, 0, len(words))
+	for i, outside := range outsideParentheses(texts) {
+		if outside {
+			lead = append(lead, words[i])
+		}
+	}
+	return lead
+}
+
+// stripBackingSyllables drops the syllables inside parentheses, matching SplitBackingVocals
+func stripBackingSyllables(syllables []Syllable) []Syllable {
+	return partSyllables(stripBackingWords(syllableParts(syllables)))
+}
+
+// outsideParentheses reports for consecutive parts of a text whether each has anything outside
+// parentheses, which may open in one part and close in a later one
+func outsideParentheses(texts []string) []bool {
+	outside := make([]bool, len(texts))
+	depth := 0
+	for i, text := range texts {
+		for _, r := range text {
+			switch {
+			case r == '(' || r == '（':
+				depth++
+			case (r == ')' || r == '）') && depth > 0:
+				depth--
+			case depth == 0 && !unicode.IsSpace(r):
+				outside[i] = true
+			}
+		}
+	}
+	return outside
+}
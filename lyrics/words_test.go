@@ -0,0 +1,67 @@
+package lyrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+var timedLine = Line{
+	StartTimeMs: "1000",
+	DurationMs:  "3000",
+	EndTimeMs:   "4000",
+	Words:       "one two (ooh) three",
+	WordTimings: []WordTiming{
+		{Text: "one ", StartMs: 1000, EndMs: 1500},
+		{Text: "two ", StartMs: 1500, EndMs: 2000},
+		{Text: "(ooh) ", StartMs: 2000, EndMs: 3000},
+		{Text: "three", StartMs: 3000, EndMs: 4000},
+	},
+}
+
+func TestRechunkWordTimings(t *testing.T) {
+	chunks := Rechunk([]Line{timedLine}, 11)
+
+	expected := []struct{ start, end, words string }{
+		{"1000", "2000", "one two"},
+		{"2000", "4000", "(ooh) three"},
+	}
+	if len(chunks) != len(expected) {
+		t.Fatalf("Expected %d chunks, got %d: %+v", len(expected), len(chunks), chunks)
+	}
+	for i, want := range expected {
+		got := chunks[i]
+		if got.StartTimeMs != want.start || got.EndTimeMs != want.end || got.Words != want.words {
+			t.Errorf("Chunk %d: expected %s-%s %q, got %s-%s %q", i, want.start, want.end, want.words, got.StartTimeMs, got.EndTimeMs, got.Words)
+		}
+		if len(got.WordTimings) != 2 {
+			t.Errorf("Chunk %d: expected 2 timed words, got %+v", i, got.WordTimings)
+		}
+	}
+}
+
+func TestStripBackingVocalsWordTimings(t *testing.T) {
+	stripped := StripBackingVocals([]Line{timedLine}, false)
+
+	var texts []string
+	for _, word := range stripped[0].WordTimings {
+		texts = append(texts, word.Text)
+	}
+	if expected := []string{"one ", "two ", "three"}; !reflect.DeepEqual(texts, expected) {
+		t.Errorf("Expected %q, got %q", expected, texts)
+	}
+}
+
+func TestShiftAndScaleWordTimings(t *testing.T) {
+	shifted := Shift([]Line{timedLine}, -1200)
+	if got := shifted[0].WordTimings[0]; got.StartMs != 0 || got.EndMs != 300 {
+		t.Errorf("Expected first word shifted to 0-300, got %d-%d", got.StartMs, got.EndMs)
+	}
+
+	scaled := Scale([]Line{timedLine}, 2)
+	if got := scaled[0].WordTimings[3]; got.StartMs != 1500 || got.EndMs != 2000 {
+		t.Errorf("Expected last word scaled to 1500-2000, got %d-%d", got.StartMs, got.EndMs)
+	}
+	if timedLine.WordTimings[0].StartMs != 1000 {
+		t.Errorf("Expected the original word timings to be left unchanged, got %d", timedLine.WordTimings[0].StartMs)
+	}
+}
@@ -1,19 +1,29 @@
 package main
 
 import (
-	"encoding/base64"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"lyrics-api-go/config"
+	"lyrics-api-go/internal/analysis"
+	"lyrics-api-go/internal/apikeys"
+	"lyrics-api-go/internal/cache"
+	"lyrics-api-go/internal/rollout"
+	"lyrics-api-go/internal/upstream"
+	"lyrics-api-go/internal/usage"
+	"lyrics-api-go/lyrics"
 	"lyrics-api-go/middleware"
 	"lyrics-api-go/utils"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 
 	"golang.org/x/time/rate"
@@ -27,73 +37,57 @@ import (
 var conf = config.Get()
 
 var (
-	LyricsURL          = conf.Configuration.LyricsUrl
-	TrackURL           = conf.Configuration.TrackUrl
-	TokenURL           = conf.Configuration.TokenUrl
-	TokenKey           = conf.Configuration.TokenKey
-	AppPlatform        = conf.Configuration.AppPlatform
-	UserAgent          = conf.Configuration.UserAgent
-	CookieStringFormat = conf.Configuration.CookieStringFormat
-	CookieValue        = conf.Configuration.CookieValue
-	ClientID           = conf.Configuration.ClientID
-	ClientSecret       = conf.Configuration.ClientSecret
-	OauthTokenUrl      = conf.Configuration.OauthTokenUrl
-	OauthTokenKey      = conf.Configuration.OauthTokenKey
+	cacheStore = cache.New()
+	// trackLocks serialize operations that read, refresh or purge the cache entries of a track
+	trackLocks = cache.NewKeyLocks()
+	// upstreamFlights deduplicates concurrent upstream searches and lyrics fetches, by cache key
+	upstreamFlights = cache.NewFlights()
+	httpClient      *http.Client
+
+	// responseHeaders are the operator-defined headers added to every response
+	responseHeaders http.Header
+	// corsOrigins are the origins allowed by CORS_ALLOWED_ORIGINS
+	corsOrigins *middleware.OriginMatcher
+	// ipFilter holds the client ranges of IP_ALLOW_LIST and IP_DENY_LIST
+	ipFilter *middleware.IPFilter
+	// originLimiters are the rate limiters of the origin classes of ORIGIN_RATE_LIMITS
+	originLimiters *middleware.OriginLimiters
+
+	// idempotencyStore is shared by the public and admin listeners
+	idempotencyStore = middleware.NewIdempotencyStore(time.Duration(conf.Configuration.IdempotencyTTLInSeconds)*time.Second,
+		conf.Configuration.IdempotencyMaxEntries, conf.Configuration.IdempotencyMaxResponseBytes)
 )
 
-var (
-	cache      sync.Map
-	httpClient *http.Client
-)
-
-type TokenData struct {
-	AccessToken                      string `json:"accessToken"`
-	AccessTokenExpirationTimestampMs int64  `json:"accessTokenExpirationTimestampMs"`
-}
-
-type Line struct {
-	StartTimeMs string   `json:"startTimeMs"`
-	DurationMs  string   `json:"durationMs"`
-	Words       string   `json:"words"`
-	Syllables   []string `json:"syllables"`
-	EndTimeMs   string   `json:"endTimeMs"`
+// CachedLyrics is the value stored under lyrics: cache keys
+type CachedLyrics struct {
+	Lyrics        []lyrics.Line `json:"lyrics"`
+	IsRtlLanguage bool          `json:"isRtlLanguage"`
+	Language      string        `json:"language"`
+	TimingVersion int           `json:"timingVersion"`
+	SyncType      string        `json:"syncType,omitempty"`
+	// UnsyncedLyrics holds plain lyrics, one line per row, when no provider has synced lines
+	UnsyncedLyrics string `json:"unsyncedLyrics,omitempty"`
+	// Provider is the name of the provider the lyrics came from
+	Provider string `json:"provider,omitempty"`
+	// FetchedAt is when the lyrics were fetched from the provider, in Unix seconds
+	FetchedAt int64 `json:"fetchedAt,omitempty"`
+	// StaleAt is when the lyrics outlive their TTL, in Unix seconds. Their entry is kept for
+	// STALE_LYRICS_TTL_IN_SECONDS longer, to be served only while every provider is failing.
+	StaleAt int64 `json:"staleAt,omitempty"`
+	// FromCache is set on lyrics read from the cache rather than fetched for the request, it is never cached
+	FromCache bool `json:"-"`
+	// Stale is set on lyrics served past their TTL because every provider failed, it is never cached
+	Stale bool `json:"-"`
 }
 
-type LyricsResponse struct {
-	Lyrics struct {
-		SyncType      string `json:"syncType"`
-		Lines         []Line `json:"lines"`
-		IsRtlLanguage bool   `json:"isRtlLanguage"`
-		Language      string `json:"language"`
-	} `json:"lyrics"`
-}
+// lyricsTimingVersion is bumped whenever lyrics.ComputeTimings changes, so cached entries computed
+// under older logic can be found and backfilled
+const lyricsTimingVersion = 3
 
-type TrackResponse struct {
-	Tracks struct {
-		Items []struct {
-			ID string `json:"id"`
-		} `json:"items"`
-	} `json:"tracks"`
-}
+// shutdownTimeout is how long requests in flight are given to complete on shutdown
+const shutdownTimeout = 10 * time.Second
 
-type CacheEntry struct {
-	Value      string
-	Expiration int64
-}
-
-type CacheDump map[string]CacheEntry
-
-type CacheDumpResponse struct {
-	NumberOfKeys int
-	SizeInKB     int
-	Cache        CacheDump
-}
-
-type TokenResponse struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int    `json:"expires_in"`
-}
+type CacheDumpResponse = cache.Dump
 
 func init() {
 
@@ -105,22 +99,140 @@ func init() {
 		log.Warn("Error loading .env file, using environment variables")
 	}
 
+	upstreamTransport = upstream.NewTransport(http.DefaultTransport, upstream.Config{
+		MaxConcurrent: conf.Configuration.UpstreamMaxConcurrencyPerHost,
+		MaxQueued:     conf.Configuration.UpstreamMaxQueuedPerHost,
+		Hosts:         conf.Configuration.UpstreamHostConcurrency,
+	})
 	httpClient = &http.Client{
-		Timeout: 10 * time.Second,
+		Timeout:   10 * time.Second,
+		Transport: usageTransport{next: upstreamTransport},
 	}
+	initProviders()
 }
 
 func main() {
-	// start goroutine to invalidate cache
-	go invalidateCache()
+	preload := flag.String("preload", "", "path of a cache dump (from GET /cache) to load before serving")
+	warmup := flag.String("warmup", "", "path of a JSON list of tracks (like POST /admin/warmup) to fetch into the cache after starting")
+	flag.Parse()
+
+	if err := loadLyricsTTLPolicy(); err != nil {
+		log.Fatalf("Unable to parse LYRICS_TTL_POLICIES: %v", err)
+	}
+	if err := loadCacheCodec(); err != nil {
+		log.Fatalf("Unable to use CACHE_CODEC: %v", err)
+	}
+	if err := setupTokenEncryption(); err != nil {
+		log.Fatalf("Unable to use CACHE_ENCRYPTION_KEY: %v", err)
+	}
+
+	if path := conf.Configuration.CacheFile; path != "" {
+		store, err := cache.Open(path, unsealedTokenKey)
+		if err != nil {
+			log.Fatalf("Unable to open the cache file %s: %v", path, err)
+		}
+		cacheStore = store
+		log.Infof("[Cache:Disk] Persisting the cache to %s", path)
+	}
+	cacheStore.OnExpire(func(key string) {
+		_, unversioned := cache.ParseStorageKey(key)
+		cacheStats.evict(unversioned)
+	})
+	setupTieredCache()
+
+	if conf.Configuration.SnapshotRestoreOnStartup {
+		// like a bad dump, a bad snapshot only costs a cold start
+		if err := restoreCacheSnapshot(context.Background()); err != nil {
+			log.Errorf("[Cache:Snapshot] Unable to restore the cache snapshot, starting cold: %v", err)
+		}
+	}
+
+	if *preload != "" {
+		// a bad dump only costs a cold start, so it never keeps the server from starting
+		if err := preloadCache(*preload); err != nil {
+			log.Errorf("[Cache:Preload] Unable to preload cache from %s, starting cold: %v", *preload, err)
+		}
+	}
+
+	if conf.Configuration.LongPollRecheckIntervalInSeconds < minLongPollRecheckIntervalInSeconds {
+		log.Fatalf("LONG_POLL_RECHECK_INTERVAL_IN_SECONDS must be at least %d", minLongPollRecheckIntervalInSeconds)
+	}
+	var err error
+	if responseHeaders, err = middleware.ParseHeaders(conf.Configuration.ResponseHeaders); err != nil {
+		log.Fatalf("Unable to parse RESPONSE_HEADERS: %v", err)
+	}
+	if corsOrigins, err = middleware.ParseOrigins(conf.Configuration.CorsAllowedOrigins); err != nil {
+		log.Fatalf("Unable to parse CORS_ALLOWED_ORIGINS: %v", err)
+	}
+	if corsOrigins.AnyExtension() {
+		log.Warn("CORS_ALLOWED_ORIGINS allows any extension of a browser to make credentialed requests; list the IDs of your extensions instead")
+	}
+	if ipFilter, err = middleware.ParseIPFilter(conf.Configuration.IPAllowList, conf.Configuration.IPDenyList); err != nil {
+		log.Fatalf("Unable to parse IP_ALLOW_LIST or IP_DENY_LIST: %v", err)
+	}
+	setupRateLimitRedis()
+	if originLimiters, err = middleware.ParseOriginLimits(conf.Configuration.OriginRateLimits, newOriginRateLimiter); err != nil {
+		log.Fatalf("Unable to parse ORIGIN_RATE_LIMITS: %v", err)
+	}
+	if apiKeys, anonymousScopes, err = parseAPIKeys(conf.Configuration.APIKeys, conf.Configuration.AnonymousScopes); err != nil {
+		log.Fatalf("Unable to parse API keys: %v", err)
+	}
+	loadAPIKeys(conf.Configuration.APIKeysFile)
+	setupAPIKeyLimits()
+	if err := setupJWT(); err != nil {
+		log.Fatalf("Unable to set up JWT authentication: %v", err)
+	}
+	idempotencyStore.Subject = callerSubject
+	setupUsage()
+	if err := setupSecrets(); err != nil {
+		log.Fatalf("Unable to read secrets: %v", err)
+	}
+	setupAdminAuth()
+	setupAbuse()
+	setupExtensionSignatures()
+	if err := setupCaptcha(); err != nil {
+		log.Fatalf("Unable to set up captcha challenges: %v", err)
+	}
+
+	rollouts = rollout.New(conf.Configuration.RolloutPercentages, conf.Configuration.RolloutMinClientVersions)
+	providerChain.load(conf.Configuration.ProvidersStateFile)
+	loadCommunityOffsets(conf.Configuration.CommunityOffsetsFile)
+	setupPopularity()
+
+	if err := registerJobs(); err != nil {
+		log.Fatalf("Unable to schedule background jobs: %v", err)
+	}
+	// jobs are stopped on SIGINT and SIGTERM, along with the server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	jobScheduler.Start(ctx)
+
+	if *warmup != "" {
+		if err := warmupFromFile(*warmup); err != nil {
+			log.Errorf("[Warmup] Unable to warm the cache from %s: %v", *warmup, err)
+		}
+	}
 
 	router := mux.NewRouter()
-	router.HandleFunc("/getLyrics", getLyrics)
-	router.HandleFunc("/cache", getCacheDump)
+	registerPublicRoutes(router)
+
+	// admin routes are served on a separate listener when one is configured, so the
+	// public port only exposes lyric lookups
+	if conf.Configuration.AdminAddr != "" {
+		adminRouter := mux.NewRouter()
+		registerAdminRoutes(adminRouter)
+		go serveAdmin(conf.Configuration.AdminAddr, adminRouter)
+	} else {
+		registerAdminRoutes(router)
+	}
+
+	// the announcement feed is polled by every extension version, so it is kept out of the versioned API
+	router.HandleFunc("/announcements", getAnnouncements).Methods("GET")
+
 	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"help": "Use /getLyrics to get the lyrics of a song. Provide the song name and artist name as query parameters. Example: /getLyrics?s=Shape%20of%20You&a=Ed%20Sheeran",
+			"help": "Use /v1/getLyrics to get the lyrics of a song. Provide the song name and artist name as query parameters. Example: /v1/getLyrics?s=Shape%20of%20You&a=Ed%20Sheeran",
 		})
 	})
 
@@ -130,396 +242,743 @@ func main() {
 	}
 
 	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"https://music.youtube.com", "http://localhost:3000"},
+		AllowOriginFunc:  corsOrigins.Allow,
 		AllowCredentials: true,
+		AllowedHeaders: []string{
+			"Origin", "Accept", "Content-Type", "X-Requested-With", "Authorization",
+			apiKeyHeader, clientVersionHeader, middleware.RequestIDHeader, middleware.TimeBudgetHeader, middleware.IdempotencyKeyHeader,
+			middleware.ExtensionTimestampHeader, middleware.ExtensionNonceHeader, middleware.ExtensionSignatureHeader, captchaTokenHeader,
+		},
+		ExposedHeaders: []string{
+			captchaProviderHeader, captchaSiteKeyHeader,
+			"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After",
+			"X-Cache", "X-Cache-Age",
+		},
 	})
 
-	limiter := middleware.NewIPRateLimiter(rate.Limit(conf.Configuration.RateLimitPerSecond), conf.Configuration.RateLimitBurstLimit)
+	limiter := newRateLimiter("ip", rate.Limit(conf.Configuration.RateLimitPerSecond), conf.Configuration.RateLimitBurstLimit)
 
 	// logging middleware
 
-	loggedRouter := middleware.LoggingMiddleware(router)
-	// chain cors middleware
-	corsHandler := c.Handler(loggedRouter)
+	loggedRouter := middleware.RequestIDMiddleware(middleware.LoggingMiddleware(middleware.TimeBudgetMiddleware(adminSignatureMiddleware(captchaMiddleware(middleware.IdempotencyMiddleware(router, idempotencyStore))))), conf.FeatureFlags.Tracing)
 
-	//chain rate limiter
-	handler := limitMiddleware(corsHandler, limiter)
+	//chain IP filter, cors, abuse bans and rate limiter; cors wraps the bans and limits so browsers can
+	//read their Retry-After and X-RateLimit headers
+	handler := middleware.HardeningMiddleware(middleware.HeadersMiddleware(middleware.IPFilterMiddleware(c.Handler(abuseMiddleware(extensionSignatureMiddleware(limitMiddleware(usageMiddleware(loggedRouter), limiter)))), ipFilter), responseHeaders), hardeningOptions())
 
 	log.Infof("Server listening on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, handler))
+	server := &http.Server{Addr: ":" + port, Handler: handler, MaxHeaderBytes: maxHeaderBytes()}
+	go func() {
+		<-ctx.Done()
+		log.Info("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Errorf("Unable to shut down gracefully: %v", err)
+		}
+	}()
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	// the jobs are stopping with ctx, their running steps are let to finish
+	jobScheduler.Wait()
+	if err := cacheStore.Close(); err != nil {
+		log.Errorf("[Cache:Disk] Error closing the cache file: %v", err)
+	}
+	if err := flushCommunityOffsets(conf.Configuration.CommunityOffsetsFile); err != nil {
+		log.Errorf("[Offsets] Error persisting submissions to %s: %v", conf.Configuration.CommunityOffsetsFile, err)
+	}
+}
 
+// hardeningOptions are the input limits of both listeners. Request bodies are JSON, except for the
+// form-encoded fingerprint lookups.
+func hardeningOptions() middleware.HardeningOptions {
+	return middleware.HardeningOptions{
+		MaxURLLength:   conf.Configuration.MaxURLLength,
+		MaxQueryLength: conf.Configuration.MaxQueryLength,
+		MaxHeaderBytes: conf.Configuration.MaxHeaderBytes,
+		MaxBodyBytes:   conf.Configuration.MaxRequestBodyBytes,
+		ContentTypes:   []string{"application/json", "application/x-www-form-urlencoded"},
+	}
 }
 
-func isRTLLanguage(langCode string) bool {
-	rtlLanguages := map[string]bool{
-		"ar": true, // Arabic
-		"fa": true, // Persian (Farsi)
-		"he": true, // Hebrew
-		"ur": true, // Urdu
-		"ps": true, // Pashto
-		"sd": true, // Sindhi
-		"ug": true, // Uyghur
-		"yi": true, // Yiddish
-		"ku": true, // Kurdish (some dialects)
-		"dv": true, // Divehi (Maldivian)
+// maxHeaderBytes is how much of the request line and headers both listeners read, so oversized
+// headers are cut off before being buffered. HardeningMiddleware enforces MAX_HEADER_BYTES exactly.
+func maxHeaderBytes() int {
+	if conf.Configuration.MaxHeaderBytes <= 0 {
+		return http.DefaultMaxHeaderBytes
 	}
-	return rtlLanguages[langCode]
+	return conf.Configuration.MaxHeaderBytes
 }
 
-func setCommonHeaders(req *http.Request) {
-	req.Header.Set("App-Platform", AppPlatform)
-	req.Header.Set("User-Agent", UserAgent)
-	req.Header.Set("cookie", fmt.Sprintf(CookieStringFormat, CookieValue))
+// registerAdminRoutes registers the cache, debug and job routes
+func registerAdminRoutes(router *mux.Router) {
+	router.HandleFunc("/cache", getCacheDump)
+	router.HandleFunc("/cache/flush", flushCache).Methods("POST")
+	router.HandleFunc("/cache/{key:.+}", deleteCacheKey).Methods("DELETE")
+	router.HandleFunc("/admin/audit", startCoverageAudit).Methods("POST")
+	router.HandleFunc("/admin/audit", getCoverageAudit).Methods("GET")
+	router.HandleFunc("/admin/backfill", startTimingBackfill).Methods("POST")
+	router.HandleFunc("/admin/backfill", getTimingBackfill).Methods("GET")
+	router.HandleFunc("/admin/warmup", startWarmup).Methods("POST")
+	router.HandleFunc("/admin/warmup", getWarmup).Methods("GET")
+	router.HandleFunc("/admin/snapshot", createCacheSnapshot).Methods("POST")
+	router.HandleFunc("/admin/support-bundle", getSupportBundle).Methods("GET")
+	router.HandleFunc("/admin/replayArchive", replayArchive).Methods("GET")
+	router.HandleFunc("/admin/providers", getProviders).Methods("GET")
+	router.HandleFunc("/admin/providers", updateProviders).Methods("PUT")
+	router.HandleFunc("/admin/jobs", getJobs).Methods("GET")
+	router.HandleFunc("/admin/jobs/{name}/run", runJob).Methods("POST")
+	router.HandleFunc("/admin/store/export", exportStore).Methods("GET")
+	router.HandleFunc("/admin/cdn/purge", purgeCDN).Methods("POST")
+	router.HandleFunc("/admin/keys", getAPIKeys).Methods("GET")
+	router.HandleFunc("/admin/keys", issueAPIKey).Methods("POST")
+	router.HandleFunc("/admin/keys/{id}", revokeAPIKey).Methods("DELETE")
+	router.HandleFunc("/admin/usage", getAllUsage).Methods("GET")
+	router.HandleFunc("/admin/bans", getBans).Methods("GET")
+	router.HandleFunc("/admin/bans/{client}", liftBan).Methods("DELETE")
+	router.HandleFunc("/admin/secrets/reload", reloadSecretsNow).Methods("POST")
+	router.HandleFunc("/stats/providers", getProviderStats).Methods("GET")
+	router.HandleFunc("/stats/pipeline", getPipelineStats).Methods("GET")
+	router.HandleFunc("/stats/cache", getCacheStats).Methods("GET")
+	router.HandleFunc("/top", getTopTracks).Methods("GET")
 }
 
-func makeHTTPRequest(method, url string, headers map[string]string) ([]byte, error) {
-	req, err := http.NewRequest(method, url, nil)
-	if err != nil {
-		return nil, err
+// serveAdmin serves the admin routes on addr, which is either a TCP address (":9090") or a
+// unix socket path prefixed with "unix:"
+func serveAdmin(addr string, router *mux.Router) {
+	network, address := "tcp", addr
+	if strings.HasPrefix(addr, "unix:") {
+		network, address = "unix", strings.TrimPrefix(addr, "unix:")
+		if err := removeStaleSocket(address); err != nil {
+			log.Fatalf("Unable to listen on admin address %s: %v", addr, err)
+		}
 	}
 
-	setCommonHeaders(req)
-	for key, value := range headers {
-		req.Header.Set(key, value)
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		log.Fatalf("Unable to listen on admin address %s: %v", addr, err)
 	}
 
-	resp, err := httpClient.Do(req)
+	handler := middleware.HardeningMiddleware(middleware.HeadersMiddleware(middleware.RequestIDMiddleware(middleware.LoggingMiddleware(adminSignatureMiddleware(middleware.IdempotencyMiddleware(router, idempotencyStore))), conf.FeatureFlags.Tracing), responseHeaders), hardeningOptions())
+
+	log.Infof("Admin server listening on %s", addr)
+	server := &http.Server{Handler: handler, MaxHeaderBytes: maxHeaderBytes()}
+	log.Fatal(server.Serve(listener))
+}
+
+// removeStaleSocket removes the socket at path left behind by a previous process. Anything else
+// at path, or a socket another process still listens on, is left alone and reported.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP request failed with status code %d", resp.StatusCode)
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
 	}
+	return os.Remove(path)
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+// setTracingHeaders forwards the request id and traceparent of the originating request to upstream
+// hosts where it is safe to do so, so provider-side logs can be correlated with our own
+func setTracingHeaders(req *http.Request) {
+	host := req.URL.Hostname()
+	allowed := false
+	for _, h := range conf.Configuration.RequestIDPropagationHosts {
+		if strings.EqualFold(h, host) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return
 	}
 
-	return body, nil
+	if requestID := middleware.RequestIDFromContext(req.Context()); requestID != "" {
+		req.Header.Set(middleware.RequestIDHeader, requestID)
+	}
+	if traceparent := middleware.TraceparentFromContext(req.Context()); traceparent != "" {
+		req.Header.Set(middleware.TraceparentHeader, traceparent)
+	}
 }
 
 func getCache(key string) (string, bool) {
-	entry, ok := cache.Load(key)
+	cacheEntry, ok := loadCacheEntry(key)
 	if !ok {
+		cacheStats.miss(key)
 		return "", false
 	}
-	cacheEntry := entry.(CacheEntry)
-	if time.Now().UnixNano() > cacheEntry.Expiration {
-		cache.Delete(key)
+	value, err := decodeCacheValue(cacheEntry.Value)
+	if err != nil {
+		log.Errorf("Error decompressing cache value: %v", err)
+		cacheStats.miss(key)
 		return "", false
 	}
-	if conf.FeatureFlags.CacheCompression {
-		// Decompress the value before returning
-		decompressedValue, err := utils.DecompressString(cacheEntry.Value)
-		if err != nil {
-			log.Errorf("Error decompressing cache value: %v", err)
-			return "", false
-		}
-		return decompressedValue, true
-	} else {
-		return cacheEntry.Value, true
-	}
+	cacheStats.hit(key)
+	return value, true
 }
 
-func setCache(key, value string, duration time.Duration) {
-	var cacheEntry CacheEntry
+// cacheCodec compresses cache values when FF_CACHE_COMPRESSION is on, set from CACHE_CODEC on startup
+var cacheCodec = utils.Gzip
 
-	if conf.FeatureFlags.CacheCompression {
-		compressedValue, err := utils.CompressString(value)
-		if err != nil {
-			log.Errorf("Error compressing cache value: %v", err)
-			return
-		}
-		cacheEntry = CacheEntry{
-			Value:      compressedValue,
-			Expiration: time.Now().Add(duration).UnixNano(),
-		}
-	} else {
-		cacheEntry = CacheEntry{
-			Value:      value,
-			Expiration: time.Now().Add(duration).UnixNano(),
+// loadCacheCodec sets the codec of cache values from CACHE_CODEC
+func loadCacheCodec() (err error) {
+	cacheCodec, err = utils.CodecByName(conf.Configuration.CacheCodec)
+	return err
+}
+
+// decodeCacheValue decompresses a cache value. Values are decompressed with the codec they were
+// written with, so switching CACHE_CODEC doesn't invalidate the cache. Values written as strings by
+// older versions (from a dump or Redis) are decoded the way they used to be.
+func decodeCacheValue(value []byte) (string, error) {
+	if !utils.IsTaggedValue(value) {
+		if conf.FeatureFlags.CacheCompression {
+			return utils.DecompressString(string(value))
 		}
+		return string(value), nil
 	}
-
-	cache.Store(key, cacheEntry)
+	decoded, err := utils.DecompressValue(value)
+	return string(decoded), err
 }
 
-func getOauthAccessToken(clientID, clientSecret string) (string, error) {
-
-	if token, ok := getCache(OauthTokenKey); ok {
-		log.Info("[Cache:OAuthToken] Using cached token")
-		return token, nil
+// encodeCacheValue compresses values of at least CACHE_COMPRESSION_MIN_BYTES, smaller ones like
+// tokens and track ids are stored as they are
+func encodeCacheValue(value string) ([]byte, error) {
+	var codec utils.Codec
+	if conf.FeatureFlags.CacheCompression {
+		codec = cacheCodec
 	}
+	return utils.CompressValue(codec, []byte(value), conf.Configuration.CacheCompressionMinBytes)
+}
 
-	auth := base64.StdEncoding.EncodeToString([]byte(clientID + ":" + clientSecret))
-
-	data := url.Values{}
-	data.Set("grant_type", "client_credentials")
-
-	req, err := http.NewRequest("POST", OauthTokenUrl,
-		strings.NewReader(data.Encode()))
+func setCache(key, value string, duration time.Duration) {
+	encodedValue, err := encodeCacheValue(value)
 	if err != nil {
-		return "", fmt.Errorf("error creating token request: %v", err)
+		log.Errorf("Error compressing cache value: %v", err)
+		return
 	}
 
-	req.Header.Set("Authorization", "Basic "+auth)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	storeCacheEntry(key, encodedValue, duration)
+	cacheStats.write(key)
+}
+
+// getCacheVersion returns the current version of a cache key, to be passed to setCacheIfUnchanged
+// once a value has been fetched
+func getCacheVersion(key string) uint64 {
+	return cacheStore.Version(storageKey(key))
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// setCacheIfUnchanged stores the value only if the key was not written or purged since version
+// was read, so a slow fetch can't clobber a newer entry or resurrect a purged one
+func setCacheIfUnchanged(key, value string, duration time.Duration, version uint64) bool {
+	encodedValue, err := encodeCacheValue(value)
 	if err != nil {
-		return "", fmt.Errorf("error making token request: %v", err)
+		log.Errorf("Error compressing cache value: %v", err)
+		return false
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("error reading token response: %v", err)
+	if !compareAndStoreCacheEntry(key, encodedValue, duration, version) {
+		log.Warnf("[Cache] Skipped writing %s, it was modified concurrently", key)
+		return false
 	}
+	cacheStats.write(key)
+	return true
+}
+
+// resolveTrackID searches for the track matching the given song and artist, using the track cache.
+// When MusicBrainz canonicalization is enabled the canonical title/artist are searched first.
+func resolveTrackID(ctx context.Context, songName, artistName string) (string, error) {
+	return resolveTrackIDWith(ctx, songName, artistName, searchTrackID)
+}
 
-	var tokenResp TokenResponse
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return "", fmt.Errorf("error parsing token response: %v", err)
+// resolveTrackIDWith resolves a song and artist to a track id using search for the provider lookup
+func resolveTrackIDWith(ctx context.Context, songName, artistName string, search func(ctx context.Context, songName, artistName string) (string, error)) (string, error) {
+	if conf.FeatureFlags.MusicBrainzCanonicalization && providerChain.enabled("musicbrainz") {
+		if canonical, ok := canonicalizeTrack(ctx, songName, artistName); ok &&
+			(canonical.Song != songName || canonical.Artist != artistName) {
+			log.Infof("[MusicBrainz] Canonicalized %q by %q to %q by %q", songName, artistName, canonical.Song, canonical.Artist)
+			trackID, err := search(ctx, canonical.Song, canonical.Artist)
+			if err == nil && trackID != "" {
+				return trackID, nil
+			}
+			if err != nil {
+				log.Warnf("[MusicBrainz] Search for the canonical track failed, falling back to the request: %v", err)
+			}
+		}
 	}
 
-	log.Warn("[Cache:OAuthToken] Caching token")
-	setCache(OauthTokenKey, tokenResp.AccessToken, time.Duration(tokenResp.ExpiresIn)*time.Second)
+	return search(ctx, songName, artistName)
+}
 
-	return tokenResp.AccessToken, nil
+// buildSearchQuery returns the escaped search query for a song and the artists credited on it.
+// Only the primary artist is part of the query.
+func buildSearchQuery(songName, artistName string) (string, []string) {
+	artists := utils.SplitArtists(artistName)
+	primaryArtist := ""
+	if len(artists) > 0 {
+		primaryArtist = artists[0]
+	}
+	return url.QueryEscape(songName + " " + primaryArtist), artists
 }
 
-func getValidAccessToken() (string, error) {
-	if token, ok := getCache(TokenKey); ok {
-		log.Info("[Cache:Token] Using cached token")
-		return token, nil
+// trackCacheKey returns the cache key of the track id resolved for a song and its artists. The
+// query is canonicalized, so "Beyoncé" and "beyonce " share one search and one entry.
+func trackCacheKey(songName string, artists []string) string {
+	return fmt.Sprintf("track:%s", url.QueryEscape(utils.CanonicalQuery(songName+" "+strings.Join(artists, ", "))))
+}
+
+// searchTrackID searches for the track matching the given song and artist, using the track cache.
+// For collaborations only the primary artist is searched and the rest are used to re-rank candidates.
+func searchTrackID(ctx context.Context, songName, artistName string) (string, error) {
+	query, artists := buildSearchQuery(songName, artistName)
+	cacheKey := trackCacheKey(songName, artists)
+	if cachedTrackID, ok := getCache(cacheKey); ok {
+		log.Infof("[Cache:Track] Found cached track id: %s", cachedTrackID)
+		return cachedTrackID, nil
 	}
 
-	body, err := makeHTTPRequest("GET", TokenURL, nil)
+	// concurrent searches for the same new song share one upstream search
+	trackID, shared, err := upstreamFlights.Do(ctx, cacheKey, func(ctx context.Context) (interface{}, error) {
+		version := getCacheVersion(cacheKey)
+		match, err := fetchTrackID(ctx, query, artists)
+		if err != nil {
+			return "", err
+		}
+		if match.ID != "" {
+			log.Warnf("[Cache:Track] Caching track id: %s", match.ID)
+			setCacheIfUnchanged(cacheKey, match.ID, requestCacheTTL(ctx, time.Duration(conf.Configuration.TrackCacheTTLInSeconds)*time.Second), version)
+			rememberReleaseDate(match.ID, match.ReleaseDate)
+		}
+		return match.ID, nil
+	})
 	if err != nil {
 		return "", err
 	}
-
-	var tokenData TokenData
-	if err := json.Unmarshal(body, &tokenData); err != nil {
-		return "", err
+	if shared {
+		log.Infof("[Cache:Track] Joined the search in flight for %s", cacheKey)
 	}
-
-	expiresInSeconds := int64((tokenData.AccessTokenExpirationTimestampMs - time.Now().UnixNano()/int64(time.Millisecond)) / 1000)
-	setCache(TokenKey, tokenData.AccessToken, time.Duration(expiresInSeconds)*time.Second)
-
-	return tokenData.AccessToken, nil
+	return trackID.(string), nil
 }
 
 func getLyrics(w http.ResponseWriter, r *http.Request) {
-	songName := r.URL.Query().Get("s") + r.URL.Query().Get("song") + r.URL.Query().Get("songName")
-	artistName := r.URL.Query().Get("a") + r.URL.Query().Get("artist") + r.URL.Query().Get("artistName")
-	customTrackID := r.URL.Query().Get("t_id") + r.URL.Query().Get("trackId")
-
-	if (songName == "" && artistName == "") && customTrackID == "" {
-		http.Error(w, "Song name or artist name not provided", http.StatusUnprocessableEntity)
+	r, err := withRequestCacheTTL(r)
+	if err != nil {
+		writeStageError(w, stageResolve, err)
 		return
 	}
 
-	accessToken, err := getValidAccessToken()
+	var resolved resolvedTrack
+	err = runStage(r.Context(), stageResolve, func(ctx context.Context) error {
+		var err error
+		resolved, err = resolveRequestTrack(ctx, r)
+		return err
+	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStageError(w, stageResolve, err)
 		return
 	}
 
-	var trackID string
-	if customTrackID != "" {
-		trackID = customTrackID
-	} else {
-		query := url.QueryEscape(songName + " " + artistName)
-		cacheKey := fmt.Sprintf("track:%s", query)
-		if cachedTrackID, ok := getCache(cacheKey); ok {
-			log.Infof("[Cache:Track] Found cached track id: %s", cachedTrackID)
-			trackID = cachedTrackID
-		} else {
-			trackID, err = fetchTrackID(query, ClientID, ClientSecret)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			if trackID != "" {
-				log.Warnf("[Cache:Track] Caching track id: %s", trackID)
-				setCache(cacheKey, trackID, time.Duration(conf.Configuration.TrackCacheTTLInSeconds)*time.Second)
-			} else {
-				http.Error(w, "Track not found", http.StatusNotFound)
-				return
-			}
-		}
+	expectLanguage := r.URL.Query().Get("expectLanguage")
+	if expectLanguage != "" && !resolved.custom && withinBudget(r.Context(), featureLanguageMatch) {
+		serveLyricsInLanguage(w, r, resolved.trackID, resolved.songName, resolved.artistName, expectLanguage)
+		return
 	}
 
-	lyricsURL := LyricsURL + trackID + "?format=json&market=from_token"
-	cacheKey := fmt.Sprintf("lyrics:%s", trackID)
-	if cachedLyrics, ok := getCache(cacheKey); ok {
-		log.Info("[Cache:Lyrics] Found cached lyrics")
-		w.Header().Set("Content-Type", "application/json")
-		var cachedData map[string]interface{}
-		json.Unmarshal([]byte(cachedLyrics), &cachedData)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":         nil,
-			"trackId":       trackID,
-			"lyrics":        cachedData["lyrics"],
-			"isRtlLanguage": cachedData["isRtlLanguage"],
-			"language":      cachedData["language"],
-		})
+	serveLyrics(w, r, resolved.trackID, resolved.songName)
+}
+
+// serveLyrics writes the lyrics response for a resolved track id, using the lyrics cache
+func serveLyrics(w http.ResponseWriter, r *http.Request, trackID, songName string) {
+	// the key is taken before fetching, so a body is only kept if the lyrics didn't change meanwhile
+	bodyKey, _ := responseBodyKey(r, trackID)
+	if bodyKey != "" && writeCachedResponseBody(w, r, trackID, bodyKey) {
+		cacheStats.hit(fmt.Sprintf("lyrics:%s", trackID))
+		popularTracks.Record(trackID)
+		recordUsage(r.Context(), usage.CacheHit)
 		return
 	}
 
-	lyrics, isRtlLanguage, language, err := fetchLyrics(lyricsURL, accessToken)
+	refresh, err := forceRefresh(r)
 	if err != nil {
-		fmt.Println("Error fetching lyrics: ", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStageError(w, stageFetch, err)
 		return
 	}
-
-	if lyrics == nil {
-		http.Error(w, "Lyrics not available for this track", http.StatusNotFound)
+	data, found, err := fetchStage(r.Context(), trackID, refresh)
+	if err != nil {
+		writeStageError(w, stageFetch, err)
+		return
+	}
+	if !found {
+		if wait := capToBudget(r.Context(), parseWait(r.URL.Query().Get("wait"))); wait > 0 {
+			data, found = lyricsWatcher.waitForLyrics(r.Context(), trackID, wait)
+		}
+	}
+	if !found {
+		serveLyricsNotFound(w, r, trackID, songName)
 		return
 	}
 
-	log.Warn("[Cache:Lyrics] Caching lyrics")
-	cacheValue, _ := json.Marshal(map[string]interface{}{
-		"lyrics":        lyrics,
-		"isRtlLanguage": isRtlLanguage,
-		"language":      language,
-	})
-	setCache(cacheKey, string(cacheValue), time.Duration(conf.Configuration.LyricsCacheTTLInSeconds)*time.Second)
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error":         nil,
-		"trackId":       trackID,
-		"lyrics":        lyrics,
-		"isRtlLanguage": isRtlLanguage,
-		"language":      language,
-	})
-
+	writeLyricsResponse(w, r, &lyricsResponse{trackID: trackID, data: data, format: r.URL.Query().Get("format"), bodyKey: bodyKey})
 }
 
-func fetchTrackID(query, clientID, clientSecret string) (string, error) {
-	accessToken, err := getOauthAccessToken(clientID, clientSecret)
+// getLyricsForTrack returns the lyrics of a track from the lyrics cache, fetching and caching them on a miss.
+// found is false when the track has no lyrics.
+func getLyricsForTrack(ctx context.Context, trackID string) (CachedLyrics, bool, error) {
+	// duplicates share the lyrics of their canonical track, so a refresh of it applies to all of them
+	if canonicalID := canonicalTrackID(trackID); canonicalID != trackID {
+		if cachedData, ok := getCachedLyrics(fmt.Sprintf("lyrics:%s", canonicalID)); ok {
+			return cachedData, true, nil
+		}
+	}
+
+	cacheKey := fmt.Sprintf("lyrics:%s", trackID)
+	if cachedData, ok := getCachedLyrics(cacheKey); ok {
+		return cachedData, true, nil
+	}
+
+	// concurrent requests for the same uncached track share one upstream fetch
+	fetched, shared, err := upstreamFlights.Do(ctx, cacheKey, func(ctx context.Context) (interface{}, error) {
+		data, found, err := fetchLyricsIntoCache(ctx, trackID, cacheKey)
+		return fetchedLyrics{data: data, found: found}, err
+	})
 	if err != nil {
-		return "", fmt.Errorf("error getting access token: %v", err)
+		// an outage upstream shouldn't take down lyrics that were served before
+		if data, ok := staleLyrics(trackID, err); ok {
+			return data, true, nil
+		}
+		return CachedLyrics{}, false, err
 	}
+	if shared {
+		log.Infof("[Cache:Lyrics] Joined the fetch in flight for %s", cacheKey)
+	}
+	return fetched.(fetchedLyrics).data, fetched.(fetchedLyrics).found, nil
+}
 
-	searchURL := TrackURL + query
+// fetchedLyrics is the result of a lyrics fetch shared between concurrent requests
+type fetchedLyrics struct {
+	data  CachedLyrics
+	found bool
+}
 
-	headers := map[string]string{
-		"Authorization": "Bearer " + accessToken,
+// fetchLyricsIntoCache fetches the lyrics of a track and caches them
+func fetchLyricsIntoCache(ctx context.Context, trackID, cacheKey string) (CachedLyrics, bool, error) {
+	// the track stays locked until the fetched lyrics are stored, so a purge of the track can't
+	// interleave with the refresh and have its deletion overwritten
+	unlock := trackLocks.Lock(trackID)
+	defer unlock()
+	if cachedData, ok := getCachedLyrics(cacheKey); ok {
+		return cachedData, true, nil
 	}
+	return fetchAndCacheLyrics(ctx, trackID, cacheKey)
+}
+
+// refreshLyrics fetches the lyrics of a track again and overwrites its cache entry. Lyrics no
+// provider has anymore leave the entry as it is.
+func refreshLyrics(ctx context.Context, trackID string) (CachedLyrics, bool, error) {
+	cacheKey := fmt.Sprintf("lyrics:%s", trackID)
+	fetched, _, err := upstreamFlights.Do(ctx, "refresh:"+cacheKey, func(ctx context.Context) (interface{}, error) {
+		unlock := trackLocks.Lock(trackID)
+		defer unlock()
+		data, found, err := fetchAndCacheLyrics(ctx, trackID, cacheKey)
+		return fetchedLyrics{data: data, found: found}, err
+	})
+	if err != nil {
+		return CachedLyrics{}, false, err
+	}
+	return fetched.(fetchedLyrics).data, fetched.(fetchedLyrics).found, nil
+}
 
-	body, err := makeHTTPRequest("GET", searchURL, headers)
+// fetchAndCacheLyrics fetches the lyrics of a track from the providers and caches them. The track
+// must be locked.
+func fetchAndCacheLyrics(ctx context.Context, trackID, cacheKey string) (CachedLyrics, bool, error) {
+	version := getCacheVersion(cacheKey)
+	data, found, err := fetchLyrics(ctx, trackID)
 	if err != nil {
-		return "", fmt.Errorf("error making search request: %v", err)
+		fmt.Println("Error fetching lyrics: ", err)
+		return CachedLyrics{}, false, err
 	}
 
-	var trackResp TrackResponse
-	if err := json.Unmarshal(body, &trackResp); err != nil {
-		return "", fmt.Errorf("error parsing search response: %v", err)
+	if !found {
+		return CachedLyrics{}, false, nil
 	}
 
-	if len(trackResp.Tracks.Items) > 0 {
-		return trackResp.Tracks.Items[0].ID, nil
+	log.Warn("[Cache:Lyrics] Caching lyrics")
+	data.TimingVersion = lyricsTimingVersion
+	data.FetchedAt = time.Now().Unix()
+	ttl := requestCacheTTL(ctx, lyricsCacheTTL(trackID, data))
+	// lyrics a client asked to keep briefly aren't kept around for outages either
+	if staleTTL := staleLyricsTTL(); staleTTL > 0 && !hasRequestCacheTTL(ctx) {
+		data.StaleAt = time.Now().Add(ttl).Unix()
+		ttl += staleTTL
 	}
+	cacheValue, _ := json.Marshal(data)
+	if setCacheIfUnchanged(cacheKey, string(cacheValue), ttl, version) {
+		linkDuplicate(trackID, data)
+	}
+	lyricsWatcher.notify(trackID)
 
-	return "", nil
+	return data, true, nil
 }
 
-func fetchLyrics(lyricsURL, accessToken string) ([]Line, bool, string, error) {
-	headers := map[string]string{
-		"Authorization": "Bearer " + accessToken,
+// serveLyricsInLanguage serves the lyrics of trackID if they are in the expected language. Otherwise the
+// next search candidates are tried, since a language mismatch usually means the wrong song was matched.
+// When no candidate matches, the lyrics of the original match are served.
+func serveLyricsInLanguage(w http.ResponseWriter, r *http.Request, trackID, songName, artistName, expectLanguage string) {
+	refresh, err := forceRefresh(r)
+	if err != nil {
+		writeStageError(w, stageFetch, err)
+		return
 	}
-	body, err := makeHTTPRequest("GET", lyricsURL, headers)
+	data, found, err := fetchStage(r.Context(), trackID, refresh)
 	if err != nil {
-		return nil, false, "", err
+		writeStageError(w, stageFetch, err)
+		return
 	}
-
-	if body == nil {
-		return nil, false, "", nil
+	if found && analysis.SameLanguage(data.Language, expectLanguage) {
+		writeLyrics(w, r, trackID, data)
+		return
 	}
 
-	var lyricsResp LyricsResponse
-	if err := json.Unmarshal(body, &lyricsResp); err != nil {
-		return nil, false, "", err
+	query, artists := buildSearchQuery(songName, artistName)
+	candidates, err := fetchTrackCandidates(r.Context(), query, artists)
+	if err != nil {
+		log.Errorf("[Language] Error searching alternate candidates: %v", err)
+		candidates = nil
 	}
 
-	lines := lyricsResp.Lyrics.Lines
-	for i := 0; i < len(lines); i++ {
-		startTime, _ := strconv.ParseInt(lines[i].StartTimeMs, 10, 64)
-		var endTime int64
+	tried := 0
+	for _, candidate := range candidates {
+		candidateID := candidate.ID
+		if candidateID == trackID {
+			continue
+		}
+		if tried >= conf.Configuration.ExpectLanguageMaxCandidates {
+			break
+		}
+		tried++
 
-		if i == len(lines)-1 {
-			endTime, _ = strconv.ParseInt(lines[i].StartTimeMs, 10, 64)
-		} else {
-			endTime, _ = strconv.ParseInt(lines[i+1].StartTimeMs, 10, 64)
+		rememberReleaseDate(candidateID, candidate.ReleaseDate)
+		candidateData, candidateFound, err := fetchStage(r.Context(), candidateID, false)
+		if err != nil || !candidateFound {
+			continue
 		}
+		if analysis.SameLanguage(candidateData.Language, expectLanguage) {
+			log.Infof("[Language] Using candidate %s instead of %s to match language %s", candidateID, trackID, expectLanguage)
+			writeLyrics(w, r, candidateID, candidateData)
+			return
+		}
+	}
 
-		duration := endTime - startTime
-		lines[i].DurationMs = strconv.FormatInt(duration, 10)
+	if !found {
+		serveLyricsNotFound(w, r, trackID, songName)
+		return
 	}
-	language := lyricsResp.Lyrics.Language
-	isRTL := isRTLLanguage(language)
+	log.Warnf("[Language] No candidate matched language %s, serving %s", expectLanguage, trackID)
+	writeLyrics(w, r, trackID, data)
+}
 
-	return lines, isRTL, language, nil
+// isTruthy reports whether a boolean query parameter is set, e.g. ?compact=1 or ?compact=true
+func isTruthy(value string) bool {
+	enabled, _ := strconv.ParseBool(value)
+	return enabled
 }
 
-func getCacheDump(w http.ResponseWriter, r *http.Request) {
-	// Check if the request is authorized by checking the access token
-	if r.Header.Get("Authorization") != conf.Configuration.CacheAccessToken {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
+func getCachedLyrics(cacheKey string) (CachedLyrics, bool) {
+	cachedData, ok := readCachedLyrics(cacheKey)
+	// lyrics past their TTL are only kept for outages, see staleLyrics
+	if !ok || cachedData.StaleAt != 0 && time.Now().Unix() >= cachedData.StaleAt {
+		cacheStats.miss(cacheKey)
+		return CachedLyrics{}, false
 	}
-	cacheDump := CacheDump{}
-	cacheDumpResponse := CacheDumpResponse{}
-	cache.Range(func(key, value interface{}) bool {
-		if key == "accessToken" {
-			return true
-		}
-		cacheDump[key.(string)] = value.(CacheEntry)
-		return true
+	cacheStats.hit(cacheKey)
+	log.Info("[Cache:Lyrics] Found cached lyrics")
+	return cachedData, true
+}
+
+// trackCandidate is a search result. Its release date is only remembered once it is chosen.
+type trackCandidate struct {
+	ID          string `json:"id"`
+	ReleaseDate string `json:"releaseDate,omitempty"`
+}
+
+// fetchTrackID searches for query and returns the candidate crediting the most of the given artists,
+// preferring the upstream ranking on ties
+func fetchTrackID(ctx context.Context, query string, artists []string) (trackCandidate, error) {
+	candidates, err := fetchTrackCandidates(ctx, query, artists)
+	if err != nil || len(candidates) == 0 {
+		return trackCandidate{}, err
+	}
+	return candidates[0], nil
+}
+
+// fetchTrackCandidates searches for query and returns all results, ranked by how many of the given
+// artists they credit and then by the upstream ranking
+func fetchTrackCandidates(ctx context.Context, query string, artists []string) ([]trackCandidate, error) {
+	tracks, err := spotifyClient.SearchTracks(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make(map[string]int, len(tracks))
+	candidates := make([]trackCandidate, 0, len(tracks))
+	for _, track := range tracks {
+		matches[track.ID] = utils.CountArtistMatches(artists, track.Artists)
+		candidates = append(candidates, trackCandidate{ID: track.ID, ReleaseDate: track.ReleaseDate})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return matches[candidates[i].ID] > matches[candidates[j].ID]
 	})
-	cacheDumpResponse.Cache = cacheDump
-	cacheDumpResponse.NumberOfKeys = len(cacheDump)
-	size := 0
-	for key, value := range cacheDump {
-		size += len(key) + len(value.Value) + 8
+
+	return candidates, nil
+}
+
+// fetchLyrics tries the enabled lyrics providers in order until one has synced lyrics. Plain lyrics
+// from a provider without timing are only used when none has synced ones. It fails with
+// errNoLyricsProviders when every lyrics provider is disabled.
+func fetchLyrics(ctx context.Context, trackID string) (CachedLyrics, bool, error) {
+	providers := providerChain.ordered(providerKindLyrics)
+	if len(providers) == 0 {
+		return CachedLyrics{}, false, errNoLyricsProviders
+	}
+
+	var lastErr error
+	var unsynced *CachedLyrics
+	for _, name := range providers {
+		lines, language, syncType, err := lyricsSources[name](ctx, trackID)
+		if err != nil {
+			log.Errorf("[Providers] Error fetching lyrics from %s: %v", name, err)
+			providerStats.record(name, trackID, lookupError, "", "")
+			lastErr = err
+			continue
+		}
+		if lines == nil {
+			providerStats.record(name, trackID, lookupNotFound, "", "")
+			continue
+		}
+		providerStats.record(name, trackID, lookupAvailable, syncType, language)
+
+		if syncType == lyrics.SyncTypeUnsynced {
+			if unsynced == nil {
+				unsynced = &CachedLyrics{
+					Lyrics:         []lyrics.Line{},
+					IsRtlLanguage:  analysis.IsRTLLanguage(language),
+					Language:       language,
+					SyncType:       lyrics.SyncTypeUnsynced,
+					UnsyncedLyrics: strings.TrimSuffix(lyrics.FormatText(lines), "\n"),
+					Provider:       name,
+				}
+			}
+			continue
+		}
+
+		lyrics.ComputeTimings(lines)
+		return CachedLyrics{
+			Lyrics:        lines,
+			IsRtlLanguage: analysis.IsRTLLanguage(language),
+			Language:      language,
+			SyncType:      syncType,
+			Provider:      name,
+		}, true, nil
 	}
-	cacheDumpResponse.SizeInKB = size / 1024
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(cacheDumpResponse)
+	if unsynced != nil {
+		return *unsynced, true, nil
+	}
+	return CachedLyrics{}, false, lastErr
 }
 
-func limitMiddleware(next http.Handler, limiter *middleware.IPRateLimiter) http.Handler {
+func fetchSpotifyLyrics(ctx context.Context, trackID string) ([]lyrics.Line, string, string, error) {
+	lyricsResp, err := spotifyClient.Lyrics(ctx, trackID)
+	if err != nil || lyricsResp == nil {
+		return nil, "", "", err
+	}
+	return lyricsResp.Lyrics.Lines, lyricsResp.Lyrics.Language, lyricsResp.Lyrics.SyncType, nil
+}
+
+// limitMiddleware rate limits requests per IP and API key, and also within the origin's class in
+// ORIGIN_RATE_LIMITS if any, except those of API keys with their own rate limit and those
+// signed by the official extension, which have their own limiter. Requests of keys are also counted
+// against their daily quota. Every response carries the X-RateLimit headers of the stricter limit,
+// and Retry-After when it was exceeded.
+func limitMiddleware(next http.Handler, ipLimiter middleware.RateLimiter) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		limiter := limiter.GetLimiter(r.RemoteAddr)
-		if !limiter.Allow() {
-			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		// the daily quota of a key is reported instead of the rate limit the request got when it
+		// leaves less room
+		var quota middleware.Decision
+		hasQuota := false
+		limit := func(decision middleware.Decision) {
+			if hasQuota && decision.Allowed {
+				decision = middleware.Stricter(decision, quota)
+			}
+			middleware.WriteRateLimitHeaders(w, decision)
+			if !decision.Allowed {
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+
+		if secret := r.Header.Get(apiKeyHeader); secret != "" {
+			decision, err := apiKeys.Allow(secret)
+			if err != nil {
+				message := "Rate limit of the API key exceeded"
+				rejected := keyRateDecision(decision)
+				if err == apikeys.ErrQuotaExceeded {
+					message = "Daily quota of the API key exceeded"
+					rejected = keyQuotaDecision(decision)
+				}
+				rejected.Allowed = false
+				middleware.WriteRateLimitHeaders(w, rejected)
+				http.Error(w, message, http.StatusTooManyRequests)
+				return
+			}
+			if decision.Quota > 0 {
+				quota, hasQuota = keyQuotaDecision(decision), true
+			}
+			if decision.Limited {
+				limit(keyRateDecision(decision))
+				return
+			}
+		}
+
+		if extensionLimiter != nil && isExtensionRequest(r) {
+			limit(extensionLimiter.Allow(middleware.ClientIP(r)))
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		// clients behind one IP, e.g. a corporate NAT, only share a limit when they use the same API
+		// key. Origin classes limit their clients on top of the limit of their IP, so made-up origins
+		// can't multiply it.
+		client := middleware.ClientIP(r)
+		if key, ok := apiKeys.Lookup(r.Header.Get(apiKeyHeader)); ok {
+			client += " key:" + key.ID
+		}
+		decision, _, classified := originLimiters.Allow(r.Header.Get("Origin"), client)
+		if !classified {
+			decision = ipLimiter.Allow(client)
+		} else if decision.Allowed {
+			// requests denied by their class don't use up the limit of their IP
+			decision = middleware.Stricter(decision, ipLimiter.Allow(client))
+		}
+		limit(decision)
 	})
 }
 
-// goroutine to invalidate cache every 1 hour based on expiration times and delete keys
-func invalidateCache() {
-	log.Infof("[Cache:Invalidation] Starting cache invalidation goroutine")
-	for {
-		time.Sleep(time.Duration(conf.Configuration.CacheInvalidationIntervalInSeconds) * time.Second)
-		cache.Range(func(key, value interface{}) bool {
-			cacheEntry := value.(CacheEntry)
-			if time.Now().UnixNano() > cacheEntry.Expiration {
-				cache.Delete(key)
-				fmt.Printf("\033[31m[Cache:Invalidation] Deleted key: %s\033[0m\n", key)
-			}
-			return true
-		})
-	}
+// keyRateDecision describes the rate limit of an API key for the X-RateLimit headers
+func keyRateDecision(d apikeys.Decision) middleware.Decision {
+	return middleware.Decision{Allowed: true, Limit: d.Limit, Remaining: d.Remaining, Reset: d.Reset, RetryAfter: d.RetryAfter}
+}
+
+// keyQuotaDecision describes the daily quota of an API key for the X-RateLimit headers, the whole
+// quota being available again at midnight UTC
+func keyQuotaDecision(d apikeys.Decision) middleware.Decision {
+	return middleware.Decision{Allowed: true, Limit: d.Quota, Remaining: d.QuotaRemaining, Reset: d.QuotaReset, RetryAfter: d.RetryAfter}
 }
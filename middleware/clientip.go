@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+)
+
+// ClientIP returns the IP address of the client of r, the one unit the IP filter, rate limits and
+// abuse bans all key clients by. It is the address of the connection, as forwarding headers can be
+// made up by the client.
+func ClientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExtensionVerifier(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	verifier := NewExtensionVerifier("secret", 5*time.Minute)
+	verifier.now = func() time.Time { return now }
+
+	req := httptest.NewRequest("GET", "/v1/getLyrics?s=Song&a=Artist", nil)
+	SignExtensionRequest(req, "secret", now.Add(-time.Minute))
+	if !verifier.Verify(req) {
+		t.Errorf("Expected the signature to verify")
+	}
+	if verifier.Verify(req) {
+		t.Errorf("Expected a replayed signature not to verify")
+	}
+
+	req = httptest.NewRequest("GET", "/v1/getLyrics?s=Song&a=Artist", nil)
+	SignExtensionRequest(req, "secret", now)
+	req.URL.RawQuery = "s=Other&a=Artist"
+	if verifier.Verify(req) {
+		t.Errorf("Expected the signature of another query not to verify")
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		signedAt time.Time
+	}{
+		{"Wrong secret", "other", now},
+		{"Stale", "secret", now.Add(-10 * time.Minute)},
+		{"Future", "secret", now.Add(10 * time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/getLyrics?s=Song&a=Artist", nil)
+			SignExtensionRequest(req, tt.secret, tt.signedAt)
+			if verifier.Verify(req) {
+				t.Errorf("Expected the signature not to verify")
+			}
+		})
+	}
+
+	if verifier.Verify(httptest.NewRequest("GET", "/v1/getLyrics", nil)) {
+		t.Errorf("Expected an unsigned request not to verify")
+	}
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+)
+
+// securityHeaders are set on every response. The API only serves data, so nothing may be framed,
+// sniffed or load further content.
+var securityHeaders = map[string]string{
+	"X-Content-Type-Options":  "nosniff",
+	"X-Frame-Options":         "DENY",
+	"Referrer-Policy":         "no-referrer",
+	"Content-Security-Policy": "default-src 'none'; frame-ancestors 'none'",
+}
+
+// HardeningOptions are the input limits enforced by HardeningMiddleware
+type HardeningOptions struct {
+	// MaxURLLength caps the length of the path and query, 0 for no limit
+	MaxURLLength int
+	// MaxQueryLength caps the length of the query alone, 0 for no limit
+	MaxQueryLength int
+	// MaxHeaderBytes caps the total size of the header names and values, 0 for no limit
+	MaxHeaderBytes int
+	// MaxBodyBytes caps the size of request bodies, 0 for no limit
+	MaxBodyBytes int64
+	// ContentTypes are the media types accepted for request bodies
+	ContentTypes []string
+}
+
+// HardeningMiddleware sets security headers and rejects requests with overlong URLs or queries
+// (414), oversized headers (431), bodies over the size limit (413) or bodies of an unsupported
+// content type (415). Headers set by later middleware or handlers take precedence over the security
+// headers.
+func HardeningMiddleware(next http.Handler, options HardeningOptions) http.Handler {
+	allowed := map[string]bool{}
+	for _, contentType := range options.ContentTypes {
+		allowed[contentType] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, value := range securityHeaders {
+			w.Header().Set(name, value)
+		}
+
+		if options.MaxURLLength > 0 && len(r.URL.RequestURI()) > options.MaxURLLength {
+			http.Error(w, "URI too long", http.StatusRequestURITooLong)
+			return
+		}
+		if options.MaxQueryLength > 0 && len(r.URL.RawQuery) > options.MaxQueryLength {
+			http.Error(w, "Query string too long", http.StatusRequestURITooLong)
+			return
+		}
+		if options.MaxHeaderBytes > 0 && headerSize(r.Header) > options.MaxHeaderBytes {
+			http.Error(w, "Request headers too large", http.StatusRequestHeaderFieldsTooLarge)
+			return
+		}
+
+		if hasBody(r) {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || !allowed[mediaType] {
+				http.Error(w, "Unsupported content type", http.StatusUnsupportedMediaType)
+				return
+			}
+			if options.MaxBodyBytes > 0 {
+				if r.ContentLength > options.MaxBodyBytes {
+					http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				// chunked bodies have no declared length and are cut off while being read instead
+				r.Body = http.MaxBytesReader(w, r.Body, options.MaxBodyBytes)
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// headerSize returns the size of the header names and values
+func headerSize(header http.Header) int {
+	size := 0
+	for name, values := range header {
+		for _, value := range values {
+			size += len(name) + len(value)
+		}
+	}
+	return size
+}
+
+// hasBody reports whether the request declares a body, with a length or chunked encoding
+func hasBody(r *http.Request) bool {
+	return r.ContentLength > 0 || (r.ContentLength < 0 && r.Body != nil && r.Body != http.NoBody)
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHardeningMiddleware(t *testing.T) {
+	handler := HardeningMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		}
+	}), HardeningOptions{
+		MaxURLLength:   32,
+		MaxQueryLength: 20,
+		MaxHeaderBytes: 64,
+		MaxBodyBytes:   16,
+		ContentTypes:   []string{"application/json"},
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		expected    int
+	}{
+		{"Plain GET", "GET", "/getLyrics?s=song", "", "", http.StatusOK},
+		{"Long URL", "GET", "/getLyrics?s=" + strings.Repeat("a", 32), "", "", http.StatusRequestURITooLong},
+		{"Long query", "GET", "/x?s=" + strings.Repeat("a", 19), "", "", http.StatusRequestURITooLong},
+		{"Large headers", "GET", "/getLyrics", strings.Repeat("a", 64), "", http.StatusRequestHeaderFieldsTooLarge},
+		{"JSON body", "POST", "/admin/audit", "application/json; charset=utf-8", `{"sample": 10}`, http.StatusOK},
+		{"Empty POST", "POST", "/admin/backfill", "", "", http.StatusOK},
+		{"Large body", "POST", "/admin/audit", "application/json", strings.Repeat(" ", 17), http.StatusRequestEntityTooLarge},
+		{"Unsupported content type", "POST", "/admin/audit", "text/xml", "<audit/>", http.StatusUnsupportedMediaType},
+		{"Missing content type", "POST", "/admin/audit", "", "{}", http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, rec.Code)
+			}
+			if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+				t.Errorf("Expected X-Content-Type-Options: nosniff, got %q", got)
+			}
+		})
+	}
+}
+
+func TestHardeningMiddlewareChunkedBody(t *testing.T) {
+	handler := HardeningMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		}
+	}), HardeningOptions{MaxBodyBytes: 16, ContentTypes: []string{"application/json"}})
+
+	req := httptest.NewRequest("POST", "/admin/audit", strings.NewReader(strings.Repeat(" ", 17)))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ParseHeaders parses a JSON object of header names to values, e.g.
+// {"Strict-Transport-Security": "max-age=31536000", "X-Powered-By": "Better Lyrics"}
+func ParseHeaders(value string) (http.Header, error) {
+	headers := http.Header{}
+	if value == "" {
+		return headers, nil
+	}
+
+	var parsed map[string]string
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return nil, fmt.Errorf("invalid response headers, expected a JSON object of strings: %v", err)
+	}
+	for name, v := range parsed {
+		if name == "" {
+			return nil, fmt.Errorf("invalid response headers, empty header name")
+		}
+		headers.Set(name, v)
+	}
+	return headers, nil
+}
+
+// HeadersMiddleware adds static headers to every response. Handlers can still override them.
+func HeadersMiddleware(next http.Handler, headers http.Header) http.Handler {
+	if len(headers) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, values := range headers {
+			w.Header()[name] = append([]string(nil), values...)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
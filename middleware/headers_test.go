@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders(`{"strict-transport-security": "max-age=31536000; includeSubDomains"}`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := headers.Get("Strict-Transport-Security"); got != "max-age=31536000; includeSubDomains" {
+		t.Errorf("Expected HSTS header, got %q", got)
+	}
+
+	if _, err := ParseHeaders(`["not", "an", "object"]`); err == nil {
+		t.Errorf("Expected an error for a JSON array, got nil")
+	}
+	if headers, err := ParseHeaders(""); err != nil || len(headers) != 0 {
+		t.Errorf("Expected no headers for an empty value, got %v, %v", headers, err)
+	}
+}
+
+func TestHeadersMiddleware(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("X-Powered-By", "Better Lyrics")
+	headers.Set("Content-Type", "text/plain")
+
+	handler := HeadersMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+	}), headers)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if got := rec.Header().Get("X-Powered-By"); got != "Better Lyrics" {
+		t.Errorf("Expected X-Powered-By header, got %q", got)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Expected handler to override Content-Type, got %q", got)
+	}
+}
@@ -0,0 +1,215 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader is the header clients use to make retries of mutating requests safe
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type idempotentResponse struct {
+	fingerprint string
+	done        bool
+	statusCode  int
+	header      http.Header
+	body        []byte
+	expiration  time.Time
+}
+
+// IdempotencyStore remembers responses of mutating requests by idempotency key for a short time
+type IdempotencyStore struct {
+	mu        sync.Mutex
+	responses map[string]*idempotentResponse
+	ttl       time.Duration
+	// maxEntries bounds the keys remembered, the responses closest to expiring make room for new ones
+	maxEntries int
+	// maxBodySize bounds the body of a remembered response, larger responses aren't remembered
+	maxBodySize int
+	// Subject identifies the caller of a request, whose keys are kept apart from those of other
+	// callers. Without it callers are told apart by their Authorization header.
+	Subject func(r *http.Request) string
+}
+
+// NewIdempotencyStore creates a store keeping up to maxEntries responses of up to maxBodySize
+// bytes for ttl. Zero bounds mean no bound.
+func NewIdempotencyStore(ttl time.Duration, maxEntries, maxBodySize int) *IdempotencyStore {
+	return &IdempotencyStore{
+		responses:   make(map[string]*idempotentResponse),
+		ttl:         ttl,
+		maxEntries:  maxEntries,
+		maxBodySize: maxBodySize,
+	}
+}
+
+// begin reserves key for a request. It returns the stored response if the request already completed,
+// or ok=false if the key is in use by another in-flight or different request. When the store is full
+// of in-flight requests it returns nil and ok=false.
+func (s *IdempotencyStore) begin(key, fingerprint string) (stored *idempotentResponse, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, resp := range s.responses {
+		if resp.done && now.After(resp.expiration) {
+			delete(s.responses, k)
+		}
+	}
+
+	if existing, exists := s.responses[key]; exists {
+		if existing.fingerprint != fingerprint || !existing.done {
+			return existing, false
+		}
+		return existing, true
+	}
+
+	if s.maxEntries > 0 && len(s.responses) >= s.maxEntries && !s.evictLocked() {
+		return nil, false
+	}
+	s.responses[key] = &idempotentResponse{fingerprint: fingerprint}
+	return nil, true
+}
+
+// evictLocked drops the completed response closest to expiring, reporting whether there was one
+func (s *IdempotencyStore) evictLocked() bool {
+	oldest := ""
+	for k, resp := range s.responses {
+		if resp.done && (oldest == "" || resp.expiration.Before(s.responses[oldest].expiration)) {
+			oldest = k
+		}
+	}
+	if oldest == "" {
+		return false
+	}
+	delete(s.responses, oldest)
+	return true
+}
+
+func (s *IdempotencyStore) finish(key string, rec *bufferedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// server errors are not remembered so the client can retry them, nor are responses too large
+	// to keep
+	if rec.statusCode >= 500 || rec.truncated {
+		delete(s.responses, key)
+		return
+	}
+
+	resp := s.responses[key]
+	resp.done = true
+	resp.statusCode = rec.statusCode
+	resp.header = rec.Header().Clone()
+	resp.body = rec.body.Bytes()
+	resp.expiration = time.Now().Add(s.ttl)
+}
+
+// release forgets key without remembering a response, so the request can be retried
+func (s *IdempotencyStore) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.responses, key)
+}
+
+// bufferedResponse writes through to the client while keeping a copy of the response, up to
+// maxBodySize bytes of it
+type bufferedResponse struct {
+	http.ResponseWriter
+	statusCode  int
+	body        bytes.Buffer
+	maxBodySize int
+	truncated   bool
+}
+
+func (b *bufferedResponse) WriteHeader(code int) {
+	b.statusCode = code
+	b.ResponseWriter.WriteHeader(code)
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.maxBodySize > 0 && b.body.Len()+len(p) > b.maxBodySize {
+		b.truncated = true
+		b.body.Reset()
+	}
+	if !b.truncated {
+		b.body.Write(p)
+	}
+	return b.ResponseWriter.Write(p)
+}
+
+// IdempotencyMiddleware replays the stored response when a POST, PUT, PATCH or DELETE request is retried
+// with the same Idempotency-Key. Reusing a key for a different request is rejected with 422, and a retry
+// while the original is still in flight is rejected with 409. Responses larger than the store's bound
+// aren't remembered, so retrying them runs the request again.
+func IdempotencyMiddleware(next http.Handler, store *IdempotencyStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || !isMutating(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Unable to read request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		// keys are scoped per caller and route so different clients can't collide
+		subject := r.Header.Get("Authorization")
+		if store.Subject != nil {
+			subject = store.Subject(r)
+		}
+		scope := r.Method + " " + r.URL.Path + " " + subject + " " + key
+		hash := sha256.Sum256(append([]byte(r.Method+" "+r.URL.String()+"\n"), body...))
+		fingerprint := hex.EncodeToString(hash[:])
+
+		stored, ok := store.begin(scope, fingerprint)
+		if !ok {
+			if stored == nil {
+				http.Error(w, "Too many requests with an Idempotency-Key are being processed", http.StatusServiceUnavailable)
+			} else if stored.fingerprint != fingerprint {
+				http.Error(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+			} else {
+				http.Error(w, "A request with this Idempotency-Key is still being processed", http.StatusConflict)
+			}
+			return
+		}
+		if stored != nil {
+			for name, values := range stored.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.statusCode)
+			w.Write(stored.body)
+			return
+		}
+
+		rec := &bufferedResponse{ResponseWriter: w, statusCode: http.StatusOK, maxBodySize: store.maxBodySize}
+		finished := false
+		// the key of a handler that panics is released, or it would answer 409 until evicted, which
+		// in-flight keys never are
+		defer func() {
+			if !finished {
+				store.release(scope)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+		store.finish(scope, rec)
+		finished = true
+	})
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCountingHandler(calls *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("created"))
+	})
+}
+
+// TestIdempotencyReplay tests that a retried request with the same key is replayed, not re-executed.
+func TestIdempotencyReplay(t *testing.T) {
+	calls := 0
+	handler := IdempotencyMiddleware(newCountingHandler(&calls), NewIdempotencyStore(time.Minute, 0, 0))
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/reports", strings.NewReader(`{"a":1}`))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusAccepted || rec.Body.String() != "created" {
+			t.Errorf("Expected replayed 202 created, got %d %q", rec.Code, rec.Body.String())
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected handler to run once, ran %d times", calls)
+	}
+}
+
+// TestIdempotencyKeyReuse tests that reusing a key for a different body is rejected.
+func TestIdempotencyKeyReuse(t *testing.T) {
+	calls := 0
+	handler := IdempotencyMiddleware(newCountingHandler(&calls), NewIdempotencyStore(time.Minute, 0, 0))
+
+	req := httptest.NewRequest("POST", "/reports", strings.NewReader(`{"a":1}`))
+	req.Header.Set(IdempotencyKeyHeader, "key-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("POST", "/reports", strings.NewReader(`{"a":2}`))
+	req.Header.Set(IdempotencyKeyHeader, "key-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for reused key, got %d", rec.Code)
+	}
+}
+
+// TestIdempotencyIgnoresSafeMethods tests that GET requests are never deduplicated.
+func TestIdempotencyIgnoresSafeMethods(t *testing.T) {
+	calls := 0
+	handler := IdempotencyMiddleware(newCountingHandler(&calls), NewIdempotencyStore(time.Minute, 0, 0))
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("GET", "/getLyrics", nil)
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if calls != 2 {
+		t.Errorf("Expected handler to run twice, ran %d times", calls)
+	}
+}
+
+// TestIdempotencyBounds tests that large responses aren't replayed and the oldest responses are evicted.
+func TestIdempotencyBounds(t *testing.T) {
+	calls := 0
+	store := NewIdempotencyStore(time.Minute, 2, 4)
+	handler := IdempotencyMiddleware(newCountingHandler(&calls), store)
+
+	for _, key := range []string{"key-1", "key-1", "key-2", "key-3"} {
+		req := httptest.NewRequest("POST", "/reports", strings.NewReader(`{"a":1}`))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Body.String() != "created" {
+			t.Errorf("Expected the full body, got %q", rec.Body.String())
+		}
+	}
+	if calls != 4 {
+		t.Errorf("Expected responses over the size bound not to be replayed, ran %d times", calls)
+	}
+	if len(store.responses) != 0 {
+		t.Errorf("Expected no responses to be remembered, got %d", len(store.responses))
+	}
+
+	store = NewIdempotencyStore(time.Minute, 2, 0)
+	handler = IdempotencyMiddleware(newCountingHandler(&calls), store)
+	for _, key := range []string{"key-1", "key-2", "key-3"} {
+		req := httptest.NewRequest("POST", "/reports", strings.NewReader(`{"a":1}`))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if len(store.responses) != 2 {
+		t.Errorf("Expected 2 remembered responses, got %d", len(store.responses))
+	}
+}
+
+// TestIdempotencySubject tests that callers told apart by the store's Subject don't share keys.
+func TestIdempotencySubject(t *testing.T) {
+	calls := 0
+	store := NewIdempotencyStore(time.Minute, 0, 0)
+	store.Subject = func(r *http.Request) string { return r.Header.Get("X-API-Key") }
+	handler := IdempotencyMiddleware(newCountingHandler(&calls), store)
+
+	for _, apiKey := range []string{"client-1", "client-2"} {
+		req := httptest.NewRequest("POST", "/reports", strings.NewReader(`{"a":1}`))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		req.Header.Set("X-API-Key", apiKey)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("Expected the request of %s not to be replayed", apiKey)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected handler to run for each caller, ran %d times", calls)
+	}
+}
+
+// TestIdempotencyPanic tests that the key of a panicking handler is released for retries.
+func TestIdempotencyPanic(t *testing.T) {
+	calls := 0
+	store := NewIdempotencyStore(time.Minute, 0, 0)
+	handler := IdempotencyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			panic("handler failed")
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}), store)
+
+	serve := func() *httptest.ResponseRecorder {
+		defer func() { recover() }()
+		req := httptest.NewRequest("POST", "/reports", strings.NewReader(`{"a":1}`))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	serve()
+	if len(store.responses) != 0 {
+		t.Errorf("Expected the key to be released, got %d entries", len(store.responses))
+	}
+	if rec := serve(); rec == nil || rec.Code != http.StatusAccepted || calls != 2 {
+		t.Errorf("Expected the retry to run the handler again, ran %d times", calls)
+	}
+}
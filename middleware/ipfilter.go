@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// IPFilter allows or denies clients by IP address. Denied ranges take precedence, and when there are
+// allowed ranges, clients outside of all of them are denied.
+type IPFilter struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// ParseIPFilter parses lists of CIDR ranges or single IP addresses, e.g. "10.0.0.0/8" or "::1"
+func ParseIPFilter(allow, deny []string) (*IPFilter, error) {
+	var err error
+	filter := &IPFilter{}
+	if filter.allow, err = parseNets(allow); err != nil {
+		return nil, err
+	}
+	if filter.deny, err = parseNets(deny); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func parseNets(values []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range %q", value)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// Empty reports whether the filter lets every client through
+func (f *IPFilter) Empty() bool {
+	return len(f.allow) == 0 && len(f.deny) == 0
+}
+
+// Allowed reports whether ip may make requests. Without an IP, only an allow list denies the client.
+func (f *IPFilter) Allowed(ip net.IP) bool {
+	if ip == nil {
+		return len(f.allow) == 0
+	}
+	if containsIP(f.deny, ip) {
+		return false
+	}
+	return len(f.allow) == 0 || containsIP(f.allow, ip)
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPFilterMiddleware rejects requests of denied clients with 403
+func IPFilterMiddleware(next http.Handler, filter *IPFilter) http.Handler {
+	if filter.Empty() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !filter.Allowed(net.ParseIP(ClientIP(r))) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
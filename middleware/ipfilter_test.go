@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPFilter(t *testing.T) {
+	filter, err := ParseIPFilter([]string{"10.0.0.0/8", "fd00::/8", "192.0.2.7"}, []string{"10.6.6.0/24"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"10.6.6.6", false},
+		{"192.0.2.7", true},
+		{"192.0.2.8", false},
+		{"::ffff:10.1.2.3", true},
+		{"fd12::1", true},
+		{"2001:db8::1", false},
+	}
+	for _, tt := range tests {
+		if got := filter.Allowed(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("Expected Allowed(%s) to be %v, got %v", tt.ip, tt.want, got)
+		}
+	}
+	if filter.Allowed(nil) {
+		t.Errorf("Expected clients without an IP to be denied by an allow list")
+	}
+
+	if _, err := ParseIPFilter([]string{"10.0.0.0/33"}, nil); err == nil {
+		t.Errorf("Expected an error for an invalid range, got nil")
+	}
+	if _, err := ParseIPFilter(nil, []string{"not-an-ip"}); err == nil {
+		t.Errorf("Expected an error for an invalid address, got nil")
+	}
+}
+
+func TestIPFilterMiddleware(t *testing.T) {
+	filter, _ := ParseIPFilter(nil, []string{"203.0.113.0/24"})
+	handler := IPFilterMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), filter)
+
+	for addr, want := range map[string]int{"203.0.113.9:4321": http.StatusForbidden, "198.51.100.1:4321": http.StatusOK} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("Expected %d for %s, got %d", want, addr, rec.Code)
+		}
+	}
+}
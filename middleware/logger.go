@@ -34,7 +34,8 @@ func (rec *ResponseRecorder) Write(b []byte) (int, error) {
 	return size, err
 }
 
-// LoggingMiddleware logs the request details with colored status codes
+// LoggingMiddleware logs the request details with colored status codes, and the request id when
+// it runs inside RequestIDMiddleware
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		rec := NewResponseRecorder(w)
@@ -45,13 +46,14 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		statusColor := getStatusColor(rec.StatusCode)
 		resetColor := "\033[0m"
 
-		fmt.Printf("%s %s %s %s%d%s %d %s\n",
+		fmt.Printf("%s %s %s %s%d%s %d %s %s\n",
 			r.Method,
 			r.URL,
 			r.Proto,
 			statusColor, rec.StatusCode, resetColor,
 			rec.BodySize,
 			duration,
+			RequestIDFromContext(r.Context()),
 		)
 	})
 }
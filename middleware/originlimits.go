@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"golang.org/x/time/rate"
+)
+
+// OriginLimit is the rate limit of the clients of an origin class
+type OriginLimit struct {
+	RatePerSecond float64 `json:"ratePerSecond"`
+	Burst         int     `json:"burst"`
+}
+
+type originClass struct {
+	name    string
+	pattern originPattern
+	limiter RateLimiter
+}
+
+// OriginLimiters rate limits requests from classes of origins with their own limits
+type OriginLimiters struct {
+	classes []originClass
+}
+
+// ParseOriginLimits parses a JSON object of origin patterns, as accepted by ParseOrigins, to their
+// limits, e.g. {"https://music.youtube.com": {"ratePerSecond": 5, "burst": 20}}. The limiter of
+// every class is created with newLimiter, or kept in memory when it is nil.
+func ParseOriginLimits(value string, newLimiter func(class string, limit OriginLimit) RateLimiter) (*OriginLimiters, error) {
+	limiters := &OriginLimiters{}
+	if value == "" {
+		return limiters, nil
+	}
+
+	var parsed map[string]OriginLimit
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return nil, fmt.Errorf("invalid origin limits, expected a JSON object of origins to limits: %v", err)
+	}
+	for name, limit := range parsed {
+		matcher, err := ParseOrigins([]string{name})
+		if err != nil || len(matcher.patterns) != 1 {
+			return nil, fmt.Errorf("invalid origin limits, %v", err)
+		}
+		if limit.RatePerSecond <= 0 || limit.Burst <= 0 {
+			return nil, fmt.Errorf("invalid origin limits of %s, the rate and burst must be positive", name)
+		}
+		var limiter RateLimiter = NewIPRateLimiter(rate.Limit(limit.RatePerSecond), limit.Burst)
+		if newLimiter != nil {
+			limiter = newLimiter(name, limit)
+		}
+		limiters.classes = append(limiters.classes, originClass{name: name, pattern: matcher.patterns[0], limiter: limiter})
+	}
+	// exact origins take precedence over subdomain wildcards, which take precedence over any
+	// extension, and longer patterns over shorter ones
+	sort.Slice(limiters.classes, func(i, j int) bool {
+		a, b := limiters.classes[i], limiters.classes[j]
+		if specificity(a.pattern) != specificity(b.pattern) {
+			return specificity(a.pattern) < specificity(b.pattern)
+		}
+		if len(a.name) != len(b.name) {
+			return len(a.name) > len(b.name)
+		}
+		return a.name < b.name
+	})
+	return limiters, nil
+}
+
+func specificity(p originPattern) int {
+	switch {
+	case p.anyHost:
+		return 2
+	case p.subdomain:
+		return 1
+	}
+	return 0
+}
+
+// Allow decides whether a request of client is allowed within the class of origin, and returns
+// the class, or false when origin is in none of the classes
+func (l *OriginLimiters) Allow(origin, client string) (Decision, string, bool) {
+	scheme, host, ok := splitOrigin(origin)
+	if !ok {
+		return Decision{}, "", false
+	}
+	for _, class := range l.classes {
+		if class.pattern.matches(scheme, host) {
+			return class.limiter.Allow(client), class.name, true
+		}
+	}
+	return Decision{}, "", false
+}
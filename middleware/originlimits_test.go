@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestOriginLimiters(t *testing.T) {
+	limiters, err := ParseOriginLimits(`{
+		"https://*.example.com": {"ratePerSecond": 1, "burst": 1},
+		"https://music.example.com": {"ratePerSecond": 1, "burst": 3},
+		"chrome-extension://*": {"ratePerSecond": 1, "burst": 2}
+	}`, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		origin string
+		class  string
+		burst  int
+	}{
+		{"https://music.example.com", "https://music.example.com", 3},
+		{"https://other.example.com", "https://*.example.com", 1},
+		{"chrome-extension://abcdef", "chrome-extension://*", 2},
+	}
+	for _, tt := range tests {
+		for i := 0; i < tt.burst; i++ {
+			decision, class, ok := limiters.Allow(tt.origin, "203.0.113.7")
+			if !ok || class != tt.class || !decision.Allowed || decision.Limit != tt.burst {
+				t.Errorf("Expected %s to be allowed as %s, got %s (%+v, %v)", tt.origin, tt.class, class, decision, ok)
+			}
+		}
+		if decision, _, _ := limiters.Allow(tt.origin, "203.0.113.7"); decision.Allowed {
+			t.Errorf("Expected %s to be limited past a burst of %d", tt.origin, tt.burst)
+		}
+	}
+	if _, _, ok := limiters.Allow("https://elsewhere.org", "203.0.113.7"); ok {
+		t.Errorf("Expected origins outside of the classes not to match")
+	}
+	if decision, _, _ := limiters.Allow("https://music.example.com", "203.0.113.8"); !decision.Allowed {
+		t.Errorf("Expected every client to have its own limit within a class")
+	}
+}
+
+func TestOriginLimitersWithLimiter(t *testing.T) {
+	var created []string
+	limiters, err := ParseOriginLimits(`{"https://music.example.com": {"ratePerSecond": 10, "burst": 3}}`, func(class string, limit OriginLimit) RateLimiter {
+		created = append(created, class)
+		return NewIPRateLimiter(rate.Limit(limit.RatePerSecond), limit.Burst)
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(created) != 1 || created[0] != "https://music.example.com" {
+		t.Errorf("Expected the limiter of the class to be created, got %v", created)
+	}
+	if decision, _, ok := limiters.Allow("https://music.example.com", "203.0.113.7"); !decision.Allowed || !ok {
+		t.Errorf("Expected the request to be allowed by the created limiter")
+	}
+}
+
+func TestParseOriginLimitsRejects(t *testing.T) {
+	for _, value := range []string{`["https://example.com"]`, `{"https://*": {"ratePerSecond": 1, "burst": 1}}`, `{"https://example.com": {"ratePerSecond": 0, "burst": 1}}`} {
+		if _, err := ParseOriginLimits(value, nil); err == nil {
+			t.Errorf("Expected an error for %s, got nil", value)
+		}
+	}
+	if limiters, err := ParseOriginLimits("", nil); err != nil || len(limiters.classes) != 0 {
+		t.Errorf("Expected no classes for an empty value, got %v, %v", limiters, err)
+	}
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+)
+
+// extensionSchemes are the origin schemes of browser extensions, whose hosts are extension IDs
+var extensionSchemes = map[string]bool{"chrome-extension": true, "moz-extension": true, "safari-web-extension": true}
+
+// originPattern is an allowed origin: an exact origin, any subdomain of a host with a leading "*."
+// or, for extension schemes only, any extension with "*"
+type originPattern struct {
+	scheme string
+	// host includes the port; for subdomain patterns it is the parent host with a leading "."
+	host      string
+	subdomain bool
+	anyHost   bool
+}
+
+// OriginMatcher checks request origins against allowed patterns, for CORS
+type OriginMatcher struct {
+	patterns []originPattern
+}
+
+// ParseOrigins parses allowed origins such as "https://music.youtube.com", "https://*.example.com",
+// "chrome-extension://<id>" or "moz-extension://*". Allowing any extension of a browser with "*"
+// also allows extensions nobody vetted, so CORS should list extension IDs instead.
+func ParseOrigins(values []string) (*OriginMatcher, error) {
+	matcher := &OriginMatcher{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		scheme, host, ok := splitOrigin(value)
+		if !ok {
+			return nil, fmt.Errorf("invalid origin %q, expected scheme://host", value)
+		}
+		pattern := originPattern{scheme: scheme, host: host}
+		switch {
+		case host == "*":
+			if !extensionSchemes[scheme] {
+				return nil, fmt.Errorf("invalid origin %q, any host is only allowed for extension schemes", value)
+			}
+			pattern.anyHost = true
+		case strings.HasPrefix(host, "*."):
+			pattern.host, pattern.subdomain = host[1:], true
+		}
+		if !pattern.anyHost && strings.Contains(pattern.host, "*") {
+			return nil, fmt.Errorf("invalid origin %q, a wildcard must be the first label of the host", value)
+		}
+		matcher.patterns = append(matcher.patterns, pattern)
+	}
+	return matcher, nil
+}
+
+// splitOrigin splits an origin into its lowercased scheme and host
+func splitOrigin(origin string) (scheme, host string, ok bool) {
+	scheme, host, ok = strings.Cut(strings.ToLower(origin), "://")
+	if !ok || scheme == "" || host == "" || strings.ContainsAny(host, "/?#@") {
+		return "", "", false
+	}
+	return scheme, host, true
+}
+
+// AnyExtension reports whether a pattern allows any extension of a browser
+func (m *OriginMatcher) AnyExtension() bool {
+	for _, p := range m.patterns {
+		if p.anyHost {
+			return true
+		}
+	}
+	return false
+}
+
+// Allow reports whether origin matches one of the patterns
+func (m *OriginMatcher) Allow(origin string) bool {
+	scheme, host, ok := splitOrigin(origin)
+	if !ok {
+		return false
+	}
+	for _, p := range m.patterns {
+		if p.matches(scheme, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p originPattern) matches(scheme, host string) bool {
+	if p.scheme != scheme {
+		return false
+	}
+	switch {
+	case p.anyHost:
+		return true
+	case p.subdomain:
+		return strings.HasSuffix(host, p.host) && len(host) > len(p.host)
+	}
+	return p.host == host
+}
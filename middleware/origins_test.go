@@ -0,0 +1,48 @@
+package middleware
+
+import "testing"
+
+func TestOriginMatcher(t *testing.T) {
+	matcher, err := ParseOrigins([]string{
+		"https://music.youtube.com", "https://*.example.com:8443", "chrome-extension://abcdefghijklmnop", "moz-extension://*",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !matcher.AnyExtension() {
+		t.Errorf("Expected moz-extension://* to allow any extension")
+	}
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://music.youtube.com", true},
+		{"HTTPS://Music.YouTube.com", true},
+		{"http://music.youtube.com", false},
+		{"https://evil-music.youtube.com", false},
+		{"https://a.example.com:8443", true},
+		{"https://a.b.example.com:8443", true},
+		{"https://example.com:8443", false},
+		{"https://a.example.com", false},
+		{"https://evilexample.com:8443", false},
+		{"chrome-extension://abcdefghijklmnop", true},
+		{"chrome-extension://otherextension", false},
+		{"moz-extension://6f1c2a2e-0c7e-4b2c-9d3e-2f1a0b9c8d7e", true},
+		{"null", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := matcher.Allow(tt.origin); got != tt.want {
+			t.Errorf("Expected Allow(%q) to be %v, got %v", tt.origin, tt.want, got)
+		}
+	}
+}
+
+func TestParseOriginsRejects(t *testing.T) {
+	for _, value := range []string{"music.youtube.com", "https://*", "https://music.*.com", "https://example.com/path"} {
+		if _, err := ParseOrigins([]string{value}); err == nil {
+			t.Errorf("Expected an error for %q, got nil", value)
+		}
+	}
+}
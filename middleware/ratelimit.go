@@ -1,11 +1,72 @@
 package middleware
 
 import (
-	"golang.org/x/time/rate"
+	"math"
+	"net/http"
+	"strconv"
 	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
 )
 
-// IPRateLimiter .
+// Decision is the outcome of checking the rate limit of a request
+type Decision struct {
+	Allowed bool
+	// Limit is the burst, the number of requests a client can make at once
+	Limit int
+	// Remaining is the number of requests the client can still make right away
+	Remaining int
+	// Reset is how long until the client can make Limit requests at once again
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed, for requests that weren't
+	RetryAfter time.Duration
+}
+
+// RateLimiter limits the rate of requests of every client
+type RateLimiter interface {
+	// Allow decides whether a request of client is allowed now, counting it if so
+	Allow(client string) Decision
+}
+
+// WriteRateLimitHeaders sets the X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset
+// headers of a rate limited response, and Retry-After when the request wasn't allowed, so clients
+// can back off instead of retrying blindly. Durations are in seconds, rounded up.
+func WriteRateLimitHeaders(w http.ResponseWriter, d Decision) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(d.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+	if !d.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(max(ceilSeconds(d.RetryAfter), 1)))
+	}
+}
+
+// Stricter returns whichever of two decisions about one request leaves the client less room: a
+// denial, the one retried later, or the one with fewer requests remaining
+func Stricter(a, b Decision) Decision {
+	switch {
+	case a.Allowed != b.Allowed:
+		if !a.Allowed {
+			return a
+		}
+		return b
+	case !a.Allowed:
+		if a.RetryAfter >= b.RetryAfter {
+			return a
+		}
+		return b
+	case a.Remaining <= b.Remaining:
+		return a
+	}
+	return b
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// IPRateLimiter limits the rate of requests of every client in memory, so every instance has its
+// own limits
 type IPRateLimiter struct {
 	ips map[string]*rate.Limiter
 	mu  *sync.RWMutex
@@ -48,3 +109,24 @@ func (i *IPRateLimiter) GetLimiter(ip string) *rate.Limiter {
 
 	return limiter
 }
+
+// Allow decides whether a request of ip is allowed now
+func (i *IPRateLimiter) Allow(ip string) Decision {
+	limiter := i.GetLimiter(ip)
+	now := time.Now()
+	decision := Decision{Allowed: true, Limit: i.b}
+	reservation := limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		decision.Allowed = false
+	} else if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		decision.Allowed, decision.RetryAfter = false, delay
+	}
+
+	tokens := limiter.TokensAt(now)
+	decision.Remaining = max(int(math.Floor(tokens)), 0)
+	if missing := float64(i.b) - tokens; missing > 0 && i.r > 0 {
+		decision.Reset = time.Duration(missing / float64(i.r) * float64(time.Second))
+	}
+	return decision
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -69,3 +70,67 @@ func TestRateLimiting(t *testing.T) {
 		t.Errorf("Expected request to be allowed after waiting")
 	}
 }
+
+// TestIPRateLimiterAllow tests the decisions of the rate limiter.
+func TestIPRateLimiterAllow(t *testing.T) {
+	rl := NewIPRateLimiter(rate.Limit(2), 3)
+	for i := 0; i < 3; i++ {
+		decision := rl.Allow("192.168.1.1")
+		if !decision.Allowed || decision.Limit != 3 || decision.Remaining != 2-i {
+			t.Errorf("Expected request %d to be allowed with %d remaining, got %+v", i+1, 2-i, decision)
+		}
+	}
+
+	decision := rl.Allow("192.168.1.1")
+	if decision.Allowed || decision.Remaining != 0 {
+		t.Errorf("Expected the request past the burst to be denied, got %+v", decision)
+	}
+	if decision.RetryAfter <= 0 || decision.RetryAfter > 500*time.Millisecond {
+		t.Errorf("Expected to retry within 500ms, got %v", decision.RetryAfter)
+	}
+	if decision.Reset <= time.Second || decision.Reset > 1500*time.Millisecond {
+		t.Errorf("Expected the burst back within 1.5s, got %v", decision.Reset)
+	}
+}
+
+// TestWriteRateLimitHeaders tests the headers of rate limited responses.
+func TestWriteRateLimitHeaders(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteRateLimitHeaders(w, Decision{Allowed: true, Limit: 5, Remaining: 4, Reset: 400 * time.Millisecond})
+	if w.Header().Get("X-RateLimit-Limit") != "5" || w.Header().Get("X-RateLimit-Remaining") != "4" || w.Header().Get("X-RateLimit-Reset") != "1" {
+		t.Errorf("Expected the limit, remaining and reset headers, got %v", w.Header())
+	}
+	if w.Header().Get("Retry-After") != "" {
+		t.Errorf("Expected no Retry-After for allowed requests, got %s", w.Header().Get("Retry-After"))
+	}
+
+	w = httptest.NewRecorder()
+	WriteRateLimitHeaders(w, Decision{Limit: 5, Reset: 2500 * time.Millisecond, RetryAfter: 200 * time.Millisecond})
+	if w.Header().Get("Retry-After") != "1" || w.Header().Get("X-RateLimit-Reset") != "3" {
+		t.Errorf("Expected Retry-After 1 and reset 3, got %v", w.Header())
+	}
+}
+
+// TestStricter tests picking the decision leaving the client less room.
+func TestStricter(t *testing.T) {
+	allowed := Decision{Allowed: true, Limit: 10, Remaining: 5}
+	tests := []struct {
+		name string
+		a, b Decision
+		want Decision
+	}{
+		{"Denial", allowed, Decision{Limit: 2, RetryAfter: time.Second}, Decision{Limit: 2, RetryAfter: time.Second}},
+		{"Later retry", Decision{RetryAfter: time.Second}, Decision{RetryAfter: time.Minute}, Decision{RetryAfter: time.Minute}},
+		{"Fewer remaining", allowed, Decision{Allowed: true, Limit: 2, Remaining: 1}, Decision{Allowed: true, Limit: 2, Remaining: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Stricter(tt.a, tt.b); got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+			if got := Stricter(tt.b, tt.a); got != tt.want {
+				t.Errorf("Expected %+v in either order, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Evaler runs Lua scripts on Redis
+type Evaler interface {
+	Eval(script string, keys []string, args ...string) (interface{}, error)
+}
+
+// gcraScript implements the generic cell rate algorithm: the key holds the theoretical arrival
+// time of the next request in microseconds, on the clock of Redis so instances don't need to agree
+// on the time. ARGV[1] is the interval between requests and ARGV[2] the burst. It returns whether
+// the request is allowed, the microseconds until the next one would be, the requests remaining
+// and the microseconds until the burst is available again.
+const gcraScript = `
+redis.replicate_commands()
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local tat = tonumber(redis.call('GET', KEYS[1])) or now
+if tat < now then
+  tat = now
+end
+local allowAt = tat + interval - interval * burst
+if now < allowAt then
+  return {0, allowAt - now, 0, tat - now}
+end
+tat = tat + interval
+redis.call('SET', KEYS[1], string.format('%.0f', tat), 'PX', math.max(1, math.ceil((tat - now) / 1000)))
+return {1, 0, math.floor((interval * burst - (tat - now)) / interval), tat - now}
+`
+
+// quotaScript counts a request against a quota: KEYS[1] holds the requests counted, expiring at
+// ARGV[2] in Unix milliseconds, and ARGV[1] is the quota. Requests past the quota aren't counted.
+// It returns whether the request is within the quota and the requests counted.
+const quotaScript = `
+local used = tonumber(redis.call('GET', KEYS[1])) or 0
+if used >= tonumber(ARGV[1]) then
+  return {0, used}
+end
+used = redis.call('INCR', KEYS[1])
+redis.call('PEXPIREAT', KEYS[1], ARGV[2])
+return {1, used}
+`
+
+// AllowGCRA counts a request under key against a limit of r requests per second with a burst of
+// b kept in Redis, with the GCRA every RedisRateLimiter uses
+func AllowGCRA(redis Evaler, key string, r rate.Limit, b int) (Decision, error) {
+	interval := int64(math.Ceil(1e6 / float64(r)))
+	return allowGCRA(redis, key, strconv.FormatInt(interval, 10), strconv.Itoa(b), b)
+}
+
+func allowGCRA(redis Evaler, key, interval, burst string, limit int) (Decision, error) {
+	reply, err := redis.Eval(gcraScript, []string{key}, interval, burst)
+	if err != nil {
+		return Decision{}, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok || len(items) != 4 {
+		return Decision{}, fmt.Errorf("unexpected rate limit reply %v", reply)
+	}
+	allowed, _ := items[0].(int64)
+	retryAfter, _ := items[1].(int64)
+	remaining, _ := items[2].(int64)
+	reset, _ := items[3].(int64)
+	return Decision{
+		Allowed:    allowed == 1,
+		Limit:      limit,
+		Remaining:  int(remaining),
+		Reset:      time.Duration(reset) * time.Microsecond,
+		RetryAfter: time.Duration(retryAfter) * time.Microsecond,
+	}, nil
+}
+
+// UseRedisQuota counts a request under key against quota in Redis, the count expiring at
+// expiresAt. It returns whether the request is within the quota and the requests counted.
+func UseRedisQuota(redis Evaler, key string, quota int, expiresAt time.Time) (bool, int, error) {
+	reply, err := redis.Eval(quotaScript, []string{key}, strconv.Itoa(quota), strconv.FormatInt(expiresAt.UnixMilli(), 10))
+	if err != nil {
+		return false, 0, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok || len(items) != 2 {
+		return false, 0, fmt.Errorf("unexpected quota reply %v", reply)
+	}
+	allowed, _ := items[0].(int64)
+	used, _ := items[1].(int64)
+	return allowed == 1, int(used), nil
+}
+
+// RedisRateLimiter limits the rate of requests of every client in Redis, so instances behind a
+// load balancer share their limits. Requests are limited by a fallback limiter while Redis is
+// unreachable.
+type RedisRateLimiter struct {
+	redis    Evaler
+	prefix   string
+	interval string
+	burst    string
+	limit    int
+	fallback RateLimiter
+	// OnError is called with the errors of Redis, if set
+	OnError func(err error)
+}
+
+// NewRedisRateLimiter creates a limiter of r requests per second with a burst of b, whose state is
+// kept under keys starting with prefix
+func NewRedisRateLimiter(redis Evaler, prefix string, r rate.Limit, b int, fallback RateLimiter) *RedisRateLimiter {
+	interval := int64(math.Ceil(1e6 / float64(r)))
+	return &RedisRateLimiter{
+		redis:    redis,
+		prefix:   prefix,
+		interval: strconv.FormatInt(interval, 10),
+		burst:    strconv.Itoa(b),
+		limit:    b,
+		fallback: fallback,
+	}
+}
+
+// Allow decides whether a request of client is allowed now across every instance
+func (l *RedisRateLimiter) Allow(client string) Decision {
+	decision, err := allowGCRA(l.redis, l.prefix+client, l.interval, l.burst, l.limit)
+	if err == nil {
+		return decision
+	}
+	if l.OnError != nil {
+		l.OnError(err)
+	}
+	return l.fallback.Allow(client)
+}
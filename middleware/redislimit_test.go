@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeGCRA runs the GCRA of gcraScript in memory, on a clock moved by the test
+type fakeGCRA struct {
+	now   int64
+	tats  map[string]int64
+	keys  []string
+	err   error
+	calls int
+}
+
+func (f *fakeGCRA) Eval(script string, keys []string, args ...string) (interface{}, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.keys = append(f.keys, keys[0])
+	interval, _ := strconv.ParseInt(args[0], 10, 64)
+	burst, _ := strconv.ParseInt(args[1], 10, 64)
+	tat, ok := f.tats[keys[0]]
+	if !ok || tat < f.now {
+		tat = f.now
+	}
+	if allowAt := tat + interval - interval*burst; f.now < allowAt {
+		return []interface{}{int64(0), allowAt - f.now, int64(0), tat - f.now}, nil
+	}
+	tat += interval
+	f.tats[keys[0]] = tat
+	return []interface{}{int64(1), int64(0), (interval*burst - (tat - f.now)) / interval, tat - f.now}, nil
+}
+
+func TestRedisRateLimiter(t *testing.T) {
+	redis := &fakeGCRA{tats: map[string]int64{}}
+	limiter := NewRedisRateLimiter(redis, "ratelimit:ip:", 2, 3, NewIPRateLimiter(2, 3))
+
+	for i := 0; i < 3; i++ {
+		decision := limiter.Allow("203.0.113.7")
+		if !decision.Allowed || decision.Limit != 3 || decision.Remaining != 2-i {
+			t.Errorf("Expected request %d of the burst to be allowed with %d remaining, got %+v", i+1, 2-i, decision)
+		}
+	}
+	decision := limiter.Allow("203.0.113.7")
+	if decision.Allowed || decision.RetryAfter != 500*time.Millisecond || decision.Reset != 1500*time.Millisecond {
+		t.Errorf("Expected the request past the burst to be denied for 500ms, got %+v", decision)
+	}
+	if !limiter.Allow("203.0.113.8").Allowed {
+		t.Errorf("Expected other clients to have their own limit")
+	}
+
+	redis.now += 500000
+	if !limiter.Allow("203.0.113.7").Allowed {
+		t.Errorf("Expected a request to be allowed after the interval")
+	}
+	if redis.keys[0] != "ratelimit:ip:203.0.113.7" {
+		t.Errorf("Expected keys to be prefixed, got %s", redis.keys[0])
+	}
+}
+
+func TestRedisRateLimiterFallsBack(t *testing.T) {
+	redis := &fakeGCRA{err: errors.New("connection refused")}
+	limiter := NewRedisRateLimiter(redis, "ratelimit:ip:", 1, 1, NewIPRateLimiter(1, 1))
+	var reported error
+	limiter.OnError = func(err error) { reported = err }
+
+	if !limiter.Allow("203.0.113.7").Allowed || limiter.Allow("203.0.113.7").Allowed {
+		t.Errorf("Expected the fallback limiter to limit requests while Redis is down")
+	}
+	if reported == nil {
+		t.Errorf("Expected the error to be reported")
+	}
+}
+
+// fakeQuota runs quotaScript in memory
+type fakeQuota struct {
+	counts  map[string]int64
+	expires map[string]string
+}
+
+func (f *fakeQuota) Eval(script string, keys []string, args ...string) (interface{}, error) {
+	quota, _ := strconv.ParseInt(args[0], 10, 64)
+	if used := f.counts[keys[0]]; used >= quota {
+		return []interface{}{int64(0), used}, nil
+	}
+	f.counts[keys[0]]++
+	f.expires[keys[0]] = args[1]
+	return []interface{}{int64(1), f.counts[keys[0]]}, nil
+}
+
+func TestUseRedisQuota(t *testing.T) {
+	redis := &fakeQuota{counts: map[string]int64{}, expires: map[string]string{}}
+	midnight := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+
+	for i := 1; i <= 2; i++ {
+		if allowed, used, err := UseRedisQuota(redis, "quota:key:2024-05-01", 2, midnight); !allowed || used != i || err != nil {
+			t.Errorf("Expected request %d within the quota, got %v %d %v", i, allowed, used, err)
+		}
+	}
+	if allowed, used, _ := UseRedisQuota(redis, "quota:key:2024-05-01", 2, midnight); allowed || used != 2 {
+		t.Errorf("Expected the quota to be exceeded without counting, got %v %d", allowed, used)
+	}
+	if redis.expires["quota:key:2024-05-01"] != strconv.FormatInt(midnight.UnixMilli(), 10) {
+		t.Errorf("Expected the count to expire at midnight, got %s", redis.expires["quota:key:2024-05-01"])
+	}
+}
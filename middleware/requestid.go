@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+type contextKey string
+
+const (
+	requestIDKey   contextKey = "requestID"
+	traceparentKey contextKey = "traceparent"
+
+	// RequestIDHeader is the header used to accept and return request ids
+	RequestIDHeader = "X-Request-ID"
+	// TraceparentHeader is the W3C trace context header
+	TraceparentHeader = "traceparent"
+)
+
+var (
+	requestIDPattern   = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+	traceparentPattern = regexp.MustCompile(`^00-([0-9a-f]{32})-[0-9a-f]{16}-([0-9a-f]{2})$`)
+)
+
+// RequestIDMiddleware assigns every request an id, reusing a well-formed incoming X-Request-ID,
+// stores it in the request context and echoes it back in the response. When tracing is enabled
+// it also derives a W3C traceparent for this hop, continuing the caller's trace if one was sent.
+func RequestIDMiddleware(next http.Handler, tracing bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = randomHex(16)
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+
+		if tracing {
+			traceparent := childTraceparent(r.Header.Get(TraceparentHeader))
+			w.Header().Set(TraceparentHeader, traceparent)
+			ctx = context.WithValue(ctx, traceparentKey, traceparent)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestIDFromContext returns the request id assigned by RequestIDMiddleware, or ""
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// TraceparentFromContext returns the traceparent for this hop, or "" when tracing is disabled
+func TraceparentFromContext(ctx context.Context) string {
+	traceparent, _ := ctx.Value(traceparentKey).(string)
+	return traceparent
+}
+
+// childTraceparent continues the trace of a valid parent traceparent with a new span id,
+// or starts a new sampled trace
+func childTraceparent(parent string) string {
+	if match := traceparentPattern.FindStringSubmatch(parent); match != nil {
+		return "00-" + match[1] + "-" + randomHex(8) + "-" + match[2]
+	}
+	return "00-" + randomHex(16) + "-" + randomHex(8) + "-01"
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
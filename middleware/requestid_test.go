@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestRequestIDGenerated tests that a request id is generated when none is sent.
+func TestRequestIDGenerated(t *testing.T) {
+	var fromContext string
+	handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fromContext = RequestIDFromContext(r.Context())
+	}), false)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if fromContext == "" {
+		t.Errorf("Expected request id in context, got empty string")
+	}
+	if rec.Header().Get(RequestIDHeader) != fromContext {
+		t.Errorf("Expected response header %q, got %q", fromContext, rec.Header().Get(RequestIDHeader))
+	}
+}
+
+// TestRequestIDReused tests that a valid incoming request id is kept and an invalid one replaced.
+func TestRequestIDReused(t *testing.T) {
+	handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), false)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get(RequestIDHeader); got != "abc-123" {
+		t.Errorf("Expected request id abc-123, got %q", got)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "bad id\nwith newline")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get(RequestIDHeader); got == "bad id\nwith newline" {
+		t.Errorf("Expected invalid request id to be replaced")
+	}
+}
+
+// TestTraceparentContinuesTrace tests that the incoming trace id is kept with a new span id.
+func TestTraceparentContinuesTrace(t *testing.T) {
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	var traceparent string
+	handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = TraceparentFromContext(r.Context())
+	}), true)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(TraceparentHeader, parent)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !strings.HasPrefix(traceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-") {
+		t.Errorf("Expected trace id to be continued, got %q", traceparent)
+	}
+	if traceparent == parent {
+		t.Errorf("Expected a new span id, got the parent traceparent")
+	}
+}
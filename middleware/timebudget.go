@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	budgetKey contextKey = "timeBudget"
+
+	// TimeBudgetHeader lets latency-sensitive clients state how long they are willing to wait, in milliseconds
+	TimeBudgetHeader = "X-Time-Budget-Ms"
+	// SkippedFeaturesHeader lists the optional features skipped to stay within the time budget
+	SkippedFeaturesHeader = "X-Skipped-Features"
+)
+
+type timeBudget struct {
+	deadline time.Time
+	mu       sync.Mutex
+	skipped  []string
+}
+
+// TimeBudgetMiddleware stores the deadline derived from a valid X-Time-Budget-Ms header in the request
+// context. The budget only informs which optional features are worth running; it never cancels the request.
+func TimeBudgetMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ms, err := strconv.Atoi(r.Header.Get(TimeBudgetHeader))
+		if err != nil || ms <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		budget := &timeBudget{deadline: time.Now().Add(time.Duration(ms) * time.Millisecond)}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), budgetKey, budget)))
+	})
+}
+
+// BudgetRemaining returns the time left of the request's budget. ok is false when the client sent no budget.
+func BudgetRemaining(ctx context.Context) (remaining time.Duration, ok bool) {
+	budget, ok := ctx.Value(budgetKey).(*timeBudget)
+	if !ok {
+		return 0, false
+	}
+	return time.Until(budget.deadline), true
+}
+
+// SkipFeature records that feature was skipped to stay within the request's budget
+func SkipFeature(ctx context.Context, feature string) {
+	budget, ok := ctx.Value(budgetKey).(*timeBudget)
+	if !ok {
+		return
+	}
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+	for _, skipped := range budget.skipped {
+		if skipped == feature {
+			return
+		}
+	}
+	budget.skipped = append(budget.skipped, feature)
+}
+
+// SkippedFeatures returns the features skipped so far for the request
+func SkippedFeatures(ctx context.Context) []string {
+	budget, ok := ctx.Value(budgetKey).(*timeBudget)
+	if !ok {
+		return nil
+	}
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+	return append([]string(nil), budget.skipped...)
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestTimeBudgetMiddleware tests that the budget header is parsed into the request context.
+func TestTimeBudgetMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		hasBudget bool
+	}{
+		{"no header", "", false},
+		{"valid budget", "500", true},
+		{"negative budget", "-5", false},
+		{"invalid budget", "soon", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var remaining time.Duration
+			var ok bool
+			handler := TimeBudgetMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				remaining, ok = BudgetRemaining(r.Context())
+			}))
+
+			req := httptest.NewRequest("GET", "/getLyrics", nil)
+			if tt.header != "" {
+				req.Header.Set(TimeBudgetHeader, tt.header)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if ok != tt.hasBudget {
+				t.Errorf("Expected budget %v, got %v", tt.hasBudget, ok)
+			}
+			if ok && (remaining <= 0 || remaining > 500*time.Millisecond) {
+				t.Errorf("Expected remaining budget within 500ms, got %s", remaining)
+			}
+		})
+	}
+}
+
+// TestSkipFeature tests that skipped features are recorded once and only when a budget was sent.
+func TestSkipFeature(t *testing.T) {
+	var skipped []string
+	handler := TimeBudgetMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SkipFeature(r.Context(), "translation")
+		SkipFeature(r.Context(), "translation")
+		SkipFeature(r.Context(), "romanization")
+		skipped = SkippedFeatures(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/getLyrics", nil)
+	req.Header.Set(TimeBudgetHeader, "100")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(skipped) != 2 || skipped[0] != "translation" || skipped[1] != "romanization" {
+		t.Errorf("Expected [translation romanization], got %v", skipped)
+	}
+
+	req = httptest.NewRequest("GET", "/getLyrics", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if len(skipped) != 0 {
+		t.Errorf("Expected no skipped features without a budget, got %v", skipped)
+	}
+}
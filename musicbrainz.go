@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"lyrics-api-go/internal/provider"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// canonicalizeTrack looks up the song and artist on MusicBrainz and returns the canonical
+// title and artist names. ok is false when no sufficiently confident match was found.
+func canonicalizeTrack(ctx context.Context, songName, artistName string) (provider.CanonicalTrack, bool) {
+	cacheKey := fmt.Sprintf("mb:%s", url.QueryEscape(songName+" "+artistName))
+	if cached, ok := getCache(cacheKey); ok {
+		log.Infof("[Cache:MusicBrainz] Found cached canonical track")
+		var track provider.CanonicalTrack
+		if err := json.Unmarshal([]byte(cached), &track); err == nil {
+			return track, track.Song != ""
+		}
+	}
+
+	track, err := musicBrainzClient.Canonicalize(ctx, songName, artistName)
+	if err != nil {
+		log.Errorf("[MusicBrainz] Error canonicalizing track: %v", err)
+		return provider.CanonicalTrack{}, false
+	}
+
+	// negative results are cached too so unknown tracks don't hit MusicBrainz on every request
+	cacheValue, _ := json.Marshal(track)
+	log.Warn("[Cache:MusicBrainz] Caching canonical track")
+	setCache(cacheKey, string(cacheValue), time.Duration(conf.Configuration.MusicBrainzCacheTTLInSeconds)*time.Second)
+
+	return track, track.Song != ""
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"lyrics-api-go/internal/offsets"
+	"lyrics-api-go/utils"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// communityOffsets holds the timing offsets submitted by listeners, keyed by canonical track id so
+// duplicates of a track share them
+var communityOffsets = offsets.NewStore(3, 250, 0)
+
+// trackIDPattern matches the track ids offsets are kept for, bounding what clients can fill the
+// store with
+var trackIDPattern = regexp.MustCompile(`^[A-Za-z0-9:._-]{1,64}$`)
+
+// OffsetSubmission is the body of POST /offsets. A positive offset delays the lyrics.
+type OffsetSubmission struct {
+	TrackID  string `json:"trackId"`
+	OffsetMs int64  `json:"offsetMs"`
+}
+
+// loadCommunityOffsets sets up communityOffsets and restores the submissions persisted in path
+func loadCommunityOffsets(path string) {
+	communityOffsets = offsets.NewStore(conf.Configuration.CommunityOffsetMinAgreeing, conf.Configuration.CommunityOffsetToleranceMs, conf.Configuration.CommunityOffsetMaxTracks)
+
+	body, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Errorf("[Offsets] Error reading submissions from %s: %v", path, err)
+		return
+	}
+	var snapshot map[string][]offsets.Submission
+	if err := json.Unmarshal(body, &snapshot); err != nil {
+		log.Errorf("[Offsets] Error parsing submissions from %s: %v", path, err)
+		return
+	}
+	communityOffsets.Restore(snapshot)
+	log.Infof("[Offsets] Restored submissions of %d tracks from %s", len(snapshot), path)
+}
+
+// offsetsSaveDelay is how long submissions are gathered before being persisted in a single write
+const offsetsSaveDelay = 5 * time.Second
+
+var (
+	// offsetsSaveMu serializes the writes of the submissions file
+	offsetsSaveMu sync.Mutex
+	// offsetsSavePending is set while a write of the submissions is scheduled
+	offsetsSavePending atomic.Bool
+)
+
+// scheduleOffsetsSave persists the submissions to path offsetsSaveDelay from now, along with those
+// submitted in the meantime
+func scheduleOffsetsSave(path string) {
+	if !offsetsSavePending.CompareAndSwap(false, true) {
+		return
+	}
+	time.AfterFunc(offsetsSaveDelay, func() {
+		if err := flushCommunityOffsets(path); err != nil {
+			log.Errorf("[Offsets] Error persisting submissions to %s: %v", path, err)
+		}
+	})
+}
+
+// flushCommunityOffsets persists the submissions to path if a write is pending, e.g. on shutdown
+func flushCommunityOffsets(path string) error {
+	if !offsetsSavePending.Swap(false) {
+		return nil
+	}
+	return saveCommunityOffsets(path)
+}
+
+// saveCommunityOffsets replaces the submissions persisted in path
+func saveCommunityOffsets(path string) error {
+	offsetsSaveMu.Lock()
+	defer offsetsSaveMu.Unlock()
+
+	body, err := json.Marshal(communityOffsets.Snapshot())
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(path, body, 0644)
+}
+
+// submitterID pseudonymizes the client making r, so persisted submissions hold no IP addresses or keys
+func submitterID(r *http.Request) string {
+	sum := sha256.Sum256([]byte(clientSubject(r)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// getOffset returns the community offset of ?trackId=
+func getOffset(w http.ResponseWriter, r *http.Request) {
+	trackID := r.URL.Query().Get("trackId")
+	if trackID == "" {
+		http.Error(w, "trackId is required", http.StatusBadRequest)
+		return
+	}
+	if !trackIDPattern.MatchString(trackID) {
+		http.Error(w, "trackId is invalid", http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(communityOffsets.Consensus(canonicalTrackID(trackID)))
+}
+
+// submitOffset records the offset a listener found to line up the lyrics of a track
+func submitOffset(w http.ResponseWriter, r *http.Request) {
+	var submission OffsetSubmission
+	if err := json.NewDecoder(r.Body).Decode(&submission); err != nil {
+		http.Error(w, "Invalid offset submission", http.StatusBadRequest)
+		return
+	}
+	if submission.TrackID == "" {
+		http.Error(w, "trackId is required", http.StatusBadRequest)
+		return
+	}
+	if !trackIDPattern.MatchString(submission.TrackID) {
+		http.Error(w, "trackId is invalid", http.StatusUnprocessableEntity)
+		return
+	}
+	if maxOffset := conf.Configuration.CommunityOffsetMaxMs; submission.OffsetMs < -maxOffset || submission.OffsetMs > maxOffset {
+		http.Error(w, "offsetMs is out of range", http.StatusUnprocessableEntity)
+		return
+	}
+
+	consensus := communityOffsets.Submit(canonicalTrackID(submission.TrackID), offsets.Submission{
+		Submitter:   submitterID(r),
+		OffsetMs:    submission.OffsetMs,
+		SubmittedAt: time.Now(),
+	})
+	scheduleOffsetsSave(conf.Configuration.CommunityOffsetsFile)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(consensus)
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"lyrics-api-go/internal/offsets"
+	"lyrics-api-go/internal/pipeline"
+	"lyrics-api-go/internal/usage"
+	"lyrics-api-go/lyrics"
+	"lyrics-api-go/middleware"
+	"lyrics-api-go/utils"
+)
+
+// A lyrics request runs through these stages in order: the song is resolved to a track, its
+// lyrics are fetched, normalized for the requested timing and layout, enriched with optional
+// annotations and encoded in the requested format.
+const (
+	stageResolve   = "resolve"
+	stageFetch     = "fetch"
+	stageNormalize = "normalize"
+	stageEnrich    = "enrich"
+	stageEncode    = "encode"
+)
+
+var pipelineMetrics = pipeline.NewMetrics()
+
+// stageError is a failure of a stage caused by the request, answered with its status
+type stageError struct {
+	status  int
+	message string
+}
+
+func (e *stageError) Error() string {
+	return e.message
+}
+
+func requestError(status int, message string) error {
+	return &stageError{status: status, message: message}
+}
+
+// runStage runs a stage with its timeout from PIPELINE_STAGE_TIMEOUTS_IN_MS, if any
+func runStage(ctx context.Context, stage string, fn pipeline.StageFunc) error {
+	timeout := time.Duration(conf.Configuration.PipelineStageTimeoutsInMs[stage]) * time.Millisecond
+	return pipelineMetrics.Run(ctx, stage, timeout, fn)
+}
+
+// writeStageError answers a failed stage: with the status of request errors, 504 when the stage
+// timed out, 503 when every lyrics provider is disabled and 500 otherwise
+func writeStageError(w http.ResponseWriter, stage string, err error) {
+	var requestErr *stageError
+	switch {
+	case errors.As(err, &requestErr):
+		http.Error(w, requestErr.message, requestErr.status)
+	case errors.Is(err, context.DeadlineExceeded):
+		http.Error(w, fmt.Sprintf("Timed out in the %s stage", stage), http.StatusGatewayTimeout)
+	case errors.Is(err, errNoLyricsProviders):
+		http.Error(w, "No lyrics provider is enabled", http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// resolvedTrack is the track a lyrics request resolved to
+type resolvedTrack struct {
+	trackID    string
+	songName   string
+	artistName string
+	// custom is set when the client passed the track id itself
+	custom bool
+}
+
+// resolveRequestTrack is the resolve stage: it reads the song, artists or track id of the request
+// and searches for the song when no track id was given
+func resolveRequestTrack(ctx context.Context, r *http.Request) (resolvedTrack, error) {
+	query := r.URL.Query()
+	resolved := resolvedTrack{songName: query.Get("s") + query.Get("song") + query.Get("songName")}
+	var artistValues []string
+	for _, param := range []string{"a", "artist", "artistName"} {
+		artistValues = append(artistValues, query[param]...)
+	}
+	resolved.artistName = strings.Join(utils.SplitArtists(artistValues...), ", ")
+
+	if customTrackID := query.Get("t_id") + query.Get("trackId"); customTrackID != "" {
+		resolved.trackID, resolved.custom = customTrackID, true
+		return resolved, nil
+	}
+	if resolved.songName == "" && resolved.artistName == "" {
+		return resolved, requestError(http.StatusUnprocessableEntity, "Song name or artist name not provided")
+	}
+
+	trackID, err := resolveTrackID(ctx, resolved.songName, resolved.artistName)
+	if err != nil {
+		return resolved, err
+	}
+	queryArchive.record(resolved.songName, resolved.artistName, trackID)
+	if trackID == "" {
+		return resolved, requestError(http.StatusNotFound, "Track not found")
+	}
+	resolved.trackID = trackID
+	return resolved, nil
+}
+
+// fetchStage is the fetch stage, getting the lyrics of a track from the cache or the providers.
+// With refresh the cache is bypassed and the lyrics fetched are written over the cached ones.
+func fetchStage(ctx context.Context, trackID string, refresh bool) (data CachedLyrics, found bool, err error) {
+	err = runStage(ctx, stageFetch, func(ctx context.Context) error {
+		if refresh {
+			// linked re-releases are served the entry of their canonical track, so that is the one fixed
+			canonicalID := canonicalTrackID(trackID)
+			if data, found, err = refreshLyrics(ctx, canonicalID); found {
+				purgeCDNInBackground(trackSurrogateKey(canonicalID))
+			}
+			return err
+		}
+		data, found, err = getLyricsForTrack(ctx, trackID)
+		return err
+	})
+	return data, found, err
+}
+
+// forceRefresh reports whether r asks to bypass and overwrite the cached lyrics with ?refresh=1,
+// which requires the admin:cache scope
+func forceRefresh(r *http.Request) (bool, error) {
+	if !isTruthy(r.URL.Query().Get("refresh")) {
+		return false, nil
+	}
+	if err := scopeError(r, scopeAdminCache); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// lyricsResponse is the state of a lyrics request passed between the stages after fetching
+type lyricsResponse struct {
+	trackID         string
+	data            CachedLyrics
+	format          string
+	isInstrumental  bool
+	communityOffset offsets.Consensus
+	annotations     lineAnnotations
+	sections        []lyrics.Section
+	direction       string
+	// bodyKey is the responseBodies key the JSON body is kept under, if it is a plain response
+	bodyKey string
+}
+
+// writeLyrics runs the normalize, enrich and encode stages on fetched lyrics, rendering them in the
+// format requested with ?format=, defaulting to JSON
+func writeLyrics(w http.ResponseWriter, r *http.Request, trackID string, data CachedLyrics) {
+	writeLyricsResponse(w, r, &lyricsResponse{trackID: trackID, data: data, format: r.URL.Query().Get("format")})
+}
+
+func writeLyricsResponse(w http.ResponseWriter, r *http.Request, response *lyricsResponse) {
+	// only tracks with lyrics count, so requests for made up track ids can't grow the counter
+	popularTracks.Record(response.trackID)
+	stages := []struct {
+		name string
+		run  pipeline.StageFunc
+	}{
+		{stageNormalize, func(ctx context.Context) error { return normalizeLyrics(r, response) }},
+		{stageEnrich, func(ctx context.Context) error { return enrichLyrics(ctx, r, response) }},
+		{stageEncode, func(ctx context.Context) error { return encodeLyrics(w, r, response) }},
+	}
+	for _, stage := range stages {
+		if err := runStage(r.Context(), stage.name, stage.run); err != nil {
+			writeStageError(w, stage.name, err)
+			return
+		}
+	}
+	if cacheStatus(response.data) == "HIT" {
+		recordUsage(r.Context(), usage.CacheHit)
+	}
+}
+
+// normalizeLyrics is the normalize stage, applying the timing and layout options of the request
+func normalizeLyrics(r *http.Request, response *lyricsResponse) error {
+	query := r.URL.Query()
+	data := &response.data
+	response.isInstrumental = data.UnsyncedLyrics == "" && lyrics.IsInstrumental(data.Lyrics)
+	estimated, err := estimateSync(r, response.trackID, *data)
+	if err != nil {
+		return err
+	}
+	*data = estimated
+	data.Lyrics = trimSilence(r, response.trackID, data.Lyrics)
+	data.Lyrics = lyrics.ParseVoices(data.Lyrics)
+	if !rolloutEnabled(r, rolloutWordSync) {
+		data.Lyrics = lyrics.StripWordTimings(data.Lyrics)
+	}
+
+	switch query.Get("backingVocals") {
+	case "":
+	case "strip":
+		data.Lyrics = lyrics.StripBackingVocals(data.Lyrics, false)
+	case "separate":
+		data.Lyrics = lyrics.StripBackingVocals(data.Lyrics, true)
+	default:
+		return requestError(http.StatusBadRequest, "Unsupported backingVocals mode")
+	}
+
+	if isTruthy(query.Get("normalize")) {
+		data.Lyrics = lyrics.Normalize(data.Lyrics)
+	}
+
+	if maxLineLength, err := strconv.Atoi(query.Get("maxLineLength")); err == nil && maxLineLength > 0 {
+		data.Lyrics = lyrics.Rechunk(data.Lyrics, maxLineLength)
+	}
+
+	if isTruthy(query.Get("interludes")) {
+		data.Lyrics = lyrics.MarkInterludes(data.Lyrics, conf.Configuration.InterludeMinGapMs)
+	}
+
+	// the community offset corrects the timing at the source, so it is applied in track time
+	if value := query.Get("communityOffset"); value == "" || isTruthy(value) {
+		if response.communityOffset = communityOffsets.Consensus(canonicalTrackID(response.trackID)); response.communityOffset.Applied {
+			data.Lyrics = lyrics.Shift(data.Lyrics, response.communityOffset.OffsetMs)
+		}
+	}
+
+	// the rate is applied first so offsets are in the listener's playback time
+	if value := query.Get("rate"); value != "" {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || !(rate >= conf.Configuration.MinPlaybackRate && rate <= conf.Configuration.MaxPlaybackRate) {
+			return requestError(http.StatusBadRequest, "Invalid rate")
+		}
+		data.Lyrics = lyrics.Scale(data.Lyrics, rate)
+	}
+	if value := query.Get("offsetMs"); value != "" {
+		offsetMs, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return requestError(http.StatusBadRequest, "Invalid offsetMs")
+		}
+		data.Lyrics = lyrics.Shift(data.Lyrics, offsetMs)
+	}
+	return nil
+}
+
+// enrichLyrics is the enrich stage, adding the sections, translations, romanizations, furigana and
+// transliterations asked for. JSON pairs annotations with each line; SubRip, WebVTT and plain text
+// stack them below the words, and the other formats have no room for them.
+func enrichLyrics(ctx context.Context, r *http.Request, response *lyricsResponse) error {
+	format := response.format
+	if format != "" && format != "json" && format != "srt" && format != "vtt" && format != "txt" {
+		return nil
+	}
+
+	query := r.URL.Query()
+	data, annotations := response.data, &response.annotations
+	if isTruthy(query.Get("sections")) && (format == "" || format == "json") {
+		response.sections = lyrics.Sections(data.Lyrics)
+	}
+
+	var err error
+	if annotations.translations, err = lyricsTranslations(ctx, r, response.trackID, data.Lyrics); err != nil {
+		return err
+	}
+	// transliterations are a superset of romanizations, so a single romanization is returned for both
+	if isTruthy(query.Get("transliterate")) && withinBudget(ctx, featureTransliteration) {
+		annotations.romanizations = transliterateLines(data.Lyrics, data.Language)
+	} else if isTruthy(query.Get("romanize")) && withinBudget(ctx, featureRomanization) {
+		annotations.romanizations = romanizeLines(response.trackID, data.Lyrics, data.Language)
+	}
+	if isTruthy(query.Get("furigana")) && (format == "" || format == "json") && withinBudget(ctx, featureFurigana) {
+		annotations.furigana = furiganaLines(data.Lyrics, data.Language)
+	}
+	return nil
+}
+
+// encodeLyrics is the encode stage, writing the response in the requested format
+func encodeLyrics(w http.ResponseWriter, r *http.Request, response *lyricsResponse) error {
+	query := r.URL.Query()
+	data, annotations, format := response.data, response.annotations, response.format
+
+	data.Lyrics, response.direction = lyrics.Directions(data.Lyrics)
+
+	// marks are added after translating so they don't end up in the text sent to the backend
+	if (data.IsRtlLanguage || response.direction != lyrics.DirLTR) && isTruthy(query.Get("rtlMarks")) {
+		data.Lyrics = lyrics.EmbedRTL(data.Lyrics)
+	}
+
+	if format == "srt" || format == "vtt" || format == "txt" {
+		data.Lyrics = lyrics.Stack(data.Lyrics, annotations.translations, annotations.romanizations)
+	}
+
+	skipped := middleware.SkippedFeatures(r.Context())
+	if len(skipped) > 0 {
+		w.Header().Set(middleware.SkippedFeaturesHeader, strings.Join(skipped, ","))
+	}
+	// the stale marker of the JSON body, for every format
+	if data.Stale {
+		w.Header().Set("Warning", `110 - "Response is Stale"`)
+	}
+
+	// without timing, unsynced lyrics can only be rendered as JSON or plain text
+	if data.UnsyncedLyrics != "" && format != "" && format != "json" {
+		if format != "txt" {
+			return requestError(http.StatusNotFound, "Only unsynced lyrics are available for this track")
+		}
+		setCacheControl(w, r, data.Stale)
+		setLastModified(w, lyricsLastModified(response.trackID, data))
+		setSurrogateKeys(w, response.trackID, data.Provider)
+		setCacheStatus(w, cacheStatus(data), data.FetchedAt)
+		writeLyricsBody(w, r, "text/plain; charset=utf-8", []byte(data.UnsyncedLyrics+"\n"))
+		return nil
+	}
+
+	setCacheControl(w, r, data.Stale)
+	lastModified := lyricsLastModified(response.trackID, data)
+	setLastModified(w, lastModified)
+	setSurrogateKeys(w, response.trackID, data.Provider)
+	setCacheStatus(w, cacheStatus(data), data.FetchedAt)
+	switch format {
+	case "", "json":
+		compact := isTruthy(query.Get("compact"))
+		var lines interface{} = data.Lyrics
+		if apiVersion(r) >= apiV2 && !compact {
+			lines = v2Lines(data.Lyrics, annotations)
+		} else if !annotations.empty() {
+			lines = annotateLines(data.Lyrics, annotations, compact)
+		} else if compact {
+			lines = lyrics.Compact(data.Lyrics)
+		}
+		body := map[string]interface{}{
+			"trackId":        response.trackID,
+			"lyrics":         lines,
+			"isRtlLanguage":  data.IsRtlLanguage,
+			"direction":      response.direction,
+			"isInstrumental": response.isInstrumental,
+			"language":       data.Language,
+		}
+		if annotations.translations != nil {
+			body["translationLanguage"] = query.Get("translate")
+		}
+		if apiVersion(r) == apiV1 {
+			body["error"] = nil
+		}
+		if data.SyncType != "" {
+			body["syncType"] = data.SyncType
+		}
+		if response.sections != nil {
+			body["sections"] = response.sections
+		}
+		if response.communityOffset.Applied {
+			body["communityOffsetMs"] = response.communityOffset.OffsetMs
+		}
+		if canonicalID := canonicalTrackID(response.trackID); canonicalID != response.trackID {
+			body["canonicalTrackId"] = canonicalID
+		}
+		if data.UnsyncedLyrics != "" {
+			body["unsyncedLyrics"] = data.UnsyncedLyrics
+		}
+		if data.Stale {
+			body["stale"] = true
+		}
+		if len(skipped) > 0 {
+			body["skippedFeatures"] = skipped
+		}
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		encoded = append(encoded, '\n')
+		// the body is only kept if the lyrics it was built from are still the ones in the cache
+		if response.bodyKey != "" && !data.Stale && len(skipped) == 0 {
+			if key, ok := responseBodyKey(r, response.trackID); ok && key == response.bodyKey {
+				storeResponseBody(key, storedResponseBody{
+					body:         encoded,
+					lastModified: lastModified,
+					fetchedAt:    data.FetchedAt,
+					staleAt:      data.StaleAt,
+					provider:     data.Provider,
+				})
+			}
+		}
+		writeLyricsBody(w, r, "application/json", encoded)
+	case "elrc":
+		writeLyricsBody(w, r, "text/plain; charset=utf-8", []byte(lyrics.FormatELRC(data.Lyrics)))
+	case "lrc":
+		writeLyricsBody(w, r, "text/plain; charset=utf-8", []byte(lyrics.FormatLRC(data.Lyrics)))
+	case "srt":
+		writeLyricsBody(w, r, "application/x-subrip; charset=utf-8", []byte(lyrics.FormatSRT(data.Lyrics)))
+	case "vtt":
+		writeLyricsBody(w, r, "text/vtt; charset=utf-8", []byte(lyrics.FormatVTT(data.Lyrics)))
+	case "ttml":
+		writeLyricsBody(w, r, "application/ttml+xml; charset=utf-8", []byte(lyrics.FormatTTML(data.Lyrics, data.Language)))
+	case "txt":
+		writeLyricsBody(w, r, "text/plain; charset=utf-8", []byte(lyrics.FormatText(data.Lyrics)))
+	default:
+		clearCacheHeaders(w)
+		return requestError(http.StatusBadRequest, "Unsupported format")
+	}
+	return nil
+}
+
+// getPipelineStats returns the run count, failures, timeouts and latency of every stage
+func getPipelineStats(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminJobs) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pipelineMetrics.Snapshot())
+}
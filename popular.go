@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lyrics-api-go/internal/popularity"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// popularityBuckets is the number of slices the popularity window is split into, the precision
+// requests fall out of it with
+const popularityBuckets = 24
+
+// popularTracks counts the lyrics requests of every resolved track over POPULARITY_WINDOW_IN_HOURS
+var popularTracks = popularity.NewCounter(24*time.Hour, popularityBuckets)
+
+// setupPopularity sets up popularTracks with the configured window
+func setupPopularity() {
+	popularTracks = popularity.NewCounter(time.Duration(max(conf.Configuration.PopularityWindowInHours, 1))*time.Hour, popularityBuckets)
+}
+
+// maxTopTracks bounds the limit of GET /top
+const maxTopTracks = 1000
+
+// TopTracks is the response of GET /top
+type TopTracks struct {
+	WindowInHours int                     `json:"windowInHours"`
+	Tracks        []popularity.TrackCount `json:"tracks"`
+}
+
+// getTopTracks returns the most requested tracks of the popularity window, ?limit= of them
+func getTopTracks(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminJobs) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	limit := 50
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 || limit > maxTopTracks {
+			http.Error(w, fmt.Sprintf("Invalid limit, it must be between 1 and %d", maxTopTracks), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TopTracks{
+		WindowInHours: int(popularTracks.Window() / time.Hour),
+		Tracks:        popularTracks.Top(limit),
+	})
+}
+
+// refreshPopularLyrics is the popularRefresh job: it fetches the lyrics of the POPULAR_REFRESH_TOP_N
+// most requested tracks of the popularity window again when their cache entry expires within POPULAR_REFRESH_AHEAD_IN_SECONDS,
+// so hot tracks never go cold while the long tail expires as usual
+func refreshPopularLyrics(ctx context.Context) error {
+	ahead := time.Duration(conf.Configuration.PopularRefreshAheadInSeconds) * time.Second
+	refreshed, failed := 0, 0
+	seen := map[string]bool{}
+	for _, track := range popularTracks.Top(conf.Configuration.PopularRefreshTopN) {
+		// linked re-releases share the entry of their canonical track
+		trackID := canonicalTrackID(track.TrackID)
+		if seen[trackID] {
+			continue
+		}
+		seen[trackID] = true
+
+		// tracks without cached lyrics are fetched by their next request
+		expiresAt, ok := lyricsExpiration(trackID)
+		if !ok || time.Until(expiresAt) > ahead {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, _, err := refreshLyrics(ctx, trackID); err != nil {
+			log.Warnf("[Refresh] Error refreshing %s: %v", trackID, err)
+			failed++
+			continue
+		}
+		refreshed++
+	}
+
+	log.Infof("[Refresh] Refreshed %d popular tracks, %d failed", refreshed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d popular tracks failed to refresh", failed, refreshed+failed)
+	}
+	return nil
+}
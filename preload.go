@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"lyrics-api-go/internal/cache"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// cacheDumpSchemaVersion is the layout of dumps served by GET /cache
+const cacheDumpSchemaVersion = cache.DumpSchemaVersion
+
+// QuarantinedEntry is a dump entry that failed verification, kept for inspection
+type QuarantinedEntry struct {
+	Key    string      `json:"key"`
+	Entry  cache.Entry `json:"entry"`
+	Reason string      `json:"reason"`
+}
+
+// cacheEntryChecksum is the checksum of an entry in a cache dump
+func cacheEntryChecksum(key string, entry cache.Entry) string {
+	return cache.EntryChecksum(key, entry)
+}
+
+// preloadCache ingests a dump previously exported from GET /cache, so a new instance starts with the
+// warm cache of the one it replaces. Expired entries and provider tokens are skipped. Entries
+// failing verification (a checksum mismatch, an unsupported schema version, or a value that can't be
+// decoded with the current cache settings) are written to <path>.quarantine.json instead of failing
+// the startup. Dumps may be gzip-compressed, like cache snapshots.
+func preloadCache(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return loadCacheDump(file, path, path+".quarantine.json")
+}
+
+// loadCacheDump ingests a cache dump read from source, gzip-compressed or not, writing entries failing
+// verification to quarantinePath
+func loadCacheDump(r io.Reader, source, quarantinePath string) error {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("invalid cache dump: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	dump, err := cache.ReadDump(r)
+	if err != nil {
+		return fmt.Errorf("invalid cache dump: %w", err)
+	}
+	if dump.SchemaVersion == 0 {
+		log.Warnf("[Cache:Preload] %s has no schema version or checksums, loading it unverified", source)
+	}
+	if version := dump.ValuesVersion(); version != cacheKeySchemaVersion {
+		// values in another format would only fail to decode, or decode wrong
+		log.Warnf("[Cache:Preload] Skipping %s, its values are of key schema version %d instead of %d", source, version, cacheKeySchemaVersion)
+		return nil
+	}
+
+	now := time.Now()
+	maxTTL := time.Duration(maxCacheTTLInSeconds()) * time.Second
+	loaded, skipped := 0, 0
+	var quarantined []QuarantinedEntry
+	for key, entry := range dump.Cache {
+		ttl := time.Unix(0, entry.Expiration).Sub(now)
+		if key == "" || tokenCacheKey(key) || ttl <= 0 {
+			skipped++
+			continue
+		}
+		if reason := verifyDumpEntry(dump, key, entry); reason != "" {
+			quarantined = append(quarantined, QuarantinedEntry{Key: key, Entry: entry, Reason: reason})
+			continue
+		}
+		// never trust a dump to keep entries around longer than this instance would have
+		if ttl > maxTTL {
+			ttl = maxTTL
+		}
+		storeCacheEntry(key, entry.Value, ttl)
+		loaded++
+	}
+
+	log.Infof("[Cache:Preload] Loaded %d entries from %s, skipped %d expired, quarantined %d", loaded, source, skipped, len(quarantined))
+	if len(quarantined) > 0 {
+		body, _ := json.MarshalIndent(quarantined, "", "  ")
+		if err := os.WriteFile(quarantinePath, body, 0644); err != nil {
+			log.Errorf("[Cache:Preload] Error writing quarantined entries to %s: %v", quarantinePath, err)
+		} else {
+			log.Warnf("[Cache:Preload] Wrote %d quarantined entries to %s", len(quarantined), quarantinePath)
+		}
+	}
+	return nil
+}
+
+// verifyDumpEntry returns why an entry of dump can't be loaded, or "" if it can
+func verifyDumpEntry(dump CacheDumpResponse, key string, entry cache.Entry) string {
+	if dump.SchemaVersion > cacheDumpSchemaVersion {
+		return fmt.Sprintf("unsupported schema version %d", dump.SchemaVersion)
+	}
+	if dump.SchemaVersion > 0 {
+		checksum, ok := dump.Checksums[key]
+		if !ok {
+			return "missing checksum"
+		}
+		if checksum != dump.Checksum(key, entry) {
+			return "checksum mismatch"
+		}
+	}
+	if !validPreloadValue(key, entry.Value) {
+		return "undecodable value"
+	}
+	return ""
+}
+
+// validPreloadValue checks the value decodes with the current compression setting and, for
+// lyrics entries, is a valid CachedLyrics
+func validPreloadValue(key string, value []byte) bool {
+	decoded, err := decodeCacheValue(value)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(key, "lyrics:") {
+		var cached CachedLyrics
+		return json.Unmarshal([]byte(decoded), &cached) == nil
+	}
+	return true
+}
+
+func maxCacheTTLInSeconds() int {
+	// lyrics entries are kept past their TTL for outages
+	maxTTL := max(int(lyricsTTLPolicy.MaxTTL()/time.Second), conf.Configuration.LyricsCacheTTLInSeconds) +
+		max(conf.Configuration.StaleLyricsTTLInSeconds, 0)
+	for _, ttl := range []int{
+		conf.Configuration.TrackCacheTTLInSeconds,
+		conf.Configuration.MusicBrainzCacheTTLInSeconds,
+		conf.Configuration.TranslationCacheTTLInSeconds,
+	} {
+		if ttl > maxTTL {
+			maxTTL = ttl
+		}
+	}
+	return maxTTL
+}
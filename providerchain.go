@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+
+	"lyrics-api-go/lyrics"
+	"lyrics-api-go/utils"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	providerKindLyrics      = "lyrics"
+	providerKindMetadata    = "metadata"
+	providerKindFingerprint = "fingerprint"
+)
+
+// ProviderState is whether a provider is used, in the order lyrics providers are tried
+type ProviderState struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Enabled bool   `json:"enabled"`
+}
+
+// errNoLyricsProviders is returned when lyrics are fetched while every lyrics provider is disabled
+var errNoLyricsProviders = errors.New("every lyrics provider is disabled")
+
+// lyricsSource fetches the lyrics of a track from a lyrics provider. lines is nil when the
+// provider has no lyrics for the track.
+type lyricsSource func(ctx context.Context, trackID string) (lines []lyrics.Line, language, syncType string, err error)
+
+// lyricsSources are the lyrics providers, keyed by name
+var lyricsSources = map[string]lyricsSource{
+	"spotify": fetchSpotifyLyrics,
+}
+
+// knownProviders is the default provider chain
+var knownProviders = []ProviderState{
+	{Name: "spotify", Kind: providerKindLyrics, Enabled: true},
+	{Name: "musicbrainz", Kind: providerKindMetadata, Enabled: true},
+	{Name: "acoustid", Kind: providerKindFingerprint, Enabled: true},
+}
+
+// providerRegistry holds the runtime state of the providers, persisted so it survives restarts
+type providerRegistry struct {
+	mu        sync.RWMutex
+	providers []ProviderState
+}
+
+var providerChain = &providerRegistry{providers: append([]ProviderState(nil), knownProviders...)}
+
+// enabled reports whether the named provider may be used
+func (p *providerRegistry) enabled(name string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, provider := range p.providers {
+		if provider.Name == name {
+			return provider.Enabled
+		}
+	}
+	return false
+}
+
+// ordered returns the names of the enabled providers of kind, in the order they are tried
+func (p *providerRegistry) ordered(kind string) []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var names []string
+	for _, provider := range p.providers {
+		if provider.Kind == kind && provider.Enabled {
+			names = append(names, provider.Name)
+		}
+	}
+	return names
+}
+
+func (p *providerRegistry) snapshot() []ProviderState {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]ProviderState(nil), p.providers...)
+}
+
+// apply moves the given providers to the front in the given order with the given enabled state,
+// keeping the others after them. Unknown provider names are ignored.
+func (p *providerRegistry) apply(updates []ProviderState) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var reordered []ProviderState
+	seen := map[string]bool{}
+	for _, update := range updates {
+		for _, provider := range p.providers {
+			if provider.Name == update.Name && !seen[provider.Name] {
+				provider.Enabled = update.Enabled
+				reordered = append(reordered, provider)
+				seen[provider.Name] = true
+			}
+		}
+	}
+	for _, provider := range p.providers {
+		if !seen[provider.Name] {
+			reordered = append(reordered, provider)
+		}
+	}
+	p.providers = reordered
+}
+
+// load restores the provider state persisted at path, if any
+func (p *providerRegistry) load(path string) {
+	body, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Errorf("[Providers] Error reading provider state from %s: %v", path, err)
+		return
+	}
+
+	var persisted []ProviderState
+	if err := json.Unmarshal(body, &persisted); err != nil {
+		log.Errorf("[Providers] Error parsing provider state from %s: %v", path, err)
+		return
+	}
+	p.apply(persisted)
+	log.Infof("[Providers] Restored provider state from %s", path)
+}
+
+// save persists the provider state to path, replacing the file atomically
+func (p *providerRegistry) save(path string) error {
+	body, _ := json.MarshalIndent(p.snapshot(), "", "  ")
+	return utils.WriteFileAtomic(path, body, 0644)
+}
+
+func getProviders(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminProviders) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(providerChain.snapshot())
+}
+
+// updateProviders enables, disables and reorders providers. The body lists providers in the order
+// they should be tried, e.g. [{"name": "spotify", "enabled": false}]; providers left out keep their
+// state and come after the listed ones.
+func updateProviders(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminProviders) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var updates []ProviderState
+	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	for _, update := range updates {
+		if !isKnownProvider(update.Name) {
+			http.Error(w, "Unknown provider "+update.Name, http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	providerChain.apply(updates)
+	log.Warnf("[Providers] Provider chain updated: %+v", providerChain.snapshot())
+	if err := providerChain.save(conf.Configuration.ProvidersStateFile); err != nil {
+		log.Errorf("[Providers] Error persisting provider state: %v", err)
+		http.Error(w, "Provider chain updated but could not be persisted", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(providerChain.snapshot())
+}
+
+func isKnownProvider(name string) bool {
+	for _, provider := range knownProviders {
+		if provider.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"lyrics-api-go/internal/cache"
+	"lyrics-api-go/internal/cdn"
+	"lyrics-api-go/internal/provider/acoustid"
+	"lyrics-api-go/internal/provider/musicbrainz"
+	"lyrics-api-go/internal/provider/spotify"
+	"lyrics-api-go/internal/provider/translation"
+	"lyrics-api-go/internal/sealing"
+	"lyrics-api-go/internal/upstream"
+)
+
+var (
+	spotifyClient     *spotify.Client
+	musicBrainzClient *musicbrainz.Client
+	acoustIDClient    *acoustid.Client
+	// translator is nil unless a translation backend is configured
+	translator translation.Translator
+	// cdnPurger is nil unless a CDN and its API token are configured
+	cdnPurger cdn.Purger
+	// upstreamTransport bounds the concurrent requests to every upstream host, queueing the rest
+	upstreamTransport *upstream.Transport
+	// tokenSealer is nil unless CACHE_ENCRYPTION_KEY is configured. It only seals provider tokens,
+	// the one secret kept in the cache.
+	tokenSealer *sealing.Sealer
+)
+
+// setupTokenEncryption sets up encrypting provider tokens in the cache with CACHE_ENCRYPTION_KEY,
+// warning when they would be persisted in the clear. Unencrypted tokens are never written to
+// CACHE_FILE, see unsealedTokenKey.
+func setupTokenEncryption() error {
+	if conf.Configuration.CacheEncryptionKey == "" {
+		if conf.Configuration.CacheRedisAddr != "" || conf.Configuration.SnapshotLocation != "" {
+			log.Warnf("[Cache] Provider tokens are persisted unencrypted, set CACHE_ENCRYPTION_KEY to encrypt them")
+		}
+		return nil
+	}
+	sealer, err := sealing.New(conf.Configuration.CacheEncryptionKey)
+	if err != nil {
+		return err
+	}
+	tokenSealer = sealer
+	return nil
+}
+
+// unsealedTokenKey reports whether the cache entry stored under key is a provider token held in the
+// clear, which the disk cache keeps in memory only
+func unsealedTokenKey(key string) bool {
+	if tokenSealer != nil {
+		return false
+	}
+	_, unversioned := cache.ParseStorageKey(key)
+	return tokenCacheKey(unversioned)
+}
+
+// tokenCacheKey reports whether key, unversioned, holds a provider token
+func tokenCacheKey(key string) bool {
+	for _, tokenKey := range []string{"accessToken", conf.Configuration.TokenKey, conf.Configuration.OauthTokenKey} {
+		if tokenKey != "" && key == tokenKey {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenCache stores provider access tokens in the shared cache, encrypted when CACHE_ENCRYPTION_KEY
+// is configured
+type tokenCache struct{}
+
+func (tokenCache) Get(key string) (string, bool) {
+	value, ok := getCache(key)
+	if !ok || tokenSealer == nil {
+		return value, ok
+	}
+	// tokens stored in the clear or with a previous key are fetched again, and stored encrypted
+	token, err := tokenSealer.Open(key, value)
+	if err != nil {
+		log.Warnf("[Cache] Ignoring the cached %s: %v", key, err)
+		return "", false
+	}
+	return token, true
+}
+
+func (tokenCache) Set(key, value string, duration time.Duration) {
+	if tokenSealer != nil {
+		sealed, err := tokenSealer.Seal(key, value)
+		if err != nil {
+			log.Errorf("[Cache] Error encrypting %s: %v", key, err)
+			return
+		}
+		value = sealed
+	}
+	setCache(key, value, duration)
+}
+
+// initProviders creates the upstream provider clients from the configuration
+func initProviders() {
+	spotifyClient = spotify.New(spotify.Config{
+		LyricsURL:          conf.Configuration.LyricsUrl,
+		TrackURL:           conf.Configuration.TrackUrl,
+		TokenURL:           conf.Configuration.TokenUrl,
+		TokenKey:           conf.Configuration.TokenKey,
+		AppPlatform:        conf.Configuration.AppPlatform,
+		UserAgent:          conf.Configuration.UserAgent,
+		CookieStringFormat: conf.Configuration.CookieStringFormat,
+		CookieValue:        conf.Configuration.CookieValue,
+		ClientID:           conf.Configuration.ClientID,
+		ClientSecret:       conf.Configuration.ClientSecret,
+		OauthTokenURL:      conf.Configuration.OauthTokenUrl,
+		OauthTokenKey:      conf.Configuration.OauthTokenKey,
+		AudioAnalysisURL:   conf.Configuration.AudioAnalysisUrl,
+		AudioFeaturesURL:   conf.Configuration.AudioFeaturesUrl,
+		PlaylistURL:        conf.Configuration.PlaylistUrl,
+	}, httpClient, tokenCache{})
+	spotifyClient.BeforeRequest = setTracingHeaders
+
+	musicBrainzClient = &musicbrainz.Client{
+		URL:           conf.Configuration.MusicBrainzUrl,
+		UserAgent:     conf.Configuration.MusicBrainzUserAgent,
+		MinScore:      conf.Configuration.MusicBrainzMinScore,
+		HTTPClient:    httpClient,
+		BeforeRequest: setTracingHeaders,
+	}
+
+	acoustIDClient = &acoustid.Client{
+		URL:           conf.Configuration.AcoustIDUrl,
+		APIKey:        conf.Configuration.AcoustIDApiKey,
+		MinScore:      conf.Configuration.AcoustIDMinScore,
+		HTTPClient:    httpClient,
+		BeforeRequest: setTracingHeaders,
+	}
+
+	if conf.Configuration.TranslationBackend != "" {
+		var err error
+		translator, err = translation.New(translation.Config{
+			Backend: conf.Configuration.TranslationBackend,
+			URL:     conf.Configuration.TranslationUrl,
+			APIKey:  conf.Configuration.TranslationApiKey,
+		}, httpClient, setTracingHeaders)
+		if err != nil {
+			log.Errorf("[Translation] Translation disabled: %v", err)
+		}
+	}
+
+	if conf.Configuration.CdnProvider != "" && conf.Configuration.CdnApiToken != "" {
+		var err error
+		cdnPurger, err = cdn.New(cdn.Config{
+			Provider:  conf.Configuration.CdnProvider,
+			URL:       conf.Configuration.CdnApiUrl,
+			APIToken:  conf.Configuration.CdnApiToken,
+			ServiceID: conf.Configuration.CdnServiceId,
+		}, httpClient, setTracingHeaders)
+		if err != nil {
+			log.Errorf("[CDN] CDN purging disabled: %v", err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"time"
+
+	"lyrics-api-go/internal/redis"
+	"lyrics-api-go/middleware"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// rateLimitRedis is nil unless RATE_LIMIT_REDIS_ADDR is configured
+var rateLimitRedis *redis.Client
+
+// rateLimitWarnInterval is how often the failures of a limiter to reach Redis are logged, as every
+// request limited locally would otherwise log one
+const rateLimitWarnInterval = time.Minute
+
+// setupRateLimitRedis connects the Redis the rate limits of every instance are kept in, if any
+func setupRateLimitRedis() {
+	if conf.Configuration.RateLimitRedisAddr == "" {
+		return
+	}
+	timeout := time.Duration(conf.Configuration.RateLimitRedisTimeoutInMs) * time.Millisecond
+	rateLimitRedis = redis.New(conf.Configuration.RateLimitRedisAddr, conf.Configuration.RateLimitRedisPassword, timeout)
+	log.Infof("[RateLimit] Sharing rate limits between instances through Redis at %s", conf.Configuration.RateLimitRedisAddr)
+}
+
+// newRateLimiter creates a limiter of r requests per second with a burst of b. With Redis its
+// limits are shared by every instance under name, and kept per instance while Redis is down.
+func newRateLimiter(name string, r rate.Limit, b int) middleware.RateLimiter {
+	local := middleware.NewIPRateLimiter(r, b)
+	if rateLimitRedis == nil {
+		return local
+	}
+	limiter := middleware.NewRedisRateLimiter(rateLimitRedis, "ratelimit:"+name+":", r, b, local)
+	limiter.OnError = redisLimitWarning(name + " limit")
+	return limiter
+}
+
+// redisLimitWarning returns a reporter of the Redis errors of a limit, logging them at most once per
+// rateLimitWarnInterval
+func redisLimitWarning(limit string) func(err error) {
+	warnings := &rate.Sometimes{Interval: rateLimitWarnInterval}
+	return func(err error) {
+		warnings.Do(func() {
+			log.Warnf("[RateLimit] Error checking the %s in Redis, limiting locally: %v", limit, err)
+		})
+	}
+}
+
+// newOriginRateLimiter creates the limiter of an origin class of ORIGIN_RATE_LIMITS
+func newOriginRateLimiter(class string, limit middleware.OriginLimit) middleware.RateLimiter {
+	return newRateLimiter("origin:"+class, rate.Limit(limit.RatePerSecond), limit.Burst)
+}
+
+// setupAPIKeyLimits keeps the rate limits and daily quotas of API keys in Redis, if configured, so
+// a key gets its limits across every instance rather than on each
+func setupAPIKeyLimits() {
+	if rateLimitRedis == nil {
+		return
+	}
+	apiKeys.SetStore(redisKeyStore{rateLimitRedis}, redisLimitWarning("API key limits"))
+}
+
+// redisKeyStore keeps the rate limits of API keys with the GCRA of the other limits, and their
+// daily usage in counters expiring at midnight UTC
+type redisKeyStore struct {
+	redis middleware.Evaler
+}
+
+func (s redisKeyStore) AllowRate(id string, ratePerSecond float64, burst int) (bool, int, time.Duration, time.Duration, error) {
+	decision, err := middleware.AllowGCRA(s.redis, "ratelimit:key:"+id, rate.Limit(ratePerSecond), burst)
+	return decision.Allowed, decision.Remaining, decision.Reset, decision.RetryAfter, err
+}
+
+func (s redisKeyStore) UseQuota(id, day string, quota int, expiresAt time.Time) (bool, int, error) {
+	return middleware.UseRedisQuota(s.redis, "quota:key:"+id+":"+day, quota, expiresAt)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"time"
+
+	"lyrics-api-go/internal/cache"
+)
+
+// responseBodies holds the serialized JSON bodies of plain lyrics responses, so cache hits are
+// written straight out instead of decoding the cached lyrics and encoding them again. Bodies are
+// keyed by the version of the lyrics entry they were built from, so they are never served after
+// the lyrics change; they are only kept in memory. Each body is stored with what its headers are
+// built from, see encodeResponseBody.
+var responseBodies = cache.New()
+
+// plainResponseParams are the query parameters that don't change the body of a lyrics response
+var plainResponseParams = map[string]bool{
+	"s": true, "song": true, "songName": true,
+	"a": true, "artist": true, "artistName": true,
+	"t_id": true, "trackId": true,
+	"wait": true,
+}
+
+// plainLyricsRequest reports whether r asks for the lyrics as JSON without any of the options
+// transforming them
+func plainLyricsRequest(r *http.Request) bool {
+	for param, values := range r.URL.Query() {
+		if param == "format" && len(values) == 1 && (values[0] == "" || values[0] == "json") {
+			continue
+		}
+		if !plainResponseParams[param] {
+			return false
+		}
+	}
+	return true
+}
+
+// responseBodyKey returns the key of the plain response body of trackID for r, and false if the
+// lyrics of the track aren't in memory. Besides the lyrics it covers everything else the body
+// depends on: the API version, the word sync rollout and the community offset.
+func responseBodyKey(r *http.Request, trackID string) (string, bool) {
+	if !plainLyricsRequest(r) {
+		return "", false
+	}
+	canonicalID := canonicalTrackID(trackID)
+	for _, lyricsKey := range []string{fmt.Sprintf("lyrics:%s", canonicalID), fmt.Sprintf("lyrics:%s", trackID)} {
+		entry, ok := cacheStore.Get(storageKey(lyricsKey))
+		if !ok {
+			continue
+		}
+		offset := communityOffsets.Consensus(canonicalID)
+		return fmt.Sprintf("%s:%s@%d:v%d:%t:%t:%d", trackID, lyricsKey, entry.Version,
+			apiVersion(r), rolloutEnabled(r, rolloutWordSync), offset.Applied, offset.OffsetMs), true
+	}
+	return "", false
+}
+
+// storedResponseBody is a plain response body with what its headers are built from
+type storedResponseBody struct {
+	body         []byte
+	lastModified time.Time
+	// fetchedAt is when the lyrics of the body were fetched, in Unix seconds
+	fetchedAt int64
+	// staleAt is when the lyrics of the body outlive their TTL, in Unix seconds, it isn't stored
+	staleAt  int64
+	provider string
+}
+
+// writeCachedResponseBody writes the stored body of key, if any
+func writeCachedResponseBody(w http.ResponseWriter, r *http.Request, trackID, key string) bool {
+	entry, ok := responseBodies.Get(key)
+	if !ok {
+		return false
+	}
+	stored := decodeResponseBody(entry.Value)
+	setCacheControl(w, r, false)
+	setLastModified(w, stored.lastModified)
+	setSurrogateKeys(w, trackID, stored.provider)
+	setCacheStatus(w, "HIT", stored.fetchedAt)
+	writeLyricsBody(w, r, "application/json", stored.body)
+	return true
+}
+
+// storeResponseBody keeps a body under key, for as long as the lyrics are kept in memory and fresh
+// at most
+func storeResponseBody(key string, stored storedResponseBody) {
+	ttl := l1TTL(time.Duration(conf.Configuration.ResponseBodyTTLInSeconds) * time.Second)
+	if stored.staleAt != 0 {
+		ttl = min(ttl, time.Until(time.Unix(stored.staleAt, 0)))
+	}
+	if ttl > 0 {
+		responseBodies.Set(key, encodeResponseBody(stored), ttl)
+	}
+}
+
+// encodeResponseBody lays a body out after the Unix seconds of its Last-Modified date and of the
+// fetch of its lyrics, as 8 big-endian bytes each, and the provider name prefixed by its length in
+// a byte
+func encodeResponseBody(stored storedResponseBody) []byte {
+	var lastModified int64
+	if !stored.lastModified.IsZero() {
+		lastModified = stored.lastModified.Unix()
+	}
+	value := make([]byte, 0, 17+len(stored.provider)+len(stored.body))
+	value = binary.BigEndian.AppendUint64(value, uint64(lastModified))
+	value = binary.BigEndian.AppendUint64(value, uint64(stored.fetchedAt))
+	value = append(value, byte(len(stored.provider)))
+	value = append(value, stored.provider...)
+	return append(value, stored.body...)
+}
+
+// decodeResponseBody splits a value laid out by encodeResponseBody
+func decodeResponseBody(value []byte) storedResponseBody {
+	var stored storedResponseBody
+	if seconds := int64(binary.BigEndian.Uint64(value[:8])); seconds != 0 {
+		stored.lastModified = time.Unix(seconds, 0)
+	}
+	stored.fetchedAt = int64(binary.BigEndian.Uint64(value[8:16]))
+	length := int(value[16])
+	stored.provider = string(value[17 : 17+length])
+	stored.body = value[17+length:]
+	return stored
+}
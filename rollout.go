@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+
+	"lyrics-api-go/internal/rollout"
+	"lyrics-api-go/middleware"
+)
+
+// clientVersionHeader is the version of the extension or app making the request
+const clientVersionHeader = "X-Client-Version"
+
+// Response features ramped up gradually on the public instance
+const (
+	// rolloutV2Shape serves the /v2 response shape on the unversioned routes
+	rolloutV2Shape = "v2Shape"
+	// rolloutTranslations honours translate=
+	rolloutTranslations = "translations"
+	// rolloutWordSync returns per-word timing
+	rolloutWordSync = "wordSync"
+)
+
+var rollouts *rollout.Controller
+
+// rolloutEnabled reports whether feature is enabled for the client making r, identified by its API
+// key or else its IP address
+func rolloutEnabled(r *http.Request, feature string) bool {
+	if rollouts == nil {
+		return true
+	}
+	return rollouts.Enabled(feature, clientSubject(r), r.Header.Get(clientVersionHeader))
+}
+
+// rolloutVaries reports whether the response to r depends on which rollouts its client falls in, in
+// which case shared caches must not serve it to other clients
+func rolloutVaries(r *http.Request) bool {
+	if rollouts == nil {
+		return false
+	}
+	features := []string{rolloutWordSync}
+	if rolledOut, _ := r.Context().Value(rolledOutAPIVersionKey{}).(bool); rolledOut {
+		features = append(features, rolloutV2Shape)
+	}
+	if r.URL.Query().Get("translate") != "" {
+		features = append(features, rolloutTranslations)
+	}
+	for _, feature := range features {
+		if rollouts.Partial(feature) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientSubject identifies the client making r by its API key or else its IP address, the same
+// address the rate limits and abuse bans are keyed by
+func clientSubject(r *http.Request) string {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return key
+	}
+	return middleware.ClientIP(r)
+}
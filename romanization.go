@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"lyrics-api-go/internal/romanize"
+	"lyrics-api-go/lyrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const featureRomanization = "romanization"
+
+// romanizeLines returns the romanization of every line, cached per track as a map of original text to
+// romanization so re-chunked lines reuse the work done for the full ones
+func romanizeLines(trackID string, lines []lyrics.Line, language string) []string {
+	cacheKey := fmt.Sprintf("romanization:%s", trackID)
+	cached := map[string]string{}
+	if value, ok := getCache(cacheKey); ok {
+		log.Infof("[Cache:Romanization] Found cached romanization")
+		json.Unmarshal([]byte(value), &cached)
+	}
+
+	changed := false
+	romanizations := make([]string, len(lines))
+	for i, line := range lines {
+		romanized, ok := cached[line.Words]
+		if !ok {
+			romanized = romanize.Romanize(line.Words, language)
+			cached[line.Words] = romanized
+			changed = true
+		}
+		romanizations[i] = romanized
+	}
+
+	if changed {
+		cacheValue, _ := json.Marshal(cached)
+		log.Warn("[Cache:Romanization] Caching romanization")
+		setCache(cacheKey, string(cacheValue), time.Duration(conf.Configuration.LyricsCacheTTLInSeconds)*time.Second)
+	}
+	return romanizations
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"lyrics-api-go/internal/awssig"
+	"lyrics-api-go/internal/provider/spotify"
+	"lyrics-api-go/internal/secretstore"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Secrets are the credentials that can be rotated without a restart, which would drop the in-memory
+// cache
+type Secrets struct {
+	CookieValue      string
+	ClientID         string
+	ClientSecret     string
+	CacheAccessToken string
+}
+
+var (
+	secretsMu sync.RWMutex
+	secrets   Secrets
+	// secretsStore is where the secrets are reloaded from, SECRETS_FILE unless SECRETS_BACKEND
+	// selects Vault or AWS Secrets Manager. It is nil when SECRETS_FILE is empty, as the secrets
+	// then can't be reloaded.
+	secretsStore secretstore.Store
+	// secretsModTime is the modification time of SECRETS_FILE when it was last reloaded
+	secretsModTime time.Time
+	// secretsRead are the values last read from the secrets backend, kept so they can be redacted
+	secretsRead map[string]string
+)
+
+// errNoSecretsStore is returned by reloadSecrets when there is nothing to reload the secrets from
+var errNoSecretsStore = fmt.Errorf("no SECRETS_FILE or SECRETS_BACKEND to reload secrets from")
+
+// setupSecrets starts with the secrets of the configuration, replaced by those of the secrets
+// backend when there is one
+func setupSecrets() error {
+	secretsMu.Lock()
+	secrets = Secrets{
+		CookieValue:      conf.Configuration.CookieValue,
+		ClientID:         conf.Configuration.ClientID,
+		ClientSecret:     conf.Configuration.ClientSecret,
+		CacheAccessToken: conf.Configuration.CacheAccessToken,
+	}
+	secretsMu.Unlock()
+	if isFileBackend(conf.Configuration.SecretsBackend) && conf.Configuration.SecretsFile == "" {
+		return nil
+	}
+
+	store, err := secretstore.Open(conf.Configuration.SecretsBackend, secretstore.Config{
+		File:        conf.Configuration.SecretsFile,
+		VaultAddr:   conf.Configuration.VaultAddr,
+		VaultToken:  conf.Configuration.VaultToken,
+		VaultPath:   conf.Configuration.VaultSecretPath,
+		AWSSecretID: conf.Configuration.SecretsAWSSecretID,
+		AWSRegion:   conf.Configuration.SecretsAWSRegion,
+		AWSEndpoint: conf.Configuration.SecretsAWSEndpoint,
+		AWSCredentials: awssig.Credentials{
+			AccessKeyID:     conf.Configuration.AWSAccessKeyID,
+			SecretAccessKey: conf.Configuration.AWSSecretAccessKey,
+			SessionToken:    conf.Configuration.AWSSessionToken,
+		},
+		HTTPClient: httpClient,
+	})
+	if err != nil {
+		return err
+	}
+
+	secretsMu.Lock()
+	secretsStore = store
+	secretsMu.Unlock()
+
+	file, ok := store.(*secretstore.FileStore)
+	if !ok {
+		// without them on disk, the secrets are only known once read from the backend
+		_, err := reloadSecrets(context.Background())
+		return err
+	}
+	if info, err := os.Stat(file.Path); err == nil {
+		secretsMu.Lock()
+		secretsModTime = info.ModTime()
+		secretsMu.Unlock()
+	}
+	return nil
+}
+
+// isFileBackend reports whether SECRETS_BACKEND reads the secrets from SECRETS_FILE
+func isFileBackend(backend string) bool {
+	return backend == "" || backend == "file"
+}
+
+// currentSecrets returns the secrets in use
+func currentSecrets() Secrets {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	return secrets
+}
+
+// backendSecrets returns the values last read from the secrets backend
+func backendSecrets() map[string]string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	return secretsRead
+}
+
+// reloadSecrets reads the secrets of the secrets backend, SECRETS_FILE, an env file like .env, by
+// default, and starts using those that changed. Secrets missing from the backend keep their value.
+// It returns the names of the changed secrets.
+func reloadSecrets(ctx context.Context) ([]string, error) {
+	if secretsStore == nil {
+		return nil, errNoSecretsStore
+	}
+	var modTime time.Time
+	if file, ok := secretsStore.(*secretstore.FileStore); ok {
+		info, err := os.Stat(file.Path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading secrets from %s: %v", secretsStore, err)
+		}
+		modTime = info.ModTime()
+	}
+	values, err := secretsStore.Read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error reading secrets from %s: %v", secretsStore, err)
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	secretsModTime = modTime
+	secretsRead = values
+
+	updated := secrets
+	var changed []string
+	for _, field := range []struct {
+		name  string
+		value *string
+	}{
+		{"COOKIE_VALUE", &updated.CookieValue},
+		{"CLIENT_ID", &updated.ClientID},
+		{"CLIENT_SECRET", &updated.ClientSecret},
+		{"CACHE_ACCESS_TOKEN", &updated.CacheAccessToken},
+	} {
+		if value, ok := values[field.name]; ok && value != *field.value {
+			*field.value = value
+			changed = append(changed, field.name)
+		}
+	}
+	if len(changed) == 0 {
+		return changed, nil
+	}
+
+	spotifyClient.SetCredentials(spotify.Credentials{
+		CookieValue:  updated.CookieValue,
+		ClientID:     updated.ClientID,
+		ClientSecret: updated.ClientSecret,
+	})
+	// cached access tokens were obtained with the previous credentials
+	if updated.CookieValue != secrets.CookieValue {
+		deleteCacheEntry(conf.Configuration.TokenKey)
+	}
+	if updated.ClientID != secrets.ClientID || updated.ClientSecret != secrets.ClientSecret {
+		deleteCacheEntry(conf.Configuration.OauthTokenKey)
+	}
+	// the verifier is created with the secrets read on startup
+	if adminSignatures != nil {
+		adminSignatures.SetSecret(updated.CacheAccessToken)
+	}
+
+	secrets = updated
+	log.Infof("[Secrets] Reloaded %v from %s", changed, secretsStore)
+	return changed, nil
+}
+
+// scheduledSecretsReload reloads the secrets when SECRETS_FILE was modified since the last reload,
+// or polls Vault or AWS Secrets Manager for rotated secrets
+func scheduledSecretsReload(ctx context.Context) error {
+	if secretsStore == nil {
+		return nil
+	}
+	file, ok := secretsStore.(*secretstore.FileStore)
+	if !ok {
+		_, err := reloadSecrets(ctx)
+		return err
+	}
+	info, err := os.Stat(file.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	secretsMu.RLock()
+	modified := !info.ModTime().Equal(secretsModTime)
+	secretsMu.RUnlock()
+	if !modified {
+		return nil
+	}
+	_, err = reloadSecrets(ctx)
+	return err
+}
+
+// reloadSecretsNow reloads the secrets of the secrets backend on demand, returning the names of those that
+// changed
+func reloadSecretsNow(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminSecrets) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	changed, err := reloadSecrets(r.Context())
+	if err == errNoSecretsStore {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Errorf("[Secrets] %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"reloaded": append([]string{}, changed...)})
+}
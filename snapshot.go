@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"lyrics-api-go/internal/awssig"
+	"lyrics-api-go/internal/snapshot"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SnapshotResult is the outcome of writing a cache snapshot
+type SnapshotResult struct {
+	Location     string `json:"location"`
+	NumberOfKeys int    `json:"numberOfKeys"`
+	SizeInKB     int    `json:"sizeInKB"`
+	DurationMs   int64  `json:"durationMs"`
+}
+
+// snapshotStore returns the store of SNAPSHOT_LOCATION
+func snapshotStore() (snapshot.Store, error) {
+	if conf.Configuration.SnapshotLocation == "" {
+		return nil, errors.New("SNAPSHOT_LOCATION is not set")
+	}
+	return snapshot.Open(conf.Configuration.SnapshotLocation, snapshot.S3Config{
+		Region:   conf.Configuration.SnapshotS3Region,
+		Endpoint: conf.Configuration.SnapshotS3Endpoint,
+		Credentials: awssig.Credentials{
+			AccessKeyID:     conf.Configuration.AWSAccessKeyID,
+			SecretAccessKey: conf.Configuration.AWSSecretAccessKey,
+			SessionToken:    conf.Configuration.AWSSessionToken,
+		},
+		HTTPClient: httpClient,
+	})
+}
+
+// writeCacheSnapshot writes the cache dump, gzip-compressed, to SNAPSHOT_LOCATION
+func writeCacheSnapshot(ctx context.Context) (SnapshotResult, error) {
+	start := time.Now()
+	store, err := snapshotStore()
+	if err != nil {
+		return SnapshotResult{}, err
+	}
+
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	numberOfKeys, err := writeCacheDump(gz, cacheDumpKeys(""))
+	if err != nil {
+		return SnapshotResult{}, err
+	}
+	if err := gz.Close(); err != nil {
+		return SnapshotResult{}, err
+	}
+	if err := store.Write(ctx, body.Bytes()); err != nil {
+		return SnapshotResult{}, fmt.Errorf("writing %s: %w", store, err)
+	}
+
+	result := SnapshotResult{
+		Location:     store.String(),
+		NumberOfKeys: numberOfKeys,
+		SizeInKB:     body.Len() / 1024,
+		DurationMs:   time.Since(start).Milliseconds(),
+	}
+	log.Infof("[Cache:Snapshot] Wrote %d entries to %s", result.NumberOfKeys, result.Location)
+	return result, nil
+}
+
+// scheduledCacheSnapshot is the cacheSnapshot background job
+func scheduledCacheSnapshot(ctx context.Context) error {
+	_, err := writeCacheSnapshot(ctx)
+	return err
+}
+
+// restoreCacheSnapshot loads the snapshot at SNAPSHOT_LOCATION into the cache. A missing snapshot
+// only means a cold start.
+func restoreCacheSnapshot(ctx context.Context) error {
+	store, err := snapshotStore()
+	if err != nil {
+		return err
+	}
+	body, err := store.Read(ctx)
+	if errors.Is(err, snapshot.ErrNotFound) {
+		log.Infof("[Cache:Snapshot] No snapshot at %s yet, starting cold", store)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", store, err)
+	}
+	quarantinePath := conf.Configuration.SnapshotLocation + ".quarantine.json"
+	if _, remote := store.(*snapshot.S3Store); remote {
+		// quarantined entries of remote snapshots are kept in the working directory
+		quarantinePath = filepath.Base(quarantinePath)
+	}
+	return loadCacheDump(bytes.NewReader(body), store.String(), quarantinePath)
+}
+
+func createCacheSnapshot(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminCache) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if conf.Configuration.SnapshotLocation == "" {
+		http.Error(w, "SNAPSHOT_LOCATION is not set", http.StatusNotImplemented)
+		return
+	}
+
+	result, err := writeCacheSnapshot(r.Context())
+	if err != nil {
+		log.Errorf("[Cache:Snapshot] %v", err)
+		http.Error(w, "Error writing the snapshot", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
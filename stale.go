@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// staleLyricsTTL is how long lyrics entries are kept past their TTL, to be served only while every
+// provider is failing
+func staleLyricsTTL() time.Duration {
+	return time.Duration(conf.Configuration.StaleLyricsTTLInSeconds) * time.Second
+}
+
+// readCachedLyrics returns the cached lyrics of cacheKey, stale or not, without counting a hit or miss
+func readCachedLyrics(cacheKey string) (CachedLyrics, bool) {
+	entry, ok := loadCacheEntry(cacheKey)
+	if !ok {
+		return CachedLyrics{}, false
+	}
+	value, err := decodeCacheValue(entry.Value)
+	if err != nil {
+		log.Errorf("Error decompressing cache value: %v", err)
+		return CachedLyrics{}, false
+	}
+	var cachedData CachedLyrics
+	json.Unmarshal([]byte(value), &cachedData)
+	cachedData.FromCache = true
+	return cachedData, true
+}
+
+// staleLyrics returns the cached lyrics of a track past their TTL after fetching them failed with err
+func staleLyrics(trackID string, err error) (CachedLyrics, bool) {
+	data, ok := readCachedLyrics(fmt.Sprintf("lyrics:%s", trackID))
+	if !ok {
+		return CachedLyrics{}, false
+	}
+	log.Warnf("[Cache:Stale] Serving stale lyrics of %s, fetching them failed: %v", trackID, err)
+	data.Stale = true
+	return data, true
+}
+
+// lyricsExpiration returns when the cached lyrics of a track stop being fresh
+func lyricsExpiration(trackID string) (time.Time, bool) {
+	cacheKey := fmt.Sprintf("lyrics:%s", trackID)
+	if data, ok := readCachedLyrics(cacheKey); ok && data.StaleAt != 0 {
+		return time.Unix(data.StaleAt, 0), true
+	}
+	return cacheEntryExpiration(cacheKey)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	statsUnknown = "unknown"
+
+	lookupAvailable = "available"
+	lookupNotFound  = "not_found"
+	lookupError     = "error"
+)
+
+// AvailabilityCell aggregates the lookups of one provider for one language and decade
+type AvailabilityCell struct {
+	Lookups     int            `json:"lookups"`
+	Available   int            `json:"available"`
+	NotFound    int            `json:"notFound"`
+	Errors      int            `json:"errors"`
+	SyncTypes   map[string]int `json:"syncTypes"`
+	SuccessRate float64        `json:"successRate"`
+	// SyncRates is the share of available lyrics of each sync type
+	SyncRates map[string]float64 `json:"syncRates"`
+}
+
+// ProviderAvailability is the availability heatmap of a provider, by language and then by decade
+type ProviderAvailability struct {
+	Lookups   int                                     `json:"lookups"`
+	Languages map[string]map[string]*AvailabilityCell `json:"languages"`
+}
+
+// availabilityStats aggregates lyrics lookups per provider since startup
+type availabilityStats struct {
+	mu        sync.Mutex
+	since     time.Time
+	providers map[string]*ProviderAvailability
+}
+
+var providerStats = &availabilityStats{since: time.Now(), providers: map[string]*ProviderAvailability{}}
+
+// record counts a lookup of trackID on provider. Languages are only known for available lyrics,
+// so misses and errors are counted under the unknown language.
+func (s *availabilityStats) record(provider, trackID, outcome, syncType, language string) {
+	decade := releaseDecade(trackID)
+	if language == "" {
+		language = statsUnknown
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	availability, ok := s.providers[provider]
+	if !ok {
+		availability = &ProviderAvailability{Languages: map[string]map[string]*AvailabilityCell{}}
+		s.providers[provider] = availability
+	}
+	decades, ok := availability.Languages[language]
+	if !ok {
+		decades = map[string]*AvailabilityCell{}
+		availability.Languages[language] = decades
+	}
+	cell, ok := decades[decade]
+	if !ok {
+		cell = &AvailabilityCell{SyncTypes: map[string]int{}}
+		decades[decade] = cell
+	}
+
+	availability.Lookups++
+	cell.Lookups++
+	switch outcome {
+	case lookupAvailable:
+		cell.Available++
+		if syncType == "" {
+			syncType = statsUnknown
+		}
+		cell.SyncTypes[syncType]++
+	case lookupNotFound:
+		cell.NotFound++
+	default:
+		cell.Errors++
+	}
+}
+
+// snapshot returns a copy of the stats with the rates filled in
+func (s *availabilityStats) snapshot() map[string]*ProviderAvailability {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	providers := make(map[string]*ProviderAvailability, len(s.providers))
+	for name, availability := range s.providers {
+		copied := &ProviderAvailability{Lookups: availability.Lookups, Languages: map[string]map[string]*AvailabilityCell{}}
+		for language, decades := range availability.Languages {
+			copied.Languages[language] = map[string]*AvailabilityCell{}
+			for decade, cell := range decades {
+				c := *cell
+				c.SyncTypes = make(map[string]int, len(cell.SyncTypes))
+				c.SyncRates = make(map[string]float64, len(cell.SyncTypes))
+				for syncType, count := range cell.SyncTypes {
+					c.SyncTypes[syncType] = count
+					c.SyncRates[syncType] = float64(count) / float64(c.Available)
+				}
+				c.SuccessRate = float64(c.Available) / float64(c.Lookups)
+				copied.Languages[language][decade] = &c
+			}
+		}
+		providers[name] = copied
+	}
+	return providers
+}
+
+// releaseDecade returns the decade of the release date remembered for trackID, e.g. "1990s"
+func releaseDecade(trackID string) string {
+	releaseDate, ok := getCache(releaseCacheKey(trackID))
+	if !ok || len(releaseDate) < 4 {
+		return statsUnknown
+	}
+	var year int
+	if _, err := fmt.Sscanf(releaseDate[:4], "%d", &year); err != nil || year <= 0 {
+		return statsUnknown
+	}
+	return fmt.Sprintf("%ds", year/10*10)
+}
+
+func releaseCacheKey(trackID string) string {
+	return fmt.Sprintf("release:%s", trackID)
+}
+
+// rememberReleaseDate keeps the release date of the search result chosen for a lookup, for the
+// availability stats and the lyrics TTL policies
+func rememberReleaseDate(trackID, releaseDate string) {
+	if releaseDate == "" {
+		return
+	}
+	setCache(releaseCacheKey(trackID), releaseDate, time.Duration(conf.Configuration.TrackCacheTTLInSeconds)*time.Second)
+}
+
+func getProviderStats(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminJobs) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"since":     providerStats.since,
+		"providers": providerStats.snapshot(),
+		"upstream":  upstreamTransport.Stats(),
+	})
+}
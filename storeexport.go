@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"lyrics-api-go/internal/cache"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// exportFlushEvery is how many records are written between flushes to the client
+const exportFlushEvery = 100
+
+// ExportedLyrics is a record of the lyrics store export
+type ExportedLyrics struct {
+	Key           string          `json:"key"`
+	TrackID       string          `json:"trackId"`
+	Lyrics        json.RawMessage `json:"lyrics"`
+	Expiration    int64           `json:"expiration"`
+	SchemaVersion int             `json:"schemaVersion"`
+	Checksum      string          `json:"checksum"`
+}
+
+// exportedLyricsChecksum is the checksum of an export record, covering its key, expiration and lyrics
+// as exported, so it can be verified against the record alone
+func exportedLyricsChecksum(key string, expiration int64, lyrics []byte) string {
+	return cache.EntryChecksum(key, cache.Entry{Value: lyrics, Expiration: expiration})
+}
+
+// exportStore streams the stored lyrics as gzip-compressed ndjson, one ExportedLyrics record per line
+// in key order, throttled by STORE_EXPORT_RATE_PER_SECOND (0 for no throttling). An interrupted export is resumed by
+// passing the key of the last record received as after=.
+func exportStore(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminCache) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	after := r.URL.Query().Get("after")
+	start := time.Now()
+
+	// keys are snapshotted so the export has a stable order to resume from, and values are read when
+	// they are written so entries purged in the meantime are left out
+	var keys []string
+	rangeCacheEntries(func(key string, entry cache.Entry) bool {
+		if strings.HasPrefix(key, "lyrics:") && key > after {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	sort.Strings(keys)
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="lyrics-store.ndjson.gz"`)
+	controller := http.NewResponseController(w)
+	gz := gzip.NewWriter(w)
+	encoder := json.NewEncoder(gz)
+	// the lyrics are written exactly as checksummed
+	encoder.SetEscapeHTML(false)
+
+	limit := rate.Inf
+	if conf.Configuration.StoreExportRatePerSecond > 0 {
+		limit = rate.Limit(conf.Configuration.StoreExportRatePerSecond)
+	}
+	limiter := rate.NewLimiter(limit, exportFlushEvery)
+	exported := 0
+	for _, key := range keys {
+		// waiting on the request context stops the export when the client goes away
+		if err := limiter.Wait(r.Context()); err != nil {
+			log.Warnf("[Export] Export stopped after %d records: %v", exported, err)
+			return
+		}
+
+		entry, ok := cacheStore.Get(storageKey(key))
+		if !ok {
+			continue
+		}
+		value, err := decodeCacheValue(entry.Value)
+		var lyrics bytes.Buffer
+		if err != nil || json.Compact(&lyrics, []byte(value)) != nil {
+			log.Errorf("[Export] Skipping undecodable entry %s", key)
+			continue
+		}
+
+		record := ExportedLyrics{
+			Key:           key,
+			TrackID:       strings.TrimPrefix(key, "lyrics:"),
+			Lyrics:        json.RawMessage(lyrics.Bytes()),
+			Expiration:    entry.Expiration,
+			SchemaVersion: cacheDumpSchemaVersion,
+			Checksum:      exportedLyricsChecksum(key, entry.Expiration, lyrics.Bytes()),
+		}
+		// writes block while the client is slow to read, so the export never buffers ahead of it
+		if err := encoder.Encode(record); err != nil {
+			log.Warnf("[Export] Export stopped after %d records: %v", exported, err)
+			return
+		}
+
+		exported++
+		if exported%exportFlushEvery == 0 {
+			gz.Flush()
+			// writers that can't flush leave it to the server to send the buffered records
+			if err := controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				log.Warnf("[Export] Export stopped after %d records: %v", exported, err)
+				return
+			}
+		}
+	}
+
+	gz.Close()
+	log.Infof("[Export] Exported %d lyrics entries in %s", exported, time.Since(start))
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"lyrics-api-go/internal/cache"
+	"lyrics-api-go/internal/provider"
+)
+
+const redacted = "[REDACTED]"
+
+// secretFieldMarkers mark config fields whose values must never leave the server
+var secretFieldMarkers = []string{"Secret", "Token", "Cookie", "Key", "Password"}
+
+// SupportBundle is a self-contained diagnostics report for a single track
+type SupportBundle struct {
+	GeneratedAt      time.Time                         `json:"generatedAt"`
+	TrackID          string                            `json:"trackId"`
+	Resolution       []ResolutionStep                  `json:"resolution,omitempty"`
+	CacheEntries     map[string]SupportBundleCacheItem `json:"cacheEntries"`
+	ProviderResponse map[string]ProviderSnapshot       `json:"providerResponses"`
+	Config           map[string]interface{}            `json:"config"`
+}
+
+// ResolutionStep is a single step of resolving a song and artist to a track id
+type ResolutionStep struct {
+	Step       string      `json:"step"`
+	Result     interface{} `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	DurationMs int64       `json:"durationMs"`
+}
+
+// SupportBundleCacheItem is a decoded cache entry
+type SupportBundleCacheItem struct {
+	Value     json.RawMessage `json:"value"`
+	ExpiresAt time.Time       `json:"expiresAt"`
+	Version   uint64          `json:"version"`
+}
+
+// ProviderSnapshot is the raw response of a provider for the track
+type ProviderSnapshot struct {
+	StatusCode int             `json:"statusCode"`
+	Body       json.RawMessage `json:"body,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+func getSupportBundle(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminJobs) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	trackID := r.URL.Query().Get("trackId")
+	songName := r.URL.Query().Get("s")
+	artistName := r.URL.Query().Get("a")
+	if trackID == "" && songName == "" && artistName == "" {
+		http.Error(w, "Track id or song name not provided", http.StatusUnprocessableEntity)
+		return
+	}
+
+	bundle := SupportBundle{
+		GeneratedAt:      time.Now(),
+		CacheEntries:     map[string]SupportBundleCacheItem{},
+		ProviderResponse: map[string]ProviderSnapshot{},
+		Config:           redactedConfig(),
+	}
+
+	if songName != "" || artistName != "" {
+		var resolvedID string
+		bundle.Resolution, resolvedID = traceResolution(r.Context(), songName, artistName)
+		if trackID == "" {
+			trackID = resolvedID
+		}
+	}
+	bundle.TrackID = trackID
+
+	if trackID != "" {
+		bundle.CacheEntries = supportBundleCacheEntries(trackID)
+		bundle.ProviderResponse["spotify"] = snapshotSpotify(r.Context(), trackID)
+	}
+
+	body, err := redactedJSON(bundle)
+	if err != nil {
+		http.Error(w, "Error encoding the support bundle", http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("support-bundle-%s-%d.json", trackID, bundle.GeneratedAt.Unix())
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Write(body)
+}
+
+// traceResolution repeats the track resolution for a song and artist, recording every step
+func traceResolution(ctx context.Context, songName, artistName string) ([]ResolutionStep, string) {
+	var steps []ResolutionStep
+	record := func(step string, fn func() (interface{}, error)) {
+		start := time.Now()
+		result, err := fn()
+		s := ResolutionStep{Step: step, Result: result, DurationMs: time.Since(start).Milliseconds()}
+		if err != nil {
+			s.Error = err.Error()
+		}
+		steps = append(steps, s)
+	}
+
+	if conf.FeatureFlags.MusicBrainzCanonicalization && providerChain.enabled("musicbrainz") {
+		record("musicbrainz", func() (interface{}, error) {
+			canonical, ok := canonicalizeTrack(ctx, songName, artistName)
+			if !ok {
+				return nil, nil
+			}
+			return canonical, nil
+		})
+	}
+
+	query, artists := buildSearchQuery(songName, artistName)
+	record("query", func() (interface{}, error) {
+		return map[string]interface{}{"query": query, "artists": artists}, nil
+	})
+
+	cacheKey := trackCacheKey(songName, artists)
+	record("trackCache", func() (interface{}, error) {
+		cachedTrackID, ok := getCache(cacheKey)
+		return map[string]interface{}{"key": cacheKey, "hit": ok, "trackId": cachedTrackID}, nil
+	})
+
+	var candidates []trackCandidate
+	record("search", func() (interface{}, error) {
+		var err error
+		candidates, err = fetchTrackCandidates(ctx, query, artists)
+		return candidates, err
+	})
+
+	var trackID string
+	record("resolve", func() (interface{}, error) {
+		var err error
+		trackID, err = resolveTrackID(ctx, songName, artistName)
+		return trackID, err
+	})
+
+	return steps, trackID
+}
+
+// supportBundleCacheEntries returns the lyrics entry of the track and every track entry resolving to it
+func supportBundleCacheEntries(trackID string) map[string]SupportBundleCacheItem {
+	items := map[string]SupportBundleCacheItem{}
+	lyricsKey := fmt.Sprintf("lyrics:%s", trackID)
+
+	rangeCacheEntries(func(key string, entry cache.Entry) bool {
+		if key != lyricsKey && !strings.HasPrefix(key, "track:") {
+			return true
+		}
+		value, err := decodeCacheValue(entry.Value)
+		if err != nil {
+			return true
+		}
+		if key != lyricsKey && value != trackID {
+			return true
+		}
+
+		raw := json.RawMessage(value)
+		if !json.Valid(raw) {
+			raw, _ = json.Marshal(value)
+		}
+		items[key] = SupportBundleCacheItem{
+			Value:     raw,
+			ExpiresAt: time.Unix(0, entry.Expiration),
+			Version:   entry.Version,
+		}
+		return true
+	})
+
+	return items
+}
+
+// snapshotSpotify fetches the raw lyrics response of the track from Spotify
+func snapshotSpotify(ctx context.Context, trackID string) ProviderSnapshot {
+	body, err := spotifyClient.LyricsRaw(ctx, trackID)
+	if err != nil {
+		var statusErr *provider.HTTPStatusError
+		if errors.As(err, &statusErr) {
+			return ProviderSnapshot{StatusCode: statusErr.StatusCode, Error: err.Error()}
+		}
+		return ProviderSnapshot{Error: err.Error()}
+	}
+
+	snapshot := ProviderSnapshot{StatusCode: http.StatusOK}
+	if json.Valid(body) {
+		snapshot.Body = body
+	}
+	return snapshot
+}
+
+// redactedConfig returns the configuration with every secret-looking field replaced
+func redactedConfig() map[string]interface{} {
+	snapshot := map[string]interface{}{}
+	for _, section := range []interface{}{conf.Configuration, conf.FeatureFlags} {
+		v := reflect.ValueOf(section)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := t.Field(i).Name
+			value := v.Field(i).Interface()
+			if isSecretField(name) && !v.Field(i).IsZero() {
+				value = redacted
+			}
+			snapshot[name] = value
+		}
+	}
+	return snapshot
+}
+
+// isSecretField reports whether a config field or secrets backend entry holds a secret, matching
+// both field names like CacheAccessToken and env names like CACHE_ACCESS_TOKEN
+func isSecretField(name string) bool {
+	name = strings.ToUpper(name)
+	for _, marker := range secretFieldMarkers {
+		if strings.Contains(name, strings.ToUpper(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretValues returns every secret known to the server: the config fields flagged by
+// isSecretField, the rotated secrets in use and the secrets read from the secrets backend
+func secretValues() []string {
+	var values []string
+	for _, section := range []interface{}{conf.Configuration, conf.FeatureFlags, currentSecrets()} {
+		v := reflect.ValueOf(section)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if isSecretField(t.Field(i).Name) && v.Field(i).Kind() == reflect.String {
+				values = append(values, v.Field(i).String())
+			}
+		}
+	}
+	for name, value := range backendSecrets() {
+		if isSecretField(name) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// redactedJSON encodes v with every secret value replaced. Secrets are redacted in the decoded
+// strings rather than in the encoded JSON, where escaping could hide them from a plain search.
+func redactedJSON(v interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(redactValue(tree, secretValues()), "", "  ")
+}
+
+// redactValue replaces secrets in every string and key of a decoded JSON value
+func redactValue(value interface{}, secrets []string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return redactSecrets(v, secrets)
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i], secrets)
+		}
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[redactSecrets(key, secrets)] = redactValue(item, secrets)
+		}
+		return result
+	}
+	return value
+}
+
+// redactSecrets replaces any of secrets appearing in s. Secrets too short to be told apart from
+// regular text are left alone.
+func redactSecrets(s string, secrets []string) string {
+	for _, secret := range secrets {
+		if len(secret) >= 4 {
+			s = strings.ReplaceAll(s, secret, redacted)
+		}
+	}
+	return s
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"time"
+
+	"lyrics-api-go/internal/cache"
+	"lyrics-api-go/internal/redis"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// cacheL2 is the Redis layer of the tiered cache, shared by all instances. It is nil unless
+// CACHE_REDIS_ADDR is set, in which case cacheStore only keeps hot entries for CACHE_L1_TTL_IN_SECONDS.
+var cacheL2 *redis.Client
+
+// cacheKeySchemaVersion is the version of the stored values, keys are stored under it in memory
+// and Redis so instances of different versions don't read each other's entries
+const cacheKeySchemaVersion = cache.KeySchemaVersion
+
+// storageKey returns the key an entry is stored under in memory and Redis
+func storageKey(key string) string {
+	return cache.StorageKey(cacheKeySchemaVersion, key)
+}
+
+// rangeCacheEntries calls fn for every entry in memory of the current key schema version, with
+// its unversioned key
+func rangeCacheEntries(fn func(key string, entry cache.Entry) bool) {
+	cacheStore.Range(func(stored string, entry cache.Entry) bool {
+		if version, key := cache.ParseStorageKey(stored); version == cacheKeySchemaVersion {
+			return fn(key, entry)
+		}
+		return true
+	})
+}
+
+// setupTieredCache connects the Redis layer of the cache if one is configured
+func setupTieredCache() {
+	if conf.Configuration.CacheRedisAddr == "" {
+		return
+	}
+	timeout := time.Duration(conf.Configuration.CacheRedisTimeoutInMs) * time.Millisecond
+	cacheL2 = redis.New(conf.Configuration.CacheRedisAddr, conf.Configuration.CacheRedisPassword, timeout)
+	log.Infof("[Cache:L2] Using Redis at %s, keeping hot entries in memory for %ds",
+		conf.Configuration.CacheRedisAddr, conf.Configuration.CacheL1TTLInSeconds)
+}
+
+// l1TTL caps how long an entry is kept in memory when the cache is tiered, so entries written or
+// purged by other instances are picked up within the L1 TTL
+func l1TTL(duration time.Duration) time.Duration {
+	if cacheL2 == nil {
+		return duration
+	}
+	if max := time.Duration(conf.Configuration.CacheL1TTLInSeconds) * time.Second; duration > max {
+		return max
+	}
+	return duration
+}
+
+// loadCacheEntry returns the stored (encoded) entry of key from memory, falling back to Redis.
+// Entries found in Redis are kept in memory for the L1 TTL, which bounds how long this instance
+// keeps serving an entry purged elsewhere.
+func loadCacheEntry(key string) (cache.Entry, bool) {
+	stored := storageKey(key)
+	if entry, ok := cacheStore.Get(stored); ok || cacheL2 == nil {
+		return entry, ok
+	}
+
+	value, expiresAt, found, err := cacheL2.Get(stored)
+	if err != nil {
+		// Redis being down only costs cache hits, requests go upstream instead
+		log.Warnf("[Cache:L2] Error reading %s: %v", key, err)
+		return cache.Entry{}, false
+	}
+	if !found {
+		return cache.Entry{}, false
+	}
+
+	ttl := l1TTL(time.Duration(maxCacheTTLInSeconds()) * time.Second)
+	if !expiresAt.IsZero() {
+		ttl = l1TTL(time.Until(expiresAt))
+	}
+	if ttl <= 0 {
+		return cache.Entry{}, false
+	}
+	entry := cache.Entry{Value: []byte(value), Expiration: time.Now().Add(ttl).UnixNano()}
+	entry.Version = cacheStore.Set(stored, entry.Value, ttl)
+	return entry, true
+}
+
+// storeCacheEntry writes an encoded value to memory and through to Redis
+func storeCacheEntry(key string, encodedValue []byte, duration time.Duration) {
+	cacheStore.Set(storageKey(key), encodedValue, l1TTL(duration))
+	storeCacheL2(key, encodedValue, duration)
+}
+
+// compareAndStoreCacheEntry writes an encoded value to memory if the key's version still equals
+// version, and through to Redis if it was written. The version only covers this instance's memory,
+// concurrent writes from other instances are last-write-wins in Redis.
+func compareAndStoreCacheEntry(key string, encodedValue []byte, duration time.Duration, version uint64) bool {
+	if !cacheStore.CompareAndSet(storageKey(key), encodedValue, l1TTL(duration), version) {
+		return false
+	}
+	storeCacheL2(key, encodedValue, duration)
+	return true
+}
+
+func storeCacheL2(key string, encodedValue []byte, duration time.Duration) {
+	if cacheL2 == nil {
+		return
+	}
+	if err := cacheL2.Set(storageKey(key), string(encodedValue), time.Now().Add(duration)); err != nil {
+		log.Warnf("[Cache:L2] Error writing %s: %v", key, err)
+	}
+}
+
+// cacheEntryExpiration returns when the entry of key expires. When the cache is tiered it is read
+// from Redis, since memory only holds entries for the L1 TTL.
+func cacheEntryExpiration(key string) (time.Time, bool) {
+	if cacheL2 == nil {
+		entry, ok := cacheStore.Get(storageKey(key))
+		return time.Unix(0, entry.Expiration), ok
+	}
+	_, expiresAt, found, err := cacheL2.Get(storageKey(key))
+	if err != nil {
+		log.Warnf("[Cache:L2] Error reading %s: %v", key, err)
+		return time.Time{}, false
+	}
+	// entries without an expiration never need refreshing
+	return expiresAt, found && !expiresAt.IsZero()
+}
+
+// deleteCacheEntry removes key from memory and Redis
+func deleteCacheEntry(key string) {
+	cacheStore.Delete(storageKey(key))
+	cacheStats.evict(key)
+	if cacheL2 == nil {
+		return
+	}
+	if _, err := cacheL2.Delete(storageKey(key)); err != nil {
+		log.Warnf("[Cache:L2] Error deleting %s: %v", key, err)
+	}
+}
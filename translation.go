@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"lyrics-api-go/lyrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const featureTranslation = "translation"
+
+var translateLanguagePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})?$`)
+
+// lyricsTranslations returns the translation of every line for ?translate=, or the request error to
+// respond with when the request can't be served. Translations are nil when none was asked for, or
+// when translating was skipped or failed, in which case the lyrics are served untranslated.
+func lyricsTranslations(ctx context.Context, r *http.Request, trackID string, lines []lyrics.Line) ([]string, error) {
+	language := r.URL.Query().Get("translate")
+	if language == "" || !rolloutEnabled(r, rolloutTranslations) {
+		return nil, nil
+	}
+	if err := scopeError(r, scopeTranslate); err != nil {
+		return nil, err
+	}
+	if translator == nil {
+		return nil, requestError(http.StatusNotImplemented, "Translation is not configured")
+	}
+	if !translateLanguagePattern.MatchString(language) {
+		return nil, requestError(http.StatusBadRequest, "Invalid translation language")
+	}
+	if !withinBudget(ctx, featureTranslation) {
+		return nil, nil
+	}
+
+	translations, err := translateLines(ctx, trackID, lines, language)
+	if err != nil {
+		log.Errorf("[Translation] Error translating %s to %s: %v", trackID, language, err)
+		return nil, nil
+	}
+	return translations, nil
+}
+
+// translateLines translates lines to language. Translations are cached per track and language as a
+// map of original text to translation, so only texts not seen before (e.g. re-chunked lines) are sent
+// to the backend.
+func translateLines(ctx context.Context, trackID string, lines []lyrics.Line, language string) ([]string, error) {
+	cacheKey := fmt.Sprintf("translation:%s:%s", trackID, language)
+	cached := map[string]string{}
+	if value, ok := getCache(cacheKey); ok {
+		log.Infof("[Cache:Translation] Found cached translation")
+		json.Unmarshal([]byte(value), &cached)
+	}
+
+	var missing []string
+	seen := map[string]bool{}
+	for _, line := range lines {
+		if _, ok := cached[line.Words]; ok || line.Words == "" || seen[line.Words] {
+			continue
+		}
+		seen[line.Words] = true
+		missing = append(missing, line.Words)
+	}
+
+	if len(missing) > 0 {
+		translated, err := translator.Translate(ctx, missing, language)
+		if err != nil {
+			return nil, err
+		}
+		for i, text := range missing {
+			cached[text] = translated[i]
+		}
+
+		cacheValue, _ := json.Marshal(cached)
+		log.Warn("[Cache:Translation] Caching translation")
+		setCache(cacheKey, string(cacheValue), time.Duration(conf.Configuration.TranslationCacheTTLInSeconds)*time.Second)
+	}
+
+	translations := make([]string, len(lines))
+	for i, line := range lines {
+		translations[i] = cached[line.Words]
+	}
+	return translations, nil
+}
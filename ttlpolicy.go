@@ -0,0 +1,37 @@
+package main
+
+import (
+	"time"
+
+	"lyrics-api-go/internal/ttlpolicy"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// lyricsTTLPolicy picks the TTL of cached lyrics, falling back to LYRICS_CACHE_TTL_IN_SECONDS
+var lyricsTTLPolicy = ttlpolicy.New(nil, 0)
+
+// loadLyricsTTLPolicy sets up lyricsTTLPolicy from LYRICS_TTL_POLICIES
+func loadLyricsTTLPolicy() error {
+	rules, err := ttlpolicy.Parse(conf.Configuration.LyricsTTLPolicies)
+	if err != nil {
+		return err
+	}
+	lyricsTTLPolicy = ttlpolicy.New(rules, time.Duration(conf.Configuration.LyricsCacheTTLInSeconds)*time.Second)
+	return nil
+}
+
+// lyricsCacheTTL returns how long the lyrics of trackID are cached. The release date is only known
+// for tracks resolved through a search, other tracks are matched by the rules without a release age.
+func lyricsCacheTTL(trackID string, data CachedLyrics) time.Duration {
+	attrs := ttlpolicy.Attributes{SyncType: data.SyncType, Language: data.Language}
+	if releaseDate, ok := getCache(releaseCacheKey(trackID)); ok {
+		attrs.ReleaseDate, _ = ttlpolicy.ParseReleaseDate(releaseDate)
+	}
+
+	ttl, rule := lyricsTTLPolicy.TTL(attrs, time.Now())
+	if rule != "" {
+		log.Infof("[Cache:Lyrics] Caching %s for %s under the %s policy", trackID, ttl, rule)
+	}
+	return ttl
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"lyrics-api-go/internal/usage"
+)
+
+// usageTracker counts the requests, cache hits and upstream calls of every API key
+var usageTracker = usage.NewTracker(30)
+
+// setupUsage sets up usageTracker with the configured retention
+func setupUsage() {
+	usageTracker = usage.NewTracker(conf.Configuration.UsageRetentionInDays)
+}
+
+// usageMiddleware attributes requests made with a known API key, and the cache hits and upstream
+// calls they cause, to the key
+func usageMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if secret := r.Header.Get(apiKeyHeader); secret != "" {
+			if key, ok := apiKeys.Lookup(secret); ok {
+				r = r.WithContext(usage.WithKeyID(r.Context(), key.ID))
+				usageTracker.Record(key.ID, usage.Request)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recordUsage counts a usage of kind against the API key of the request ctx belongs to, if any
+func recordUsage(ctx context.Context, kind usage.Kind) {
+	if keyID := usage.KeyIDFromContext(ctx); keyID != "" {
+		usageTracker.Record(keyID, kind)
+	}
+}
+
+// usageTransport counts every upstream call against the API key of the request it is made for.
+// Calls shared between concurrent requests are counted once, for the request that made them.
+type usageTransport struct {
+	next http.RoundTripper
+}
+
+func (t usageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recordUsage(req.Context(), usage.UpstreamCall)
+	return t.next.RoundTrip(req)
+}
+
+// getUsage returns the usage of the API key of the request over the retained days
+func getUsage(w http.ResponseWriter, r *http.Request) {
+	key, ok := apiKeys.Lookup(r.Header.Get(apiKeyHeader))
+	if !ok {
+		http.Error(w, "Usage is only tracked for API keys", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usageTracker.Report(key.ID))
+}
+
+// UsageOverview is the usage of every API key and their total
+type UsageOverview struct {
+	Total usage.Counts   `json:"total"`
+	Keys  []usage.Report `json:"keys"`
+}
+
+// getAllUsage returns the usage of every API key over the retained days, for capacity planning
+func getAllUsage(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminKeys) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	overview := UsageOverview{Keys: usageTracker.Reports()}
+	for _, report := range overview.Keys {
+		overview.Total.Add(report.Total)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(overview)
+}
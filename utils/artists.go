@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// artistSeparator matches the explicit separators between collaborating artists. "&" and "and" are
+// left alone, they are part of names like "Simon & Garfunkel" as often as they join two artists.
+var artistSeparator = regexp.MustCompile(`(?i)\s*(?:,|\s+feat\.?\s+|\s+ft\.?\s+|\s+featuring\s+)\s*`)
+
+// SplitArtists splits a collaboration credit such as "Artist A, Artist B feat. C" into its
+// individual artist names. The first name is the primary artist.
+func SplitArtists(artists ...string) []string {
+	var result []string
+	for _, credit := range artists {
+		for _, name := range artistSeparator.Split(credit, -1) {
+			name = strings.TrimSpace(name)
+			if name != "" {
+				result = append(result, name)
+			}
+		}
+	}
+	return result
+}
+
+// CountArtistMatches returns how many of the wanted artists are among the candidate's artist names,
+// compared in their canonical form so case, diacritics and spacing don't matter
+func CountArtistMatches(wanted, candidate []string) int {
+	matches := 0
+	for _, w := range wanted {
+		w = CanonicalQuery(w)
+		for _, c := range candidate {
+			if CanonicalQuery(c) == w {
+				matches++
+				break
+			}
+		}
+	}
+	return matches
+}
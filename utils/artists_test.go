@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArtists(t *testing.T) {
+	tests := []struct {
+		name    string
+		credits []string
+		want    []string
+	}{
+		{
+			name:    "Single artist",
+			credits: []string{"Ed Sheeran"},
+			want:    []string{"Ed Sheeran"},
+		},
+		{
+			name:    "Comma",
+			credits: []string{"Artist A, Artist B, C"},
+			want:    []string{"Artist A", "Artist B", "C"},
+		},
+		{
+			name:    "Ampersand in a name",
+			credits: []string{"Simon & Garfunkel"},
+			want:    []string{"Simon & Garfunkel"},
+		},
+		{
+			name:    "Featuring",
+			credits: []string{"Calvin Harris feat. Rihanna"},
+			want:    []string{"Calvin Harris", "Rihanna"},
+		},
+		{
+			name:    "Multiple values",
+			credits: []string{"Daft Punk", "Pharrell Williams, Nile Rodgers"},
+			want:    []string{"Daft Punk", "Pharrell Williams", "Nile Rodgers"},
+		},
+		{
+			name:    "Empty",
+			credits: []string{""},
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitArtists(tt.credits...)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCountArtistMatches(t *testing.T) {
+	wanted := []string{"Pharrell Williams", "nile rodgers"}
+	candidate := []string{"Daft Punk", "Pharrell Williams", "Nile Rodgers"}
+
+	if got := CountArtistMatches(wanted, candidate); got != 2 {
+		t.Errorf("Expected 2 matches, got %d", got)
+	}
+	if got := CountArtistMatches(wanted, []string{"Daft Punk"}); got != 0 {
+		t.Errorf("Expected 0 matches, got %d", got)
+	}
+	if got := CountArtistMatches([]string{"Beyoncé"}, []string{"beyonce"}); got != 1 {
+		t.Errorf("Expected 1 match ignoring diacritics, got %d", got)
+	}
+	if got := CountArtistMatches([]string{"Drake"}, []string{"Drake Bell"}); got != 0 {
+		t.Errorf("Expected 0 matches for a name containing another, got %d", got)
+	}
+}
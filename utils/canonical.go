@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// stripMarks drops the diacritics of precomposed letters and combining marks alike
+var stripMarks = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// strokeFolds maps the lowercase Latin letters with strokes, which have no decomposition, to the
+// ASCII letter they are built on
+var strokeFolds = map[rune]rune{'ø': 'o', 'đ': 'd', 'ł': 'l', 'ı': 'i', 'ħ': 'h', 'ŧ': 't'}
+
+// CanonicalQuery folds a search query to the form cache keys are built from, so trivially different
+// spellings of a song share one entry: diacritics are dropped from words in Latin script, everything
+// is lowercased and runs of whitespace collapse into a single space. Words in other scripts are only
+// lowercased, as their combining marks can change the letter.
+func CanonicalQuery(query string) string {
+	words := strings.Fields(query)
+	for i, word := range words {
+		if strings.IndexFunc(word, func(r rune) bool { return unicode.Is(unicode.Latin, r) }) >= 0 {
+			word, _, _ = transform.String(stripMarks, word)
+		}
+		words[i] = strings.Map(func(r rune) rune {
+			if base, ok := strokeFolds[r]; ok {
+				return base
+			}
+			return r
+		}, strings.ToLower(word))
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,27 @@
+package utils
+
+import "testing"
+
+func TestCanonicalQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "Accents and case", query: "Beyoncé", want: "beyonce"},
+		{name: "Combining marks", query: "Beyonce\u0301", want: "beyonce"},
+		{name: "Whitespace", query: "  Crazy  in\tLove ", want: "crazy in love"},
+		{name: "Strokes", query: "Łódź Øresund", want: "lodz oresund"},
+		{name: "Stacked diacritics", query: "Tiếng Việt", want: "tieng viet"},
+		{name: "Dakuten kept", query: "\u304b\u3099", want: "\u304b\u3099"},
+		{name: "Non-Latin lowercased", query: "ΑΓΆΠΗ", want: "αγάπη"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanonicalQuery(tt.query); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec compresses cache values
+type Codec interface {
+	Name() string
+	Encode(data []byte) ([]byte, error)
+	Decode(data []byte) ([]byte, error)
+}
+
+var (
+	// Gzip is the default codec
+	Gzip Codec = gzipCodec{}
+	// Zstd compresses about as well as gzip with much faster encoding and decoding
+	Zstd Codec = zstdCodec{}
+)
+
+var codecs = map[string]Codec{
+	Gzip.Name(): Gzip,
+	Zstd.Name(): Zstd,
+}
+
+// CodecByName returns the codec with the given name
+func CodecByName(name string) (Codec, error) {
+	codec, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported codec %q", name)
+	}
+	return codec, nil
+}
+
+// Cache values are stored as bytes whose first byte tags how the rest is encoded. Values written
+// before tagging are printable strings, so they never start with a tag.
+const (
+	valueTagRaw byte = iota
+	valueTagGzip
+	valueTagZstd
+)
+
+var valueTags = map[Codec]byte{
+	Gzip: valueTagGzip,
+	Zstd: valueTagZstd,
+}
+
+// CompressValue encodes data as a tagged cache value, compressed with codec unless codec is nil
+// or data is shorter than minSize, since short values don't shrink enough to pay for it
+func CompressValue(codec Codec, data []byte, minSize int) ([]byte, error) {
+	if codec == nil || len(data) < minSize {
+		return append([]byte{valueTagRaw}, data...), nil
+	}
+	compressed, err := codec.Encode(data)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{valueTags[codec]}, compressed...), nil
+}
+
+// IsTaggedValue reports whether value was encoded by CompressValue
+func IsTaggedValue(value []byte) bool {
+	return len(value) > 0 && value[0] <= valueTagZstd
+}
+
+// DecompressValue decodes a value of CompressValue
+func DecompressValue(value []byte) ([]byte, error) {
+	if !IsTaggedValue(value) {
+		return nil, errors.New("untagged cache value")
+	}
+	switch value[0] {
+	case valueTagGzip:
+		return Gzip.Decode(value[1:])
+	case valueTagZstd:
+		return Zstd.Decode(value[1:])
+	}
+	return value[1:], nil
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string {
+	return "gzip"
+}
+
+func (gzipCodec) Encode(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	if _, err := gzipWriter.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decode(data []byte) ([]byte, error) {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gzipReader.Close()
+	return io.ReadAll(gzipReader)
+}
+
+// zstdEncoder and zstdDecoder are shared, their EncodeAll and DecodeAll are safe for concurrent use
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+type zstdCodec struct{}
+
+func (zstdCodec) Name() string {
+	return "zstd"
+}
+
+func (zstdCodec) Encode(data []byte) ([]byte, error) {
+	return zstdEncoder.EncodeAll(data, nil), nil
+}
+
+func (zstdCodec) Decode(data []byte) ([]byte, error) {
+	return zstdDecoder.DecodeAll(data, nil)
+}
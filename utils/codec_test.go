@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestCodecsRoundTrip(t *testing.T) {
+	random := make([]byte, 200000)
+	rand.New(rand.NewSource(1)).Read(random)
+	lyrics := strings.Repeat(`{"startTimeMs":"1000","words":"Never gonna give you up","syllables":[]},`, 2000)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Empty", nil},
+		{"Short", []byte("Hello, world!")},
+		{"Repetitive JSON spanning blocks", []byte(lyrics)},
+		{"Random spanning blocks", random},
+		{"Long run", bytes.Repeat([]byte{'a'}, 1000)},
+	}
+
+	for _, codec := range []Codec{Gzip, Zstd} {
+		for _, tt := range tests {
+			t.Run(codec.Name()+"/"+tt.name, func(t *testing.T) {
+				encoded, err := codec.Encode(tt.data)
+				if err != nil {
+					t.Fatalf("Encode error: %v", err)
+				}
+				decoded, err := codec.Decode(encoded)
+				if err != nil {
+					t.Fatalf("Decode error: %v", err)
+				}
+				if !bytes.Equal(decoded, tt.data) {
+					t.Errorf("Expected %d bytes back, got %d", len(tt.data), len(decoded))
+				}
+			})
+		}
+	}
+}
+
+func TestZstdCompresses(t *testing.T) {
+	data := []byte(strings.Repeat("Never gonna give you up, never gonna let you down. ", 200))
+	encoded, _ := Zstd.Encode(data)
+	if len(encoded) > len(data)/5 {
+		t.Errorf("Expected repetitive text to compress at least 5x, got %d of %d bytes", len(encoded), len(data))
+	}
+}
+
+func TestZstdRejectsCorruptInput(t *testing.T) {
+	encoded, _ := Zstd.Encode([]byte(strings.Repeat("la la la ", 100)))
+	tests := map[string][]byte{
+		"Not zstd":  []byte("Hello, world!"),
+		"Truncated": encoded[:len(encoded)/2],
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Zstd.Decode(data); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestCodecByName(t *testing.T) {
+	if codec, err := CodecByName("zstd"); err != nil || codec != Zstd {
+		t.Errorf("Expected zstd, got %v (%v)", codec, err)
+	}
+	if _, err := CodecByName("brotli"); err == nil {
+		t.Error("Expected an error for an unknown codec")
+	}
+}
+
+func TestCompressValue(t *testing.T) {
+	lyrics := []byte(strings.Repeat(`{"words":"la la la"},`, 100))
+	tests := []struct {
+		name       string
+		codec      Codec
+		data       []byte
+		compressed bool
+	}{
+		{"Below threshold", Gzip, []byte("4uLU6hMCjMI75M1A2tKUQC"), false},
+		{"No codec", nil, lyrics, false},
+		{"Gzip", Gzip, lyrics, true},
+		{"Zstd", Zstd, lyrics, true},
+		{"Empty", Gzip, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := CompressValue(tt.codec, tt.data, 256)
+			if err != nil {
+				t.Fatalf("CompressValue error: %v", err)
+			}
+			if !IsTaggedValue(value) {
+				t.Fatalf("Expected a tagged value, got %q", value)
+			}
+			if compressed := len(value) < len(tt.data); compressed != tt.compressed {
+				t.Errorf("Expected compressed %v, got %d of %d bytes", tt.compressed, len(value), len(tt.data))
+			}
+			decoded, err := DecompressValue(value)
+			if err != nil || !bytes.Equal(decoded, tt.data) {
+				t.Errorf("Expected %q back, got %q (%v)", tt.data, decoded, err)
+			}
+		})
+	}
+}
+
+func TestLegacyValuesAreUntagged(t *testing.T) {
+	legacy, _ := CompressString("legacy")
+	for _, value := range []string{legacy, `{"lyrics":[]}`, "4uLU6hMCjMI75M1A2tKUQC", ""} {
+		if IsTaggedValue([]byte(value)) {
+			t.Errorf("Expected %q to be untagged", value)
+		}
+		if _, err := DecompressValue([]byte(value)); err == nil {
+			t.Errorf("Expected an error decompressing %q", value)
+		}
+	}
+}
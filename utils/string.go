@@ -1,24 +1,14 @@
 package utils
 
-import (
-	"bytes"
-	"compress/gzip"
-	"encoding/base64"
-	"io/ioutil"
-)
+import "encoding/base64"
 
 // CompressString compresses the input string using gzip and returns the base64 encoded string.
 func CompressString(input string) (string, error) {
-	var buf bytes.Buffer
-	gzipWriter := gzip.NewWriter(&buf)
-	_, err := gzipWriter.Write([]byte(input))
+	data, err := Gzip.Encode([]byte(input))
 	if err != nil {
 		return "", err
 	}
-	if err := gzipWriter.Close(); err != nil {
-		return "", err
-	}
-	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+	return base64.StdEncoding.EncodeToString(data), nil
 }
 
 // DecompressString decompresses the input base64 encoded string using gzip and returns the original string.
@@ -27,13 +17,7 @@ func DecompressString(input string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	buf := bytes.NewBuffer(data)
-	gzipReader, err := gzip.NewReader(buf)
-	if err != nil {
-		return "", err
-	}
-	defer gzipReader.Close()
-	result, err := ioutil.ReadAll(gzipReader)
+	result, err := Gzip.Decode(data)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// WarmupRequest is the body accepted by POST /admin/warmup and the content of -warmup files. Tracks
+// are given like for a coverage audit, by id or by song and artist, and the tracks of the Spotify
+// playlist are added to them.
+type WarmupRequest struct {
+	Tracks     []AuditTrack `json:"tracks"`
+	PlaylistID string       `json:"playlistId"`
+}
+
+// WarmupReport is the state of the latest cache warmup
+type WarmupReport struct {
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Total      int        `json:"total"`
+	Processed  int        `json:"processed"`
+	// Warmed tracks had their lyrics fetched into the cache, Cached ones were warm already
+	Warmed   int `json:"warmed"`
+	Cached   int `json:"cached"`
+	NotFound int `json:"notFound"`
+	Errors   int `json:"errors"`
+}
+
+const (
+	warmupWarmed   = "warmed"
+	warmupCached   = "cached"
+	warmupNotFound = "not_found"
+	warmupError    = "error"
+)
+
+var (
+	warmupMu     sync.Mutex
+	warmupReport *WarmupReport
+)
+
+func startWarmup(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminCache) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req WarmupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.PlaylistID != "" && conf.Configuration.PlaylistUrl == "" {
+		http.Error(w, "Playlists are not enabled", http.StatusUnprocessableEntity)
+		return
+	}
+	tracks, err := warmupTracks(r.Context(), req)
+	if err != nil {
+		log.Errorf("[Warmup] Error listing the tracks of playlist %s: %v", req.PlaylistID, err)
+		http.Error(w, "Error listing the tracks of the playlist", http.StatusBadGateway)
+		return
+	}
+	if len(tracks) == 0 {
+		http.Error(w, "No tracks provided", http.StatusUnprocessableEntity)
+		return
+	}
+	if len(tracks) > conf.Configuration.WarmupMaxTracks {
+		http.Error(w, "Too many tracks provided", http.StatusUnprocessableEntity)
+		return
+	}
+
+	report, ok := beginWarmup(len(tracks))
+	if !ok {
+		http.Error(w, "A warmup is already running", http.StatusConflict)
+		return
+	}
+	go runWarmup(report, tracks)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": report.Status,
+		"total":  report.Total,
+	})
+}
+
+func getWarmup(w http.ResponseWriter, r *http.Request) {
+	if !isAuthorized(r, scopeAdminCache) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	warmupMu.Lock()
+	defer warmupMu.Unlock()
+
+	if warmupReport == nil {
+		http.Error(w, "No warmup has been run", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(warmupReport)
+}
+
+// warmupFromFile starts a warmup of the tracks listed in a file shaped like a WarmupRequest, for
+// the -warmup flag
+func warmupFromFile(path string) error {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var req WarmupRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return fmt.Errorf("invalid warmup file: %w", err)
+	}
+	tracks, err := warmupTracks(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	if len(tracks) > conf.Configuration.WarmupMaxTracks {
+		log.Warnf("[Warmup] Only warming the first %d of %d tracks of %s", conf.Configuration.WarmupMaxTracks, len(tracks), path)
+		tracks = tracks[:conf.Configuration.WarmupMaxTracks]
+	}
+
+	report, _ := beginWarmup(len(tracks))
+	go runWarmup(report, tracks)
+	return nil
+}
+
+// warmupTracks returns the tracks of a warmup request, with the tracks of its playlist appended
+func warmupTracks(ctx context.Context, req WarmupRequest) ([]AuditTrack, error) {
+	tracks := req.Tracks
+	if req.PlaylistID == "" {
+		return tracks, nil
+	}
+	if conf.Configuration.PlaylistUrl == "" {
+		return nil, fmt.Errorf("PLAYLIST_URL is not set")
+	}
+	ids, err := spotifyClient.PlaylistTrackIDs(ctx, req.PlaylistID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		tracks = append(tracks, AuditTrack{TrackID: id})
+	}
+	return tracks, nil
+}
+
+// beginWarmup records a new running warmup, unless one is already running
+func beginWarmup(total int) (*WarmupReport, bool) {
+	warmupMu.Lock()
+	defer warmupMu.Unlock()
+
+	if warmupReport != nil && warmupReport.Status == jobStatusRunning {
+		return nil, false
+	}
+	warmupReport = &WarmupReport{Status: jobStatusRunning, StartedAt: time.Now(), Total: total}
+	return warmupReport, true
+}
+
+// runWarmup fetches the lyrics of every track into the cache with bounded concurrency
+func runWarmup(report *WarmupReport, tracks []AuditTrack) {
+	log.Infof("[Warmup] Warming the cache with %d tracks", len(tracks))
+
+	concurrency := conf.Configuration.WarmupConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for _, track := range tracks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(track AuditTrack) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			recordWarmupResult(report, warmTrack(context.Background(), track))
+		}(track)
+	}
+	wg.Wait()
+
+	warmupMu.Lock()
+	finishedAt := time.Now()
+	report.FinishedAt = &finishedAt
+	report.Status = jobStatusDone
+	warmed, cached := report.Warmed, report.Cached
+	warmupMu.Unlock()
+
+	log.Infof("[Warmup] Warmed %d tracks, %d were cached already, in %s", warmed, cached, finishedAt.Sub(report.StartedAt))
+}
+
+// warmTrack resolves a track and fetches its lyrics into the cache, returning the outcome
+func warmTrack(ctx context.Context, track AuditTrack) string {
+	trackID := track.TrackID
+	if trackID == "" {
+		var err error
+		if trackID, err = resolveTrackID(ctx, track.Song, track.Artist); err != nil {
+			log.Warnf("[Warmup] Error resolving %s - %s: %v", track.Song, track.Artist, err)
+			return warmupError
+		}
+		if trackID == "" {
+			return warmupNotFound
+		}
+	}
+
+	if _, ok := getCachedLyrics(fmt.Sprintf("lyrics:%s", trackID)); ok {
+		return warmupCached
+	}
+	_, found, err := getLyricsForTrack(ctx, trackID)
+	switch {
+	case err != nil:
+		log.Warnf("[Warmup] Error fetching the lyrics of %s: %v", trackID, err)
+		return warmupError
+	case !found:
+		return warmupNotFound
+	}
+	return warmupWarmed
+}
+
+func recordWarmupResult(report *WarmupReport, result string) {
+	warmupMu.Lock()
+	defer warmupMu.Unlock()
+
+	report.Processed++
+	switch result {
+	case warmupWarmed:
+		report.Warmed++
+	case warmupCached:
+		report.Cached++
+	case warmupNotFound:
+		report.NotFound++
+	default:
+		report.Errors++
+	}
+}